@@ -0,0 +1,126 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// AliasSource is implemented by sources which rename migrations, e.g. the FileSource with a config file of the form
+// {"aliases": {"<previous id>": "<current id>"}, "migrations": ["<current id>"]}. A renamed migration takes over
+// the changelog entry of its previous ID instead of being treated as a removed and a new migration.
+type AliasSource interface {
+	Aliases() (map[string]string, error)
+}
+
+// aliases collects the previous IDs of renamed migrations of all sources, both IDs are qualified with the namespace
+func (m MigrationService) aliases() (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, s := range m.sources {
+		as, ok := s.source.(AliasSource)
+		if !ok {
+			continue
+		}
+		sourceAliases, err := as.Aliases()
+		if err != nil {
+			return nil, err
+		}
+		for previous, current := range sourceAliases {
+			aliases[s.qualifiedId(previous)] = s.qualifiedId(current)
+		}
+	}
+	return aliases, nil
+}
+
+// checkAliases checks that the aliases rename configured migrations and do not hide one
+func checkAliases(aliases map[string]string, migrations map[string]Migration) error {
+	for _, previous := range aliasIds(aliases) {
+		if _, ok := migrations[previous]; ok {
+			return fmt.Errorf("alias %s is the ID of a configured migration", previous)
+		}
+		if _, ok := migrations[aliases[previous]]; !ok {
+			return fmt.Errorf("alias %s refers to unknown migration %s", previous, aliases[previous])
+		}
+	}
+	return nil
+}
+
+// aliasIds returns the previous IDs of the aliases in lexical order
+func aliasIds(aliases map[string]string) []string {
+	ids := make([]string, 0, len(aliases))
+	for previous := range aliases {
+		ids = append(ids, previous)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// renameAliases replaces the previous IDs of renamed migrations in the changelog and its auxiliary tables,
+// entries whose current ID is already recorded are kept
+func (m MigrationService) renameAliases(ctx context.Context, migrations map[string]Migration) error {
+	aliases, err := m.aliases()
+	if err != nil {
+		return err
+	}
+	if err := checkAliases(aliases, migrations); err != nil {
+		return err
+	}
+	if len(aliases) == 0 {
+		return nil
+	}
+
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, previous := range aliasIds(aliases) {
+		current := aliases[previous]
+		for _, table := range []string{"changelog", "changelog_archive", "changelog_backfill", "changelog_job"} {
+			renamed, err := m.renameId(ctx, tx, table, previous, current)
+			if err != nil {
+				return fmt.Errorf("failed to rename migration %s in %s: %w", previous, table, err)
+			}
+			if renamed && table == "changelog" {
+				m.log().Info("renamed migration in changelog", "id", current, "previous", previous)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// renameId replaces the previous ID of a migration in the table unless the current ID is already recorded. The check
+// is a query of its own, MySQL does not allow a subquery on the updated table.
+func (m MigrationService) renameId(ctx context.Context, tx *sql.Tx, table, previous, current string) (bool, error) {
+	var recorded int
+	query, args := m.rebind(`SELECT COUNT(*) FROM `+table+` WHERE id = $1`, current)
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&recorded); err != nil {
+		return false, err
+	}
+	if recorded > 0 {
+		return false, nil
+	}
+	query, args = m.rebind(`UPDATE `+table+` SET id = $1 WHERE id = $2`, current, previous)
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	renamed, err := result.RowsAffected()
+	return renamed > 0, err
+}
+
+// applyAliases returns the changelog entries with the current IDs of renamed migrations without changing the changelog,
+// for read-only operations
+func applyAliases(existingMigrations []Migration, aliases map[string]string) []Migration {
+	recorded := make(map[string]bool, len(existingMigrations))
+	for _, migration := range existingMigrations {
+		recorded[migration.Id] = true
+	}
+	for i, migration := range existingMigrations {
+		if current, ok := aliases[migration.Id]; ok && !recorded[current] {
+			existingMigrations[i].Id = current
+		}
+	}
+	return existingMigrations
+}
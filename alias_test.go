@@ -0,0 +1,20 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyAliases(t *testing.T) {
+	existing := []Migration{{Id: "0002_old"}, {Id: "0001_init"}, {Id: "0003_both"}, {Id: "0003_renamed"}}
+	aliases := map[string]string{"0002_old": "0002_new", "0003_both": "0003_renamed"}
+	assert.Equal(t, []Migration{{Id: "0002_new"}, {Id: "0001_init"}, {Id: "0003_both"}, {Id: "0003_renamed"}}, applyAliases(existing, aliases))
+}
+
+func Test_checkAliases(t *testing.T) {
+	migrations := map[string]Migration{"0001_init": {}, "0002_new": {}}
+	assert.NoError(t, checkAliases(map[string]string{"0002_old": "0002_new"}, migrations))
+	assert.EqualError(t, checkAliases(map[string]string{"0001_init": "0002_new"}, migrations), "alias 0001_init is the ID of a configured migration")
+	assert.EqualError(t, checkAliases(map[string]string{"0002_old": "0002_typo"}, migrations), "alias 0002_old refers to unknown migration 0002_typo")
+}
@@ -0,0 +1,113 @@
+package migrago
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// assertionOperators are the comparisons of assertions
+var assertionOperators = []string{"==", "!=", "<", "<=", ">", ">="}
+
+// Assertion is an invariant checked after the script of a migration, the migration fails if the single value returned
+// by the query does not compare to Expected, e.g. "SELECT count(*) FROM orders WHERE status IS NULL == 0"
+type Assertion struct {
+	Query string `yaml:"query"`
+	// Operator is one of ==, !=, <, <=, >, >=
+	Operator string `yaml:"operator"`
+	// Expected is compared numerically if both values are numbers and as text otherwise, NULL matches a NULL result
+	Expected string `yaml:"expected"`
+}
+
+func (a Assertion) String() string {
+	return a.Query + " " + a.Operator + " " + a.Expected
+}
+
+// parseAssertion parses the arguments of a "-- migrago:assert <query> <operator> <expected>" directive
+func parseAssertion(args string) (Assertion, error) {
+	fields := strings.Fields(args)
+	if len(fields) < 3 || !slices.Contains(assertionOperators, fields[len(fields)-2]) {
+		return Assertion{}, fmt.Errorf("invalid assert directive %q, expected <query> <operator> <value> with one of the operators %s",
+			args, strings.Join(assertionOperators, " "))
+	}
+	return Assertion{
+		Query:    strings.Join(fields[:len(fields)-2], " "),
+		Operator: fields[len(fields)-2],
+		Expected: fields[len(fields)-1],
+	}, nil
+}
+
+// validate checks the operator of an assertion declared in the metadata
+func (a Assertion) validate() error {
+	if strings.TrimSpace(a.Query) == "" {
+		return fmt.Errorf("assertion %q has no query", a)
+	}
+	if !slices.Contains(assertionOperators, a.Operator) {
+		return fmt.Errorf("assertion %q has invalid operator %q, expected one of %s", a, a.Operator, strings.Join(assertionOperators, " "))
+	}
+	return nil
+}
+
+// holds compares the result of the query with the expected value
+func (a Assertion) holds(actual sql.NullString) bool {
+	if !actual.Valid || strings.EqualFold(a.Expected, "NULL") {
+		// NULL only equals NULL and can not be ordered
+		equal := !actual.Valid && strings.EqualFold(a.Expected, "NULL")
+		switch a.Operator {
+		case "==":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+	var c int
+	actualNumber, actualErr := strconv.ParseFloat(strings.TrimSpace(actual.String), 64)
+	expectedNumber, expectedErr := strconv.ParseFloat(a.Expected, 64)
+	if actualErr == nil && expectedErr == nil {
+		c = cmp.Compare(actualNumber, expectedNumber)
+	} else {
+		c = strings.Compare(actual.String, a.Expected)
+	}
+	switch a.Operator {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// querier queries single rows, it is implemented by *sql.Tx and *sql.Conn
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// checkAssertions evaluates the assertions of a migration after its script, the first violated one fails the migration
+func checkAssertions(ctx context.Context, q querier, migration Migration) error {
+	for _, assertion := range migration.Metadata.Assert {
+		var actual sql.NullString
+		if err := q.QueryRowContext(ctx, assertion.Query).Scan(&actual); err != nil {
+			return fmt.Errorf("failed to evaluate assertion %q of migration %s: %w", assertion, migration.Id, err)
+		}
+		if !assertion.holds(actual) {
+			result := actual.String
+			if !actual.Valid {
+				result = "NULL"
+			}
+			return &AssertionError{Id: migration.Id, Assertion: assertion, Actual: result}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package migrago
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseAssertion(t *testing.T) {
+	assertion, err := parseAssertion("SELECT count(*) FROM orders WHERE status IS NULL == 0")
+	assert.NoError(t, err)
+	assert.Equal(t, Assertion{Query: "SELECT count(*) FROM orders WHERE status IS NULL", Operator: "==", Expected: "0"}, assertion)
+
+	_, err = parseAssertion("SELECT count(*) FROM orders")
+	assert.ErrorContains(t, err, "invalid assert directive")
+	_, err = parseAssertion("== 0")
+	assert.ErrorContains(t, err, "invalid assert directive")
+}
+
+func Test_assertionHolds(t *testing.T) {
+	value := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	assert.True(t, Assertion{Operator: "==", Expected: "0"}.holds(value("0")))
+	assert.True(t, Assertion{Operator: "==", Expected: "10"}.holds(value("10.0")))
+	assert.False(t, Assertion{Operator: "==", Expected: "0"}.holds(value("3")))
+	assert.True(t, Assertion{Operator: "<", Expected: "10"}.holds(value("9")))
+	assert.False(t, Assertion{Operator: "<", Expected: "10"}.holds(value("10")))
+	assert.True(t, Assertion{Operator: ">=", Expected: "10"}.holds(value("10")))
+	assert.True(t, Assertion{Operator: "==", Expected: "active"}.holds(value("active")))
+	assert.True(t, Assertion{Operator: "!=", Expected: "active"}.holds(value("inactive")))
+	assert.True(t, Assertion{Operator: "==", Expected: "NULL"}.holds(sql.NullString{}))
+	assert.False(t, Assertion{Operator: "==", Expected: "0"}.holds(sql.NullString{}))
+	assert.False(t, Assertion{Operator: ">", Expected: "0"}.holds(sql.NullString{}))
+	assert.True(t, Assertion{Operator: "!=", Expected: "NULL"}.holds(value("0")))
+}
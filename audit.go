@@ -0,0 +1,53 @@
+package migrago
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// newRunId generates a random id for a migration run
+func newRunId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate run id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// startRun inserts a new run into the audit table and returns its id, a preset id is shared with other targets of an Orchestrator
+func (m MigrationService) startRun(ctx context.Context) (string, error) {
+	runId := m.runId
+	if runId == "" {
+		var err error
+		if runId, err = newRunId(); err != nil {
+			return "", err
+		}
+	}
+	var revision *string
+	if m.revision != "" {
+		revision = &m.revision
+	}
+	query, args := m.rebind(`INSERT INTO changelog_run (id, sourceRevision) VALUES ($1, $2)`, runId, revision)
+	_, err := m.conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert into changelog_run: %w", err)
+	}
+	return runId, nil
+}
+
+// finishRun stores the end time and the error (if any) of a run
+func (m MigrationService) finishRun(ctx context.Context, runId string, runErr error) error {
+	var message *string
+	if runErr != nil {
+		s := runErr.Error()
+		message = &s
+	}
+	query, args := m.rebind(`UPDATE changelog_run SET finishedAt = CURRENT_TIMESTAMP, error = $2 WHERE id = $1`, runId, message)
+	_, err := m.conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update changelog_run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Package azure provides Azure AD access tokens of a managed identity for Azure Database for PostgreSQL
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DatabaseResource is the resource of the tokens accepted by Azure Database for PostgreSQL and MySQL
+	DatabaseResource = "https://ossrdbms-aad.database.windows.net"
+	imdsEndpoint     = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// ManagedIdentity requests access tokens for the database from the managed identity endpoint of the host.
+// It implements migrago.TokenSource, use it with migrago.WithTokenAuth.
+type ManagedIdentity struct {
+	clientId string
+	client   *http.Client
+	// endpoint and header are the endpoint of App Service, Functions and Container Apps and its secret,
+	// without header the instance metadata service of virtual machines and AKS is used
+	endpoint string
+	header   string
+}
+
+// NewManagedIdentity creates a token source for the system assigned identity, or for the user assigned identity
+// with the client ID if it is not empty. The endpoint is detected from the environment of the host.
+func NewManagedIdentity(clientId string) *ManagedIdentity {
+	m := &ManagedIdentity{clientId: clientId, client: &http.Client{Timeout: 30 * time.Second}, endpoint: imdsEndpoint}
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		m.endpoint, m.header = endpoint, header
+	}
+	return m
+}
+
+// tokenResponse is the response of both endpoints, expires_on is a string on some hosts and a number on others
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresOn   json.RawMessage `json:"expires_on"`
+}
+
+// Token requests a new access token and returns it with its expiry
+func (m *ManagedIdentity) Token(ctx context.Context) (string, time.Time, error) {
+	query := url.Values{"resource": {DatabaseResource}}
+	if m.header != "" {
+		query.Set("api-version", "2019-08-01")
+	} else {
+		query.Set("api-version", "2018-02-01")
+	}
+	if m.clientId != "" {
+		query.Set("client_id", m.clientId)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if m.header != "" {
+		req.Header.Set("X-IDENTITY-HEADER", m.header)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to request managed identity token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("failed to request managed identity token: unexpected status %s", resp.Status)
+	}
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode managed identity token: %w", err)
+	}
+	expiresOn, err := strconv.ParseInt(strings.Trim(string(token.ExpiresOn), `"`), 10, 64)
+	if err != nil || token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("invalid managed identity token response")
+	}
+	return token.AccessToken, time.Unix(expiresOn, 0), nil
+}
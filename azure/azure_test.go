@@ -0,0 +1,58 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Token(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("resource") != DatabaseResource {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("client_id") == "user-assigned" {
+			w.Write([]byte(`{"access_token": "user", "expires_on": 1790000000}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "system", "expires_on": "1790000000"}`))
+	}))
+	defer server.Close()
+
+	identity := &ManagedIdentity{client: server.Client(), endpoint: server.URL}
+	token, expiry, err := identity.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "system", token)
+	assert.Equal(t, time.Unix(1790000000, 0), expiry)
+
+	identity.clientId = "user-assigned"
+	token, _, err = identity.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "user", token)
+}
+
+func Test_TokenAppService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-IDENTITY-HEADER") != "secret" || r.URL.Query().Get("api-version") != "2019-08-01" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"access_token": "app", "expires_on": "1790000000"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("IDENTITY_ENDPOINT", server.URL)
+	t.Setenv("IDENTITY_HEADER", "secret")
+	token, _, err := NewManagedIdentity("").Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "app", token)
+
+	t.Setenv("IDENTITY_HEADER", "wrong")
+	_, _, err = NewManagedIdentity("").Token(context.Background())
+	assert.ErrorContains(t, err, "unexpected status 401")
+}
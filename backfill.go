@@ -0,0 +1,241 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBackfillBatchSize is the number of rows updated per batch if the backfill does not define it
+const DefaultBackfillBatchSize = 1000
+
+// Backfill turns a migration into a data backfill: the script is a single statement which is executed
+// once per batch of rows with the first and last key of the batch as $1 and $2, e.g.
+// "UPDATE users SET email_lower = lower(email) WHERE id BETWEEN $1 AND $2". Every batch is committed
+// on its own, so the script has to be idempotent. Backfills are only supported on Postgres and CockroachDB.
+type Backfill struct {
+	// Table is the (optionally schema qualified) table the batches are built from
+	Table string `yaml:"table"`
+	// Key is a unique, sortable column of the table used for keyset pagination
+	Key string `yaml:"key"`
+	// BatchSize is the number of rows per batch, default is DefaultBackfillBatchSize
+	BatchSize int `yaml:"batchSize"`
+	// Sleep is the pause between two batches, see also WithThrottleProbe
+	Sleep time.Duration `yaml:"sleep"`
+}
+
+// parseBackfill parses the arguments of a "-- migrago:backfill table=users key=id batch-size=1000 sleep=100ms" directive
+func parseBackfill(args string) (*Backfill, error) {
+	backfill := &Backfill{}
+	for _, arg := range strings.Fields(args) {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid backfill argument %q", arg)
+		}
+		switch name {
+		case "table":
+			backfill.Table = value
+		case "key":
+			backfill.Key = value
+		case "batch-size":
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid backfill batch size %q", value)
+			}
+			backfill.BatchSize = size
+		case "sleep":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid backfill sleep %q", value)
+			}
+			backfill.Sleep = d
+		default:
+			return nil, fmt.Errorf("unknown backfill argument %q", name)
+		}
+	}
+	return backfill, nil
+}
+
+// validate checks that the backfill defines the table and the key
+func (b *Backfill) validate() error {
+	if b.Table == "" || b.Key == "" {
+		return fmt.Errorf("backfill needs a table and a key")
+	}
+	if b.BatchSize < 0 {
+		return fmt.Errorf("backfill batch size must not be negative")
+	}
+	return nil
+}
+
+// batchSize returns the configured batch size or the default
+func (b *Backfill) batchSize() int {
+	if b.BatchSize > 0 {
+		return b.BatchSize
+	}
+	return DefaultBackfillBatchSize
+}
+
+// boundsQuery returns the query for the first and last key of the next batch, the keys are returned as text
+// and passed back as untyped parameters, so the database converts them to the type of the key
+func (b *Backfill) boundsQuery(first bool) string {
+	key := quoteIdentifier(b.Key)
+	where := ""
+	if !first {
+		where = " WHERE " + key + " > $2"
+	}
+	return fmt.Sprintf(`SELECT min(k)::text, max(k)::text FROM (SELECT %s AS k FROM %s%s ORDER BY %s LIMIT $1) batch`,
+		key, quoteIdentifier(b.Table), where, key)
+}
+
+// quoteIdentifier quotes a possibly schema qualified identifier
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// backfillCheckpoint is the progress of a backfill, it is committed together with every batch
+type backfillCheckpoint struct {
+	lastKey sql.NullString
+	batches int64
+	rows    int64
+}
+
+// loadCheckpoint returns the checkpoint of an interrupted backfill, checkpoints of a different script are discarded
+func (m MigrationService) loadCheckpoint(ctx context.Context, migration Migration) (backfillCheckpoint, error) {
+	var checkpoint backfillCheckpoint
+	var checksum string
+	query, args := m.rebind(`SELECT checksum, lastKey, batches, rowsAffected FROM changelog_backfill WHERE id = $1`, migration.Id)
+	err := m.conn.QueryRowContext(ctx, query, args...).
+		Scan(&checksum, &checkpoint.lastKey, &checkpoint.batches, &checkpoint.rows)
+	if errors.Is(err, sql.ErrNoRows) {
+		return backfillCheckpoint{}, nil
+	}
+	if err != nil {
+		return backfillCheckpoint{}, fmt.Errorf("failed to query changelog_backfill: %w", err)
+	}
+	if checksum != migration.Checksum {
+		m.log().WarnContext(ctx, "backfill script changed, restarting from the first row", "id", migration.Id)
+		return backfillCheckpoint{}, nil
+	}
+	return checkpoint, nil
+}
+
+// executeBackfill executes the script of a backfill migration batch by batch, every batch is committed
+// in its own transaction together with a checkpoint, so an interrupted backfill resumes after the last
+// committed batch. The migration is added to the changelog after the last batch.
+func (m MigrationService) executeBackfill(ctx context.Context, migration Migration) error {
+	if migration.OpenScript != nil {
+		return fmt.Errorf("backfill migration %s is too large to be executed in batches", migration.Id)
+	}
+	checkpoint, err := m.loadCheckpoint(ctx, migration)
+	if err != nil {
+		return err
+	}
+	if checkpoint.lastKey.Valid {
+		m.log().InfoContext(ctx, "resuming backfill", "id", migration.Id, "batches", checkpoint.batches, "lastKey", checkpoint.lastKey.String)
+	}
+
+	start := time.Now()
+	for first := true; ; first = false {
+		if !first {
+			if err := m.throttle(ctx, migration); err != nil {
+				return fmt.Errorf("backfill %s failed after %d batches: %w", migration.Id, checkpoint.batches, err)
+			}
+		}
+		done, err := m.executeBatch(ctx, migration, &checkpoint)
+		if err != nil {
+			return fmt.Errorf("backfill %s failed after %d batches: %w", migration.Id, checkpoint.batches, err)
+		}
+		if done {
+			break
+		}
+		m.log().InfoContext(ctx, "backfill batch committed", "id", migration.Id, "batch", checkpoint.batches, "rows", checkpoint.rows,
+			"lastKey", checkpoint.lastKey.String, "elapsed", time.Since(start).Round(time.Millisecond))
+	}
+
+	migration.Duration = time.Since(start)
+	m.checkSlow(ctx, migration)
+	tx, err := m.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	// The batches are committed, a violated assertion keeps the backfill pending
+	if err := checkAssertions(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// A rerun replaces the existing changelog entry
+	query, args := m.rebind(`DELETE FROM changelog WHERE id = $1`, migration.Id)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete from changelog: %w", err)
+	}
+	query, args = m.rebind(`DELETE FROM changelog_backfill WHERE id = $1`, migration.Id)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete from changelog_backfill: %w", err)
+	}
+	if err := m.insertChangelog(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	m.log().InfoContext(ctx, "backfill finished", "id", migration.Id, "batches", checkpoint.batches, "rows", checkpoint.rows,
+		"duration", migration.Duration.Round(time.Millisecond))
+	return tx.Commit()
+}
+
+// executeBatch executes the script for the batch after the last key of the checkpoint and commits it together with
+// the updated checkpoint, done is set if there are no rows left
+func (m MigrationService) executeBatch(ctx context.Context, migration Migration, checkpoint *backfillCheckpoint) (done bool, err error) {
+	backfill := migration.Metadata.Backfill
+	tx, err := m.beginTx(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var from, to sql.NullString
+	args := []any{backfill.batchSize()}
+	if checkpoint.lastKey.Valid {
+		args = append(args, checkpoint.lastKey.String)
+	}
+	if err := tx.QueryRowContext(ctx, backfill.boundsQuery(!checkpoint.lastKey.Valid), args...).Scan(&from, &to); err != nil {
+		return false, fmt.Errorf("failed to query the next batch: %w", err)
+	}
+	if !to.Valid {
+		return true, tx.Rollback()
+	}
+
+	result, err := tx.ExecContext(ctx, migration.Script, from.String, to.String)
+	if err != nil {
+		return false, fmt.Errorf("failed to execute batch %s to %s: %w", from.String, to.String, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	next := backfillCheckpoint{lastKey: to, batches: checkpoint.batches + 1, rows: checkpoint.rows + affected}
+	_, err = tx.ExecContext(ctx, `INSERT INTO changelog_backfill (id, checksum, lastKey, batches, rowsAffected) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET checksum = $2, lastKey = $3, batches = $4, rowsAffected = $5, updatedAt = CURRENT_TIMESTAMP`,
+		migration.Id, migration.Checksum, next.lastKey, next.batches, next.rows)
+	if err != nil {
+		return false, fmt.Errorf("failed to store backfill checkpoint: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	*checkpoint = next
+	return false, nil
+}
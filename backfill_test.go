@@ -0,0 +1,49 @@
+package migrago
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseBackfill(t *testing.T) {
+	backfill, err := parseBackfill("table=public.users key=id batch-size=500 sleep=100ms")
+	assert.NoError(t, err)
+	assert.Equal(t, &Backfill{Table: "public.users", Key: "id", BatchSize: 500, Sleep: 100 * time.Millisecond}, backfill)
+	assert.NoError(t, backfill.validate())
+
+	backfill, err = parseBackfill("table=users")
+	assert.NoError(t, err)
+	assert.ErrorContains(t, backfill.validate(), "needs a table and a key")
+
+	_, err = parseBackfill("table=users size=10")
+	assert.ErrorContains(t, err, `unknown backfill argument "size"`)
+	_, err = parseBackfill("table=users batch-size=many")
+	assert.ErrorContains(t, err, "invalid backfill batch size")
+}
+
+func Test_boundsQuery(t *testing.T) {
+	backfill := &Backfill{Table: "public.users", Key: "id"}
+	assert.Equal(t, `SELECT min(k)::text, max(k)::text FROM (SELECT "id" AS k FROM "public"."users" ORDER BY "id" LIMIT $1) batch`, backfill.boundsQuery(true))
+	assert.Equal(t, `SELECT min(k)::text, max(k)::text FROM (SELECT "id" AS k FROM "public"."users" WHERE "id" > $2 ORDER BY "id" LIMIT $1) batch`, backfill.boundsQuery(false))
+	assert.Equal(t, DefaultBackfillBatchSize, backfill.batchSize())
+	assert.Equal(t, `"we""ird"`, quoteIdentifier(`we"ird`))
+}
+
+func Test_backfillDialects(t *testing.T) {
+	migrations := []Migration{
+		{Id: "0001_backfill", Script: "-- migrago:backfill table=users key=id\nUPDATE users SET name = lower(name) WHERE id BETWEEN $1 AND $2", RevertScript: "SELECT 1"},
+		{Id: "0002_async", Script: "-- migrago:async\nCREATE INDEX users_name ON users (name)", RevertScript: "DROP INDEX users_name"},
+	}
+	for _, dialect := range []Dialect{PostgresDialect{}, CockroachDialect{}} {
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), nil, WithDialect(dialect))
+		_, _, err := service.getMigrations()
+		assert.NoError(t, err)
+	}
+	for i, migration := range migrations {
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations[i:i+1]), nil, WithDialect(SQLiteDialect{}))
+		_, _, err := service.getMigrations()
+		assert.EqualError(t, err, "migration "+migration.Id+": backfills and async migrations are only supported on Postgres and CockroachDB, not on sqlite")
+	}
+}
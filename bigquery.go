@@ -0,0 +1,82 @@
+package migrago
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BigQueryDialect is the dialect of Google BigQuery with a database/sql driver. The changelog tables are created in
+// the default dataset of the connection, e.g. bigquery://project/dataset. Statements are never part of a
+// transaction; WithStatementProgress lets a failed migration resume after its last successful statement.
+type BigQueryDialect struct{}
+
+func (BigQueryDialect) Name() string {
+	return "bigquery"
+}
+
+func (BigQueryDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (BigQueryDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnInteger:
+		return "INT64"
+	case ColumnTimestamp:
+		return "TIMESTAMP"
+	case ColumnCreatedAt:
+		return "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP()"
+	case ColumnFlag:
+		return "BOOL NOT NULL DEFAULT FALSE"
+	case ColumnSequence:
+		// There are no auto increment columns, the microseconds of the insert order the changelog of a single runner
+		return "INT64 NOT NULL DEFAULT UNIX_MICROS(CURRENT_TIMESTAMP())"
+	default:
+		return "STRING"
+	}
+}
+
+// ChangelogDDL creates the changelog tables, BigQuery only accepts primary keys which are not enforced
+func (d BigQueryDialect) ChangelogDDL() []string {
+	statements := createChangelogTables(d)
+	for i, table := range changelogTables {
+		key := fmt.Sprintf("PRIMARY KEY (%s)", table.columns[0].name)
+		statements[i] = strings.Replace(statements[i], key, key+" NOT ENFORCED", 1)
+	}
+	return statements
+}
+
+func (BigQueryDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) > 0 FROM INFORMATION_SCHEMA.TABLES WHERE table_name = '%s'`, table)
+}
+
+func (BigQueryDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT LOWER(column_name) FROM INFORMATION_SCHEMA.COLUMNS WHERE table_name = '%s'`, table)
+}
+
+func (BigQueryDialect) InsertIgnore(table string, columns ...string) string {
+	return mergeInsert(table, columns)
+}
+
+func (BigQueryDialect) SessionSettingStatement() string {
+	return ""
+}
+
+func (BigQueryDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for every statement, every DDL and DML statement is a job of its own
+func (BigQueryDialect) NonTransactional(string) bool {
+	return true
+}
+
+// VersionQuery returns 0, BigQuery is not versioned
+func (BigQueryDialect) VersionQuery() string {
+	return `SELECT '0'`
+}
+
+// SplitRules are the rules of MySQL, GoogleSQL also escapes with backslashes and accepts # comments
+func (BigQueryDialect) SplitRules() SplitRules {
+	return mysqlSplitRules
+}
@@ -0,0 +1,17 @@
+package migrago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BigQueryDialect(t *testing.T) {
+	d := BigQueryDialect{}
+	statements := d.ChangelogDDL()
+	assert.Contains(t, statements[0], "\tsequence INT64 NOT NULL DEFAULT UNIX_MICROS(CURRENT_TIMESTAMP()),")
+	assert.True(t, strings.HasSuffix(statements[len(statements)-1], "PRIMARY KEY (name) NOT ENFORCED\n)"))
+	assert.True(t, d.NonTransactional("UPDATE events SET processed = TRUE WHERE TRUE"))
+	assert.Equal(t, "bigquery", driverDialect("bigquery").Name())
+}
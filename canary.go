@@ -0,0 +1,109 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CanaryThresholds are the limits a canary run has to stay within before production is migrated, zero means no limit
+type CanaryThresholds struct {
+	// MaxDuration limits the total execution time of the migrations on the canary
+	MaxDuration time.Duration
+	// MaxMigrationDuration limits the execution time of every single migration on the canary
+	MaxMigrationDuration time.Duration
+}
+
+// Canary migrates a canary database, e.g. a restored copy of production in staging, before the production database.
+// Production is only migrated if the canary run succeeded, exercised all migrations pending in production and stayed
+// within the thresholds.
+type Canary struct {
+	Canary     MigrationService
+	Production MigrationService
+	Thresholds CanaryThresholds
+}
+
+// CanaryReport is the outcome of a canary run
+type CanaryReport struct {
+	RunId string
+	// Applied are the migrations applied to the canary with their execution times, in execution order
+	Applied []MigrationStatus
+	// Duration is the total execution time of the canary run
+	Duration time.Duration
+	// Production is set if the production database was migrated
+	Production bool
+}
+
+// Run migrates the canary and, if it passed, production under a shared run ID. A failed canary returns a
+// *CanaryError and leaves production untouched, the error of the production run is returned as is.
+func (c Canary) Run(ctx context.Context) (CanaryReport, error) {
+	runId, err := newRunId()
+	if err != nil {
+		return CanaryReport{}, err
+	}
+	report := CanaryReport{RunId: runId}
+
+	production, err := c.Production.Status(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to read the status of production: %w", err)
+	}
+	before, err := c.Canary.Status(ctx)
+	if err != nil {
+		return report, &CanaryError{Err: err}
+	}
+
+	canary := c.Canary
+	canary.runId = runId
+	start := time.Now()
+	if err := canary.ExecuteMigration(ctx); err != nil {
+		return report, &CanaryError{Err: err}
+	}
+	report.Duration = time.Since(start)
+
+	after, err := c.Canary.Status(ctx)
+	if err != nil {
+		return report, &CanaryError{Err: err}
+	}
+	exercised := make(map[string]bool, len(before.Pending))
+	for _, pending := range before.Pending {
+		exercised[pending.Id] = true
+	}
+	// Applied migrations are ordered newest first
+	for i := len(after.Applied) - 1; i >= 0; i-- {
+		if exercised[after.Applied[i].Id] {
+			report.Applied = append(report.Applied, after.Applied[i])
+		}
+	}
+
+	if problems := c.Thresholds.check(report, production.Pending, exercised); len(problems) > 0 {
+		return report, &CanaryError{Problems: problems}
+	}
+	c.Canary.log().Info("canary passed", "run", runId, "migrations", len(report.Applied), "duration", report.Duration.Round(time.Millisecond))
+
+	service := c.Production
+	service.runId = runId
+	if err := service.ExecuteMigration(ctx); err != nil {
+		return report, err
+	}
+	report.Production = true
+	return report, nil
+}
+
+// check compares the canary run with the thresholds and the migrations pending in production
+func (t CanaryThresholds) check(report CanaryReport, production []MigrationStatus, exercised map[string]bool) []string {
+	var problems []string
+	for _, pending := range production {
+		if !exercised[pending.Id] {
+			problems = append(problems, fmt.Sprintf("migration %s is pending in production but was not applied to the canary", pending.Id))
+		}
+	}
+	if t.MaxDuration > 0 && report.Duration > t.MaxDuration {
+		problems = append(problems, fmt.Sprintf("the canary run took %s, more than %s", report.Duration.Round(time.Millisecond), t.MaxDuration))
+	}
+	for _, applied := range report.Applied {
+		if t.MaxMigrationDuration > 0 && applied.Duration > t.MaxMigrationDuration {
+			problems = append(problems, fmt.Sprintf("migration %s took %s on the canary, more than %s", applied.Id, applied.Duration, t.MaxMigrationDuration))
+		}
+	}
+	return problems
+}
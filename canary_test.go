@@ -0,0 +1,32 @@
+package migrago
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_canaryThresholds(t *testing.T) {
+	report := CanaryReport{
+		Duration: 3 * time.Minute,
+		Applied: []MigrationStatus{
+			{Id: "1", Duration: time.Second},
+			{Id: "2", Duration: 2 * time.Minute},
+		},
+	}
+	exercised := map[string]bool{"1": true, "2": true}
+	production := []MigrationStatus{{Id: "1"}, {Id: "2"}}
+
+	assert.Empty(t, CanaryThresholds{}.check(report, production, exercised))
+	assert.Empty(t, CanaryThresholds{MaxDuration: 5 * time.Minute, MaxMigrationDuration: 2 * time.Minute}.check(report, production, exercised))
+
+	problems := CanaryThresholds{MaxDuration: time.Minute, MaxMigrationDuration: time.Minute}.check(report, production, exercised)
+	assert.Equal(t, []string{
+		"the canary run took 3m0s, more than 1m0s",
+		"migration 2 took 2m0s on the canary, more than 1m0s",
+	}, problems)
+
+	problems = CanaryThresholds{}.check(report, append(production, MigrationStatus{Id: "3"}), exercised)
+	assert.Equal(t, []string{"migration 3 is pending in production but was not applied to the canary"}, problems)
+}
@@ -0,0 +1,78 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// cancelGracePeriod is the time the driver gets to cancel a statement after the context is done,
+// afterwards the statement is cancelled with pg_cancel_backend
+const cancelGracePeriod = 5 * time.Second
+
+// cancelTimeout limits the pg_cancel_backend call including opening its connection
+const cancelTimeout = 10 * time.Second
+
+// backendPID returns the process id of the server backend executing the transaction, it is 0 for other databases
+// than Postgres, their statements are only cancelled by the driver
+func (m MigrationService) backendPID(ctx context.Context, tx *sql.Tx) (int, error) {
+	if !m.postgres() {
+		return 0, nil
+	}
+	var pid int
+	if err := tx.QueryRowContext(ctx, `SELECT pg_backend_pid()`).Scan(&pid); err != nil {
+		return 0, fmt.Errorf("failed to query the backend pid: %w", err)
+	}
+	return pid, nil
+}
+
+// watchCancel cancels the statement running in the backend if the context is done and the driver
+// did not abort it within the grace period, until the returned function is called
+func (m MigrationService) watchCancel(ctx context.Context, pid int) (stop func()) {
+	if pid == 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(cancelGracePeriod)
+		defer timer.Stop()
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+		}
+
+		cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelTimeout)
+		defer cancel()
+		m.log().Warn("cancelling migration statement", "pid", pid)
+		if err := m.cancelBackend(cancelCtx, pid); err != nil {
+			m.log().Error("failed to cancel migration statement", "pid", pid, "error", err)
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// cancelBackend cancels the statement running in the backend with pg_cancel_backend. The transaction holds a
+// connection of the pool, which is the only one of connections opened by NewMigrationServiceFromDSN by default,
+// so the cancellation uses a dedicated connection of their connector. Connections passed in need a free
+// connection in their pool.
+func (m MigrationService) cancelBackend(ctx context.Context, pid int) error {
+	db := m.conn
+	if m.connector != nil {
+		db = sql.OpenDB(m.connector)
+		defer db.Close()
+	}
+	_, err := db.ExecContext(ctx, `SELECT pg_cancel_backend($1)`, pid)
+	return err
+}
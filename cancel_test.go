@@ -0,0 +1,81 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// uncancellableDriver wraps lib/pq without its context support, like drivers which ignore the cancellation
+type uncancellableDriver struct{}
+
+func (uncancellableDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := pq.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return uncancellableConn{conn}, nil
+}
+
+type uncancellableConn struct {
+	driver.Conn
+}
+
+func (c uncancellableConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return c.Conn.(driver.Execer).Exec(query, args)
+}
+
+func (c uncancellableConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.Conn.(driver.Queryer).Query(query, args)
+}
+
+func init() {
+	sql.Register("postgres-uncancellable", uncancellableDriver{})
+}
+
+func Test_ExecuteMigrationCancelBackend(t *testing.T) {
+	t.Run("Test a statement ignoring the cancellation is cancelled with the default pool", func(t *testing.T) {
+		ctx := context.Background()
+		dsn, err := CreateTestPostgresDSN(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := sql.Open("postgres", dsn)
+		assert.NoError(t, err)
+		defer d.Close()
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY); SELECT pg_sleep(60)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service, err := NewMigrationServiceFromDSN("postgres-uncancellable", dsn, "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.Equal(t, 1, service.conn.Stats().MaxOpenConnections)
+
+		runCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		start := time.Now()
+		err = service.ExecuteMigration(runCtx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// The grace period passes, but the cancellation does not wait for a connection of the pool
+		assert.Less(t, time.Since(start), cancelGracePeriod+cancelTimeout)
+
+		var running int
+		err = d.QueryRow("SELECT count(*) FROM pg_stat_activity WHERE query LIKE 'SELECT pg_sleep%'").Scan(&running)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, running)
+		var exists bool
+		err = d.QueryRow("SELECT to_regclass('test') IS NOT NULL").Scan(&exists)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
@@ -0,0 +1,170 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ChangelogDiff is the difference between the changelogs of two databases, e.g. staging and production.
+// Archived migrations count as applied but are not compared.
+type ChangelogDiff struct {
+	// OnlyLeft and OnlyRight are the migrations applied to one database only, in the order they were applied
+	OnlyLeft  []MigrationStatus
+	OnlyRight []MigrationStatus
+	// Checksums are the migrations applied to both databases with different checksums
+	Checksums []ChecksumDifference
+	// Reordered are the migrations applied to both databases, but in a different order relative to the others
+	Reordered []string
+}
+
+// ChecksumDifference is a migration recorded with different checksums in two changelogs
+type ChecksumDifference struct {
+	Id    string
+	Left  string
+	Right string
+}
+
+// Empty reports whether both changelogs match
+func (d ChangelogDiff) Empty() bool {
+	return len(d.OnlyLeft) == 0 && len(d.OnlyRight) == 0 && len(d.Checksums) == 0 && len(d.Reordered) == 0
+}
+
+// DiffChangelogs compares the changelogs of two databases without changing them, renamed migrations are compared
+// by their current IDs
+func DiffChangelogs(ctx context.Context, left, right MigrationService) (ChangelogDiff, error) {
+	leftApplied, leftArchived, err := left.changelogEntries(ctx)
+	if err != nil {
+		return ChangelogDiff{}, fmt.Errorf("failed to read left changelog: %w", err)
+	}
+	rightApplied, rightArchived, err := right.changelogEntries(ctx)
+	if err != nil {
+		return ChangelogDiff{}, fmt.Errorf("failed to read right changelog: %w", err)
+	}
+
+	var diff ChangelogDiff
+	rightById := make(map[string]Migration, len(rightApplied))
+	for _, migration := range rightApplied {
+		rightById[migration.Id] = migration
+	}
+	leftIds := make(map[string]bool, len(leftApplied))
+	var common []string
+	for _, migration := range leftApplied {
+		leftIds[migration.Id] = true
+		other, ok := rightById[migration.Id]
+		switch {
+		case !ok && !rightArchived[migration.Id]:
+			diff.OnlyLeft = append(diff.OnlyLeft, appliedStatus(migration))
+		case !ok:
+			// Archived on the right, nothing left to compare
+		case other.Checksum != migration.Checksum:
+			diff.Checksums = append(diff.Checksums, ChecksumDifference{Id: migration.Id, Left: migration.Checksum, Right: other.Checksum})
+			common = append(common, migration.Id)
+		default:
+			common = append(common, migration.Id)
+		}
+	}
+	var rightCommon []string
+	for _, migration := range rightApplied {
+		switch {
+		case leftIds[migration.Id]:
+			rightCommon = append(rightCommon, migration.Id)
+		case !leftArchived[migration.Id]:
+			diff.OnlyRight = append(diff.OnlyRight, appliedStatus(migration))
+		}
+	}
+	diff.Reordered = reordered(common, rightCommon)
+	return diff, nil
+}
+
+// changelogEntries reads the changelog oldest first with the current IDs of renamed migrations and the IDs of the
+// archived migrations, without changelog nothing is applied
+func (m MigrationService) changelogEntries(ctx context.Context) ([]Migration, map[string]bool, error) {
+	state, err := m.readChangelogState(ctx)
+	if err != nil || !state.changelog {
+		return nil, nil, err
+	}
+	existingMigrations, err := m.readExistingMigrations(ctx, state)
+	if err != nil {
+		return nil, nil, err
+	}
+	aliases, err := m.aliases()
+	if err != nil {
+		return nil, nil, err
+	}
+	existingMigrations = applyAliases(existingMigrations, aliases)
+	slices.Reverse(existingMigrations)
+
+	archived := make(map[string]bool)
+	if !state.archive {
+		return existingMigrations, archived, nil
+	}
+	rows, err := m.conn.QueryContext(ctx, `SELECT id FROM changelog_archive`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query changelog_archive: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, nil, err
+		}
+		if current, ok := aliases[id]; ok {
+			id = current
+		}
+		archived[id] = true
+	}
+	return existingMigrations, archived, rows.Err()
+}
+
+// appliedStatus returns the status of a changelog entry
+func appliedStatus(migration Migration) MigrationStatus {
+	return MigrationStatus{
+		Id:          migration.Id,
+		Checksum:    migration.Checksum,
+		Description: migration.Metadata.Description,
+		InstalledAt: migration.InstalledAt,
+		LSNBefore:   migration.LSNBefore,
+		LSNAfter:    migration.LSNAfter,
+		Duration:    migration.Duration,
+	}
+}
+
+// reordered returns the IDs of the left order which are not part of the longest sequence applied in the same order on
+// both sides, i.e. the fewest migrations whose position has to change to get from one order to the other
+func reordered(left, right []string) []string {
+	position := make(map[string]int, len(right))
+	for i, id := range right {
+		position[id] = i
+	}
+	// Longest increasing subsequence of the right positions in the left order, tails holds the indexes into left of
+	// the smallest tail of every length and previous links each element to its predecessor
+	var tails []int
+	previous := make([]int, len(left))
+	for i, id := range left {
+		length := sort.Search(len(tails), func(j int) bool { return position[left[tails[j]]] >= position[id] })
+		previous[i] = -1
+		if length > 0 {
+			previous[i] = tails[length-1]
+		}
+		if length == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[length] = i
+		}
+	}
+	inOrder := make(map[int]bool, len(tails))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			inOrder[i] = true
+		}
+	}
+	var ids []string
+	for i, id := range left {
+		if !inOrder[i] {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,20 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_reordered(t *testing.T) {
+	assert.Empty(t, reordered([]string{"1", "2", "3"}, []string{"1", "2", "3"}))
+	assert.Empty(t, reordered(nil, nil))
+	assert.Equal(t, []string{"2"}, reordered([]string{"1", "2", "3", "4"}, []string{"1", "3", "2", "4"}))
+	assert.Equal(t, []string{"4"}, reordered([]string{"1", "2", "3", "4"}, []string{"4", "1", "2", "3"}))
+	assert.Equal(t, []string{"1", "2"}, reordered([]string{"1", "2", "3"}, []string{"3", "2", "1"}))
+}
+
+func Test_ChangelogDiffEmpty(t *testing.T) {
+	assert.True(t, ChangelogDiff{}.Empty())
+	assert.False(t, ChangelogDiff{Reordered: []string{"1"}}.Empty())
+}
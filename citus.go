@@ -0,0 +1,138 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Citus contains the Citus specific settings of a migration
+type Citus struct {
+	// Workers runs every statement of the script on all worker nodes with run_command_on_workers instead of the
+	// coordinator, also settable with "-- migrago:citus-workers". The commands are committed on the workers
+	// independently of the changelog transaction, so the statements have to be idempotent.
+	Workers bool `yaml:"workers"`
+	// Distribute distributes tables after the script, also settable with
+	// "-- migrago:citus-distribute table=<table> column=<column> colocate-with=<table>"
+	Distribute []DistributedTable `yaml:"distribute"`
+	// Reference turns tables into reference tables after the script, also settable with "-- migrago:citus-reference <table>"
+	Reference []string `yaml:"reference"`
+	// Colocated are tables which have to be in the same co-location group after the script, the migration
+	// fails otherwise, also settable with "-- migrago:citus-colocated <table>,<table>"
+	Colocated []string `yaml:"colocated"`
+}
+
+// DistributedTable is a table distributed with create_distributed_table
+type DistributedTable struct {
+	Table  string `yaml:"table"`
+	Column string `yaml:"column"`
+	// ColocateWith is passed as colocate_with, default is Citus' own default
+	ColocateWith string `yaml:"colocateWith"`
+}
+
+// isZero checks if the migration has no Citus specific settings
+func (c Citus) isZero() bool {
+	return !c.Workers && len(c.Distribute) == 0 && len(c.Reference) == 0 && len(c.Colocated) == 0
+}
+
+// parseDistributedTable parses the arguments of a "-- migrago:citus-distribute table=orders column=customer_id" directive
+func parseDistributedTable(args string) (DistributedTable, error) {
+	var table DistributedTable
+	for _, arg := range strings.Fields(args) {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return DistributedTable{}, fmt.Errorf("invalid citus-distribute argument %q", arg)
+		}
+		switch name {
+		case "table":
+			table.Table = value
+		case "column":
+			table.Column = value
+		case "colocate-with":
+			table.ColocateWith = value
+		default:
+			return DistributedTable{}, fmt.Errorf("unknown citus-distribute argument %q", name)
+		}
+	}
+	if table.Table == "" || table.Column == "" {
+		return DistributedTable{}, fmt.Errorf("citus-distribute needs a table and a column")
+	}
+	return table, nil
+}
+
+// runOnWorkers executes a statement on all worker nodes and fails if it failed on any of them
+func runOnWorkers(ctx context.Context, tx *sql.Tx, statement string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT nodename, nodeport, success, result FROM run_command_on_workers($1)`, statement)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var failures []string
+	for rows.Next() {
+		var node, result string
+		var port int
+		var success bool
+		if err := rows.Scan(&node, &port, &success, &result); err != nil {
+			return err
+		}
+		if !success {
+			failures = append(failures, fmt.Sprintf("%s:%d: %s", node, port, result))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("statement failed on workers: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// applyCitus distributes the tables of the migration and validates the co-location afterwards
+func applyCitus(ctx context.Context, tx *sql.Tx, migration Migration) error {
+	citus := migration.Metadata.Citus
+	for _, table := range citus.Distribute {
+		var err error
+		if table.ColocateWith == "" {
+			_, err = tx.ExecContext(ctx, `SELECT create_distributed_table($1, $2)`, table.Table, table.Column)
+		} else {
+			_, err = tx.ExecContext(ctx, `SELECT create_distributed_table($1, $2, colocate_with => $3)`, table.Table, table.Column, table.ColocateWith)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to distribute table %s: %w", table.Table, err)
+		}
+	}
+	for _, table := range citus.Reference {
+		if _, err := tx.ExecContext(ctx, `SELECT create_reference_table($1)`, table); err != nil {
+			return fmt.Errorf("failed to create reference table %s: %w", table, err)
+		}
+	}
+	if len(citus.Colocated) > 0 {
+		return checkColocation(ctx, tx, migration.Id, citus.Colocated)
+	}
+	return nil
+}
+
+// checkColocation checks that all tables are distributed and in the same co-location group
+func checkColocation(ctx context.Context, tx *sql.Tx, migrationId string, tables []string) error {
+	groups := map[string]string{}
+	for _, table := range tables {
+		var group sql.NullString
+		err := tx.QueryRowContext(ctx, `SELECT (SELECT colocationid::text FROM pg_dist_partition WHERE logicalrelid = $1::regclass)`, table).Scan(&group)
+		if err != nil {
+			return fmt.Errorf("failed to query co-location of %s: %w", table, err)
+		}
+		if !group.Valid {
+			return fmt.Errorf("migration %s: table %s is not distributed", migrationId, table)
+		}
+		groups[table] = group.String
+	}
+	for _, table := range tables[1:] {
+		if groups[table] != groups[tables[0]] {
+			return fmt.Errorf("migration %s breaks co-location: %s is in group %s, %s in group %s",
+				migrationId, tables[0], groups[tables[0]], table, groups[table])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseDistributedTable(t *testing.T) {
+	table, err := parseDistributedTable("table=orders column=customer_id colocate-with=customers")
+	assert.NoError(t, err)
+	assert.Equal(t, DistributedTable{Table: "orders", Column: "customer_id", ColocateWith: "customers"}, table)
+
+	_, err = parseDistributedTable("table=orders")
+	assert.ErrorContains(t, err, "needs a table and a column")
+	_, err = parseDistributedTable("table=orders column=id shards=32")
+	assert.ErrorContains(t, err, `unknown citus-distribute argument "shards"`)
+}
+
+func Test_applyDirectivesCitus(t *testing.T) {
+	migration := Migration{Id: "Test", Script: `-- migrago:citus-distribute table=orders column=customer_id colocate-with=customers
+-- migrago:citus-reference countries
+-- migrago:citus-colocated customers, orders
+CREATE TABLE orders (id BIGINT, customer_id BIGINT)`}
+	assert.NoError(t, migration.applyDirectives())
+	assert.Equal(t, Citus{
+		Distribute: []DistributedTable{{Table: "orders", Column: "customer_id", ColocateWith: "customers"}},
+		Reference:  []string{"countries"},
+		Colocated:  []string{"customers", "orders"},
+	}, migration.Metadata.Citus)
+	assert.False(t, migration.Metadata.Citus.isZero())
+
+	migration = Migration{Id: "Test", Script: "-- migrago:citus-workers\nALTER SYSTEM SET work_mem = '64MB'"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.True(t, migration.Metadata.Citus.Workers)
+}
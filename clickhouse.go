@@ -0,0 +1,111 @@
+package migrago
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// clickHouseUpdatePattern matches the UPDATE statements of the service, they are executed as mutations
+var clickHouseUpdatePattern = regexp.MustCompile(`(?s)^UPDATE (\w+) SET (.+) WHERE (.+)$`)
+
+// ClickHouseDialect is the dialect of ClickHouse, e.g. with the database/sql driver of
+// github.com/ClickHouse/clickhouse-go. ClickHouse has no multi-statement transactions, so every migration is executed
+// statement by statement and recorded after its last statement. The changelog tables use the MergeTree engine, the
+// DSN should set mutations_sync=2, so the changelog updates are visible once the statement returns.
+type ClickHouseDialect struct{}
+
+func (ClickHouseDialect) Name() string {
+	return "clickhouse"
+}
+
+// Placeholder returns numbered parameters, the statement inserting a missing changelog row binds the ID twice
+func (ClickHouseDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// ColumnType returns the types without Nullable, ChangelogDDL wraps the nullable columns
+func (ClickHouseDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnInteger:
+		return "Int64"
+	case ColumnTimestamp:
+		return "DateTime64(6, 'UTC')"
+	case ColumnCreatedAt:
+		return "DateTime64(6, 'UTC') DEFAULT now64(6)"
+	case ColumnFlag:
+		return "Bool DEFAULT false"
+	case ColumnSequence:
+		// There are no auto increment columns, the microseconds of the insert order the changelog of a single runner
+		return "UInt64 DEFAULT toUnixTimestamp64Micro(now64(6))"
+	default:
+		return "String"
+	}
+}
+
+// ChangelogDDL creates MergeTree tables ordered by their primary key
+func (d ClickHouseDialect) ChangelogDDL() []string {
+	statements := make([]string, 0, len(changelogTables))
+	for _, table := range changelogTables {
+		columns := make([]string, len(table.columns))
+		for i, column := range table.columns {
+			typ := d.ColumnType(column.typ)
+			nullable := column.typ != ColumnCreatedAt && column.typ != ColumnFlag && column.typ != ColumnSequence
+			if nullable && !column.notNull && i > 0 {
+				typ = "Nullable(" + typ + ")"
+			}
+			columns[i] = fmt.Sprintf("\n\t%s %s", column.name, typ)
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s\n) ENGINE = MergeTree ORDER BY %s",
+			table.name, strings.Join(columns, ","), table.columns[0].name))
+	}
+	return statements
+}
+
+func (ClickHouseDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT count() > 0 FROM system.tables WHERE database = currentDatabase() AND name = '%s'`, table)
+}
+
+func (ClickHouseDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT lower(name) FROM system.columns WHERE database = currentDatabase() AND table = '%s'`, table)
+}
+
+// InsertIgnore inserts the row unless the primary key exists, without transactions concurrent runs are not serialized
+func (d ClickHouseDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s WHERE (SELECT count() FROM %s WHERE %s = $1) = 0`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)), table, columns[0])
+}
+
+// ReportsRowsAffected is false, the driver returns 0 affected rows for every statement
+func (ClickHouseDialect) ReportsRowsAffected() bool {
+	return false
+}
+
+func (ClickHouseDialect) SessionSettingStatement() string {
+	return ""
+}
+
+func (ClickHouseDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for every statement
+func (ClickHouseDialect) NonTransactional(string) bool {
+	return true
+}
+
+func (ClickHouseDialect) VersionQuery() string {
+	return `SELECT version()`
+}
+
+// SplitRules are the rules of MySQL, ClickHouse also escapes with backslashes and accepts # comments
+func (ClickHouseDialect) SplitRules() SplitRules {
+	return mysqlSplitRules
+}
+
+// Rewrite executes updates as mutations and replaces CURRENT_TIMESTAMP with the current time in microseconds.
+// The primary key of a MergeTree table can not be updated, so migrations can not be renamed.
+func (ClickHouseDialect) Rewrite(statement string) string {
+	statement = strings.ReplaceAll(statement, "CURRENT_TIMESTAMP", "now64(6)")
+	return clickHouseUpdatePattern.ReplaceAllString(statement, "ALTER TABLE $1 UPDATE $2 WHERE $3")
+}
@@ -0,0 +1,121 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func CreateTestClickHouseContainer(t *testing.T, ctx context.Context) (*sql.DB, error) {
+	dsn := func(host string, port nat.Port) string {
+		return fmt.Sprintf("clickhouse://migrago:migrago@%s:%s/default?mutations_sync=2", host, port.Port())
+	}
+	req := testcontainers.ContainerRequest{
+		Image:        "clickhouse/clickhouse-server:24.3",
+		ExposedPorts: []string{"9000/tcp"},
+		Env: map[string]string{
+			"CLICKHOUSE_USER":     "migrago",
+			"CLICKHOUSE_PASSWORD": "migrago",
+		},
+		WaitingFor: wait.ForSQL(nat.Port("9000"), "clickhouse", dsn),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		container.Terminate(ctx)
+	})
+	ip, err := container.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	port, err := container.MappedPort(ctx, "9000")
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("clickhouse", dsn(ip, port))
+}
+
+func Test_ClickHouseDialect(t *testing.T) {
+	d := ClickHouseDialect{}
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS changelog_tag (
+	name String,
+	sequence Int64,
+	createdAt DateTime64(6, 'UTC') DEFAULT now64(6)
+) ENGINE = MergeTree ORDER BY name`, d.ChangelogDDL()[len(changelogTables)-1])
+	assert.Contains(t, d.ChangelogDDL()[0], "\n\trevertscript Nullable(String),")
+	assert.Equal(t, `INSERT INTO changelog_job (id, scheduledAt) SELECT $1, $2 WHERE (SELECT count() FROM changelog_job WHERE id = $1) = 0`, d.InsertIgnore("changelog_job", "id", "scheduledAt"))
+
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(d))
+	query, _ := service.rebind(`UPDATE changelog_run SET finishedAt = CURRENT_TIMESTAMP, error = $2 WHERE id = $1`)
+	assert.Equal(t, `ALTER TABLE changelog_run UPDATE finishedAt = now64(6), error = $2 WHERE id = $1`, query)
+	assert.True(t, service.nonTransactional(Migration{Script: "INSERT INTO events SELECT * FROM staging"}))
+
+	var statements []string
+	assert.NoError(t, service.splitRevertScript("DROP TABLE a;\nDROP TABLE b;", func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	}))
+	assert.Equal(t, []string{"DROP TABLE a", "DROP TABLE b"}, statements)
+}
+
+func Test_ClickHouse(t *testing.T) {
+	t.Run("Test migrations are applied and reverted with the ClickHouse dialect", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := CreateTestClickHouseContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_events", Script: "CREATE TABLE events (id UInt64) ENGINE = MergeTree ORDER BY id", RevertScript: "DROP TABLE events"},
+			{Id: "0002_visits", Script: "CREATE TABLE visits (id UInt64) ENGINE = MergeTree ORDER BY id", RevertScript: "DROP TABLE visits"},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, db, WithDialect(ClickHouseDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		// The driver reports no affected rows, an applied migration is not mistaken for a concurrent run
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.Empty(t, status.Pending)
+
+		fs = CreateFSForMigrations([]Migration{
+			{Id: "0001_events", Script: "CREATE TABLE events (id UInt64) ENGINE = MergeTree ORDER BY id", RevertScript: "DROP TABLE events"},
+		})
+		service = NewMigrationService("config.json", "scripts", fs, db, WithDialect(ClickHouseDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var exists bool
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT count() > 0 FROM system.tables WHERE database = currentDatabase() AND name = 'visits'`).Scan(&exists))
+		assert.False(t, exists)
+	})
+	t.Run("Test an existing changelog row is reported as concurrent insert", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := CreateTestClickHouseContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(nil), db, WithDialect(ClickHouseDialect{}))
+		assert.NoError(t, service.prepareDatabase(ctx))
+
+		tx, err := db.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		migration := Migration{Id: "0001_events", Checksum: "9c23564a026f0826f2a05b8423aa21f9", RevertScript: "DROP TABLE events"}
+		assert.NoError(t, service.insertChangelog(ctx, tx, migration))
+		assert.ErrorIs(t, service.insertChangelog(ctx, tx, migration), ErrAppliedConcurrently)
+		assert.NoError(t, tx.Commit())
+	})
+}
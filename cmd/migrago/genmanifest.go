@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"strings"
+
+	"github.com/Soemii/migrago"
+)
+
+// genManifest writes a Go file declaring the manifest of the migration directory as variable Manifest,
+// the package is taken from $GOPACKAGE which is set by go generate
+func genManifest(dir migrationDir, output string) error {
+	source := migrago.NewFileSource(dir.configFile, dir.scriptPath, os.DirFS(dir.path))
+	manifest, err := source.Manifest()
+	if err != nil {
+		return err
+	}
+	if err := dir.policy.Check(manifestIds(manifest)...); err != nil {
+		return err
+	}
+	pkg := os.Getenv("GOPACKAGE")
+	if pkg == "" {
+		pkg = "migrations"
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by migrago gen-manifest. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	fmt.Fprintf(&b, "import \"github.com/Soemii/migrago\"\n\n")
+	fmt.Fprintf(&b, "// Manifest lists the migrations of %s in execution order with the checksums of their scripts\n", dir.configFile)
+	fmt.Fprintf(&b, "var Manifest = migrago.Manifest{\n")
+	for _, entry := range manifest {
+		b.WriteString(manifestEntryLiteral(entry))
+		b.WriteString(",\n")
+	}
+	b.WriteString("}\n")
+
+	code, err := format.Source(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format manifest: %w", err)
+	}
+	return os.WriteFile(output, code, 0o644)
+}
+
+// manifestEntryLiteral returns the composite literal of an entry without its type, empty fields are omitted
+func manifestEntryLiteral(entry migrago.ManifestEntry) string {
+	var fields []string
+	for _, field := range []struct{ name, value string }{
+		{"Id", entry.Id},
+		{"Script", entry.Script},
+		{"RevertScript", entry.RevertScript},
+		{"Checksum", entry.Checksum},
+		{"Description", entry.Description},
+	} {
+		if field.value != "" {
+			fields = append(fields, fmt.Sprintf("%s: %q", field.name, field.value))
+		}
+	}
+	if len(entry.Directives) > 0 {
+		directives := make([]string, len(entry.Directives))
+		for i, directive := range entry.Directives {
+			directives[i] = fmt.Sprintf("%q", directive)
+		}
+		fields = append(fields, fmt.Sprintf("Directives: []string{%s}", strings.Join(directives, ", ")))
+	}
+	if len(entry.Variants) > 0 {
+		var variants strings.Builder
+		for _, dialect := range entry.Dialects() {
+			fmt.Fprintf(&variants, "\n%q: %s,", dialect, manifestEntryLiteral(entry.Variants[dialect]))
+		}
+		fields = append(fields, fmt.Sprintf("Variants: map[string]migrago.ManifestEntry{%s\n}", variants.String()))
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+// manifestIds returns the IDs of the manifest in execution order
+func manifestIds(manifest migrago.Manifest) []string {
+	ids := make([]string, len(manifest))
+	for i, entry := range manifest {
+		ids[i] = entry.Id
+	}
+	return ids
+}
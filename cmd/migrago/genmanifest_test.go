@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Soemii/migrago"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_genManifest(t *testing.T) {
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+		}
+		return dir
+	}
+
+	t.Run("Test manifest with variants", func(t *testing.T) {
+		t.Setenv("GOPACKAGE", "schema")
+		path := writeFiles(t, map[string]string{
+			"config.json":                     `["0001_init", "0002_users"]`,
+			"scripts/0001_init.sql":           "-- Create the schema\n-- migrago:no-transaction\nCREATE SCHEMA app",
+			"scripts/0001_init.revert.sql":    "DROP SCHEMA app",
+			"scripts/0002_users.postgres.sql": "CREATE TABLE users (id serial)",
+			"scripts/0002_users.mysql.sql":    "CREATE TABLE users (id int auto_increment)",
+		})
+		output := filepath.Join(t.TempDir(), "manifest.go")
+		err := genManifest(migrationDir{path: path, configFile: "config.json", scriptPath: "scripts", policy: defaultIDPolicy}, output)
+		assert.NoError(t, err)
+
+		code, err := os.ReadFile(output)
+		assert.NoError(t, err)
+		file, err := parser.ParseFile(token.NewFileSet(), output, code, parser.ParseComments)
+		assert.NoError(t, err)
+		assert.Equal(t, "schema", file.Name.Name)
+		assert.Contains(t, string(code), "// Code generated by migrago gen-manifest. DO NOT EDIT.")
+		assert.Contains(t, string(code), `{Id: "0001_init", Script: "scripts/0001_init.sql", RevertScript: "scripts/0001_init.revert.sql", Checksum: "`)
+		assert.Contains(t, string(code), `Description: "Create the schema", Directives: []string{"-- migrago:no-transaction"}}`)
+		assert.Contains(t, string(code), `"mysql":    {Script: "scripts/0002_users.mysql.sql", Checksum: "`)
+		assert.Contains(t, string(code), `"postgres": {Script: "scripts/0002_users.postgres.sql", Checksum: "`)
+		assert.Less(t, bytes.Index(code, []byte(`"mysql"`)), bytes.Index(code, []byte(`"postgres"`)))
+	})
+	t.Run("Test default package", func(t *testing.T) {
+		t.Setenv("GOPACKAGE", "")
+		path := writeFiles(t, map[string]string{"config.json": `["Test"]`, "scripts/Test.sql": "SELECT 1"})
+		output := filepath.Join(t.TempDir(), "manifest.go")
+		assert.NoError(t, genManifest(migrationDir{path: path, configFile: "config.json", scriptPath: "scripts", policy: defaultIDPolicy}, output))
+		code, err := os.ReadFile(output)
+		assert.NoError(t, err)
+		assert.Contains(t, string(code), "package migrations\n")
+	})
+	t.Run("Test ID violating the policy", func(t *testing.T) {
+		path := writeFiles(t, map[string]string{"config.json": `["Test"]`, "scripts/Test.sql": "SELECT 1"})
+		output := filepath.Join(t.TempDir(), "manifest.go")
+		err := genManifest(migrationDir{path: path, configFile: "config.json", scriptPath: "scripts", policy: migrago.IDPolicySequential}, output)
+		assert.ErrorContains(t, err, "do not match the naming policy")
+		assert.NoFileExists(t, output)
+	})
+}
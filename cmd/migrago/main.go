@@ -0,0 +1,794 @@
+// Command migrago executes database migrations from the command line
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/Soemii/migrago"
+	"github.com/Soemii/migrago/azure"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// dialects are the dialects selectable with -dialect
+var dialects = map[string]migrago.Dialect{
+	"postgres":    migrago.PostgresDialect{},
+	"cockroachdb": migrago.CockroachDialect{},
+	"mysql":       migrago.MySQLDialect{},
+	"sqlite":      migrago.SQLiteDialect{},
+	"sqlserver":   migrago.SQLServerDialect{},
+	"clickhouse":  migrago.ClickHouseDialect{},
+	"snowflake":   migrago.SnowflakeDialect{},
+	"bigquery":    migrago.BigQueryDialect{},
+	"redshift":    migrago.RedshiftDialect{},
+	"spanner":     migrago.SpannerDialect{},
+	"duckdb":      migrago.DuckDBDialect{},
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	usage string
+	run   func(ctx context.Context, service migrago.MigrationService, args []string) error
+	// offline is used instead of run by commands which only work on the migration directory, they need no database
+	offline func(dir migrationDir, args []string) error
+	// canary is used instead of run by commands which migrate the -canary-dsn database before the one of -dsn
+	canary func(ctx context.Context, canary migrago.Canary) error
+	// compare is used instead of run by commands which compare the database with the one of the DSN in the first argument
+	compare func(ctx context.Context, service, other migrago.MigrationService) error
+}
+
+// migrationDir is the migration directory given by the flags
+type migrationDir struct {
+	path       string
+	configFile string
+	scriptPath string
+	policy     migrago.IDPolicy
+}
+
+var commands = map[string]command{
+	"migrate": {
+		usage: "migrate            execute all pending migrations",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			return service.ExecuteMigration(ctx)
+		},
+	},
+	"fake": {
+		usage: "fake <id>          mark a migration as applied without executing it",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			if len(args) != 1 {
+				return errors.New("fake expects exactly one migration id")
+			}
+			return service.MarkApplied(ctx, args[0])
+		},
+	},
+	"rerun": {
+		usage: "rerun <id>         revert (if possible) and re-apply a migration",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			if len(args) != 1 {
+				return errors.New("rerun expects exactly one migration id")
+			}
+			return service.RerunMigration(ctx, args[0])
+		},
+	},
+	"tag": {
+		usage: "tag <name>         record a named checkpoint after the applied migrations (e.g. a release)",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			if len(args) != 1 {
+				return errors.New("tag expects exactly one name")
+			}
+			return service.Tag(ctx, args[0])
+		},
+	},
+	"rollback": {
+		usage: "rollback <tag>     revert all migrations applied after the tag",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			if len(args) != 1 {
+				return errors.New("rollback expects exactly one tag")
+			}
+			return service.RollbackToTag(ctx, args[0])
+		},
+	},
+	"release": {
+		usage: "release <apply|rollback|current> [name]   apply or revert a release, or show the current one",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			if len(args) == 1 && args[0] == "current" {
+				current, err := service.CurrentRelease(ctx)
+				if err != nil {
+					return err
+				}
+				if current == "" {
+					current = "-"
+				}
+				fmt.Fprintln(os.Stdout, current)
+				return nil
+			}
+			if len(args) != 2 {
+				return errors.New("release expects apply <name>, rollback <name> or current")
+			}
+			switch args[0] {
+			case "apply":
+				return service.ApplyRelease(ctx, args[1])
+			case "rollback":
+				return service.RollbackRelease(ctx, args[1])
+			}
+			return fmt.Errorf("unknown release action %q", args[0])
+		},
+	},
+	"prune": {
+		usage: "prune <duration>   archive changelog entries older than the duration (e.g. 8760h)",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			if len(args) != 1 {
+				return errors.New("prune expects exactly one duration")
+			}
+			olderThan, err := time.ParseDuration(args[0])
+			if err != nil {
+				return err
+			}
+			archived, err := service.Prune(ctx, olderThan)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "archived %d changelog entries\n", archived)
+			return nil
+		},
+	},
+	"jobs": {
+		usage: "jobs               execute the scheduled async migrations",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			executed, err := service.RunAsyncJobs(ctx)
+			fmt.Fprintf(os.Stdout, "executed %d async migrations\n", executed)
+			return err
+		},
+	},
+	"explain": {
+		usage: "explain            show the estimated plans of the DML statements of pending migrations",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			plans, err := service.Explain(ctx)
+			if err != nil {
+				return err
+			}
+			printPlans(os.Stdout, plans)
+			return nil
+		},
+	},
+	"preflight": {
+		usage: "preflight          check the connection, the server and the requirements without changing anything",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			report, err := service.Preflight(ctx)
+			if report.Version != "" {
+				printPreflight(os.Stdout, report)
+			}
+			return err
+		},
+	},
+	"graph": {
+		usage: "graph [dot|mermaid] print the migrations in execution order as graph (default dot)",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			format := migrago.GraphDOT
+			if len(args) > 0 {
+				format = migrago.GraphFormat(args[0])
+			}
+			return service.ExportGraph(os.Stdout, format)
+		},
+	},
+	"new": {
+		usage: "new <name>         create the scripts of a new migration and append it to the config",
+		offline: func(dir migrationDir, args []string) error {
+			if len(args) != 1 {
+				return errors.New("new expects exactly one name")
+			}
+			id, err := newMigration(dir, args[0], time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout, filepath.Join(dir.path, dir.scriptPath, id+".sql"))
+			return nil
+		},
+	},
+	"verify-local": {
+		usage: "verify-local [update] check the migrations against the committed checksums without database, update records them",
+		offline: func(dir migrationDir, args []string) error {
+			update := len(args) == 1 && args[0] == "update"
+			if len(args) > 0 && !update {
+				return errors.New("verify-local expects no argument or update")
+			}
+			service := migrago.NewMigrationService(dir.configFile, dir.scriptPath, os.DirFS(dir.path), nil, migrago.WithIDPolicy(dir.policy))
+			sumFile := filepath.Join(dir.path, filepath.Dir(dir.configFile), migrago.ChecksumsFile)
+			committed, err := readChecksumsFile(sumFile)
+			if err != nil {
+				return err
+			}
+			if update {
+				// modified and removed migrations are accepted
+				committed = nil
+			}
+			var verifyErr *migrago.VerifyError
+			if err := service.VerifyLocal(committed); errors.As(err, &verifyErr) {
+				for _, problem := range verifyErr.Problems {
+					fmt.Fprintln(os.Stdout, problem)
+				}
+				return fmt.Errorf("%d problems found", len(verifyErr.Problems))
+			} else if err != nil {
+				return err
+			}
+			if !update {
+				return nil
+			}
+			migrations, err := service.Migrations()
+			if err != nil {
+				return err
+			}
+			var sums strings.Builder
+			if err := migrago.WriteChecksums(&sums, migrations); err != nil {
+				return err
+			}
+			return os.WriteFile(sumFile, []byte(sums.String()), 0o644)
+		},
+	},
+	"gen-manifest": {
+		usage: "gen-manifest <file.go> generate a Go file with the migrations and their checksums (go generate)",
+		offline: func(dir migrationDir, args []string) error {
+			if len(args) != 1 {
+				return errors.New("gen-manifest expects exactly one output file")
+			}
+			return genManifest(dir, args[0])
+		},
+	},
+	"tui": {
+		usage: "tui                interactively list, inspect, apply and roll back migrations (development)",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			return runTUI(ctx, service, os.Stdin, os.Stdout)
+		},
+	},
+	"canary": {
+		usage: "canary             migrate the -canary-dsn database and, if it passed the thresholds, the -dsn database",
+		canary: func(ctx context.Context, canary migrago.Canary) error {
+			report, err := canary.Run(ctx)
+			// the execution times on the canary are printed in any case, the problems are part of the error
+			for _, applied := range report.Applied {
+				fmt.Fprintf(os.Stdout, "%s\t%s\n", applied.Id, applied.Duration)
+			}
+			return err
+		},
+	},
+	"diff": {
+		usage: "diff <dsn>         compare the changelog with the one of another database, e.g. staging with production",
+		compare: func(ctx context.Context, service, other migrago.MigrationService) error {
+			diff, err := migrago.DiffChangelogs(ctx, service, other)
+			if err != nil {
+				return err
+			}
+			printDiff(os.Stdout, diff)
+			if !diff.Empty() {
+				return errors.New("the changelogs differ")
+			}
+			return nil
+		},
+	},
+	"status": {
+		usage: "status             show applied, pending and unknown migrations",
+		run: func(ctx context.Context, service migrago.MigrationService, args []string) error {
+			status, err := service.Status(ctx)
+			if err != nil {
+				return err
+			}
+			printStatus(os.Stdout, status)
+			return nil
+		},
+	},
+}
+
+// Exit codes of the CLI, deployment scripts can branch on them without parsing stderr
+const (
+	exitOK = 0
+	// exitFailure is any failure without a more specific exit code
+	exitFailure = 1
+	exitUsage   = 2
+	// exitPending means the run stopped with pending migrations left, e.g. after a stop signal or outside the
+	// maintenance window
+	exitPending          = 3
+	exitChecksumMismatch = 4
+	// exitConcurrentRun means another run applied the same migration at the same time
+	exitConcurrentRun = 5
+	// exitConnection means the database is unreachable, refused the login or is a read replica
+	exitConnection = 6
+	// exitSQL means the database rejected a statement
+	exitSQL = 7
+	// exitCanary means the canary run failed or exceeded its thresholds, production was not migrated
+	exitCanary = 8
+	// exitLockHeld means a lock was held by another session for longer than the lock timeout, e.g. lock_timeout
+	exitLockHeld = 9
+)
+
+// exitCodes is the description of the exit codes in the usage
+const exitCodes = `  0  success
+  1  failure
+  2  invalid usage
+  3  pending migrations left (stopped, time budget exceeded or deferred to the maintenance window)
+  4  checksum mismatch of an applied migration
+  5  migration applied by a concurrent run
+  6  connection failure (unreachable, authentication or read replica)
+  7  SQL failure
+  8  canary failed, production not migrated
+  9  lock held by another session (lock timeout or busy database)`
+
+// exitCode returns the exit code for the error of a command
+func exitCode(err error) int {
+	var interrupted *migrago.InterruptedError
+	var pending *migrago.PendingMigrationsError
+	var mismatch *migrago.ChecksumMismatchError
+	var canary *migrago.CanaryError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &canary):
+		return exitCanary
+	case errors.Is(err, migrago.ErrAppliedConcurrently):
+		return exitConcurrentRun
+	case errors.As(err, &mismatch):
+		return exitChecksumMismatch
+	case errors.As(err, &interrupted), errors.As(err, &pending):
+		return exitPending
+	case isConnectionError(err):
+		return exitConnection
+	}
+	if sqlErr, ok := databaseError(err); ok {
+		if sqlErr.lock {
+			return exitLockHeld
+		}
+		return exitSQL
+	}
+	return exitFailure
+}
+
+// isConnectionError checks if the database could not be used at all
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, migrago.ErrNotPrimary) {
+		return true
+	}
+	sqlErr, ok := databaseError(err)
+	return ok && sqlErr.connection
+}
+
+// sqlError is the category of an error returned by the database
+type sqlError struct {
+	// connection is set if the login failed or the server does not accept connections
+	connection bool
+	// lock is set if a lock held by another session was not granted in time
+	lock bool
+}
+
+// databaseError classifies the errors of the drivers registered by the CLI, ok is false for other errors
+func databaseError(err error) (sqlError, bool) {
+	var pqErr *pq.Error
+	var mysqlErr *mysql.MySQLError
+	var mssqlErr mssql.Error
+	var sqliteErr *sqlite.Error
+	var clickhouseErr *clickhouse.Exception
+	switch {
+	case errors.As(err, &pqErr):
+		// 08: connection exception, 28: invalid authorization, 57P03: the server is starting up, 55P03: lock not available
+		return sqlError{
+			connection: pqErr.Code.Class() == "08" || pqErr.Code.Class() == "28" || pqErr.Code == "57P03",
+			lock:       pqErr.Code == "55P03",
+		}, true
+	case errors.As(err, &mysqlErr):
+		// 1040: too many connections, 1044/1045: access denied, 1205: lock wait timeout, 3572: NOWAIT lock not granted
+		return sqlError{
+			connection: mysqlErr.Number == 1040 || mysqlErr.Number == 1044 || mysqlErr.Number == 1045,
+			lock:       mysqlErr.Number == 1205 || mysqlErr.Number == 3572,
+		}, true
+	case errors.As(err, &mssqlErr):
+		// 4060: the database can not be opened, 18456: login failed, 1222: lock request timeout
+		return sqlError{
+			connection: mssqlErr.Number == 4060 || mssqlErr.Number == 18456,
+			lock:       mssqlErr.Number == 1222,
+		}, true
+	case errors.As(err, &sqliteErr):
+		// the extended result codes keep the primary code in the lower byte
+		code := sqliteErr.Code() & 0xff
+		return sqlError{
+			connection: code == sqlite3.SQLITE_CANTOPEN,
+			lock:       code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED,
+		}, true
+	case errors.As(err, &clickhouseErr):
+		// 516: authentication failed, 473: a table lock was not acquired in time
+		return sqlError{
+			connection: clickhouseErr.Code == 516,
+			lock:       clickhouseErr.Code == 473,
+		}, true
+	}
+	return sqlError{}, false
+}
+
+// commandOrder is the order of the commands in the usage
+var commandOrder = []string{"migrate", "status", "preflight", "jobs", "explain", "graph", "fake", "rerun", "tag", "rollback", "release", "prune", "new", "verify-local", "gen-manifest", "tui", "canary", "diff"}
+
+// printStatus prints the status as table
+func printStatus(w io.Writer, status migrago.Status) {
+	if status.Uninitialized {
+		fmt.Fprintln(w, "changelog not initialized, all migrations are pending")
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATE\tINSTALLED AT\tDURATION\tDESCRIPTION")
+	for _, s := range status.Applied {
+		fmt.Fprintf(tw, "%s\tapplied\t%s\t%s\t%s\n", s.Id, s.InstalledAt.Format(time.RFC3339), formatDuration(s.Duration), s.Description)
+	}
+	for _, s := range status.Unknown {
+		fmt.Fprintf(tw, "%s\tunknown\t%s\t%s\t%s\n", s.Id, s.InstalledAt.Format(time.RFC3339), formatDuration(s.Duration), s.Description)
+	}
+	for _, s := range status.Pending {
+		fmt.Fprintf(tw, "%s\tpending\t-\t-\t%s\n", s.Id, s.Description)
+	}
+	for _, job := range status.Jobs {
+		switch {
+		case !job.FinishedAt.IsZero():
+			continue
+		case job.Error != "":
+			fmt.Fprintf(tw, "%s\tjob failed: %s\t-\t-\n", job.Id, job.Error)
+		case !job.StartedAt.IsZero():
+			fmt.Fprintf(tw, "%s\tjob running\t-\t-\n", job.Id)
+		default:
+			fmt.Fprintf(tw, "%s\tjob scheduled\t-\t-\n", job.Id)
+		}
+	}
+	tw.Flush()
+}
+
+// printDiff prints the differences of two changelogs, the database of -dsn is the left one
+func printDiff(w io.Writer, diff migrago.ChangelogDiff) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDIFFERENCE")
+	for _, s := range diff.OnlyLeft {
+		fmt.Fprintf(tw, "%s\tonly applied to -dsn at %s\n", s.Id, s.InstalledAt.Format(time.RFC3339))
+	}
+	for _, s := range diff.OnlyRight {
+		fmt.Fprintf(tw, "%s\tonly applied to the other database at %s\n", s.Id, s.InstalledAt.Format(time.RFC3339))
+	}
+	for _, c := range diff.Checksums {
+		fmt.Fprintf(tw, "%s\tchecksum %s differs from %s\n", c.Id, c.Left, c.Right)
+	}
+	for _, id := range diff.Reordered {
+		fmt.Fprintf(tw, "%s\tapplied in a different order\n", id)
+	}
+	tw.Flush()
+}
+
+// printPreflight prints the preflight report
+func printPreflight(w io.Writer, report migrago.PreflightReport) {
+	fmt.Fprintf(w, "server:    %s %d (%s)\n", report.ServerType, report.VersionNum, report.Version)
+	switch {
+	case !report.ChangelogExists:
+		fmt.Fprintln(w, "changelog: missing, created by the first run")
+	case report.ChangelogUpgradable:
+		fmt.Fprintln(w, "changelog: ok")
+	default:
+		fmt.Fprintln(w, "changelog: not upgradable")
+	}
+	for _, problem := range report.Problems {
+		fmt.Fprintf(w, "problem:   %s\n", problem)
+	}
+}
+
+// handleSignals stops the run after the current migration on the first signal if finish is set,
+// otherwise and on the second signal the current migration is cancelled
+func handleSignals(signals <-chan os.Signal, finish bool, stop chan<- struct{}, cancel context.CancelFunc, logger *slog.Logger) {
+	sig, ok := <-signals
+	if !ok {
+		return
+	}
+	if finish {
+		logger.Warn("signal received, stopping after the current migration, send again to cancel it", "signal", sig)
+		close(stop)
+		if sig, ok = <-signals; !ok {
+			return
+		}
+	}
+	logger.Warn("signal received, cancelling the current migration", "signal", sig)
+	cancel()
+}
+
+// printPlans prints the estimated plans as table, statements are shortened to their first line
+func printPlans(w io.Writer, plans []migrago.StatementPlan) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDESCRIPTION\tSTATEMENT\tNODE\tROWS\tCOST")
+	for _, plan := range plans {
+		statement, _, _ := strings.Cut(plan.Statement, "\n")
+		if plan.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t%s\terror: %s\t-\t-\n", plan.MigrationId, plan.Description, statement, plan.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.0f\t%.2f\n", plan.MigrationId, plan.Description, statement, plan.NodeType, plan.EstimatedRows, plan.TotalCost)
+	}
+	tw.Flush()
+}
+
+// formatDuration formats the duration of a migration, unknown durations are printed as -
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.String()
+}
+
+// defaultIDPolicy is used by the new command if no -id-policy is given, the name is used as ID
+var defaultIDPolicy = migrago.IDPolicy{Pattern: regexp.MustCompile(`^[^\s/]+$`), Description: "without whitespace and slashes"}
+
+// newMigration creates empty scripts for a new migration and appends its ID to the config, a missing config is created
+func newMigration(dir migrationDir, name string, now time.Time) (string, error) {
+	existing, err := migrago.NewFileSource(dir.configFile, dir.scriptPath, os.DirFS(dir.path)).List()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	id, err := dir.policy.NewID(name, existing, now)
+	if err != nil {
+		return "", err
+	}
+	if slices.Contains(existing, id) {
+		return "", fmt.Errorf("migration %s already exists", id)
+	}
+
+	scripts := filepath.Join(dir.path, dir.scriptPath)
+	if err := os.MkdirAll(scripts, 0o755); err != nil {
+		return "", err
+	}
+	for _, file := range []string{id + ".sql", id + ".revert.sql"} {
+		f, err := os.OpenFile(filepath.Join(scripts, file), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", err
+		}
+		_, err = fmt.Fprintf(f, "-- %s\n", name)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return id, appendToConfig(filepath.Join(dir.path, dir.configFile), id)
+}
+
+// appendToConfig appends a migration ID to a config file which is either a list of IDs or an object with migrations
+func appendToConfig(path, id string) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		content, err = []byte("[]"), nil
+	}
+	if err != nil {
+		return err
+	}
+	var updated any
+	if trimmed := strings.TrimSpace(string(content)); strings.HasPrefix(trimmed, "{") {
+		var config map[string]json.RawMessage
+		if err := json.Unmarshal(content, &config); err != nil {
+			return fmt.Errorf("failed to decode config file: %w", err)
+		}
+		var migrations []string
+		if raw, ok := config["migrations"]; ok {
+			if err := json.Unmarshal(raw, &migrations); err != nil {
+				return fmt.Errorf("failed to decode config file: %w", err)
+			}
+		}
+		if config["migrations"], err = json.Marshal(append(migrations, id)); err != nil {
+			return err
+		}
+		updated = config
+	} else {
+		var migrations []string
+		if err := json.Unmarshal(content, &migrations); err != nil {
+			return fmt.Errorf("failed to decode config file: %w", err)
+		}
+		updated = append(migrations, id)
+	}
+	content, err = json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// readChecksumsFile reads the committed checksums, a missing file means nothing was committed yet
+func readChecksumsFile(name string) (migrago.Checksums, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return migrago.ReadChecksums(f)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run parses the arguments, executes the command and returns the exit code
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("migrago", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dsn := flags.String("dsn", os.Getenv("MIGRAGO_DSN"), "database connection string (default $MIGRAGO_DSN)")
+	driver := flags.String("driver", "postgres", "database/sql driver name: postgres, mysql, sqlite, sqlserver or clickhouse")
+	dialectName := flags.String("dialect", "", "database dialect: postgres, cockroachdb, mysql, sqlite, sqlserver, clickhouse, snowflake, bigquery, redshift, spanner or duckdb (default chosen by -driver)")
+	dir := flags.String("dir", ".", "migration directory")
+	configFile := flags.String("config", "config.json", "config file, relative to the migration directory")
+	scriptPath := flags.String("scripts", "scripts", "script directory, relative to the migration directory")
+	skip := flags.String("skip", "", "comma separated IDs of pending migrations to skip")
+	maxRunDuration := flags.Duration("max-run-duration", 0, "do not start further migrations once the run took this long (0 = unlimited)")
+	onSignal := flags.String("on-signal", "finish", "on SIGTERM/SIGINT finish the current migration and stop, or cancel it (finish or cancel)")
+	tlsMode := flags.String("tls-mode", "", "TLS mode: disable, require, verify-ca or verify-full")
+	tlsCA := flags.String("tls-ca", "", "CA bundle to verify the server certificate")
+	tlsCert := flags.String("tls-cert", "", "client certificate")
+	tlsKey := flags.String("tls-key", "", "client certificate key")
+	azureIdentity := flags.Bool("azure-managed-identity", false, "authenticate with an Azure AD token of the managed identity instead of a password")
+	azureClientId := flags.String("azure-client-id", "", "client ID of the user assigned managed identity (default system assigned)")
+	canaryDSN := flags.String("canary-dsn", os.Getenv("MIGRAGO_CANARY_DSN"), "connection string of the canary database (default $MIGRAGO_CANARY_DSN)")
+	canaryMaxDuration := flags.Duration("canary-max-duration", 0, "fail the canary if its run took longer (0 = unlimited)")
+	canaryMaxMigrationDuration := flags.Duration("canary-max-migration-duration", 0, "fail the canary if a single migration took longer (0 = unlimited)")
+	idPolicy := flags.String("id-policy", "", "naming policy of migration IDs: timestamp, sequential or a regular expression")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: migrago [flags] <command> [args]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, name := range commandOrder {
+			fmt.Fprintln(stderr, "  "+commands[name].usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		flags.PrintDefaults()
+		fmt.Fprintln(stderr, "\nexit codes:")
+		fmt.Fprintln(stderr, exitCodes)
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	name := "migrate"
+	if flags.NArg() > 0 {
+		name = flags.Arg(0)
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", name)
+		flags.Usage()
+		return exitUsage
+	}
+	policy := defaultIDPolicy
+	if *idPolicy != "" {
+		var err error
+		if policy, err = migrago.ParseIDPolicy(*idPolicy); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	}
+	var cmdArgs []string
+	if flags.NArg() > 1 {
+		cmdArgs = flags.Args()[1:]
+	}
+	if cmd.offline != nil {
+		if err := cmd.offline(migrationDir{path: *dir, configFile: *configFile, scriptPath: *scriptPath, policy: policy}, cmdArgs); err != nil {
+			fmt.Fprintf(stderr, "%s failed: %v\n", name, err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	if *dsn == "" {
+		fmt.Fprintln(stderr, "missing -dsn or $MIGRAGO_DSN")
+		return exitUsage
+	}
+	if cmd.canary != nil && *canaryDSN == "" {
+		fmt.Fprintln(stderr, "missing -canary-dsn or $MIGRAGO_CANARY_DSN")
+		return exitUsage
+	}
+	if *onSignal != "finish" && *onSignal != "cancel" {
+		fmt.Fprintf(stderr, "invalid -on-signal %q\n", *onSignal)
+		return exitUsage
+	}
+	dialect, ok := dialects[*dialectName]
+	if *dialectName != "" && !ok {
+		fmt.Fprintf(stderr, "invalid -dialect %q\n", *dialectName)
+		return exitUsage
+	}
+
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go handleSignals(signals, *onSignal == "finish", stop, cancel, logger)
+
+	opts := []migrago.Option{migrago.WithLogger(logger), migrago.WithStopChannel(stop)}
+	if *maxRunDuration > 0 {
+		opts = append(opts, migrago.WithMaxRunDuration(*maxRunDuration))
+	}
+	if *idPolicy != "" {
+		opts = append(opts, migrago.WithIDPolicy(policy))
+	}
+	if *skip != "" {
+		opts = append(opts, migrago.WithSkipIDs(strings.Split(*skip, ",")...))
+	}
+	if *tlsMode != "" || *tlsCA != "" || *tlsCert != "" || *tlsKey != "" {
+		opts = append(opts, migrago.WithTLS(migrago.TLSConfig{
+			Mode:     migrago.TLSMode(*tlsMode),
+			CAFile:   *tlsCA,
+			CertFile: *tlsCert,
+			KeyFile:  *tlsKey,
+		}))
+	}
+	if *azureIdentity {
+		opts = append(opts, migrago.WithTokenAuth(azure.NewManagedIdentity(*azureClientId)))
+	}
+	if dialect != nil {
+		opts = append(opts, migrago.WithDialect(dialect))
+	}
+	service, err := migrago.NewMigrationServiceFromDSN(*driver, *dsn, *configFile, *scriptPath, os.DirFS(*dir), opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCode(err)
+	}
+	defer service.Close()
+	if cmd.canary != nil {
+		canary, err := migrago.NewMigrationServiceFromDSN(*driver, *canaryDSN, *configFile, *scriptPath, os.DirFS(*dir), opts...)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitCode(err)
+		}
+		defer canary.Close()
+		err = cmd.canary(ctx, migrago.Canary{
+			Canary:     canary,
+			Production: service,
+			Thresholds: migrago.CanaryThresholds{MaxDuration: *canaryMaxDuration, MaxMigrationDuration: *canaryMaxMigrationDuration},
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "%s failed: %v\n", name, err)
+			return exitCode(err)
+		}
+		return exitOK
+	}
+	if cmd.compare != nil {
+		if len(cmdArgs) != 1 {
+			fmt.Fprintf(stderr, "%s expects exactly one DSN\n", name)
+			return exitUsage
+		}
+		other, err := migrago.NewMigrationServiceFromDSN(*driver, cmdArgs[0], *configFile, *scriptPath, os.DirFS(*dir), opts...)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitCode(err)
+		}
+		defer other.Close()
+		if err := cmd.compare(ctx, service, other); err != nil {
+			fmt.Fprintf(stderr, "%s failed: %v\n", name, err)
+			return exitCode(err)
+		}
+		return exitOK
+	}
+	if err := cmd.run(ctx, service, cmdArgs); err != nil {
+		fmt.Fprintf(stderr, "%s failed: %v\n", name, err)
+		return exitCode(err)
+	}
+	return exitOK
+}
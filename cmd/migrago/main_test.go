@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/Soemii/migrago"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_exitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"success", nil, exitOK},
+		{"failure", errors.New("failed"), exitFailure},
+		{"interrupted", &migrago.InterruptedError{Reason: "stop requested", Remaining: []string{"Test"}}, exitPending},
+		{"pending", fmt.Errorf("check: %w", &migrago.PendingMigrationsError{Pending: []string{"Test"}}), exitPending},
+		{"checksum mismatch", &migrago.ChecksumMismatchError{Id: "Test"}, exitChecksumMismatch},
+		{"concurrent run", fmt.Errorf("migration Test: %w", migrago.ErrAppliedConcurrently), exitConcurrentRun},
+		{"unreachable", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, exitConnection},
+		{"bad connection", driver.ErrBadConn, exitConnection},
+		{"read replica", migrago.ErrNotPrimary, exitConnection},
+		{"authentication", &pq.Error{Code: "28P01"}, exitConnection},
+		{"server starting up", &pq.Error{Code: "57P03"}, exitConnection},
+		{"SQL", fmt.Errorf("migration Test: %w", &pq.Error{Code: "42P01"}), exitSQL},
+		{"canary", &migrago.CanaryError{Err: &pq.Error{Code: "42P01"}}, exitCanary},
+		{"canary thresholds", &migrago.CanaryError{Problems: []string{"run took 2m"}}, exitCanary},
+		{"lock held", fmt.Errorf("migration Test: %w", &pq.Error{Code: "55P03"}), exitLockHeld},
+		{"MySQL invalid connection", mysql.ErrInvalidConn, exitConnection},
+		{"MySQL authentication", &mysql.MySQLError{Number: 1045}, exitConnection},
+		{"MySQL too many connections", &mysql.MySQLError{Number: 1040}, exitConnection},
+		{"MySQL lock wait timeout", &mysql.MySQLError{Number: 1205}, exitLockHeld},
+		{"MySQL lock NOWAIT", &mysql.MySQLError{Number: 3572}, exitLockHeld},
+		{"MySQL SQL", fmt.Errorf("migration Test: %w", &mysql.MySQLError{Number: 1146}), exitSQL},
+		{"SQL Server login", mssql.Error{Number: 18456}, exitConnection},
+		{"SQL Server database", mssql.Error{Number: 4060}, exitConnection},
+		{"SQL Server lock timeout", mssql.Error{Number: 1222}, exitLockHeld},
+		{"SQL Server SQL", fmt.Errorf("migration Test: %w", mssql.Error{Number: 208}), exitSQL},
+		{"ClickHouse authentication", &clickhouse.Exception{Code: 516}, exitConnection},
+		{"ClickHouse lock", &clickhouse.Exception{Code: 473}, exitLockHeld},
+		{"ClickHouse SQL", &clickhouse.Exception{Code: 60}, exitSQL},
+	}
+	for _, test := range tests {
+		t.Run("Test "+test.name, func(t *testing.T) {
+			assert.Equal(t, test.code, exitCode(test.err))
+		})
+	}
+}
+
+func Test_exitCodeSQLite(t *testing.T) {
+	dir := t.TempDir()
+	open := func(t *testing.T, dsn string) *sql.DB {
+		db, err := sql.Open("sqlite", dsn)
+		assert.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+
+	t.Run("Test missing directory", func(t *testing.T) {
+		err := open(t, "file:"+filepath.Join(dir, "missing", "test.db")).Ping()
+		assert.Equal(t, exitConnection, exitCode(err))
+	})
+	t.Run("Test SQL", func(t *testing.T) {
+		_, err := open(t, "file:"+filepath.Join(dir, "test.db")).Exec("SELECT * FROM missing")
+		assert.Equal(t, exitSQL, exitCode(err))
+	})
+	t.Run("Test busy database", func(t *testing.T) {
+		holder := open(t, "file:"+filepath.Join(dir, "test.db"))
+		holder.SetMaxOpenConns(1)
+		tx, err := holder.Begin()
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.Exec("CREATE TABLE locked (id INTEGER)")
+		assert.NoError(t, err)
+
+		_, err = open(t, "file:"+filepath.Join(dir, "test.db")+"?_pragma=busy_timeout(0)").Exec("CREATE TABLE other (id INTEGER)")
+		assert.Equal(t, exitLockHeld, exitCode(err))
+	})
+}
+
+func Test_run(t *testing.T) {
+	t.Run("Test unknown command", func(t *testing.T) {
+		var stderr bytes.Buffer
+		assert.Equal(t, exitUsage, run([]string{"unknown"}, &stderr))
+		assert.Contains(t, stderr.String(), `unknown command "unknown"`)
+	})
+	t.Run("Test missing DSN", func(t *testing.T) {
+		t.Setenv("MIGRAGO_DSN", "")
+		var stderr bytes.Buffer
+		assert.Equal(t, exitUsage, run([]string{"status"}, &stderr))
+		assert.Contains(t, stderr.String(), "missing -dsn")
+	})
+	t.Run("Test offline command", func(t *testing.T) {
+		dir := t.TempDir()
+		var stderr bytes.Buffer
+		assert.Equal(t, exitOK, run([]string{"-dir", dir, "-id-policy", "sequential", "new", "Add users"}, &stderr))
+		assert.FileExists(t, filepath.Join(dir, "scripts", "0001_add_users.sql"))
+
+		assert.Equal(t, exitFailure, run([]string{"-dir", dir, "new"}, &stderr))
+		assert.Contains(t, stderr.String(), "new failed: new expects exactly one name")
+	})
+}
+
+func Test_newMigration(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Test without config", func(t *testing.T) {
+		dir := migrationDir{path: t.TempDir(), configFile: "config.json", scriptPath: "scripts", policy: migrago.IDPolicyTimestamp}
+		id, err := newMigration(dir, "Add users", now)
+		assert.NoError(t, err)
+		assert.Equal(t, "20261016120000_add_users", id)
+
+		script, err := os.ReadFile(filepath.Join(dir.path, "scripts", id+".sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, "-- Add users\n", string(script))
+		revertScript, err := os.ReadFile(filepath.Join(dir.path, "scripts", id+".revert.sql"))
+		assert.NoError(t, err)
+		assert.Equal(t, "-- Add users\n", string(revertScript))
+		config, err := os.ReadFile(filepath.Join(dir.path, "config.json"))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `["20261016120000_add_users"]`, string(config))
+	})
+	t.Run("Test with sequential policy", func(t *testing.T) {
+		dir := migrationDir{path: t.TempDir(), configFile: "config.json", scriptPath: "scripts", policy: migrago.IDPolicySequential}
+		assert.NoError(t, os.WriteFile(filepath.Join(dir.path, "config.json"), []byte(`["0007_init"]`), 0o644))
+		assert.NoError(t, os.Mkdir(filepath.Join(dir.path, "scripts"), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir.path, "scripts", "0007_init.sql"), []byte("SELECT 1"), 0o644))
+
+		id, err := newMigration(dir, "Add users", now)
+		assert.NoError(t, err)
+		assert.Equal(t, "0008_add_users", id)
+		config, err := os.ReadFile(filepath.Join(dir.path, "config.json"))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `["0007_init", "0008_add_users"]`, string(config))
+	})
+	t.Run("Test with existing migration", func(t *testing.T) {
+		dir := migrationDir{path: t.TempDir(), configFile: "config.json", scriptPath: "scripts", policy: defaultIDPolicy}
+		_, err := newMigration(dir, "users", now)
+		assert.NoError(t, err)
+		_, err = newMigration(dir, "users", now)
+		assert.EqualError(t, err, "migration users already exists")
+	})
+	t.Run("Test with ID violating the policy", func(t *testing.T) {
+		dir := migrationDir{path: t.TempDir(), configFile: "config.json", scriptPath: "scripts", policy: defaultIDPolicy}
+		_, err := newMigration(dir, "add users", now)
+		assert.ErrorContains(t, err, "do not match the naming policy")
+		assert.NoFileExists(t, filepath.Join(dir.path, "config.json"))
+	})
+}
+
+func Test_appendToConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{"missing", "", `["Test"]`},
+		{"list", `["Init"]`, `["Init", "Test"]`},
+		{"object", `{"requires": {"postgres": ">=14"}, "migrations": ["Init"]}`, `{"requires": {"postgres": ">=14"}, "migrations": ["Init", "Test"]}`},
+		{"object without migrations", `{"directories": ["users"]}`, `{"directories": ["users"], "migrations": ["Test"]}`},
+	}
+	for _, test := range tests {
+		t.Run("Test "+test.name+" config", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if test.config != "" {
+				assert.NoError(t, os.WriteFile(path, []byte(test.config), 0o644))
+			}
+			assert.NoError(t, appendToConfig(path, "Test"))
+			content, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.JSONEq(t, test.expected, string(content))
+		})
+	}
+	t.Run("Test invalid config", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"migrations": "Init"}`), 0o644))
+		assert.ErrorContains(t, appendToConfig(path, "Test"), "failed to decode config file")
+	})
+}
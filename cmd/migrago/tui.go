@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Soemii/migrago"
+)
+
+// tuiHelp lists the commands of the TUI
+const tuiHelp = `commands:
+  list             show the migrations again
+  show <n>         show the scripts of a migration and the differences to the applied version
+  apply <n>        apply the pending migrations up to and including n
+  rollback <n>     revert the migrations applied after n and n itself
+  quit`
+
+// tuiEntry is a migration listed by the TUI
+type tuiEntry struct {
+	// state is applied, changed (the script was edited after it was applied), pending or unknown (not configured)
+	state      string
+	id         string
+	configured *migrago.Migration
+	applied    *migrago.Migration
+}
+
+// runTUI lists the applied and pending migrations and executes the commands read from in until quit
+func runTUI(ctx context.Context, service migrago.MigrationService, in io.Reader, out io.Writer) error {
+	entries, err := loadTUIEntries(ctx, service)
+	if err != nil {
+		return err
+	}
+	printTUIEntries(out, entries)
+	fmt.Fprintln(out, tuiHelp)
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		action, arg, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		switch action {
+		case "":
+			continue
+		case "quit", "q":
+			return nil
+		case "list", "l":
+			printTUIEntries(out, entries)
+			continue
+		case "help", "h":
+			fmt.Fprintln(out, tuiHelp)
+			continue
+		case "show", "s", "apply", "a", "rollback", "r":
+		default:
+			fmt.Fprintf(out, "unknown command %q\n%s\n", action, tuiHelp)
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil || n < 1 || n > len(entries) {
+			fmt.Fprintf(out, "%s expects a number between 1 and %d\n", action, len(entries))
+			continue
+		}
+		entry := entries[n-1]
+		var verb string
+		var run func() error
+		switch action {
+		case "show", "s":
+			showTUIEntry(out, entry)
+			continue
+		case "apply", "a":
+			if entry.state != "pending" {
+				fmt.Fprintf(out, "%s is not pending\n", entry.id)
+				continue
+			}
+			verb, run = "apply", func() error { return service.ApplyThrough(ctx, entry.id) }
+		default:
+			if entry.state == "pending" {
+				fmt.Fprintf(out, "%s is not applied\n", entry.id)
+				continue
+			}
+			verb, run = "rollback", func() error { return service.RevertThrough(ctx, entry.id) }
+		}
+
+		fmt.Fprintf(out, "%s through %s? [y/N] ", verb, entry.id)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return scanner.Err()
+		}
+		if answer := strings.ToLower(strings.TrimSpace(scanner.Text())); answer != "y" && answer != "yes" {
+			continue
+		}
+		if err := run(); err != nil {
+			fmt.Fprintf(out, "failed: %v\n", err)
+		}
+		if entries, err = loadTUIEntries(ctx, service); err != nil {
+			return err
+		}
+		printTUIEntries(out, entries)
+	}
+}
+
+// loadTUIEntries lists the applied and unknown migrations in installation order, followed by the pending ones
+func loadTUIEntries(ctx context.Context, service migrago.MigrationService) ([]tuiEntry, error) {
+	migrations, err := service.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := service.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status, err := service.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	find := func(id string) *migrago.Migration {
+		index := slices.IndexFunc(migrations, func(migration migrago.Migration) bool { return migration.Id == id })
+		if index < 0 {
+			return nil
+		}
+		return &migrations[index]
+	}
+
+	var entries []tuiEntry
+	// applied migrations are ordered newest first
+	for i := len(applied) - 1; i >= 0; i-- {
+		entry := tuiEntry{state: "applied", id: applied[i].Id, configured: find(applied[i].Id), applied: &applied[i]}
+		if entry.configured == nil {
+			entry.state = "unknown"
+		} else if entry.configured.Checksum != entry.applied.Checksum {
+			entry.state = "changed"
+		}
+		entries = append(entries, entry)
+	}
+	for _, pending := range status.Pending {
+		entries = append(entries, tuiEntry{state: "pending", id: pending.Id, configured: find(pending.Id)})
+	}
+	return entries, nil
+}
+
+// printTUIEntries prints the numbered migrations
+func printTUIEntries(w io.Writer, entries []tuiEntry) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tSTATE\tID")
+	for i, entry := range entries {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", i+1, entry.state, entry.id)
+	}
+	tw.Flush()
+}
+
+// showTUIEntry prints the scripts of a migration, revert scripts which differ from the recorded one are shown as diff
+func showTUIEntry(w io.Writer, entry tuiEntry) {
+	fmt.Fprintf(w, "%s (%s)\n", entry.id, entry.state)
+	if entry.state == "changed" {
+		fmt.Fprintf(w, "the script was changed after it was applied, checksum %s is now %s\n", entry.applied.Checksum, entry.configured.Checksum)
+	}
+	if entry.configured != nil {
+		fmt.Fprintln(w, "--- script")
+		if entry.configured.Script == "" && entry.configured.OpenScript != nil {
+			fmt.Fprintln(w, "(large script, not shown)")
+		} else {
+			fmt.Fprintln(w, strings.TrimRight(entry.configured.Script, "\n"))
+		}
+	}
+
+	fmt.Fprintln(w, "--- revert script")
+	switch {
+	case entry.applied == nil:
+		fmt.Fprintln(w, strings.TrimRight(entry.configured.RevertScript, "\n"))
+	case entry.configured == nil || entry.configured.RevertScript == entry.applied.RevertScript:
+		fmt.Fprintln(w, strings.TrimRight(entry.applied.RevertScript, "\n"))
+	default:
+		fmt.Fprintln(w, "(- recorded in the changelog, + configured)")
+		for _, line := range diffLines(entry.applied.RevertScript, entry.configured.RevertScript) {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// diffLines returns the lines of both texts prefixed with "-" if they were removed, "+" if they were added
+// and " " if they are unchanged, based on the longest common subsequence
+func diffLines(a, b string) []string {
+	before := strings.Split(strings.TrimRight(a, "\n"), "\n")
+	after := strings.Split(strings.TrimRight(b, "\n"), "\n")
+	// common[i][j] is the length of the longest common subsequence of before[i:] and after[j:]
+	common := make([][]int, len(before)+1)
+	for i := range common {
+		common[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case i < len(before) && j < len(after) && before[i] == after[j]:
+			lines = append(lines, " "+before[i])
+			i, j = i+1, j+1
+		case i < len(before) && (j == len(after) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "-"+before[i])
+			i++
+		default:
+			lines = append(lines, "+"+after[j])
+			j++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,114 @@
+package migrago
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// CockroachDialect is the dialect of CockroachDB. It speaks the Postgres protocol, but has neither WAL positions nor
+// backend cancellation, and retries migration transactions which fail with serialization errors.
+type CockroachDialect struct {
+	PostgresDialect
+}
+
+func (CockroachDialect) Name() string {
+	return "cockroachdb"
+}
+
+func (d CockroachDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnSequence:
+		// unique_rowid is ordered by the insert time without the contention of a sequence
+		return "INT8 NOT NULL DEFAULT unique_rowid()"
+	case ColumnLSN:
+		return "STRING"
+	default:
+		return d.PostgresDialect.ColumnType(t)
+	}
+}
+
+// ChangelogDDL creates the changelog tables of the current version, the Postgres upgrades of earlier versions rely on
+// PL/pgSQL and sequences owned by columns. Columns of newer versions are added with IF NOT EXISTS instead, which
+// CockroachDB runs as an online schema change.
+func (d CockroachDialect) ChangelogDDL() []string {
+	return append(createChangelogTables(d), `ALTER TABLE changelog_job ADD COLUMN IF NOT EXISTS heartbeatAt TIMESTAMPTZ`)
+}
+
+// VersionQuery extracts the CockroachDB version, server_version is the version of Postgres it is compatible with
+func (CockroachDialect) VersionQuery() string {
+	return `SELECT regexp_extract(version(), 'v(\d+\.\d+\.\d+)')`
+}
+
+// Retryable is true for serialization failures, CockroachDB returns them under contention and expects the client
+// to retry the transaction
+func (CockroachDialect) Retryable(err error) bool {
+	var sqlState interface{ SQLState() string }
+	return errors.As(err, &sqlState) && sqlState.SQLState() == "40001"
+}
+
+// RetryDialect is implemented by dialects whose transactions may fail with errors which go away on a retry
+type RetryDialect interface {
+	Dialect
+	Retryable(err error) bool
+}
+
+// TransactionRetry configures the retries of migration transactions with a RetryDialect
+type TransactionRetry struct {
+	// Attempts is the maximum number of executions of a transaction, 5 by default
+	Attempts int
+	// Backoff is the delay before the first retry, 100ms by default; it doubles on every retry up to MaxBackoff,
+	// 5s by default, and is jittered by up to half
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// withDefaults returns the retry configuration with the defaults of unset fields
+func (r TransactionRetry) withDefaults() TransactionRetry {
+	if r.Attempts <= 0 {
+		r.Attempts = 5
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = 100 * time.Millisecond
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = 5 * time.Second
+	}
+	return r
+}
+
+// delay returns the jittered delay before the retry after the attempt
+func (r TransactionRetry) delay(attempt int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < attempt && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, r.MaxBackoff)
+	return delay - rand.N(delay/2+1)
+}
+
+// retryTx executes the transaction of a migration until it succeeds, fails with an error which is not retryable or
+// the attempts are exhausted. Without a RetryDialect it is executed once.
+func (m MigrationService) retryTx(ctx context.Context, migrationId string, fn func() error) error {
+	d, ok := m.sqlDialect().(RetryDialect)
+	if !ok {
+		return fn()
+	}
+	retry := m.txRetry.withDefaults()
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retry.Attempts || !d.Retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := retry.delay(attempt)
+		m.log().Warn("migration transaction failed with a retryable error, retrying", "id", migrationId, "attempt", attempt, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package migrago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_retryTx(t *testing.T) {
+	serialization := fmt.Errorf("failed to execute migration script: %w", &pq.Error{Code: "40001"})
+	service := NewMigrationService("config.json", "scripts", nil, nil,
+		WithDialect(CockroachDialect{}), WithTransactionRetry(TransactionRetry{Attempts: 3, Backoff: time.Millisecond}))
+
+	var attempts int
+	err := service.retryTx(context.Background(), "Test", func() error {
+		if attempts++; attempts < 3 {
+			return serialization
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	err = service.retryTx(context.Background(), "Test", func() error {
+		attempts++
+		return serialization
+	})
+	assert.ErrorIs(t, err, serialization)
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	other := errors.New("syntax error")
+	assert.ErrorIs(t, service.retryTx(context.Background(), "Test", func() error {
+		attempts++
+		return other
+	}), other)
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	assert.Error(t, MigrationService{}.retryTx(context.Background(), "Test", func() error {
+		attempts++
+		return serialization
+	}))
+	assert.Equal(t, 1, attempts)
+}
+
+func Test_TransactionRetry_delay(t *testing.T) {
+	retry := TransactionRetry{}.withDefaults()
+	assert.InDelta(t, 75*time.Millisecond, retry.delay(1), float64(25*time.Millisecond))
+	assert.InDelta(t, 150*time.Millisecond, retry.delay(2), float64(50*time.Millisecond))
+	assert.LessOrEqual(t, retry.delay(20), 5*time.Second)
+	assert.GreaterOrEqual(t, retry.delay(20), 2500*time.Millisecond)
+}
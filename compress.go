@@ -0,0 +1,56 @@
+package migrago
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// gzipMarker prefixes revert scripts which are stored gzip compressed and base64 encoded in the changelog
+const gzipMarker = "migrago:gzip:"
+
+// compressionThreshold is the size in bytes from which revert scripts are stored compressed,
+// smaller scripts stay readable in the changelog
+const compressionThreshold = 4 << 10
+
+// encodeRevertScript compresses a revert script for the changelog if it exceeds the compression threshold
+func encodeRevertScript(script string) (string, error) {
+	if len(script) < compressionThreshold {
+		return script, nil
+	}
+	var buf bytes.Buffer
+	buf.WriteString(gzipMarker)
+	b64 := base64.NewEncoder(base64.StdEncoding, &buf)
+	gz := gzip.NewWriter(b64)
+	if _, err := io.WriteString(gz, script); err != nil {
+		return "", fmt.Errorf("failed to compress revert script: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress revert script: %w", err)
+	}
+	if err := b64.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress revert script: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// decodeRevertScript decompresses a revert script read from the changelog, uncompressed scripts are returned as is
+func decodeRevertScript(stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, gzipMarker)
+	if !ok {
+		return stored, nil
+	}
+	gz, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(encoded)))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress revert script: %w", err)
+	}
+	defer gz.Close()
+	script, err := io.ReadAll(gz)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress revert script: %w", err)
+	}
+	return string(script), nil
+}
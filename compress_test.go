@@ -0,0 +1,32 @@
+package migrago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_encodeRevertScript(t *testing.T) {
+	small := "DROP TABLE test"
+	encoded, err := encodeRevertScript(small)
+	assert.NoError(t, err)
+	assert.Equal(t, small, encoded)
+
+	large := strings.Repeat("INSERT INTO test (name) VALUES ('restored');\n", 1000)
+	encoded, err = encodeRevertScript(large)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, gzipMarker))
+	assert.Less(t, len(encoded), len(large))
+
+	decoded, err := decodeRevertScript(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, large, decoded)
+
+	decoded, err = decodeRevertScript(small)
+	assert.NoError(t, err)
+	assert.Equal(t, small, decoded)
+
+	_, err = decodeRevertScript(gzipMarker + "not base64")
+	assert.ErrorContains(t, err, "failed to decompress revert script")
+}
@@ -0,0 +1,238 @@
+package migrago
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaKind is the expected type of a JSON value in the config file
+type schemaKind int
+
+const (
+	kindString schemaKind = iota
+	kindArray
+	kindObject
+	// kindMap is an object with arbitrary keys and values of the same schema
+	kindMap
+)
+
+// schema describes the expected shape of a JSON value in the config file
+type schema struct {
+	kind schemaKind
+	// what describes the value in error messages, e.g. "migration ID"
+	what string
+	// nonEmpty rejects empty strings
+	nonEmpty bool
+	fields   map[string]*schema
+	items    *schema
+}
+
+var (
+	migrationIdSchema = &schema{kind: kindString, what: "migration ID", nonEmpty: true}
+	stringListSchema  = &schema{kind: kindArray, what: "list", items: &schema{kind: kindString, what: "string"}}
+	// migrationsSchema is the schema of a config file which is a list of migration IDs
+	migrationsSchema = &schema{kind: kindArray, what: "list of migration IDs", items: migrationIdSchema}
+	// configSchema is the schema of a config file which is an object
+	configSchema = &schema{kind: kindObject, what: "config", fields: map[string]*schema{
+		"requires": {kind: kindObject, what: "requirements", fields: map[string]*schema{
+			"extensions": stringListSchema,
+			"collations": stringListSchema,
+			"settings":   {kind: kindMap, what: "settings", items: &schema{kind: kindString, what: "setting value"}},
+			"schemas":    stringListSchema,
+		}},
+		"migrations":  migrationsSchema,
+		"directories": {kind: kindArray, what: "list of directories", items: &schema{kind: kindString, what: "directory", nonEmpty: true}},
+		"aliases":     {kind: kindMap, what: "aliases", items: &schema{kind: kindString, what: "migration ID", nonEmpty: true}},
+		"superseded":  {kind: kindMap, what: "superseded migrations", items: &schema{kind: kindString, what: "migration ID", nonEmpty: true}},
+		"releases": {kind: kindArray, what: "list of releases", items: &schema{kind: kindObject, what: "release", fields: map[string]*schema{
+			"name":       {kind: kindString, what: "release name", nonEmpty: true},
+			"migrations": migrationsSchema,
+		}}},
+	}}
+)
+
+// configValidator walks the tokens of a config file and compares them with a schema
+type configValidator struct {
+	content  []byte
+	decoder  *json.Decoder
+	problems []ConfigProblem
+}
+
+// validateConfig validates the content of a config file against the schema, a syntax error stops the validation
+func validateConfig(file string, content []byte) error {
+	root := migrationsSchema
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		root = configSchema
+	}
+	v := &configValidator{content: content, decoder: json.NewDecoder(bytes.NewReader(content))}
+	err := v.value(root)
+	if err == nil {
+		if _, err = v.decoder.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			v.problem(v.decoder.InputOffset(), "unexpected content after the end of the config")
+		}
+	}
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// the offset of a syntax error is behind the invalid character
+		v.problem(max(syntaxErr.Offset-1, 0), syntaxErr.Error())
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		v.problem(int64(len(content)), "unexpected end of the config")
+	case err != nil:
+		return err
+	}
+	if len(v.problems) > 0 {
+		return &ConfigError{File: file, Problems: v.problems}
+	}
+	return nil
+}
+
+// problem records a problem at the given byte offset
+func (v *configValidator) problem(offset int64, format string, args ...any) {
+	line, column := 1, 1
+	for _, c := range v.content[:min(int(offset), len(v.content))] {
+		if c == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	v.problems = append(v.problems, ConfigProblem{Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// next reads the next token and returns the offset of its first byte
+func (v *configValidator) next() (json.Token, int64, error) {
+	start := v.decoder.InputOffset()
+	token, err := v.decoder.Token()
+	if err != nil {
+		return nil, start, err
+	}
+	for int(start) < len(v.content) && strings.IndexByte(" \t\r\n,:", v.content[start]) >= 0 {
+		start++
+	}
+	return token, start, nil
+}
+
+// value validates the next value against the schema, values of an unexpected type are skipped
+func (v *configValidator) value(s *schema) error {
+	token, start, err := v.next()
+	if err != nil {
+		return err
+	}
+	switch s.kind {
+	case kindString:
+		str, ok := token.(string)
+		if !ok {
+			v.problem(start, "%s must be a string, got %s", s.what, describeToken(token))
+			return v.skip(token)
+		}
+		if s.nonEmpty && strings.TrimSpace(str) == "" {
+			v.problem(start, "%s must not be empty", s.what)
+		}
+		return nil
+	case kindArray:
+		if token != json.Delim('[') {
+			v.problem(start, "%s must be an array, got %s", s.what, describeToken(token))
+			return v.skip(token)
+		}
+		for v.decoder.More() {
+			if err := v.value(s.items); err != nil {
+				return err
+			}
+		}
+		_, err = v.decoder.Token()
+		return err
+	default:
+		if token != json.Delim('{') {
+			v.problem(start, "%s must be an object, got %s", s.what, describeToken(token))
+			return v.skip(token)
+		}
+		for v.decoder.More() {
+			key, keyStart, err := v.next()
+			if err != nil {
+				return err
+			}
+			field := s.items
+			if s.kind == kindObject {
+				field = s.fields[key.(string)]
+			}
+			if field == nil {
+				v.problem(keyStart, "unknown field %q in %s, expected one of %s", key, s.what, fieldNames(s))
+				if err := v.skipValue(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := v.value(field); err != nil {
+				return err
+			}
+		}
+		_, err = v.decoder.Token()
+		return err
+	}
+}
+
+// skipValue skips the next value
+func (v *configValidator) skipValue() error {
+	token, err := v.decoder.Token()
+	if err != nil {
+		return err
+	}
+	return v.skip(token)
+}
+
+// skip skips the rest of a value whose first token has already been read
+func (v *configValidator) skip(token json.Token) error {
+	if token != json.Delim('{') && token != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		token, err := v.decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// describeToken describes the type of a value by its first token
+func describeToken(token json.Token) string {
+	switch token.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	if token == json.Delim('{') {
+		return "object"
+	}
+	return "array"
+}
+
+// fieldNames returns the sorted names of the fields of an object schema
+func fieldNames(s *schema) string {
+	names := make([]string, 0, len(s.fields))
+	for name := range s.fields {
+		names = append(names, strconv.Quote(name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
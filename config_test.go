@@ -0,0 +1,45 @@
+package migrago
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateConfig(t *testing.T) {
+	assert.NoError(t, validateConfig("config.json", []byte(`["0001_init", "0002_users"]`)))
+	assert.NoError(t, validateConfig("config.json", []byte(`{
+	"requires": {"extensions": ["pgcrypto"], "settings": {"wal_level": "logical"}},
+	"migrations": ["0001_init"],
+	"releases": [{"name": "v2", "migrations": ["0002_users"]}]
+}`)))
+
+	err := validateConfig("config.json", []byte(`{
+	"migration": ["0001_init"],
+	"migrations": ["0001_init", "", 3],
+	"releases": [{"name": "v2", "migrations": "0002_users"}],
+	"requires": {"settings": {"wal_level": true}}
+}`))
+	var configErr *ConfigError
+	assert.ErrorAs(t, err, &configErr)
+	assert.Equal(t, []ConfigProblem{
+		{Line: 2, Column: 2, Message: `unknown field "migration" in config, expected one of "aliases", "directories", "migrations", "releases", "requires", "superseded"`},
+		{Line: 3, Column: 30, Message: "migration ID must not be empty"},
+		{Line: 3, Column: 34, Message: "migration ID must be a string, got number"},
+		{Line: 4, Column: 44, Message: "list of migration IDs must be an array, got string"},
+		{Line: 5, Column: 41, Message: "setting value must be a string, got boolean"},
+	}, configErr.Problems)
+
+	err = validateConfig("config.json", []byte("[\n\t\"0001_init\"\n\t\"0002_users\"\n]"))
+	assert.EqualError(t, err, "config.json:3:2: invalid character '\"' after array element")
+
+	err = validateConfig("config.json", []byte(`{"migrations": ["0001_init"]`))
+	assert.EqualError(t, err, "config.json:1:28: unexpected end of JSON input")
+}
+
+func Test_FileSourceInvalidConfig(t *testing.T) {
+	fs := fstest.MapFS{"config.json": {Data: []byte(`{"migrations": ["0001_init"], "release": []}`)}}
+	_, err := NewFileSource("config.json", "scripts", fs).List()
+	assert.ErrorContains(t, err, `config.json:1:31: unknown field "release" in config`)
+}
@@ -0,0 +1,95 @@
+package migrago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNotConfirmed is returned if a destructive operation was declined by the ConfirmFunc
+var ErrNotConfirmed = errors.New("operation not confirmed")
+
+// OperationKind is the kind of a destructive operation
+type OperationKind string
+
+const (
+	// OperationRevert is the execution of a revert script
+	OperationRevert OperationKind = "revert"
+	// OperationDrop is a migration containing DROP or TRUNCATE statements
+	OperationDrop OperationKind = "drop"
+	// OperationDangerous is a migration flagged with "-- migrago:dangerous"
+	OperationDangerous OperationKind = "dangerous"
+)
+
+// Operation describes a destructive operation which has to be confirmed
+type Operation struct {
+	Kind        OperationKind
+	MigrationId string
+	// Statements contains the statements which caused the confirmation (drops) or the revert script
+	Statements  []string
+	Description string
+}
+
+// ConfirmFunc is called before destructive operations are executed, returning false aborts the run
+type ConfirmFunc func(ctx context.Context, op Operation) bool
+
+// dropStatement matches statements removing objects or data
+var dropStatement = regexp.MustCompile(`(?i)^\s*(DROP\s+\w+|ALTER\s+TABLE\s+.*\bDROP\b|TRUNCATE)\b`)
+
+// findDropStatements returns all lines of the script starting a DROP or TRUNCATE statement
+func findDropStatements(script string) []string {
+	var statements []string
+	for _, line := range strings.Split(script, "\n") {
+		if dropStatement.MatchString(line) {
+			statements = append(statements, strings.TrimSpace(line))
+		}
+	}
+	return statements
+}
+
+// confirm asks the ConfirmFunc (if any) for the confirmation of an operation
+func (m MigrationService) confirm(ctx context.Context, op Operation) error {
+	if m.confirmFunc == nil || m.confirmFunc(ctx, op) {
+		return nil
+	}
+	return fmt.Errorf("%s of migration %s: %w", op.Kind, op.MigrationId, ErrNotConfirmed)
+}
+
+// confirmMigration asks for the confirmation of a pending migration if it is destructive
+func (m MigrationService) confirmMigration(ctx context.Context, migration Migration) error {
+	if m.confirmFunc == nil {
+		return nil
+	}
+	if migration.Metadata.Dangerous {
+		err := m.confirm(ctx, Operation{
+			Kind:        OperationDangerous,
+			MigrationId: migration.Id,
+			Statements:  []string{migration.Script},
+			Description: fmt.Sprintf("migration %s is flagged as dangerous", migration.Id),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if drops := findDropStatements(migration.Script); len(drops) > 0 {
+		return m.confirm(ctx, Operation{
+			Kind:        OperationDrop,
+			MigrationId: migration.Id,
+			Statements:  drops,
+			Description: fmt.Sprintf("migration %s drops or truncates %d objects", migration.Id, len(drops)),
+		})
+	}
+	return nil
+}
+
+// confirmRevert asks for the confirmation of a revert
+func (m MigrationService) confirmRevert(ctx context.Context, migration Migration) error {
+	return m.confirm(ctx, Operation{
+		Kind:        OperationRevert,
+		MigrationId: migration.Id,
+		Statements:  []string{migration.RevertScript},
+		Description: fmt.Sprintf("migration %s will be reverted", migration.Id),
+	})
+}
@@ -0,0 +1,31 @@
+package migrago
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_findDropStatements(t *testing.T) {
+	script := "CREATE TABLE test2 (id INT);\ndrop table test;\nALTER TABLE test3 DROP COLUMN name;\n-- DROP TABLE comment\nTRUNCATE test4;"
+	assert.Equal(t, []string{"drop table test;", "ALTER TABLE test3 DROP COLUMN name;", "TRUNCATE test4;"}, findDropStatements(script))
+}
+
+func Test_confirmMigration(t *testing.T) {
+	var operations []Operation
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithConfirmFunc(func(ctx context.Context, op Operation) bool {
+		operations = append(operations, op)
+		return op.Kind != OperationDrop
+	}))
+
+	err := service.confirmMigration(context.Background(), Migration{Id: "Test", Script: "CREATE TABLE test (id INT)"})
+	assert.NoError(t, err)
+	assert.Empty(t, operations)
+
+	err = service.confirmMigration(context.Background(), Migration{Id: "Test", Script: "DROP TABLE test", Metadata: Metadata{Dangerous: true}})
+	assert.ErrorIs(t, err, ErrNotConfirmed)
+	assert.Len(t, operations, 2)
+	assert.Equal(t, OperationDangerous, operations[0].Kind)
+	assert.Equal(t, []string{"DROP TABLE test"}, operations[1].Statements)
+}
@@ -0,0 +1,125 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// poolConfig constrains the connection pool of a connection opened by the service
+type poolConfig struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+// defaultPoolConfig uses a single connection, migrations are executed one after another anyway
+var defaultPoolConfig = poolConfig{maxOpenConns: 1, maxIdleConns: 1}
+
+// NewMigrationServiceFromDSN opens the database connection itself and creates a MigrationService.
+// The connection pool is limited to one connection unless configured otherwise, Close closes it.
+// Without WithDialect the dialect is chosen by the driver name.
+func NewMigrationServiceFromDSN(driverName, dsn, configFile, scriptPath string, fs fs.FS, opts ...Option) (MigrationService, error) {
+	m := NewMigrationService(configFile, scriptPath, fs, nil, opts...)
+	if m.dialect == nil {
+		m.dialect = driverDialect(driverName)
+	}
+	if d, ok := m.dialect.(ConnectionDialect); ok {
+		var err error
+		if dsn, err = d.ConfigureDSN(dsn); err != nil {
+			return MigrationService{}, err
+		}
+	}
+	if m.tls != nil {
+		var err error
+		if dsn, err = applyTLS(driverName, dsn, *m.tls); err != nil {
+			return MigrationService{}, err
+		}
+	}
+	var connector driver.Connector
+	if m.tokens != nil {
+		if driverName != "postgres" {
+			return MigrationService{}, fmt.Errorf("token authentication is not supported for driver %s", driverName)
+		}
+		connector = &tokenConnector{dsn: dsn, tokens: &cachedTokenSource{source: m.tokens}}
+	} else {
+		var err error
+		if connector, err = openConnector(driverName, dsn); err != nil {
+			return MigrationService{}, fmt.Errorf("failed to open database: %w", err)
+		}
+	}
+	conn := sql.OpenDB(connector)
+	m.conn = conn
+	m.connector = connector
+	m.ownsConn = true
+	conn.SetMaxOpenConns(m.pool.maxOpenConns)
+	conn.SetMaxIdleConns(m.pool.maxIdleConns)
+	conn.SetConnMaxLifetime(m.pool.connMaxLifetime)
+	if d, ok := m.dialect.(SingleWriterDialect); ok && d.SingleWriter() {
+		conn.SetMaxOpenConns(1)
+	}
+	return m, nil
+}
+
+// openConnector returns a connector of the registered driver for the DSN like sql.Open
+func openConnector(driverName, dsn string) (driver.Connector, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	db.Close()
+	if dc, ok := d.(driver.DriverContext); ok {
+		return dc.OpenConnector(dsn)
+	}
+	return dsnConnector{dsn: dsn, driver: d}, nil
+}
+
+// dsnConnector opens connections of drivers without their own connector
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// driverDialect returns the dialect of a database/sql driver, Postgres for unknown drivers
+func driverDialect(driverName string) Dialect {
+	switch driverName {
+	case "mysql":
+		return MySQLDialect{}
+	case "sqlite", "sqlite3":
+		return SQLiteDialect{}
+	case "sqlserver", "mssql":
+		return SQLServerDialect{}
+	case "clickhouse":
+		return ClickHouseDialect{}
+	case "snowflake":
+		return SnowflakeDialect{}
+	case "bigquery":
+		return BigQueryDialect{}
+	case "spanner":
+		return SpannerDialect{}
+	case "duckdb":
+		return DuckDBDialect{}
+	default:
+		return PostgresDialect{}
+	}
+}
+
+// Close closes the database connection if it was opened by the service, connections passed in are left open
+func (m MigrationService) Close() error {
+	if !m.ownsConn {
+		return nil
+	}
+	return m.conn.Close()
+}
@@ -0,0 +1,40 @@
+package migrago
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewMigrationServiceFromDSN(t *testing.T) {
+	service, err := NewMigrationServiceFromDSN("postgres", "postgres://localhost/test", "config.json", "scripts", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, service.conn.Stats().MaxOpenConnections)
+	assert.NoError(t, service.Close())
+
+	service, err = NewMigrationServiceFromDSN("postgres", "postgres://localhost/test", "config.json", "scripts", nil,
+		WithMaxOpenConns(4), WithMaxIdleConns(2), WithConnMaxLifetime(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, service.conn.Stats().MaxOpenConnections)
+	assert.NoError(t, service.Close())
+
+	// Single writer dialects keep one connection
+	service, err = NewMigrationServiceFromDSN("postgres", "postgres://localhost/test", "config.json", "scripts", nil,
+		WithMaxOpenConns(4), WithDialect(DuckDBDialect{}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, service.conn.Stats().MaxOpenConnections)
+	assert.NoError(t, service.Close())
+
+	_, err = NewMigrationServiceFromDSN("unknown", "", "config.json", "scripts", nil)
+	assert.ErrorContains(t, err, "failed to open database")
+
+	// Connections passed in are not closed
+	db, err := sql.Open("postgres", "postgres://localhost/test")
+	assert.NoError(t, err)
+	assert.NoError(t, NewMigrationService("config.json", "scripts", nil, db).Close())
+	if err := db.Ping(); err != nil {
+		assert.NotContains(t, err.Error(), "database is closed")
+	}
+}
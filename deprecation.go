@@ -0,0 +1,232 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DeprecationSource is implemented by sources which deprecate migrations in favor of a replacement, e.g. the
+// FileSource with a config file of the form {"superseded": {"<deprecated id>": "<replacement id>"}}
+type DeprecationSource interface {
+	Superseded() (map[string]string, error)
+}
+
+// markSuperseded sets the replacements declared by the source on its deprecated migrations,
+// all replacements are qualified with the namespace
+func (s namedSource) markSuperseded(migrations []Migration) error {
+	superseded := make(map[string]string)
+	if ds, ok := s.source.(DeprecationSource); ok {
+		declared, err := ds.Superseded()
+		if err != nil {
+			return err
+		}
+		for deprecated, replacement := range declared {
+			superseded[s.qualifiedId(deprecated)] = replacement
+		}
+	}
+	for i, migration := range migrations {
+		if replacement, ok := superseded[migration.Id]; ok {
+			migrations[i].Metadata.SupersededBy = replacement
+		}
+		if replacement := migrations[i].Metadata.SupersededBy; replacement != "" {
+			migrations[i].Metadata.SupersededBy = s.qualifiedId(replacement)
+		}
+	}
+	return nil
+}
+
+// checkSuperseded checks that deprecated migrations are superseded by a configured migration which is not deprecated itself
+func checkSuperseded(migrations map[string]Migration) error {
+	for _, migration := range migrations {
+		replacement := migration.Metadata.SupersededBy
+		if replacement == "" {
+			continue
+		}
+		superseding, ok := migrations[replacement]
+		if !ok {
+			return fmt.Errorf("migration %s is superseded by unknown migration %s", migration.Id, replacement)
+		}
+		if superseding.Metadata.SupersededBy != "" {
+			return fmt.Errorf("migration %s is superseded by %s, which is deprecated itself", migration.Id, replacement)
+		}
+	}
+	return nil
+}
+
+// supersededBy groups the deprecated migrations by their replacement, in execution order
+func supersededBy(sourceMigrations [][]Migration) map[string][]Migration {
+	replaced := make(map[string][]Migration)
+	for _, migrations := range sourceMigrations {
+		for _, migration := range migrations {
+			if replacement := migration.Metadata.SupersededBy; replacement != "" {
+				replaced[replacement] = append(replaced[replacement], migration)
+			}
+		}
+	}
+	return replaced
+}
+
+// planSuperseded removes the deprecated migrations from the pending ones, they are never applied by fresh installs.
+// Pending replacements of applied deprecated migrations are returned separately, they are recorded without execution
+// because their schema already exists. A replacement of partially applied migrations can neither be applied nor recorded.
+func planSuperseded(sourceMigrations [][]Migration, pending []Migration) (remaining, recorded []Migration, err error) {
+	isPending := make(map[string]bool, len(pending))
+	for _, migration := range pending {
+		isPending[migration.Id] = true
+	}
+	replaced := supersededBy(sourceMigrations)
+	for _, migration := range pending {
+		if migration.Metadata.SupersededBy != "" {
+			continue
+		}
+		deprecated := replaced[migration.Id]
+		var missing []string
+		for _, d := range deprecated {
+			if isPending[d.Id] {
+				missing = append(missing, d.Id)
+			}
+		}
+		switch {
+		case len(deprecated) == 0 || len(missing) == len(deprecated):
+			remaining = append(remaining, migration)
+		case len(missing) == 0:
+			recorded = append(recorded, migration)
+		default:
+			return nil, nil, fmt.Errorf("migration %s can not replace partially applied deprecated migrations, not applied: %s",
+				migration.Id, strings.Join(missing, ", "))
+		}
+	}
+	return remaining, recorded, nil
+}
+
+// recordSuperseding records the replacements of applied deprecated migrations in the changelog without executing them
+func (m MigrationService) recordSuperseding(ctx context.Context, replacements []Migration) error {
+	for _, migration := range replacements {
+		tx, err := m.beginTx(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.insertChangelog(ctx, tx, migration); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		m.log().Info("replacement of applied deprecated migrations recorded without execution", "id", migration.Id)
+	}
+	return nil
+}
+
+// VerifyReplacements checks that every replacement creates the same schema objects as the deprecated migrations it
+// supersedes. Both are executed in scratch schemas of a transaction which is rolled back, so the scripts must not
+// qualify their objects with a schema or depend on objects of other migrations. All differences are reported as VerifyError.
+func (m MigrationService) VerifyReplacements(ctx context.Context) error {
+	sourceMigrations, migrations, err := m.getMigrations()
+	if err != nil {
+		return err
+	}
+	replaced := supersededBy(sourceMigrations)
+	replacements := make([]string, 0, len(replaced))
+	for id := range replaced {
+		replacements = append(replacements, id)
+	}
+	slices.Sort(replacements)
+
+	var problems []string
+	for _, id := range replacements {
+		differences, err := m.compareReplacement(ctx, migrations[id], replaced[id])
+		if err != nil {
+			return err
+		}
+		problems = append(problems, differences...)
+	}
+	if len(problems) > 0 {
+		return &VerifyError{Problems: problems}
+	}
+	return nil
+}
+
+// compareReplacement executes the deprecated migrations and their replacement in two scratch schemas and
+// returns the differences of the created objects
+func (m MigrationService) compareReplacement(ctx context.Context, replacement Migration, deprecated []Migration) ([]string, error) {
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	objects := make([][]string, 2)
+	for i, run := range [][]Migration{deprecated, {replacement}} {
+		schema := fmt.Sprintf("migrago_verify_%d", i)
+		if _, err := tx.ExecContext(ctx, `CREATE SCHEMA `+schema); err != nil {
+			return nil, fmt.Errorf("failed to create scratch schema: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `SET LOCAL search_path TO `+schema); err != nil {
+			return nil, err
+		}
+		for _, migration := range run {
+			if migration, err = migration.loadScripts(); err != nil {
+				return nil, err
+			}
+			err := m.scriptStatements(migration, func(statement string) error {
+				_, err := tx.ExecContext(ctx, statement)
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to execute migration %s in a scratch schema: %w", migration.Id, err)
+			}
+		}
+		if objects[i], err = schemaObjects(ctx, tx, schema); err != nil {
+			return nil, err
+		}
+	}
+
+	var differences []string
+	for _, object := range objects[0] {
+		if !slices.Contains(objects[1], object) {
+			differences = append(differences, fmt.Sprintf("replacement %s does not create %s", replacement.Id, object))
+		}
+	}
+	for _, object := range objects[1] {
+		if !slices.Contains(objects[0], object) {
+			differences = append(differences, fmt.Sprintf("replacement %s additionally creates %s", replacement.Id, object))
+		}
+	}
+	return differences, nil
+}
+
+// schemaObjects describes the columns, constraints, indexes, views and functions of a schema without the schema name
+func schemaObjects(ctx context.Context, tx *sql.Tx, schema string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT format('column %s.%s %s%s%s', table_name, column_name, data_type,
+			CASE WHEN is_nullable = 'NO' THEN ' not null' ELSE '' END, COALESCE(' default ' || column_default, ''))
+		FROM information_schema.columns WHERE table_schema = $1
+		UNION ALL
+		SELECT format('constraint %s on %s %s', c.conname, c.conrelid::regclass, pg_get_constraintdef(c.oid))
+		FROM pg_constraint c JOIN pg_namespace n ON n.oid = c.connamespace WHERE n.nspname = $1
+		UNION ALL
+		SELECT format('index %s', indexdef) FROM pg_indexes WHERE schemaname = $1
+		UNION ALL
+		SELECT format('view %s as %s', viewname, definition) FROM pg_views WHERE schemaname = $1
+		UNION ALL
+		SELECT format('function %s(%s) returns %s', p.proname, pg_get_function_identity_arguments(p.oid), pg_get_function_result(p.oid))
+		FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace WHERE n.nspname = $1
+		ORDER BY 1`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe scratch schema: %w", err)
+	}
+	defer rows.Close()
+	var objects []string
+	for rows.Next() {
+		var object string
+		if err := rows.Scan(&object); err != nil {
+			return nil, err
+		}
+		objects = append(objects, strings.ReplaceAll(object, schema+".", ""))
+	}
+	return objects, rows.Err()
+}
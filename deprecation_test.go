@@ -0,0 +1,53 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkSuperseded(t *testing.T) {
+	migrations := map[string]Migration{
+		"0001_init":   {Id: "0001_init", Metadata: Metadata{SupersededBy: "0003_squash"}},
+		"0002_users":  {Id: "0002_users", Metadata: Metadata{SupersededBy: "0003_squash"}},
+		"0003_squash": {Id: "0003_squash"},
+	}
+	assert.NoError(t, checkSuperseded(migrations))
+
+	migrations["0002_users"] = Migration{Id: "0002_users", Metadata: Metadata{SupersededBy: "0004_typo"}}
+	assert.EqualError(t, checkSuperseded(migrations), "migration 0002_users is superseded by unknown migration 0004_typo")
+
+	migrations["0002_users"] = Migration{Id: "0002_users", Metadata: Metadata{SupersededBy: "0001_init"}}
+	assert.EqualError(t, checkSuperseded(migrations), "migration 0002_users is superseded by 0001_init, which is deprecated itself")
+}
+
+func Test_planSuperseded(t *testing.T) {
+	sourceMigrations := [][]Migration{{
+		{Id: "0001_init", Metadata: Metadata{SupersededBy: "0003_squash"}},
+		{Id: "0002_users", Metadata: Metadata{SupersededBy: "0003_squash"}},
+		{Id: "0003_squash"},
+		{Id: "0004_teams"},
+	}}
+	ids := func(migrations []Migration) []string {
+		var ids []string
+		for _, migration := range migrations {
+			ids = append(ids, migration.Id)
+		}
+		return ids
+	}
+
+	// Fresh install
+	remaining, recorded, err := planSuperseded(sourceMigrations, sourceMigrations[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0003_squash", "0004_teams"}, ids(remaining))
+	assert.Empty(t, recorded)
+
+	// The deprecated migrations are applied
+	remaining, recorded, err = planSuperseded(sourceMigrations, sourceMigrations[0][2:])
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0004_teams"}, ids(remaining))
+	assert.Equal(t, []string{"0003_squash"}, ids(recorded))
+
+	_, _, err = planSuperseded(sourceMigrations, sourceMigrations[0][1:])
+	assert.EqualError(t, err, "migration 0003_squash can not replace partially applied deprecated migrations, not applied: 0002_users")
+}
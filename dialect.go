@@ -0,0 +1,347 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ColumnType is a type of the columns of the changelog tables, it is mapped to a database type by the Dialect
+type ColumnType int
+
+const (
+	// ColumnString is a short text such as an ID or a checksum
+	ColumnString ColumnType = iota
+	// ColumnText is a text of any length such as a revert script
+	ColumnText
+	// ColumnInteger is a 64 bit integer
+	ColumnInteger
+	// ColumnTimestamp is a point in time, it is read and written in UTC
+	ColumnTimestamp
+	// ColumnCreatedAt is a not null timestamp which defaults to the time of the insert
+	ColumnCreatedAt
+	// ColumnFlag is a not null boolean which defaults to false
+	ColumnFlag
+	// ColumnSequence is a not null integer which is incremented on every insert, it orders the changelog
+	ColumnSequence
+	// ColumnLSN is a position in the write-ahead log of the database
+	ColumnLSN
+)
+
+// Dialect adapts the changelog tables and the statements of the service to a database system. PostgresDialect
+// is used by default, WithDialect configures another one.
+type Dialect interface {
+	// Name identifies the database system, it selects the dialect specific scripts <id>.<name>.sql and the
+	// version requirements of the migrations
+	Name() string
+	// Placeholder returns the bind parameter with the 1-based index n, e.g. $1 or ?
+	Placeholder(n int) string
+	// ColumnType returns the column definition of a column type of the changelog tables
+	ColumnType(t ColumnType) string
+	// ChangelogDDL returns the statements which create the changelog tables or upgrade them to the current version,
+	// they are executed before every run and have to be idempotent
+	ChangelogDDL() []string
+	// TableExistsQuery returns a query for a single boolean whether the table exists in the current schema
+	TableExistsQuery(table string) string
+	// ColumnsQuery returns a query for the lower-case names of the columns of the table in the current schema
+	ColumnsQuery(table string) string
+	// InsertIgnore returns a statement which inserts the columns into the table and does nothing if a row with the
+	// same primary key exists. The first column is the primary key, the values are bound in the order of the columns.
+	InsertIgnore(table string, columns ...string) string
+	// SessionSettingStatement returns a statement with the bind parameters name and value which changes a setting for
+	// the current transaction, empty if the database has no such settings
+	SessionSettingStatement() string
+	// LockStatement is executed first in every migration transaction to serialize concurrent runs until the
+	// transaction ends, empty if inserting the same ID into the changelog blocks concurrent runs anyway
+	LockStatement() string
+	// NonTransactional reports whether a statement of a migration, without its comments, can not be part of a
+	// transaction, e.g. because it commits it implicitly. Migrations with such statements are executed statement by
+	// statement and recorded after the last one.
+	NonTransactional(statement string) bool
+	// VersionQuery returns a query for the version of the server as text, e.g. 15.4 or 8.0.35-log
+	VersionQuery() string
+}
+
+// BatchDialect is implemented by dialects whose scripts are split into batches at separator lines, e.g. GO of
+// SQL Server, instead of into statements at semicolons
+type BatchDialect interface {
+	Dialect
+	// BatchSeparator returns the separator, it is matched case-insensitively against whole lines
+	BatchSeparator() string
+}
+
+// SplitRulesDialect is implemented by dialects whose scripts are lexed differently from Postgres scripts when they
+// are split into statements, e.g. with backslash escapes and # comments on MySQL
+type SplitRulesDialect interface {
+	Dialect
+	SplitRules() SplitRules
+}
+
+// StatementRewriter is implemented by dialects which can not execute some of the statements of the service as they
+// are written, e.g. UPDATE. Rewrite is applied to every changelog statement before its placeholders are replaced.
+type StatementRewriter interface {
+	Dialect
+	Rewrite(statement string) string
+}
+
+// ConnectionDialect is implemented by dialects which configure the connection, NewMigrationServiceFromDSN passes
+// the DSN through ConfigureDSN before it is opened
+type ConnectionDialect interface {
+	Dialect
+	ConfigureDSN(dsn string) (string, error)
+}
+
+// SingleWriterDialect is implemented by dialects of embedded databases which execute one write transaction at a
+// time, connections opened by NewMigrationServiceFromDSN are limited to one open connection if SingleWriter is true
+type SingleWriterDialect interface {
+	Dialect
+	SingleWriter() bool
+}
+
+// RowsAffectedDialect is implemented by dialects whose drivers do not report the affected rows of a statement,
+// e.g. ClickHouse. Whether InsertIgnore inserted a row is checked by counting the rows with its primary key before and
+// after the insert if ReportsRowsAffected is false.
+type RowsAffectedDialect interface {
+	Dialect
+	ReportsRowsAffected() bool
+}
+
+// changelogColumn is a column of a changelog table
+type changelogColumn struct {
+	name    string
+	typ     ColumnType
+	notNull bool
+}
+
+// changelogTable describes a changelog table of the current version, the first column is the primary key
+type changelogTable struct {
+	name    string
+	columns []changelogColumn
+}
+
+// changelogTables are the changelog tables of the current version
+var changelogTables = []changelogTable{
+	{name: "changelog", columns: []changelogColumn{
+		{name: "id", typ: ColumnString},
+		{name: "checksum", typ: ColumnString, notNull: true},
+		{name: "installedAt", typ: ColumnCreatedAt},
+		{name: "revertscript", typ: ColumnText},
+		{name: "irreversible", typ: ColumnFlag},
+		{name: "sequence", typ: ColumnSequence},
+		{name: "lsnBefore", typ: ColumnLSN},
+		{name: "lsnAfter", typ: ColumnLSN},
+		{name: "durationMs", typ: ColumnInteger},
+		{name: "description", typ: ColumnText},
+	}},
+	{name: "changelog_run", columns: []changelogColumn{
+		{name: "id", typ: ColumnString},
+		{name: "sourceRevision", typ: ColumnString},
+		{name: "startedAt", typ: ColumnCreatedAt},
+		{name: "finishedAt", typ: ColumnTimestamp},
+		{name: "error", typ: ColumnText},
+		{name: "heartbeatAt", typ: ColumnTimestamp},
+	}},
+	{name: "changelog_archive", columns: []changelogColumn{
+		{name: "id", typ: ColumnString},
+		{name: "checksum", typ: ColumnString, notNull: true},
+		{name: "installedAt", typ: ColumnTimestamp, notNull: true},
+		{name: "revertscript", typ: ColumnText},
+		{name: "irreversible", typ: ColumnFlag},
+		{name: "sequence", typ: ColumnInteger, notNull: true},
+		{name: "archivedAt", typ: ColumnCreatedAt},
+	}},
+	// Checkpoints of running backfills, the last processed key is stored as text
+	{name: "changelog_backfill", columns: []changelogColumn{
+		{name: "id", typ: ColumnString},
+		{name: "checksum", typ: ColumnString, notNull: true},
+		{name: "lastKey", typ: ColumnText},
+		{name: "batches", typ: ColumnInteger, notNull: true},
+		{name: "rowsAffected", typ: ColumnInteger, notNull: true},
+		{name: "updatedAt", typ: ColumnCreatedAt},
+	}},
+	// Async migrations scheduled by ExecuteMigration for RunAsyncJobs
+	{name: "changelog_job", columns: []changelogColumn{
+		{name: "id", typ: ColumnString},
+		{name: "scheduledAt", typ: ColumnCreatedAt},
+		{name: "startedAt", typ: ColumnTimestamp},
+		{name: "finishedAt", typ: ColumnTimestamp},
+		{name: "error", typ: ColumnText},
+		{name: "heartbeatAt", typ: ColumnTimestamp},
+	}},
+	// Statements executed by migrations without transaction, so a failed migration resumes after them
+	{name: "changelog_progress", columns: []changelogColumn{
+		{name: "id", typ: ColumnString},
+		{name: "checksum", typ: ColumnString, notNull: true},
+		{name: "statements", typ: ColumnInteger, notNull: true},
+		{name: "updatedAt", typ: ColumnCreatedAt},
+	}},
+	// Named checkpoints of the changelog, e.g. releases, sequence is the last applied migration at tagging time
+	{name: "changelog_tag", columns: []changelogColumn{
+		{name: "name", typ: ColumnString},
+		{name: "sequence", typ: ColumnInteger, notNull: true},
+		{name: "createdAt", typ: ColumnCreatedAt},
+	}},
+}
+
+// createChangelogTables renders the CREATE TABLE statements of the changelog tables with the column types of the dialect
+func createChangelogTables(d Dialect) []string {
+	statements := make([]string, 0, len(changelogTables))
+	for _, table := range changelogTables {
+		var b strings.Builder
+		fmt.Fprintf(&b, "CREATE TABLE IF NOT EXISTS %s (", table.name)
+		for i, column := range table.columns {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "\n\t%s %s", column.name, d.ColumnType(column.typ))
+			if column.notNull {
+				b.WriteString(" NOT NULL")
+			}
+		}
+		fmt.Fprintf(&b, ",\n\tPRIMARY KEY (%s)\n)", table.columns[0].name)
+		statements = append(statements, b.String())
+	}
+	return statements
+}
+
+// nonTransactional reports whether a statement of the migration can not be executed in its transaction. Streamed
+// scripts are not inspected, they are usually data loads; mark them with "-- migrago:no-transaction" otherwise.
+func (m MigrationService) nonTransactional(migration Migration) bool {
+	if migration.OpenScript != nil {
+		return false
+	}
+	d := m.sqlDialect()
+	hashComments := m.splitRules().HashComments
+	var found bool
+	m.splitScript(strings.NewReader(migration.Script), func(statement string) error {
+		found = found || d.NonTransactional(stripComments(statement, hashComments))
+		return nil
+	})
+	return found
+}
+
+// splitScript calls fn for every part of a script which is executed at once, the batches of a BatchDialect and the
+// statements otherwise
+func (m MigrationService) splitScript(r io.Reader, fn func(statement string) error) error {
+	if d, ok := m.sqlDialect().(BatchDialect); ok {
+		return splitBatches(r, d.BatchSeparator(), fn)
+	}
+	return splitStatements(r, m.splitRules(), fn)
+}
+
+// splitRules returns the rules of a SplitRulesDialect, the Postgres rules otherwise
+func (m MigrationService) splitRules() SplitRules {
+	if d, ok := m.sqlDialect().(SplitRulesDialect); ok {
+		return d.SplitRules()
+	}
+	return SplitRules{}
+}
+
+// splitRevertScript calls fn with the whole revert script on Postgres, other drivers execute a single statement or
+// batch per call
+func (m MigrationService) splitRevertScript(script string, fn func(script string) error) error {
+	if m.postgres() {
+		return fn(script)
+	}
+	return m.splitScript(strings.NewReader(script), fn)
+}
+
+// firstKeyword returns the upper-case first word of a statement
+func firstKeyword(statement string) string {
+	fields := strings.Fields(statement)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
+
+// placeholderPattern matches the Postgres style bind parameters the statements of the service are written with
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+
+// sqlDialect returns the configured dialect, Postgres by default
+func (m MigrationService) sqlDialect() Dialect {
+	if m.dialect == nil {
+		return PostgresDialect{}
+	}
+	return m.dialect
+}
+
+// postgres reports whether the dialect is Postgres, the server checks such as the WAL position, the backend
+// cancellation and the replica detection are only available there
+func (m MigrationService) postgres() bool {
+	return m.sqlDialect().Name() == "postgres"
+}
+
+// postgresCompatible reports whether the dialect executes the Postgres statements of backfills and async jobs,
+// e.g. ON CONFLICT and FOR UPDATE SKIP LOCKED
+func (m MigrationService) postgresCompatible() bool {
+	name := m.dialectName()
+	return name == "postgres" || name == "cockroachdb"
+}
+
+// rebind replaces the bind parameters $1, $2, ... of a statement with the placeholders of the dialect, statements
+// are rewritten first by a StatementRewriter. Placeholders without an index like ? are bound by position, so the
+// arguments are returned in the order of the placeholders, repeated ones included.
+func (m MigrationService) rebind(query string, args ...any) (string, []any) {
+	d := m.sqlDialect()
+	if rewriter, ok := d.(StatementRewriter); ok {
+		query = rewriter.Rewrite(query)
+	}
+	if d.Placeholder(1) == "$1" {
+		return query, args
+	}
+	positional := d.Placeholder(1) == d.Placeholder(2)
+	var bound []any
+	query = placeholderPattern.ReplaceAllStringFunc(query, func(placeholder string) string {
+		n, _ := strconv.Atoi(placeholder[1:])
+		if positional && n >= 1 && n <= len(args) {
+			bound = append(bound, args[n-1])
+		}
+		return d.Placeholder(n)
+	})
+	if !positional {
+		return query, args
+	}
+	return query, bound
+}
+
+// execQuerier executes statements and queries single rows, it is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type execQuerier interface {
+	execer
+	querier
+}
+
+// insertIgnore executes the InsertIgnore statement of the dialect and reports whether it inserted the row, the first
+// argument is the primary key. Without affected rows the rows with the key are counted before and after the insert,
+// a concurrent insert of the same key reports false as well.
+func (m MigrationService) insertIgnore(ctx context.Context, db execQuerier, table string, columns []string, args ...any) (bool, error) {
+	d := m.sqlDialect()
+	insert := d.InsertIgnore(table, columns...)
+	if rd, ok := d.(RowsAffectedDialect); !ok || rd.ReportsRowsAffected() {
+		result, err := db.ExecContext(ctx, insert, args...)
+		if err != nil {
+			return false, err
+		}
+		inserted, err := result.RowsAffected()
+		return inserted > 0, err
+	}
+
+	count, _ := m.rebind(fmt.Sprintf(`SELECT count(*) FROM %s WHERE %s = $1`, table, columns[0]))
+	var before, after int64
+	if err := db.QueryRowContext(ctx, count, args[0]).Scan(&before); err != nil {
+		return false, err
+	}
+	if before > 0 {
+		return false, nil
+	}
+	if _, err := db.ExecContext(ctx, insert, args...); err != nil {
+		return false, err
+	}
+	if err := db.QueryRowContext(ctx, count, args[0]).Scan(&after); err != nil {
+		return false, err
+	}
+	return after == 1, nil
+}
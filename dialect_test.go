@@ -0,0 +1,85 @@
+package migrago
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// questionMarkDialect is a Postgres dialect with positional ? placeholders
+type questionMarkDialect struct {
+	PostgresDialect
+}
+
+func (questionMarkDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// unreportedRowsDialect is a SQLite dialect whose driver reports no affected rows like the one of ClickHouse
+type unreportedRowsDialect struct {
+	SQLiteDialect
+}
+
+func (unreportedRowsDialect) ReportsRowsAffected() bool {
+	return false
+}
+
+func Test_rebind(t *testing.T) {
+	query := `UPDATE changelog SET id = $2 WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM changelog WHERE id = $2)`
+	t.Run("Test numbered placeholders", func(t *testing.T) {
+		rebound, args := MigrationService{}.rebind(query, "previous", "current")
+		assert.Equal(t, query, rebound)
+		assert.Equal(t, []any{"previous", "current"}, args)
+
+		rebound, args = NewMigrationService("config.json", "scripts", nil, nil, WithDialect(SQLServerDialect{})).rebind(query, "previous", "current")
+		assert.Equal(t, `UPDATE changelog SET id = @p2 WHERE id = @p1 AND NOT EXISTS (SELECT 1 FROM changelog WHERE id = @p2)`, rebound)
+		assert.Equal(t, []any{"previous", "current"}, args)
+	})
+	t.Run("Test positional placeholders", func(t *testing.T) {
+		rebound, args := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(questionMarkDialect{})).rebind(query, "previous", "current")
+		assert.Equal(t, `UPDATE changelog SET id = ? WHERE id = ? AND NOT EXISTS (SELECT 1 FROM changelog WHERE id = ?)`, rebound)
+		assert.Equal(t, []any{"current", "previous", "current"}, args)
+	})
+}
+
+func Test_PostgresDialect(t *testing.T) {
+	d := PostgresDialect{}
+	assert.Equal(t, "postgres", MigrationService{}.dialectName())
+	assert.Equal(t, `INSERT INTO changelog_job (id, scheduledAt) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`, d.InsertIgnore("changelog_job", "id", "scheduledAt"))
+}
+
+func Test_createChangelogTables(t *testing.T) {
+	statements := createChangelogTables(PostgresDialect{})
+	assert.Len(t, statements, len(changelogTables))
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS changelog_tag (
+	name VARCHAR(255),
+	sequence BIGINT NOT NULL,
+	createdAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (name)
+)`, statements[len(statements)-1])
+}
+
+func Test_insertIgnore(t *testing.T) {
+	ctx := context.Background()
+	service, err := NewMigrationServiceFromDSN("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"), "config.json", "scripts", nil,
+		WithDialect(unreportedRowsDialect{}))
+	assert.NoError(t, err)
+	defer service.Close()
+	assert.NoError(t, service.prepareDatabase(ctx))
+
+	inserted, err := service.insertIgnore(ctx, service.conn, "changelog_job", []string{"id"}, "Test")
+	assert.NoError(t, err)
+	assert.True(t, inserted)
+	inserted, err = service.insertIgnore(ctx, service.conn, "changelog_job", []string{"id"}, "Test")
+	assert.NoError(t, err)
+	assert.False(t, inserted)
+
+	tx, err := service.conn.BeginTx(ctx, nil)
+	assert.NoError(t, err)
+	defer tx.Rollback()
+	migration := Migration{Id: "Test", Checksum: "9c23564a026f0826f2a05b8423aa21f9", RevertScript: "DROP TABLE test"}
+	assert.NoError(t, service.insertChangelog(ctx, tx, migration))
+	assert.ErrorIs(t, service.insertChangelog(ctx, tx, migration), ErrAppliedConcurrently)
+}
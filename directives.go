@@ -0,0 +1,171 @@
+package migrago
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// directivePrefix marks a comment line in a script as directive for migrago, e.g. "-- migrago:irreversible"
+const directivePrefix = "-- migrago:"
+
+// applyDirectives parses the directives of the script and stores them in the metadata of the migration
+func (mig *Migration) applyDirectives() error {
+	if mig.lazy != nil {
+		// The directives of a manifest entry were collected when the manifest was generated
+		if mig.Metadata.Description == "" {
+			mig.Metadata.Description = mig.lazy.entry.Description
+		}
+		for _, line := range mig.lazy.entry.Directives {
+			if err := mig.applyDirective(line); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if mig.OpenScript == nil {
+		return mig.parseDirectives(strings.NewReader(mig.Script))
+	}
+	r, err := mig.OpenScript()
+	if err != nil {
+		return fmt.Errorf("failed to open script of migration %s: %w", mig.Id, err)
+	}
+	defer r.Close()
+	return mig.parseDirectives(r)
+}
+
+// parseDirectives reads the script line by line and applies the directives, the leading comment
+// is used as description unless the metadata already contains one
+func (mig *Migration) parseDirectives(r io.Reader) error {
+	description := leadingComment(func(text string) {
+		if mig.Metadata.Description == "" {
+			mig.Metadata.Description = text
+		}
+	})
+	return scanDirectives(r, func(line string) error {
+		description(line)
+		return mig.applyDirective(line)
+	})
+}
+
+// directiveLines returns the directive lines and the description of a script
+func directiveLines(r io.Reader) (lines []string, description string, err error) {
+	leading := leadingComment(func(text string) { description = text })
+	err = scanDirectives(r, func(line string) error {
+		leading(line)
+		if line = strings.TrimSpace(line); strings.HasPrefix(line, directivePrefix) {
+			lines = append(lines, line)
+		}
+		return nil
+	})
+	return lines, description, err
+}
+
+// leadingComment returns a line handler which passes the text of the first comment line before the first statement
+// to set, e.g. "add index for order lookups" for "-- add index for order lookups". Blank lines and directives are skipped.
+func leadingComment(set func(text string)) func(line string) {
+	done := false
+	return func(line string) {
+		line = strings.TrimSpace(line)
+		if done || line == "" || strings.HasPrefix(line, directivePrefix) {
+			return
+		}
+		text, ok := strings.CutPrefix(line, "--")
+		if text = strings.TrimSpace(text); ok && text == "" {
+			return
+		}
+		done = true
+		if ok {
+			set(text)
+		}
+	}
+}
+
+// scanDirectives calls fn for every line of the script, lines longer than the buffer can not be directives and are skipped
+func scanDirectives(r io.Reader, fn func(line string) error) error {
+	reader := bufio.NewReaderSize(r, 4096)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = reader.ReadSlice('\n')
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if fnErr := fn(string(line)); fnErr != nil {
+			return fnErr
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
+
+// applyDirective applies a single line of a script if it is a directive
+func (mig *Migration) applyDirective(line string) error {
+	directive, ok := strings.CutPrefix(strings.TrimSpace(line), directivePrefix)
+	if !ok {
+		return nil
+	}
+	name, args, _ := strings.Cut(strings.TrimSpace(directive), " ")
+	switch name {
+	case "irreversible":
+		mig.Metadata.Irreversible = true
+	case "dangerous":
+		mig.Metadata.Dangerous = true
+	case "async":
+		mig.Metadata.Async = true
+	case "heavy":
+		mig.Metadata.Heavy = true
+	case "no-transaction":
+		mig.Metadata.NoTransaction = true
+	case "online-ddl":
+		mig.Metadata.OnlineDDL = true
+	case "priority":
+		priority, err := parsePriority(args)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", mig.Id, err)
+		}
+		mig.Metadata.Priority = priority
+	case "requires":
+		mig.Metadata.Requires = append(mig.Metadata.Requires, strings.TrimSpace(args))
+	case "refresh":
+		view, err := parseRefresh(args)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", mig.Id, err)
+		}
+		mig.Metadata.Refresh = append(mig.Metadata.Refresh, view)
+	case "citus-workers":
+		mig.Metadata.Citus.Workers = true
+	case "citus-distribute":
+		table, err := parseDistributedTable(args)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", mig.Id, err)
+		}
+		mig.Metadata.Citus.Distribute = append(mig.Metadata.Citus.Distribute, table)
+	case "citus-reference":
+		mig.Metadata.Citus.Reference = append(mig.Metadata.Citus.Reference, strings.Fields(args)...)
+	case "citus-colocated":
+		mig.Metadata.Citus.Colocated = append(mig.Metadata.Citus.Colocated, strings.Split(strings.Join(strings.Fields(args), ""), ",")...)
+	case "assert":
+		assertion, err := parseAssertion(args)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", mig.Id, err)
+		}
+		mig.Metadata.Assert = append(mig.Metadata.Assert, assertion)
+	case "backfill":
+		backfill, err := parseBackfill(args)
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", mig.Id, err)
+		}
+		mig.Metadata.Backfill = backfill
+	default:
+		return fmt.Errorf("unknown directive %q in migration %s", name, mig.Id)
+	}
+	return nil
+}
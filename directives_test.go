@@ -0,0 +1,51 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyDirectives(t *testing.T) {
+	migration := Migration{Id: "Test", Script: "-- migrago:irreversible\nDELETE FROM test"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.True(t, migration.Metadata.Irreversible)
+
+	migration = Migration{Id: "Test", Script: "DELETE FROM test"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.False(t, migration.Metadata.Irreversible)
+
+	migration = Migration{Id: "Test", Script: "-- migrago:backfill table=test key=id\nUPDATE test SET name = upper(name) WHERE id BETWEEN $1 AND $2"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.Equal(t, &Backfill{Table: "test", Key: "id"}, migration.Metadata.Backfill)
+
+	migration = Migration{Id: "Test", Script: "-- migrago:async\nUPDATE test SET name = upper(name)"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.True(t, migration.Metadata.Async)
+
+	migration = Migration{Id: "Test", Script: "-- migrago:heavy\nALTER TABLE test ALTER COLUMN id TYPE BIGINT"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.True(t, migration.Metadata.Heavy)
+
+	migration = Migration{Id: "Test", Script: "-- migrago:no-transaction\nCREATE INDEX CONCURRENTLY test_name ON test (name)"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.True(t, migration.Metadata.NoTransaction)
+
+	migration = Migration{Id: "Test", Script: "-- migrago:unknown\nDELETE FROM test"}
+	assert.ErrorContains(t, migration.applyDirectives(), `unknown directive "unknown"`)
+}
+
+func Test_leadingCommentDescription(t *testing.T) {
+	migration := Migration{Id: "Test", Script: "-- migrago:heavy\n\n--\n-- add index for order lookups\n-- second line\nCREATE INDEX orders_customer ON orders (customer)"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.Equal(t, "add index for order lookups", migration.Metadata.Description)
+	assert.True(t, migration.Metadata.Heavy)
+
+	migration = Migration{Id: "Test", Script: "CREATE TABLE test ()\n-- not a description"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.Empty(t, migration.Metadata.Description)
+
+	migration = Migration{Id: "Test", Script: "-- from the script\nCREATE TABLE test ()", Metadata: Metadata{Description: "from metadata.yaml"}}
+	assert.NoError(t, migration.applyDirectives())
+	assert.Equal(t, "from metadata.yaml", migration.Metadata.Description)
+}
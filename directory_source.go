@@ -0,0 +1,138 @@
+package migrago
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DirectorySource is a Source where every migration is a directory containing up.sql, down.sql
+// and an optional metadata.yaml. The directory name is the migration ID. Dialect specific
+// variants are stored as up.<dialect>.sql and down.<dialect>.sql instead.
+type DirectorySource struct {
+	configFile string
+	scriptPath string
+	fs         fs.FS
+}
+
+// DirectorySource constructor. If configFile is empty, all directories
+// below scriptPath are migrations and executed in lexical order.
+func NewDirectorySource(configFile, scriptPath string, fs fs.FS) DirectorySource {
+	return DirectorySource{
+		configFile: configFile,
+		scriptPath: scriptPath,
+		fs:         fs,
+	}
+}
+
+// List returns the migration IDs from the config file or the directory names
+func (s DirectorySource) List() ([]string, error) {
+	if s.configFile != "" {
+		return NewFileSource(s.configFile, s.scriptPath, s.fs).List()
+	}
+
+	entries, err := fs.ReadDir(s.fs, s.scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration directory: %w", err)
+	}
+	var migrationIds []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			migrationIds = append(migrationIds, entry.Name())
+		}
+	}
+	slices.Sort(migrationIds)
+	return migrationIds, nil
+}
+
+// Load reads the scripts and metadata of a migration directory
+func (s DirectorySource) Load(migrationId string) (Migration, error) {
+	dir := path.Join(s.scriptPath, migrationId)
+	metadata, err := readMetadata(s.fs, path.Join(dir, "metadata.yaml"))
+	if err != nil {
+		return Migration{}, err
+	}
+
+	script, checksum, err := readScript(s.fs, path.Join(dir, "up.sql"))
+	if errors.Is(err, fs.ErrNotExist) {
+		variants, err := s.loadVariants(dir)
+		if err != nil {
+			return Migration{}, err
+		}
+		return Migration{Id: migrationId, Variants: variants, Metadata: metadata}, nil
+	}
+	if err != nil {
+		return Migration{}, err
+	}
+
+	revertScript, noRevertScript, err := readRevertScript(s.fs, path.Join(dir, "down.sql"))
+	if err != nil {
+		return Migration{}, err
+	}
+
+	return Migration{
+		Id:             migrationId,
+		Script:         script,
+		RevertScript:   revertScript,
+		NoRevertScript: noRevertScript,
+		Checksum:       checksum,
+		Metadata:       metadata,
+	}, nil
+}
+
+// loadVariants loads all dialect specific scripts up.<dialect>.sql and down.<dialect>.sql of a migration directory
+func (s DirectorySource) loadVariants(dir string) (map[string]ScriptVariant, error) {
+	entries, err := fs.ReadDir(s.fs, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration directory: %w", err)
+	}
+
+	variants := make(map[string]ScriptVariant)
+	for _, entry := range entries {
+		dialect, ok := strings.CutPrefix(entry.Name(), "up.")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if dialect, ok = strings.CutSuffix(dialect, ".sql"); !ok || strings.Contains(dialect, ".") {
+			continue
+		}
+
+		var variant ScriptVariant
+		if variant.Script, variant.Checksum, err = readScript(s.fs, path.Join(dir, entry.Name())); err != nil {
+			return nil, err
+		}
+		if variant.RevertScript, variant.NoRevertScript, err = readRevertScript(s.fs, path.Join(dir, "down."+dialect+".sql")); err != nil {
+			return nil, err
+		}
+		variants[dialect] = variant
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no script found in migration directory %s", dir)
+	}
+	return variants, nil
+}
+
+// readMetadata reads an optional metadata file, unknown fields are rejected to catch typos
+func readMetadata(fsys fs.FS, name string) (Metadata, error) {
+	var metadata Metadata
+	f, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return metadata, nil
+	}
+	if err != nil {
+		return metadata, fmt.Errorf("failed to open metadata file %s: %w", name, err)
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&metadata); err != nil {
+		return metadata, fmt.Errorf("failed to decode metadata file %s: %w", name, err)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,38 @@
+package migrago
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DirectorySource(t *testing.T) {
+	fs := fstest.MapFS{
+		"migrations/002_orders/up.sql":        {Data: []byte("CREATE TABLE orders (id INT)")},
+		"migrations/002_orders/down.sql":      {Data: []byte("DROP TABLE orders")},
+		"migrations/001_users/up.sql":         {Data: []byte("CREATE TABLE users (id INT)")},
+		"migrations/001_users/down.sql":       {Data: []byte("DROP TABLE users")},
+		"migrations/001_users/metadata.yaml":  {Data: []byte("description: create users table\n")},
+		"migrations/003_broken/up.sql":        {Data: []byte("SELECT 1")},
+		"migrations/003_broken/down.sql":      {Data: []byte("SELECT 1")},
+		"migrations/003_broken/metadata.yaml": {Data: []byte("descripton: typo\n")},
+	}
+	source := NewDirectorySource("", "migrations", fs)
+
+	ids, err := source.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"001_users", "002_orders", "003_broken"}, ids)
+
+	migration, err := source.Load("001_users")
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE users", migration.RevertScript)
+	assert.Equal(t, "create users table", migration.Metadata.Description)
+
+	migration, err = source.Load("002_orders")
+	assert.NoError(t, err)
+	assert.Equal(t, Metadata{}, migration.Metadata)
+
+	_, err = source.Load("003_broken")
+	assert.ErrorContains(t, err, "failed to decode metadata file")
+}
@@ -0,0 +1,85 @@
+package migrago
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// duckDBNonTransactionalKeywords start the statements DuckDB refuses or ignores inside of a transaction
+var duckDBNonTransactionalKeywords = []string{"CHECKPOINT", "FORCE", "VACUUM", "ATTACH", "DETACH"}
+
+// DuckDBDialect is the dialect of DuckDB, e.g. with the driver github.com/marcboeker/go-duckdb. A database file is
+// opened for writing by a single process, concurrent transactions of its connections conflict instead of waiting,
+// so connections opened by NewMigrationServiceFromDSN are limited to one.
+type DuckDBDialect struct{}
+
+func (DuckDBDialect) Name() string {
+	return "duckdb"
+}
+
+func (DuckDBDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (DuckDBDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText, ColumnLSN:
+		return "VARCHAR"
+	case ColumnInteger:
+		return "BIGINT"
+	case ColumnTimestamp:
+		return "TIMESTAMPTZ"
+	case ColumnCreatedAt:
+		return "TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	case ColumnFlag:
+		return "BOOLEAN NOT NULL DEFAULT false"
+	case ColumnSequence:
+		return "BIGINT NOT NULL DEFAULT nextval('changelog_sequence')"
+	default:
+		return "VARCHAR(255)"
+	}
+}
+
+// ChangelogDDL creates the sequence of the changelog before the changelog tables
+func (d DuckDBDialect) ChangelogDDL() []string {
+	return append([]string{`CREATE SEQUENCE IF NOT EXISTS changelog_sequence`}, createChangelogTables(d)...)
+}
+
+func (DuckDBDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = '%s'`, table)
+}
+
+// ColumnsQuery lowers the column names, DuckDB keeps the case of unquoted identifiers
+func (DuckDBDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT LOWER(column_name) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = '%s'`, table)
+}
+
+func (d DuckDBDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO NOTHING`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)), columns[0])
+}
+
+func (DuckDBDialect) SessionSettingStatement() string {
+	return ""
+}
+
+// LockStatement is empty, the single connection serializes the runs of the process
+func (DuckDBDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for checkpoints, VACUUM and attaching databases, DDL is transactional in DuckDB
+func (DuckDBDialect) NonTransactional(statement string) bool {
+	return slices.Contains(duckDBNonTransactionalKeywords, firstKeyword(statement))
+}
+
+// VersionQuery strips the v of the version, e.g. v1.1.3
+func (DuckDBDialect) VersionQuery() string {
+	return `SELECT ltrim(version(), 'v')`
+}
+
+// SingleWriter is true, DuckDB executes one write transaction at a time
+func (DuckDBDialect) SingleWriter() bool {
+	return true
+}
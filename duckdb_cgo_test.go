@@ -0,0 +1,59 @@
+//go:build cgo
+
+package migrago
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb"
+	"github.com/stretchr/testify/assert"
+)
+
+// The DuckDB driver needs CGO, the tests run in-process like the ones of SQLite
+func Test_DuckDB(t *testing.T) {
+	t.Run("Test migrations are applied and reverted with the DuckDB dialect", func(t *testing.T) {
+		ctx := context.Background()
+		dsn := filepath.Join(t.TempDir(), "test.duckdb")
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INTEGER PRIMARY KEY);", RevertScript: "DROP TABLE users;"},
+			{Id: "0002_orders", Script: "CREATE TABLE orders (id INTEGER PRIMARY KEY);\nCHECKPOINT;", RevertScript: "DROP TABLE orders;"},
+		})
+		service, err := NewMigrationServiceFromDSN("duckdb", dsn, "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.Empty(t, status.Pending)
+		assert.NoError(t, service.Close())
+
+		fs = CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INTEGER PRIMARY KEY);", RevertScript: "DROP TABLE users;"},
+		})
+		service, err = NewMigrationServiceFromDSN("duckdb", dsn, "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var exists bool
+		assert.NoError(t, service.conn.QueryRowContext(ctx, DuckDBDialect{}.TableExistsQuery("orders")).Scan(&exists))
+		assert.False(t, exists)
+	})
+	t.Run("Test a failed migration is rolled back", func(t *testing.T) {
+		ctx := context.Background()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INTEGER PRIMARY KEY);\nINSERT INTO missing VALUES (1);", RevertScript: "DROP TABLE users;"},
+		})
+		service, err := NewMigrationServiceFromDSN("duckdb", filepath.Join(t.TempDir(), "test.duckdb"), "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.ErrorContains(t, service.ExecuteMigration(ctx), "missing")
+		var exists bool
+		assert.NoError(t, service.conn.QueryRowContext(ctx, DuckDBDialect{}.TableExistsQuery("users")).Scan(&exists))
+		assert.False(t, exists)
+	})
+}
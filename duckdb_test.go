@@ -0,0 +1,18 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DuckDBDialect(t *testing.T) {
+	d := DuckDBDialect{}
+	statements := d.ChangelogDDL()
+	assert.Len(t, statements, len(changelogTables)+1)
+	assert.Equal(t, `CREATE SEQUENCE IF NOT EXISTS changelog_sequence`, statements[0])
+	assert.Contains(t, statements[1], "\tsequence BIGINT NOT NULL DEFAULT nextval('changelog_sequence'),\n")
+	assert.Equal(t, `INSERT INTO changelog_job (id) VALUES ($1) ON CONFLICT (id) DO NOTHING`, d.InsertIgnore("changelog_job", "id"))
+	assert.True(t, d.NonTransactional("force checkpoint"))
+	assert.False(t, d.NonTransactional("ALTER TABLE events ADD COLUMN name VARCHAR"))
+}
@@ -0,0 +1,129 @@
+package migrago
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// normalizeEncoding returns a reader which strips a UTF-8 byte order mark and decodes UTF-16 scripts, detected by
+// their byte order mark, to UTF-8. Scripts are checksummed after the normalization, so the checksum does not depend
+// on the editor which saved a script. Scripts with a byte order mark could not be executed before, so no checksum
+// in a changelog is affected.
+func normalizeEncoding(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	bom, _ := br.Peek(len(bomUTF8))
+	switch {
+	case bytes.HasPrefix(bom, bomUTF8):
+		br.Discard(len(bomUTF8))
+	case bytes.HasPrefix(bom, bomUTF16LE):
+		br.Discard(len(bomUTF16LE))
+		return &utf16Reader{r: br, order: binary.LittleEndian}
+	case bytes.HasPrefix(bom, bomUTF16BE):
+		br.Discard(len(bomUTF16BE))
+		return &utf16Reader{r: br, order: binary.BigEndian}
+	}
+	return br
+}
+
+// normalizeScript normalizes the encoding of a script which was loaded by a custom source
+func normalizeScript(script string) (string, error) {
+	if !strings.HasPrefix(script, string(bomUTF8)) && !strings.HasPrefix(script, string(bomUTF16LE)) && !strings.HasPrefix(script, string(bomUTF16BE)) {
+		return script, nil
+	}
+	var normalized strings.Builder
+	if _, err := io.Copy(&normalized, normalizeEncoding(strings.NewReader(script))); err != nil {
+		return "", err
+	}
+	return normalized.String(), nil
+}
+
+// normalizedFile is an opened script whose content is normalized while it is read
+type normalizedFile struct {
+	io.Reader
+	io.Closer
+}
+
+// utf16Reader decodes UTF-16 to UTF-8 while reading, unpaired surrogates are replaced with U+FFFD
+type utf16Reader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	// decoded contains decoded bytes which were not read yet
+	decoded []byte
+	// pending is a code unit which was read after an unpaired high surrogate
+	pending *uint16
+	err     error
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.decoded) < len(p) && u.err == nil {
+		u.decodeRune()
+	}
+	if len(u.decoded) == 0 {
+		return 0, u.err
+	}
+	n := copy(p, u.decoded)
+	u.decoded = u.decoded[n:]
+	return n, nil
+}
+
+// decodeRune decodes the next code point
+func (u *utf16Reader) decodeRune() {
+	unit, err := u.unit()
+	if err != nil {
+		u.err = err
+		return
+	}
+	r := rune(unit)
+	switch {
+	case isHighSurrogate(r):
+		next, err := u.unit()
+		if err != nil && !errors.Is(err, io.EOF) {
+			u.err = err
+			return
+		}
+		if err == nil && isLowSurrogate(rune(next)) {
+			r = utf16.DecodeRune(r, rune(next))
+			break
+		}
+		if err == nil {
+			u.pending = &next
+		}
+		r = utf8.RuneError
+	case isLowSurrogate(r):
+		r = utf8.RuneError
+	}
+	u.decoded = utf8.AppendRune(u.decoded, r)
+}
+
+func isHighSurrogate(r rune) bool { return r >= 0xD800 && r < 0xDC00 }
+
+func isLowSurrogate(r rune) bool { return r >= 0xDC00 && r < 0xE000 }
+
+// unit reads the next code unit
+func (u *utf16Reader) unit() (uint16, error) {
+	if u.pending != nil {
+		unit := *u.pending
+		u.pending = nil
+		return unit, nil
+	}
+	var b [2]byte
+	if _, err := io.ReadFull(u.r, b[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, errors.New("invalid UTF-16 script: odd number of bytes")
+		}
+		return 0, err
+	}
+	return u.order.Uint16(b[:]), nil
+}
@@ -0,0 +1,82 @@
+package migrago
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeUTF16 encodes a script as UTF-16 with byte order mark
+func encodeUTF16(script string, bigEndian bool) []byte {
+	encoded := []byte{0xFF, 0xFE}
+	if bigEndian {
+		encoded = []byte{0xFE, 0xFF}
+	}
+	for _, unit := range utf16.Encode([]rune(script)) {
+		if bigEndian {
+			encoded = append(encoded, byte(unit>>8), byte(unit))
+		} else {
+			encoded = append(encoded, byte(unit), byte(unit>>8))
+		}
+	}
+	return encoded
+}
+
+func Test_normalizeEncoding(t *testing.T) {
+	script := "INSERT INTO test (name) VALUES ('Grüße 😀')"
+	for name, content := range map[string][]byte{
+		"utf-8":     []byte(script),
+		"utf-8 bom": append([]byte{0xEF, 0xBB, 0xBF}, script...),
+		"utf-16le":  encodeUTF16(script, false),
+		"utf-16be":  encodeUTF16(script, true),
+	} {
+		normalized, err := io.ReadAll(normalizeEncoding(strings.NewReader(string(content))))
+		assert.NoError(t, err, name)
+		assert.Equal(t, script, string(normalized), name)
+	}
+
+	// an unpaired high surrogate does not swallow the next character
+	normalized, err := io.ReadAll(normalizeEncoding(strings.NewReader(string([]byte{0xFF, 0xFE, 0x00, 0xD8, 0x41, 0x00}))))
+	assert.NoError(t, err)
+	assert.Equal(t, "�A", string(normalized))
+
+	_, err = io.ReadAll(normalizeEncoding(strings.NewReader(string([]byte{0xFF, 0xFE, 0x41}))))
+	assert.ErrorContains(t, err, "odd number of bytes")
+}
+
+func Test_FileSourceEncoding(t *testing.T) {
+	script := "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)"
+	fs := fstest.MapFS{
+		"config.json":             {Data: []byte(`["Test", "Streamed"]`)},
+		"scripts/Test.sql":        {Data: encodeUTF16(script, false)},
+		"scripts/Test.revert.sql": {Data: append([]byte{0xEF, 0xBB, 0xBF}, "DROP TABLE test"...)},
+		"scripts/Streamed.sql":    {Data: append([]byte{0xEF, 0xBB, 0xBF}, script...)},
+	}
+	source := NewFileSource("config.json", "scripts", fs)
+	migration, err := source.Load("Test")
+	assert.NoError(t, err)
+	assert.Equal(t, script, migration.Script)
+	assert.Equal(t, "DROP TABLE test", migration.RevertScript)
+	// the checksum is the same as for the script saved as UTF-8 without byte order mark
+	assert.Equal(t, "9c23564a026f0826f2a05b8423aa21f9", migration.Checksum)
+
+	migration, err = source.WithStreamingThreshold(10).Load("Streamed")
+	assert.NoError(t, err)
+	assert.Equal(t, "9c23564a026f0826f2a05b8423aa21f9", migration.Checksum)
+	r, err := migration.OpenScript()
+	assert.NoError(t, err)
+	defer r.Close()
+	streamed, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, script, string(streamed))
+
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithSource("custom", staticSource{{Id: "Custom", Script: "\ufeff" + script}}))
+	migration, err = service.loadMigration(service.sources[0], "Custom")
+	assert.NoError(t, err)
+	assert.Equal(t, script, migration.Script)
+	assert.Equal(t, "9c23564a026f0826f2a05b8423aa21f9", migration.Checksum)
+}
@@ -0,0 +1,90 @@
+package migrago
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedMarker prefixes revert scripts which are stored encrypted and base64 encoded in the changelog
+const encryptedMarker = "migrago:enc:"
+
+// RevertScriptCipher encrypts revert scripts before they are stored in the changelog,
+// implement it to use a KMS instead of a local key
+type RevertScriptCipher interface {
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// aesCipher encrypts with AES-GCM, the random nonce is prepended to the ciphertext
+type aesCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESCipher creates a RevertScriptCipher using AES-GCM with a 16, 24 or 32 byte key
+func NewAESCipher(key []byte) (RevertScriptCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aesCipher{aead: aead}, nil
+}
+
+// Encrypt seals the plaintext with a random nonce
+func (c aesCipher) Encrypt(_ context.Context, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt opens a ciphertext created by Encrypt
+func (c aesCipher) Decrypt(_ context.Context, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+	return c.aead.Open(nil, nonce, sealed, nil)
+}
+
+// encryptRevertScript encrypts the stored revert script if a cipher is configured
+func (m MigrationService) encryptRevertScript(ctx context.Context, stored string) (string, error) {
+	if m.revertCipher == nil {
+		return stored, nil
+	}
+	ciphertext, err := m.revertCipher.Encrypt(ctx, []byte(stored))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt revert script: %w", err)
+	}
+	return encryptedMarker + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decryptRevertScript decrypts a revert script read from the changelog, unencrypted scripts are returned as is
+func (m MigrationService) decryptRevertScript(ctx context.Context, stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, encryptedMarker)
+	if !ok {
+		return stored, nil
+	}
+	if m.revertCipher == nil {
+		return "", errors.New("revert script is encrypted, configure WithRevertScriptEncryption to read it")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt revert script: %w", err)
+	}
+	plaintext, err := m.revertCipher.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt revert script: %w", err)
+	}
+	return string(plaintext), nil
+}
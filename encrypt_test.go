@@ -0,0 +1,44 @@
+package migrago
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RevertScriptEncryption(t *testing.T) {
+	ctx := context.Background()
+	cipher, err := NewAESCipher([]byte("0123456789abcdef0123456789abcdef"))
+	assert.NoError(t, err)
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithRevertScriptEncryption(cipher))
+
+	stored, err := service.encodeChangelogRevertScript(ctx, Migration{Id: "Test", RevertScript: "DROP TABLE test"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored, encryptedMarker))
+	assert.NotContains(t, stored, "DROP TABLE")
+
+	decoded, err := service.decodeChangelogRevertScript(ctx, stored)
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE test", decoded)
+
+	// Large scripts are compressed before they are encrypted
+	large := strings.Repeat("INSERT INTO test (name) VALUES ('restored');\n", 1000)
+	stored, err = service.encodeChangelogRevertScript(ctx, Migration{Id: "Test", RevertScript: large})
+	assert.NoError(t, err)
+	decoded, err = service.decodeChangelogRevertScript(ctx, stored)
+	assert.NoError(t, err)
+	assert.Equal(t, large, decoded)
+
+	_, err = NewMigrationService("config.json", "scripts", nil, nil).decodeChangelogRevertScript(ctx, stored)
+	assert.ErrorContains(t, err, "configure WithRevertScriptEncryption")
+
+	other, err := NewAESCipher([]byte("fedcba9876543210fedcba9876543210"))
+	assert.NoError(t, err)
+	_, err = NewMigrationService("config.json", "scripts", nil, nil, WithRevertScriptEncryption(other)).decodeChangelogRevertScript(ctx, stored)
+	assert.ErrorContains(t, err, "failed to decrypt revert script")
+
+	_, err = NewAESCipher([]byte("short"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package migrago
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrAppliedConcurrently is returned if another runner applied the same migration at the same time,
+// the changes of this runner are rolled back
+var ErrAppliedConcurrently = errors.New("migration was applied by a concurrent run")
+
+// ChecksumMismatchError is returned if the script of an applied migration was changed
+type ChecksumMismatchError struct {
+	Id       string
+	File     string
+	Database string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for migration %s: file: %s, database: %s", e.Id, e.File, e.Database)
+}
+
+// UnknownMigrationsError is returned in strict mode if the changelog contains migrations which are not in the configuration
+type UnknownMigrationsError struct {
+	Migrations []Migration
+}
+
+func (e *UnknownMigrationsError) Error() string {
+	details := make([]string, len(e.Migrations))
+	for i, migration := range e.Migrations {
+		details[i] = fmt.Sprintf("%s (checksum: %s, installed at: %s)", migration.Id, migration.Checksum, migration.InstalledAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("changelog contains %d migrations which are not in the configuration: %s", len(e.Migrations), strings.Join(details, ", "))
+}
+
+// InterruptedError is returned if a run stopped cleanly between two migrations, e.g. after a stop request.
+// The applied migrations are committed, a subsequent run continues with the remaining ones.
+type InterruptedError struct {
+	Reason string
+	// Remaining contains the IDs of the pending migrations which were not executed, in execution order
+	Remaining []string
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("run interrupted (%s), %d pending migrations remaining: %s", e.Reason, len(e.Remaining), strings.Join(e.Remaining, ", "))
+}
+
+// PreflightError is returned if requirements of the migrations are not met, it lists all problems at once
+type PreflightError struct {
+	Problems []string
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("preflight check failed with %d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// PendingMigrationsError is returned by Check if the schema is not up to date
+type PendingMigrationsError struct {
+	// Pending contains the IDs of the pending migrations in execution order
+	Pending []string
+}
+
+func (e *PendingMigrationsError) Error() string {
+	return fmt.Sprintf("%d pending migrations: %s", len(e.Pending), strings.Join(e.Pending, ", "))
+}
+
+// ConfigProblem is a single problem of a config file with its position, line and column are 1-based
+type ConfigProblem struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// ConfigError is returned if the config file is not valid JSON or does not match the expected schema,
+// it lists all problems at once
+type ConfigError struct {
+	File     string
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		problems[i] = e.File + ":" + problem.String()
+	}
+	return strings.Join(problems, "; ")
+}
+
+// PhaseTimeoutError is returned if a phase of a run exceeded its timeout, see PhaseTimeouts
+type PhaseTimeoutError struct {
+	// Phase is prepare, migration or revert
+	Phase string
+	// Id is the migration which was executed or reverted, it is empty for the preparation
+	Id      string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *PhaseTimeoutError) Error() string {
+	if e.Id == "" {
+		return fmt.Sprintf("%s phase exceeded its timeout of %s: %v", e.Phase, e.Timeout, e.Err)
+	}
+	return fmt.Sprintf("%s phase of %s exceeded its timeout of %s: %v", e.Phase, e.Id, e.Timeout, e.Err)
+}
+
+func (e *PhaseTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// AssertionError is returned if an assertion of a migration is violated after its script
+type AssertionError struct {
+	Id        string
+	Assertion Assertion
+	// Actual is the result of the query, NULL if it returned NULL
+	Actual string
+}
+
+func (e *AssertionError) Error() string {
+	return fmt.Sprintf("assertion %q of migration %s failed: got %s", e.Assertion, e.Id, e.Actual)
+}
+
+// CanaryError is returned if the canary run failed or exceeded its thresholds, production was not migrated
+type CanaryError struct {
+	// Err is the error of the canary run
+	Err error
+	// Problems are the exceeded thresholds and the migrations the canary did not exercise
+	Problems []string
+}
+
+func (e *CanaryError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("canary failed, production was not migrated: %v", e.Err)
+	}
+	return fmt.Sprintf("canary failed with %d problems, production was not migrated: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+func (e *CanaryError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyError is returned by VerifyLocal and VerifyReplacements, it lists all problems at once
+type VerifyError struct {
+	Problems []string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("verification failed with %d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
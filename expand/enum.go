@@ -0,0 +1,110 @@
+package expand
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Soemii/migrago"
+)
+
+// Enum generates the safe migrations to change a Postgres enum type
+type Enum struct {
+	// Name is the (optionally schema qualified) type
+	Name string
+	// Values are the current values of the type in their order, they are needed to revert the changes
+	Values []string
+}
+
+// AddValue adds a value after another value or at the end if after is empty. ALTER TYPE ... ADD VALUE can not
+// run inside a transaction block before Postgres 12 and the new value can not be used in the same transaction
+// since then, so the migration is executed without a transaction. The revert recreates the type without the value.
+func (e Enum) AddValue(id, value, after string) migrago.Migration {
+	position := ""
+	if after != "" {
+		position = " AFTER " + quoteLiteral(after)
+	}
+	return migrago.Migration{
+		Id:           id,
+		Script:       fmt.Sprintf("ALTER TYPE %s ADD VALUE IF NOT EXISTS %s%s", quoteIdentifier(e.Name), quoteLiteral(value), position),
+		RevertScript: e.recreate(e.Values),
+		Metadata:     migrago.Metadata{NoTransaction: true},
+	}
+}
+
+// RenameValue renames a value with ALTER TYPE ... RENAME VALUE on Postgres 10 and later, older versions
+// update the catalog directly, which needs superuser privileges
+func (e Enum) RenameValue(id, from, to string) migrago.Migration {
+	return migrago.Migration{
+		Id:           id,
+		Script:       e.rename(from, to),
+		RevertScript: e.rename(to, from),
+	}
+}
+
+// RemoveValue removes a value, which Postgres does not support directly: the type is recreated without the value
+// and all columns of the type are converted to the new type. The migration fails if a row still uses the value.
+// Column defaults using the type have to be dropped before and added again afterwards.
+func (e Enum) RemoveValue(id, value string) migrago.Migration {
+	values := slices.DeleteFunc(slices.Clone(e.Values), func(v string) bool { return v == value })
+	return migrago.Migration{
+		Id:           id,
+		Script:       e.recreate(values),
+		RevertScript: e.recreate(e.Values),
+	}
+}
+
+// rename builds a DO block renaming a value depending on the server version
+func (e Enum) rename(from, to string) string {
+	return fmt.Sprintf(`DO $migrago$
+BEGIN
+	IF current_setting('server_version_num')::int >= 100000 THEN
+		ALTER TYPE %s RENAME VALUE %s TO %s;
+	ELSE
+		UPDATE pg_enum SET enumlabel = %s WHERE enumtypid = %s::regtype AND enumlabel = %s;
+	END IF;
+END
+$migrago$`, quoteIdentifier(e.Name), quoteLiteral(from), quoteLiteral(to), quoteLiteral(to), quoteLiteral(e.Name), quoteLiteral(from))
+}
+
+// recreate builds the script replacing the type by a new type with the given values, all columns of
+// the old type are converted via text
+func (e Enum) recreate(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quoteLiteral(value)
+	}
+	schema, name, ok := strings.Cut(e.Name, ".")
+	if !ok {
+		schema, name = "", e.Name
+	}
+	old := name + "_migrago_old"
+	oldQualified := old
+	if schema != "" {
+		oldQualified = schema + "." + old
+	}
+	return fmt.Sprintf(`ALTER TYPE %s RENAME TO %s;
+CREATE TYPE %s AS ENUM (%s);
+DO $migrago$
+DECLARE
+	col record;
+BEGIN
+	FOR col IN SELECT a.attrelid::regclass AS tbl, a.attname FROM pg_attribute a JOIN pg_class c ON c.oid = a.attrelid
+		WHERE a.atttypid = %s::regtype AND c.relkind IN ('r', 'p') AND NOT a.attisdropped
+	LOOP
+		EXECUTE format('ALTER TABLE %%s ALTER COLUMN %%I TYPE %s USING %%I::text::%s', col.tbl, col.attname, col.attname);
+	END LOOP;
+END
+$migrago$;
+DROP TYPE %s`,
+		quoteIdentifier(e.Name), quoteIdentifier(old),
+		quoteIdentifier(e.Name), strings.Join(quoted, ", "),
+		quoteLiteral(oldQualified),
+		strings.ReplaceAll(quoteIdentifier(e.Name), "'", "''"), strings.ReplaceAll(quoteIdentifier(e.Name), "'", "''"),
+		quoteIdentifier(oldQualified))
+}
+
+// quoteLiteral quotes a string literal
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
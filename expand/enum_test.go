@@ -0,0 +1,34 @@
+package expand
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_EnumAddValue(t *testing.T) {
+	mood := Enum{Name: "mood", Values: []string{"sad", "happy"}}
+	migration := mood.AddValue("mood-ok", "ok", "sad")
+	assert.Equal(t, `ALTER TYPE "mood" ADD VALUE IF NOT EXISTS 'ok' AFTER 'sad'`, migration.Script)
+	assert.True(t, migration.Metadata.NoTransaction)
+	assert.Contains(t, migration.RevertScript, `CREATE TYPE "mood" AS ENUM ('sad', 'happy');`)
+}
+
+func Test_EnumRenameValue(t *testing.T) {
+	mood := Enum{Name: "public.mood", Values: []string{"sad", "happy"}}
+	migration := mood.RenameValue("mood-glad", "happy", "glad")
+	assert.Contains(t, migration.Script, `ALTER TYPE "public"."mood" RENAME VALUE 'happy' TO 'glad';`)
+	assert.Contains(t, migration.Script, `UPDATE pg_enum SET enumlabel = 'glad' WHERE enumtypid = 'public.mood'::regtype AND enumlabel = 'happy';`)
+	assert.Contains(t, migration.RevertScript, `RENAME VALUE 'glad' TO 'happy';`)
+}
+
+func Test_EnumRemoveValue(t *testing.T) {
+	mood := Enum{Name: "public.mood", Values: []string{"sad", "ok", "happy"}}
+	migration := mood.RemoveValue("mood-no-ok", "ok")
+	assert.Contains(t, migration.Script, `ALTER TYPE "public"."mood" RENAME TO "mood_migrago_old";`)
+	assert.Contains(t, migration.Script, `CREATE TYPE "public"."mood" AS ENUM ('sad', 'happy');`)
+	assert.Contains(t, migration.Script, `'public.mood_migrago_old'::regtype`)
+	assert.Contains(t, migration.Script, `DROP TYPE "public"."mood_migrago_old"`)
+	assert.Contains(t, migration.RevertScript, `CREATE TYPE "public"."mood" AS ENUM ('sad', 'ok', 'happy');`)
+	assert.Equal(t, []string{"sad", "ok", "happy"}, mood.Values)
+}
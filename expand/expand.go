@@ -0,0 +1,148 @@
+// Package expand generates multi-step migrations for zero-downtime schema changes following the
+// expand/contract pattern: every step only takes short locks and is committed on its own
+package expand
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Soemii/migrago"
+)
+
+// Source provides generated migrations in the given order, it can be added with migrago.WithSource
+type Source []migrago.Migration
+
+// NewSource concatenates the steps of several helpers into a Source
+func NewSource(steps ...[]migrago.Migration) Source {
+	var source Source
+	for _, s := range steps {
+		source = append(source, s...)
+	}
+	return source
+}
+
+// List returns the IDs of all migrations
+func (s Source) List() ([]string, error) {
+	ids := make([]string, len(s))
+	for i, migration := range s {
+		ids[i] = migration.Id
+	}
+	return ids, nil
+}
+
+// Load returns the migration with the given ID
+func (s Source) Load(migrationId string) (migrago.Migration, error) {
+	for _, migration := range s {
+		if migration.Id == migrationId {
+			return migration, nil
+		}
+	}
+	return migrago.Migration{}, fmt.Errorf("unknown migration %s", migrationId)
+}
+
+// Column describes a column added with AddNotNullColumn
+type Column struct {
+	// Table is the (optionally schema qualified) table
+	Table string
+	// Name and Type of the column
+	Name string
+	Type string
+	// Fill is the SQL expression the existing rows are backfilled with, e.g. "lower(email)"
+	Fill string
+	// Key is a unique, sortable column of the table the backfill is batched by, default is "id"
+	Key string
+	// BatchSize of the backfill, default is migrago.DefaultBackfillBatchSize
+	BatchSize int
+}
+
+// AddNotNullColumn adds a NOT NULL column to a table without locking it for the duration of a table rewrite:
+//  1. <id>-add: the column is added as nullable
+//  2. <id>-backfill: existing rows are filled in batches
+//  3. <id>-constraint: a CHECK (column IS NOT NULL) constraint is added as NOT VALID
+//  4. <id>-validate: the constraint is validated without blocking writes, the column is set NOT NULL
+//     (which uses the validated constraint instead of a table scan) and the constraint is dropped
+//
+// Writers have to fill the column before the constraint step is executed.
+func AddNotNullColumn(id string, column Column) []migrago.Migration {
+	table := quoteIdentifier(column.Table)
+	name := quoteIdentifier(column.Name)
+	key := column.Key
+	if key == "" {
+		key = "id"
+	}
+	constraint := quoteIdentifier(constraintName(column.Table, column.Name, "not_null"))
+	return []migrago.Migration{
+		{
+			Id:           id + "-add",
+			Script:       fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, name, column.Type),
+			RevertScript: fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, name),
+		},
+		{
+			Id:           id + "-backfill",
+			Script:       fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s BETWEEN $1 AND $2 AND %s IS NULL", table, name, column.Fill, quoteIdentifier(key), name),
+			RevertScript: fmt.Sprintf("-- the column is dropped by %s-add", id),
+			Metadata: migrago.Metadata{
+				Backfill: &migrago.Backfill{Table: column.Table, Key: key, BatchSize: column.BatchSize},
+			},
+		},
+		{
+			Id:           id + "-constraint",
+			Script:       fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IS NOT NULL) NOT VALID", table, constraint, name),
+			RevertScript: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, constraint),
+		},
+		{
+			Id: id + "-validate",
+			Script: fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s;\nALTER TABLE %s ALTER COLUMN %s SET NOT NULL;\nALTER TABLE %s DROP CONSTRAINT %s",
+				table, constraint, table, name, table, constraint),
+			RevertScript: fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;\nALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IS NOT NULL) NOT VALID",
+				table, name, table, constraint, name),
+		},
+	}
+}
+
+// ForeignKey describes a foreign key added with AddForeignKey
+type ForeignKey struct {
+	Table     string
+	Column    string
+	RefTable  string
+	RefColumn string
+}
+
+// AddForeignKey adds a foreign key without blocking writes on both tables while the existing rows are checked:
+//  1. <id>-constraint: the foreign key is added as NOT VALID, it is only enforced for new rows
+//  2. <id>-validate: the existing rows are validated with a lock that does not block writes
+func AddForeignKey(id string, fk ForeignKey) []migrago.Migration {
+	table := quoteIdentifier(fk.Table)
+	constraint := quoteIdentifier(constraintName(fk.Table, fk.Column, "fkey"))
+	return []migrago.Migration{
+		{
+			Id: id + "-constraint",
+			Script: fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) NOT VALID",
+				table, constraint, quoteIdentifier(fk.Column), quoteIdentifier(fk.RefTable), quoteIdentifier(fk.RefColumn)),
+			RevertScript: fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT %s", table, constraint),
+		},
+		{
+			Id:           id + "-validate",
+			Script:       fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", table, constraint),
+			RevertScript: fmt.Sprintf("-- the constraint is dropped by %s-constraint", id),
+		},
+	}
+}
+
+// constraintName builds the name of a constraint like Postgres does, e.g. users_email_not_null
+func constraintName(table, column, suffix string) string {
+	_, name, ok := strings.Cut(table, ".")
+	if !ok {
+		name = table
+	}
+	return name + "_" + column + "_" + suffix
+}
+
+// quoteIdentifier quotes a possibly schema qualified identifier
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,43 @@
+package expand
+
+import (
+	"testing"
+
+	"github.com/Soemii/migrago"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AddNotNullColumn(t *testing.T) {
+	steps := AddNotNullColumn("users-email-lower", Column{Table: "public.users", Name: "email_lower", Type: "TEXT", Fill: "lower(email)"})
+	assert.Len(t, steps, 4)
+	assert.Equal(t, "users-email-lower-add", steps[0].Id)
+	assert.Equal(t, `ALTER TABLE "public"."users" ADD COLUMN "email_lower" TEXT`, steps[0].Script)
+	assert.Equal(t, `UPDATE "public"."users" SET "email_lower" = lower(email) WHERE "id" BETWEEN $1 AND $2 AND "email_lower" IS NULL`, steps[1].Script)
+	assert.Equal(t, &migrago.Backfill{Table: "public.users", Key: "id"}, steps[1].Metadata.Backfill)
+	assert.Equal(t, `ALTER TABLE "public"."users" ADD CONSTRAINT "users_email_lower_not_null" CHECK ("email_lower" IS NOT NULL) NOT VALID`, steps[2].Script)
+	assert.Contains(t, steps[3].Script, `VALIDATE CONSTRAINT "users_email_lower_not_null"`)
+	assert.Contains(t, steps[3].Script, `ALTER COLUMN "email_lower" SET NOT NULL`)
+}
+
+func Test_AddForeignKey(t *testing.T) {
+	steps := AddForeignKey("orders-customer", ForeignKey{Table: "orders", Column: "customer_id", RefTable: "customers", RefColumn: "id"})
+	assert.Equal(t, `ALTER TABLE "orders" ADD CONSTRAINT "orders_customer_id_fkey" FOREIGN KEY ("customer_id") REFERENCES "customers" ("id") NOT VALID`, steps[0].Script)
+	assert.Equal(t, `ALTER TABLE "orders" VALIDATE CONSTRAINT "orders_customer_id_fkey"`, steps[1].Script)
+}
+
+func Test_Source(t *testing.T) {
+	source := NewSource(
+		AddForeignKey("orders-customer", ForeignKey{Table: "orders", Column: "customer_id", RefTable: "customers", RefColumn: "id"}),
+		AddNotNullColumn("users-email-lower", Column{Table: "users", Name: "email_lower", Type: "TEXT", Fill: "lower(email)"}),
+	)
+	ids, err := source.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders-customer-constraint", "orders-customer-validate", "users-email-lower-add",
+		"users-email-lower-backfill", "users-email-lower-constraint", "users-email-lower-validate"}, ids)
+
+	migration, err := source.Load("users-email-lower-add")
+	assert.NoError(t, err)
+	assert.Equal(t, "users-email-lower-add", migration.Id)
+	_, err = source.Load("unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package expand
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Soemii/migrago"
+)
+
+// Interval is the time range covered by a single partition
+type Interval int
+
+const (
+	Daily Interval = iota
+	Monthly
+	Yearly
+)
+
+// truncate returns the start of the partition containing t
+func (i Interval) truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch i {
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// next returns the start of the following partition
+func (i Interval) next(t time.Time) time.Time {
+	switch i {
+	case Daily:
+		return t.AddDate(0, 0, 1)
+	case Monthly:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(1, 0, 0)
+	}
+}
+
+// suffix names the partition starting at t, e.g. p202610 for a monthly partition
+func (i Interval) suffix(t time.Time) string {
+	switch i {
+	case Daily:
+		return t.Format("p20060102")
+	case Monthly:
+		return t.Format("p200601")
+	default:
+		return t.Format("p2006")
+	}
+}
+
+// Partitions is a Source of time-range partitions of a table created with PARTITION BY RANGE. Every partition
+// is a migration, the list grows with the time: it contains all partitions from Start until Ahead partitions
+// after the current one, so executing the migrations periodically pre-creates the future partitions, see Maintain.
+type Partitions struct {
+	// Table is the (optionally schema qualified) partitioned table
+	Table    string
+	Interval Interval
+	// Start is contained in the first partition
+	Start time.Time
+	// Ahead is the number of partitions created in advance
+	Ahead int
+	// Now returns the current time, default is time.Now
+	Now func() time.Time
+}
+
+// List returns the IDs of the partitions, e.g. events-p202610
+func (p Partitions) List() ([]string, error) {
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	last := p.Interval.truncate(now())
+	for range p.Ahead {
+		last = p.Interval.next(last)
+	}
+	var ids []string
+	for start := p.Interval.truncate(p.Start); !start.After(last); start = p.Interval.next(start) {
+		ids = append(ids, p.id(start))
+	}
+	return ids, nil
+}
+
+// Load returns the migration creating a partition
+func (p Partitions) Load(migrationId string) (migrago.Migration, error) {
+	suffix, ok := strings.CutPrefix(migrationId, p.baseName()+"-")
+	if !ok {
+		return migrago.Migration{}, fmt.Errorf("unknown migration %s", migrationId)
+	}
+	layout := map[Interval]string{Daily: "p20060102", Monthly: "p200601", Yearly: "p2006"}[p.Interval]
+	start, err := time.Parse(layout, suffix)
+	if err != nil {
+		return migrago.Migration{}, fmt.Errorf("unknown migration %s", migrationId)
+	}
+	partition := p.partitionName(start)
+	return migrago.Migration{
+		Id: migrationId,
+		Script: fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			quoteIdentifier(partition), quoteIdentifier(p.Table), formatBound(start), formatBound(p.Interval.next(start))),
+		RevertScript: fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdentifier(partition)),
+	}, nil
+}
+
+// Maintain executes the migrations of the service every interval until the context is done, so the partitions
+// registered at the service with migrago.WithSource are created in advance. It returns the error of a failed run.
+func (p Partitions) Maintain(ctx context.Context, service migrago.MigrationService, every time.Duration) error {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		if err := service.ExecuteMigration(ctx); err != nil {
+			return fmt.Errorf("failed to create partitions of %s: %w", p.Table, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// id returns the migration ID of the partition starting at start
+func (p Partitions) id(start time.Time) string {
+	return p.baseName() + "-" + p.Interval.suffix(start)
+}
+
+// baseName returns the table name without schema
+func (p Partitions) baseName() string {
+	if _, name, ok := strings.Cut(p.Table, "."); ok {
+		return name
+	}
+	return p.Table
+}
+
+// partitionName returns the schema qualified name of the partition starting at start, e.g. public.events_p202610
+func (p Partitions) partitionName(start time.Time) string {
+	return strings.TrimSuffix(p.Table, p.baseName()) + p.baseName() + "_" + p.Interval.suffix(start)
+}
+
+// formatBound formats a partition bound which is valid for date, timestamp and timestamptz keys
+func formatBound(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05+00")
+}
@@ -0,0 +1,41 @@
+package expand
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PartitionsList(t *testing.T) {
+	partitions := Partitions{
+		Table:    "public.events",
+		Interval: Monthly,
+		Start:    time.Date(2026, 8, 15, 0, 0, 0, 0, time.UTC),
+		Ahead:    2,
+		Now:      func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) },
+	}
+	ids, err := partitions.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"events-p202608", "events-p202609", "events-p202610", "events-p202611", "events-p202612"}, ids)
+
+	partitions.Interval = Daily
+	partitions.Start = time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	partitions.Ahead = 1
+	ids, err = partitions.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"events-p20261015", "events-p20261016", "events-p20261017"}, ids)
+}
+
+func Test_PartitionsLoad(t *testing.T) {
+	partitions := Partitions{Table: "public.events", Interval: Monthly}
+	migration, err := partitions.Load("events-p202612")
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE TABLE IF NOT EXISTS "public"."events_p202612" PARTITION OF "public"."events" FOR VALUES FROM ('2026-12-01 00:00:00+00') TO ('2027-01-01 00:00:00+00')`, migration.Script)
+	assert.Equal(t, `DROP TABLE IF EXISTS "public"."events_p202612"`, migration.RevertScript)
+
+	_, err = partitions.Load("users-p202612")
+	assert.Error(t, err)
+	_, err = partitions.Load("events-p2026")
+	assert.Error(t, err)
+}
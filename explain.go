@@ -0,0 +1,146 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// explainableKeywords are the first keywords of statements which can be explained
+var explainableKeywords = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "WITH"}
+
+// StatementPlan is the estimated plan of a DML statement of a pending migration
+type StatementPlan struct {
+	MigrationId string
+	// Description is the description of the migration
+	Description string
+	Statement   string
+	// NodeType is the top plan node, e.g. "Seq Scan" or "ModifyTable"
+	NodeType      string
+	EstimatedRows float64
+	TotalCost     float64
+	// Plan is the full plan in the JSON format of EXPLAIN
+	Plan string
+	// Error is set if the statement could not be explained, e.g. because it uses a table created by a pending migration
+	Error string
+}
+
+// Explain runs EXPLAIN for the DML statements of all pending migrations and returns the estimated plans,
+// so reviewers can spot accidental full table rewrites. Nothing is executed, the statements are explained
+// in a read-only transaction against the current schema.
+func (m MigrationService) Explain(ctx context.Context) ([]StatementPlan, error) {
+	if err := m.prepareDatabase(ctx); err != nil {
+		return nil, err
+	}
+	sourceMigrations, _, err := m.getMigrations()
+	if err != nil {
+		return nil, err
+	}
+	existingMigrations, err := m.getExistingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := m.getPendingMigrations(ctx, sourceMigrations, existingMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := m.conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var plans []StatementPlan
+	for _, migration := range pending {
+		err := m.scriptStatements(migration, func(statement string) error {
+			if !isExplainable(statement) {
+				return nil
+			}
+			plan, err := explainStatement(ctx, tx, statement)
+			if err != nil {
+				return err
+			}
+			plan.MigrationId = migration.Id
+			plan.Description = migration.Metadata.Description
+			plans = append(plans, plan)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to explain migration %s: %w", migration.Id, err)
+		}
+	}
+	return plans, nil
+}
+
+// explainStatement explains a single statement, a failing EXPLAIN is reported in the plan and rolled back
+// to a savepoint, so the remaining statements can still be explained
+func explainStatement(ctx context.Context, tx *sql.Tx, statement string) (StatementPlan, error) {
+	plan := StatementPlan{Statement: statement}
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT migrago_explain`); err != nil {
+		return plan, err
+	}
+	var output string
+	if err := tx.QueryRowContext(ctx, `EXPLAIN (FORMAT JSON) `+statement).Scan(&output); err != nil {
+		plan.Error = err.Error()
+		_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT migrago_explain`)
+		return plan, err
+	}
+
+	var parsed []struct {
+		Plan struct {
+			NodeType  string  `json:"Node Type"`
+			PlanRows  float64 `json:"Plan Rows"`
+			TotalCost float64 `json:"Total Cost"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil || len(parsed) == 0 {
+		return plan, fmt.Errorf("failed to parse plan: %s", output)
+	}
+	plan.Plan = output
+	plan.NodeType = parsed[0].Plan.NodeType
+	plan.EstimatedRows = parsed[0].Plan.PlanRows
+	plan.TotalCost = parsed[0].Plan.TotalCost
+	return plan, nil
+}
+
+// isExplainable checks if a statement is DML which EXPLAIN supports, leading comments are ignored
+func isExplainable(statement string) bool {
+	for _, line := range strings.Split(statement, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		keyword, _, _ := strings.Cut(line, " ")
+		keyword = strings.ToUpper(strings.TrimRight(keyword, "("))
+		for _, explainable := range explainableKeywords {
+			if keyword == explainable {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// scriptStatements calls fn for every statement of the script of a migration split by the rules of the dialect,
+// streamed scripts are opened
+func (m MigrationService) scriptStatements(migration Migration, fn func(statement string) error) error {
+	return readMigrationScript(migration, m.splitScript, fn)
+}
+
+// readMigrationScript splits the script of a migration with split, streamed scripts are opened
+func readMigrationScript(migration Migration, split func(r io.Reader, fn func(statement string) error) error, fn func(statement string) error) error {
+	if migration.OpenScript == nil {
+		return split(strings.NewReader(migration.Script), fn)
+	}
+	r, err := migration.OpenScript()
+	if err != nil {
+		return fmt.Errorf("failed to open migration script: %w", err)
+	}
+	defer r.Close()
+	return split(r, fn)
+}
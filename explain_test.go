@@ -0,0 +1,16 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_isExplainable(t *testing.T) {
+	assert.True(t, isExplainable("UPDATE test SET name = 'a'"))
+	assert.True(t, isExplainable("-- backfill\ndelete from test"))
+	assert.True(t, isExplainable("WITH moved AS (DELETE FROM test RETURNING *) INSERT INTO archive SELECT * FROM moved"))
+	assert.False(t, isExplainable("CREATE TABLE test (id serial PRIMARY KEY)"))
+	assert.False(t, isExplainable("ALTER TABLE test ADD COLUMN name TEXT"))
+	assert.False(t, isExplainable("-- only a comment"))
+}
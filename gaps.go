@@ -0,0 +1,89 @@
+package migrago
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GapError is returned if pending migrations sort before already applied migrations
+type GapError struct {
+	Gaps []Gap
+}
+
+// Gap is a pending migration which sorts before already applied migrations
+type Gap struct {
+	Pending      string
+	AppliedAfter []string
+}
+
+func (e *GapError) Error() string {
+	details := make([]string, len(e.Gaps))
+	for i, gap := range e.Gaps {
+		details[i] = fmt.Sprintf("%s is pending but %s already applied", gap.Pending, strings.Join(gap.AppliedAfter, ", "))
+	}
+	return fmt.Sprintf("missing migrations detected: %s; remediation: apply them out of order with WithAllowOutOfOrder, "+
+		"rename them to sort after the applied migrations, or exclude them with WithSkipIDs", strings.Join(details, "; "))
+}
+
+// orderKey splits a migration ID into namespace and numeric prefix (without leading zeros),
+// ok is false if the ID does not start with a number
+func orderKey(migrationId string) (namespace, number string, ok bool) {
+	name := migrationId
+	if i := strings.LastIndex(migrationId, "/"); i >= 0 {
+		namespace, name = migrationId[:i], migrationId[i+1:]
+	}
+	end := 0
+	for end < len(name) && name[end] >= '0' && name[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return namespace, "", false
+	}
+	number = strings.TrimLeft(name[:end], "0")
+	return namespace, number, true
+}
+
+// lessNumber compares two numeric strings without leading zeros
+func lessNumber(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// detectGaps finds pending migrations which sort before applied migrations of the same namespace.
+// Namespaces with IDs without numeric prefix have no ordering and are ignored.
+func detectGaps(pending []Migration, applied []Migration) []Gap {
+	unordered := make(map[string]bool)
+	highest := make(map[string]string)
+	for _, migration := range slices.Concat(pending, applied) {
+		if namespace, _, ok := orderKey(migration.Id); !ok {
+			unordered[namespace] = true
+		}
+	}
+	for _, a := range applied {
+		namespace, number, _ := orderKey(a.Id)
+		if current, ok := highest[namespace]; !ok || lessNumber(current, number) {
+			highest[namespace] = number
+		}
+	}
+
+	var gaps []Gap
+	for _, p := range pending {
+		namespace, number, _ := orderKey(p.Id)
+		if unordered[namespace] || !lessNumber(number, highest[namespace]) {
+			continue
+		}
+		// Only pending migrations below the highest applied one need the full list
+		var after []string
+		for _, a := range applied {
+			appliedNamespace, appliedNumber, _ := orderKey(a.Id)
+			if appliedNamespace == namespace && lessNumber(number, appliedNumber) {
+				after = append(after, a.Id)
+			}
+		}
+		gaps = append(gaps, Gap{Pending: p.Id, AppliedAfter: after})
+	}
+	return gaps
+}
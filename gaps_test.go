@@ -0,0 +1,23 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_detectGaps(t *testing.T) {
+	applied := []Migration{{Id: "003_orders"}, {Id: "001_users"}, {Id: "platform/20240101120000_base"}}
+
+	gaps := detectGaps([]Migration{{Id: "002_products"}, {Id: "004_invoices"}}, applied)
+	assert.Equal(t, []Gap{{Pending: "002_products", AppliedAfter: []string{"003_orders"}}}, gaps)
+
+	gaps = detectGaps([]Migration{{Id: "platform/20230101120000_legacy"}}, applied)
+	assert.Equal(t, []Gap{{Pending: "platform/20230101120000_legacy", AppliedAfter: []string{"platform/20240101120000_base"}}}, gaps)
+
+	gaps = detectGaps([]Migration{{Id: "0002_products"}}, []Migration{{Id: "10_orders"}})
+	assert.Len(t, gaps, 1)
+
+	gaps = detectGaps([]Migration{{Id: "002_products"}}, []Migration{{Id: "003_orders"}, {Id: "Test"}})
+	assert.Empty(t, gaps)
+}
@@ -0,0 +1,103 @@
+// Package gcs provides a Google Cloud Storage migration source and revert script store
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+
+	"github.com/Soemii/migrago"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	defaultEndpoint = "https://storage.googleapis.com"
+	readOnlyScope   = "https://www.googleapis.com/auth/devstorage.read_only"
+	readWriteScope  = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// Store reads and writes objects of a Google Cloud Storage bucket
+type Store struct {
+	bucket   string
+	client   *http.Client
+	endpoint string
+}
+
+// New creates a Store authenticated with the Application Default Credentials
+func New(ctx context.Context, bucket string) (*Store, error) {
+	client, err := google.DefaultClient(ctx, readOnlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load application default credentials: %w", err)
+	}
+	return NewWithClient(bucket, client), nil
+}
+
+// NewReadWrite creates a Store authenticated with the Application Default Credentials which can upload objects,
+// e.g. for migrago.WithRevertScriptStore
+func NewReadWrite(ctx context.Context, bucket string) (*Store, error) {
+	client, err := google.DefaultClient(ctx, readWriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load application default credentials: %w", err)
+	}
+	return NewWithClient(bucket, client), nil
+}
+
+// NewWithClient creates a Store which uses an already authenticated http client
+func NewWithClient(bucket string, client *http.Client) *Store {
+	return &Store{bucket: bucket, client: client, endpoint: defaultEndpoint}
+}
+
+// NewFS creates a fs.FS for the migration service which reads all files below prefix from the bucket.
+// The downloads use ctx, so the fs.FS should be created for a single run, see migrago.NewRemoteFS.
+func NewFS(ctx context.Context, bucket, prefix string) (fs.FS, error) {
+	store, err := New(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return migrago.NewRemoteFS(ctx, store, prefix), nil
+}
+
+// GetObject downloads the content of an object
+func (s *Store) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.endpoint, url.PathEscape(s.bucket), url.PathEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("object %s: %w", key, fs.ErrNotExist)
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to get object %s: unexpected status %s", key, resp.Status)
+	}
+}
+
+// PutObject uploads the body as object, an existing object is overwritten
+func (s *Store) PutObject(ctx context.Context, key string, body io.Reader) error {
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", s.endpoint, url.PathEscape(s.bucket), url.QueryEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/sql")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to put object %s: unexpected status %s", key, resp.Status)
+	}
+	return nil
+}
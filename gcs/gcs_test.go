@@ -0,0 +1,68 @@
+package gcs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Soemii/migrago"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetObject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/storage/v1/b/bucket/o/migrations%2Fconfig.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`["Test"]`))
+	}))
+	defer server.Close()
+
+	store := NewWithClient("bucket", server.Client())
+	store.endpoint = server.URL
+	fsys := migrago.NewRemoteFS(context.Background(), store, "migrations")
+
+	f, err := fsys.Open("config.json")
+	assert.NoError(t, err)
+	content, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, `["Test"]`, string(content))
+
+	_, err = fsys.Open("scripts/Test.sql")
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	// the fs.FS is bound to the context of the load
+	ctx, cancel := context.WithCancel(context.Background())
+	fsys = migrago.NewRemoteFS(ctx, store, "migrations")
+	cancel()
+	_, err = fsys.Open("config.json")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func Test_PutObject(t *testing.T) {
+	var uploaded string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/upload/storage/v1/b/bucket/o" || r.URL.Query().Get("name") != "reverts/abc.sql" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		uploaded = string(body)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	store := NewWithClient("bucket", server.Client())
+	store.endpoint = server.URL
+	err := store.PutObject(context.Background(), "reverts/abc.sql", strings.NewReader("DROP TABLE test"))
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE test", uploaded)
+
+	err = store.PutObject(context.Background(), "other.sql", strings.NewReader("DROP TABLE test"))
+	assert.ErrorContains(t, err, "unexpected status 404")
+}
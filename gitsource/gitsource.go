@@ -0,0 +1,103 @@
+// Package gitsource provides a migration source which reads the migrations from a git repository.
+// The git binary has to be available in the PATH.
+package gitsource
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Repository is a checked out git ref
+type Repository struct {
+	// FS contains the files of the configured subdirectory
+	FS fs.FS
+	// Commit is the resolved commit SHA, it can be recorded with migrago.WithSourceRevision
+	Commit string
+}
+
+// Checkout clones the repository into dir (or fetches if dir already contains a clone),
+// checks out ref and returns the files below subdir. The repository URL has to be an https, ssh or file URL
+// and the ref a valid git ref name, so neither of them is interpreted as an option of git.
+func Checkout(ctx context.Context, repoURL, ref, dir, subdir string) (Repository, error) {
+	if err := checkURL(repoURL); err != nil {
+		return Repository{}, err
+	}
+	if err := checkRef(ctx, ref); err != nil {
+		return Repository{}, err
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if _, err := git(ctx, "", "clone", "--no-checkout", "--", repoURL, dir); err != nil {
+			return Repository{}, err
+		}
+	} else if err != nil {
+		return Repository{}, fmt.Errorf("failed to check clone directory: %w", err)
+	}
+
+	if _, err := git(ctx, dir, "fetch", "--tags", "--", "origin", ref); err != nil {
+		return Repository{}, err
+	}
+	if _, err := git(ctx, dir, "checkout", "--force", "--detach", "FETCH_HEAD"); err != nil {
+		return Repository{}, err
+	}
+	commit, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return Repository{}, err
+	}
+
+	return Repository{
+		FS:     os.DirFS(filepath.Join(dir, subdir)),
+		Commit: commit,
+	}, nil
+}
+
+// allowedSchemes are the URL schemes of the repositories, other transports such as ext:: can execute commands
+var allowedSchemes = map[string]bool{"https": true, "ssh": true, "file": true}
+
+// checkURL checks that the repository URL uses one of the allowed schemes
+func checkURL(repoURL string) error {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL: %w", err)
+	}
+	if !allowedSchemes[u.Scheme] {
+		return fmt.Errorf("repository URL %q has to be an https, ssh or file URL", repoURL)
+	}
+	return nil
+}
+
+// checkRef checks that the ref is a valid ref name and not an option
+func checkRef(ctx context.Context, ref string) error {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid ref %q", ref)
+	}
+	if _, err := git(ctx, "", "check-ref-format", "--allow-onelevel", ref); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("invalid ref %q", ref)
+		}
+		return err
+	}
+	return nil
+}
+
+// git executes a git command and returns the trimmed output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,82 @@
+package gitsource
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestRepository(t *testing.T) (string, string) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "db", "scripts"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "db", "config.json"), []byte(`["Test"]`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "db", "scripts", "Test.sql"), []byte("CREATE TABLE test (id INT)"), 0o644))
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+		{"tag", "v1"},
+	} {
+		_, err := git(ctx, dir, args...)
+		assert.NoError(t, err)
+	}
+	commit, err := git(ctx, dir, "rev-parse", "HEAD")
+	assert.NoError(t, err)
+	return dir, commit
+}
+
+func Test_Checkout(t *testing.T) {
+	origin, commit := createTestRepository(t)
+	clone := filepath.Join(t.TempDir(), "clone")
+	originURL := "file://" + filepath.ToSlash(origin)
+
+	for _, name := range []string{"Test fresh clone", "Test existing clone"} {
+		t.Run(name, func(t *testing.T) {
+			repo, err := Checkout(context.Background(), originURL, "v1", clone, "db")
+			assert.NoError(t, err)
+			assert.Equal(t, commit, repo.Commit)
+
+			f, err := repo.FS.Open("config.json")
+			assert.NoError(t, err)
+			defer f.Close()
+			content, err := io.ReadAll(f)
+			assert.NoError(t, err)
+			assert.Equal(t, `["Test"]`, string(content))
+		})
+	}
+}
+
+func Test_Checkout_Hostile(t *testing.T) {
+	origin, _ := createTestRepository(t)
+	originURL := "file://" + filepath.ToSlash(origin)
+	marker := filepath.Join(t.TempDir(), "pwned")
+
+	t.Run("Test a ref starting with a dash is rejected", func(t *testing.T) {
+		clone := filepath.Join(t.TempDir(), "clone")
+		_, err := Checkout(context.Background(), originURL, "--upload-pack=touch "+marker, clone, "db")
+		assert.ErrorContains(t, err, "invalid ref")
+		assert.NoFileExists(t, marker)
+		assert.NoDirExists(t, clone)
+	})
+	t.Run("Test an invalid ref name is rejected", func(t *testing.T) {
+		_, err := Checkout(context.Background(), originURL, "v1..v2", filepath.Join(t.TempDir(), "clone"), "db")
+		assert.ErrorContains(t, err, "invalid ref")
+	})
+	t.Run("Test a URL starting with a dash is rejected", func(t *testing.T) {
+		_, err := Checkout(context.Background(), "--upload-pack=touch "+marker, "v1", filepath.Join(t.TempDir(), "clone"), "db")
+		assert.ErrorContains(t, err, "has to be an https, ssh or file URL")
+		assert.NoFileExists(t, marker)
+	})
+	t.Run("Test other transports are rejected", func(t *testing.T) {
+		for _, repoURL := range []string{origin, "ext::sh -c touch% " + marker, "http://example.com/repo.git"} {
+			_, err := Checkout(context.Background(), repoURL, "v1", filepath.Join(t.TempDir(), "clone"), "db")
+			assert.ErrorContains(t, err, "has to be an https, ssh or file URL")
+		}
+		assert.NoFileExists(t, marker)
+	})
+}
@@ -0,0 +1,184 @@
+package migrago
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GraphFormat is the output format of ExportGraph
+type GraphFormat string
+
+const (
+	GraphDOT     GraphFormat = "dot"
+	GraphMermaid GraphFormat = "mermaid"
+)
+
+// graphNode is a migration in the exported graph
+type graphNode struct {
+	id      string
+	label   string
+	release string
+}
+
+// graphSource groups the nodes of a source in execution order
+type graphSource struct {
+	namespace string
+	nodes     []graphNode
+}
+
+// ExportGraph writes the migrations as a graph in execution order, e.g. to review the ordering of large migration sets.
+// Every source is a cluster containing its releases, the edges follow the execution order. The database is not queried.
+func (m MigrationService) ExportGraph(w io.Writer, format GraphFormat) error {
+	sourceMigrations, _, err := m.getMigrations()
+	if err != nil {
+		return err
+	}
+	releases, err := m.releases()
+	if err != nil {
+		return err
+	}
+	releaseOf := make(map[string]string)
+	for _, release := range releases {
+		for _, id := range release.Migrations {
+			releaseOf[id] = release.Name
+		}
+	}
+	sources := make([]graphSource, len(sourceMigrations))
+	for i, migrations := range sourceMigrations {
+		sources[i].namespace = m.sources[i].namespace
+		for _, migration := range migrations {
+			sources[i].nodes = append(sources[i].nodes, graphNode{id: migration.Id, label: graphLabel(migration), release: releaseOf[migration.Id]})
+		}
+	}
+	switch format {
+	case GraphDOT:
+		return writeDOT(w, sources)
+	case GraphMermaid:
+		return writeMermaid(w, sources)
+	}
+	return fmt.Errorf("unknown graph format %q", format)
+}
+
+// graphLabel returns the ID of the migration with its execution mode
+func graphLabel(migration Migration) string {
+	metadata := migration.Metadata
+	var flags []string
+	for _, flag := range []struct {
+		name string
+		set  bool
+	}{
+		{"irreversible", metadata.Irreversible},
+		{"dangerous", metadata.Dangerous},
+		{"async", metadata.Async},
+		{"heavy", metadata.Heavy},
+		{"backfill", metadata.Backfill != nil},
+		{"online-ddl", metadata.OnlineDDL},
+	} {
+		if flag.set {
+			flags = append(flags, flag.name)
+		}
+	}
+	if len(flags) == 0 {
+		return migration.Id
+	}
+	return migration.Id + " (" + strings.Join(flags, ", ") + ")"
+}
+
+// name returns the label of a source, the own migrations of the service have no namespace
+func (s graphSource) name() string {
+	if s.namespace == "" {
+		return "migrations"
+	}
+	return s.namespace
+}
+
+// byRelease groups the nodes of a source by release in the order of their first migration, nodes without release come first
+func (s graphSource) byRelease() (ungrouped []graphNode, releases []string, grouped map[string][]graphNode) {
+	grouped = make(map[string][]graphNode)
+	for _, node := range s.nodes {
+		if node.release == "" {
+			ungrouped = append(ungrouped, node)
+			continue
+		}
+		if _, ok := grouped[node.release]; !ok {
+			releases = append(releases, node.release)
+		}
+		grouped[node.release] = append(grouped[node.release], node)
+	}
+	return ungrouped, releases, grouped
+}
+
+// writeDOT writes the graph in the Graphviz DOT format
+func writeDOT(w io.Writer, sources []graphSource) error {
+	quote := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"` }
+	var b strings.Builder
+	b.WriteString("digraph migrations {\n\trankdir=TB;\n\tnode [shape=box];\n")
+	for i, source := range sources {
+		fmt.Fprintf(&b, "\tsubgraph cluster_%d {\n\t\tlabel=%s;\n", i, quote(source.name()))
+		ungrouped, releases, grouped := source.byRelease()
+		for _, node := range ungrouped {
+			fmt.Fprintf(&b, "\t\t%s [label=%s];\n", quote(node.id), quote(node.label))
+		}
+		for j, release := range releases {
+			fmt.Fprintf(&b, "\t\tsubgraph cluster_%d_%d {\n\t\t\tlabel=%s;\n", i, j, quote(release))
+			for _, node := range grouped[release] {
+				fmt.Fprintf(&b, "\t\t\t%s [label=%s];\n", quote(node.id), quote(node.label))
+			}
+			b.WriteString("\t\t}\n")
+		}
+		b.WriteString("\t}\n")
+	}
+	var previous string
+	for _, source := range sources {
+		for _, node := range source.nodes {
+			if previous != "" {
+				fmt.Fprintf(&b, "\t%s -> %s;\n", quote(previous), quote(node.id))
+			}
+			previous = node.id
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeMermaid writes the graph as Mermaid flowchart, the nodes are numbered because IDs may contain any character
+func writeMermaid(w io.Writer, sources []graphSource) error {
+	quote := func(s string) string { return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"` }
+	ids := make(map[string]string)
+	nodeId := func(id string) string {
+		if _, ok := ids[id]; !ok {
+			ids[id] = fmt.Sprintf("n%d", len(ids))
+		}
+		return ids[id]
+	}
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for i, source := range sources {
+		fmt.Fprintf(&b, "\tsubgraph s%d [%s]\n", i, quote(source.name()))
+		ungrouped, releases, grouped := source.byRelease()
+		for _, node := range ungrouped {
+			fmt.Fprintf(&b, "\t\t%s[%s]\n", nodeId(node.id), quote(node.label))
+		}
+		for j, release := range releases {
+			fmt.Fprintf(&b, "\t\tsubgraph s%d_%d [%s]\n", i, j, quote(release))
+			for _, node := range grouped[release] {
+				fmt.Fprintf(&b, "\t\t\t%s[%s]\n", nodeId(node.id), quote(node.label))
+			}
+			b.WriteString("\t\tend\n")
+		}
+		b.WriteString("\tend\n")
+	}
+	var previous string
+	for _, source := range sources {
+		for _, node := range source.nodes {
+			if previous != "" {
+				fmt.Fprintf(&b, "\t%s --> %s\n", nodeId(previous), nodeId(node.id))
+			}
+			previous = node.id
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
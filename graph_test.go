@@ -0,0 +1,67 @@
+package migrago
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func graphTestService() MigrationService {
+	platform := staticSource{
+		{Id: "0001_roles", Script: "CREATE TABLE roles (id INT)", RevertScript: "DROP TABLE roles"},
+	}
+	fs := fstest.MapFS{
+		"config.json":                   {Data: []byte(`{"migrations": ["0001_init"], "releases": [{"name": "v1", "migrations": ["0002_users"]}]}`)},
+		"scripts/0001_init.sql":         {Data: []byte("CREATE TABLE test (id INT)")},
+		"scripts/0002_users.sql":        {Data: []byte("-- migrago:irreversible\nDROP TABLE test")},
+		"scripts/0001_init.revert.sql":  {Data: []byte("DROP TABLE test")},
+		"scripts/0002_users.revert.sql": {Data: []byte("CREATE TABLE test (id INT)")},
+	}
+	return NewMigrationService("config.json", "scripts", fs, nil, WithSource("platform", platform))
+}
+
+func Test_ExportGraphDOT(t *testing.T) {
+	var b strings.Builder
+	assert.NoError(t, graphTestService().ExportGraph(&b, GraphDOT))
+	assert.Equal(t, `digraph migrations {
+	rankdir=TB;
+	node [shape=box];
+	subgraph cluster_0 {
+		label="platform";
+		"platform/0001_roles" [label="platform/0001_roles"];
+	}
+	subgraph cluster_1 {
+		label="migrations";
+		"0001_init" [label="0001_init"];
+		subgraph cluster_1_0 {
+			label="v1";
+			"0002_users" [label="0002_users (irreversible)"];
+		}
+	}
+	"platform/0001_roles" -> "0001_init";
+	"0001_init" -> "0002_users";
+}
+`, b.String())
+}
+
+func Test_ExportGraphMermaid(t *testing.T) {
+	var b strings.Builder
+	assert.NoError(t, graphTestService().ExportGraph(&b, GraphMermaid))
+	assert.Equal(t, `flowchart TD
+	subgraph s0 ["platform"]
+		n0["platform/0001_roles"]
+	end
+	subgraph s1 ["migrations"]
+		n1["0001_init"]
+		subgraph s1_0 ["v1"]
+			n2["0002_users (irreversible)"]
+		end
+	end
+	n0 --> n1
+	n1 --> n2
+`, b.String())
+
+	assert.ErrorContains(t, graphTestService().ExportGraph(&b, "svg"), `unknown graph format "svg"`)
+}
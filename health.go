@@ -0,0 +1,26 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrationService can be registered with health frameworks which accept a Check(ctx) error method or func
+var _ interface{ Check(context.Context) error } = MigrationService{}
+
+// Check reports the health of the schema: nil if it is up to date, a *PendingMigrationsError if migrations are
+// pending and an error if the changelog is unreachable
+func (m MigrationService) Check(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("changelog unreachable: %w", err)
+	}
+	if len(status.Pending) > 0 {
+		pending := make([]string, len(status.Pending))
+		for i, migration := range status.Pending {
+			pending[i] = migration.Id
+		}
+		return &PendingMigrationsError{Pending: pending}
+	}
+	return nil
+}
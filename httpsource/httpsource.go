@@ -0,0 +1,219 @@
+// Package httpsource provides a migration source which downloads a pinned bundle over HTTPS
+package httpsource
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/Soemii/migrago"
+)
+
+// ManifestFile is the name of the manifest inside a bundle. It maps every file of the bundle to its SHA-256 checksum:
+//
+//	{"config.json": "<sha256>", "scripts/001.sql": "<sha256>", ...}
+const ManifestFile = "manifest.json"
+
+// DefaultMaxSize is the maximum size of a downloaded bundle
+const DefaultMaxSize = 64 << 20
+
+// Option configures optional behaviour of NewFS
+type Option func(*options)
+
+type options struct {
+	maxSize int64
+}
+
+// WithMaxSize sets the maximum size of the downloaded bundle in bytes
+func WithMaxSize(maxSize int64) Option {
+	return func(o *options) {
+		o.maxSize = maxSize
+	}
+}
+
+// bundle is the verified content of a downloaded bundle
+type bundle map[string][]byte
+
+// NewFS downloads the bundle (zip, tar or tar.gz) from bundleURL, verifies it against the pinned SHA-256 digest
+// (hex encoded) and the files against the manifest and returns the content as fs.FS. Only https URLs are accepted.
+func NewFS(ctx context.Context, client *http.Client, bundleURL, digest string, opts ...Option) (fs.FS, error) {
+	o := options{maxSize: DefaultMaxSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	u, err := url.Parse(bundleURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle url: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("bundle url must use https, got %q", u.Scheme)
+	}
+	if digest == "" {
+		return nil, errors.New("bundle digest is required")
+	}
+
+	data, err := download(ctx, client, bundleURL, o.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, digest) {
+		return nil, fmt.Errorf("bundle digest mismatch: expected: %s, actual: %s", digest, actual)
+	}
+	files, err := extract(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := files.verify(); err != nil {
+		return nil, err
+	}
+	return migrago.NewRemoteFS(ctx, files, ""), nil
+}
+
+// download fetches the raw bundle, which must not be larger than maxSize
+func download(ctx context.Context, client *http.Client, bundleURL string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bundleURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download bundle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download bundle: unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download bundle: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("bundle exceeds the maximum size of %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// extract unpacks the bundle, the archive format is detected from the content
+func extract(data []byte) (bundle, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return extractZip(data)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress bundle: %w", err)
+		}
+		defer gz.Close()
+		return extractTar(gz)
+	default:
+		return extractTar(bytes.NewReader(data))
+	}
+}
+
+// extractZip reads all regular files of a zip archive
+func extractZip(data []byte) (bundle, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip bundle: %w", err)
+	}
+	files := bundle{}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+		}
+		files[cleanName(f.Name)] = content
+	}
+	return files, nil
+}
+
+// extractTar reads all regular files of a tar archive
+func extractTar(r io.Reader) (bundle, error) {
+	tr := tar.NewReader(r)
+	files := bundle{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		files[cleanName(hdr.Name)] = content
+	}
+}
+
+// cleanName normalizes archive paths like "./scripts/001.sql"
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// verify checks that the manifest exists and matches every file of the bundle
+func (b bundle) verify() error {
+	raw, ok := b[ManifestFile]
+	if !ok {
+		return fmt.Errorf("bundle does not contain %s", ManifestFile)
+	}
+	var manifest map[string]string
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	for name, content := range b {
+		if name == ManifestFile {
+			continue
+		}
+		expected, ok := manifest[name]
+		if !ok {
+			return fmt.Errorf("file %s is not listed in the manifest", name)
+		}
+		sum := sha256.Sum256(content)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+			return fmt.Errorf("checksum mismatch for %s: manifest: %s, bundle: %s", name, expected, actual)
+		}
+	}
+	for name := range manifest {
+		if _, ok := b[name]; !ok {
+			return fmt.Errorf("file %s from the manifest is missing in the bundle", name)
+		}
+	}
+	return nil
+}
+
+// GetObject returns a file of the bundle
+func (b bundle) GetObject(_ context.Context, key string) (io.ReadCloser, error) {
+	content, ok := b[key]
+	if !ok {
+		return nil, fmt.Errorf("file %s: %w", key, fs.ErrNotExist)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
@@ -0,0 +1,186 @@
+package httpsource
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createZipBundle(t *testing.T, files map[string]string, manifest map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+		f.Write([]byte(content))
+	}
+	if manifest != nil {
+		b, err := json.Marshal(manifest)
+		assert.NoError(t, err)
+		f, err := w.Create(ManifestFile)
+		assert.NoError(t, err)
+		f.Write(b)
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func createTarBundle(t *testing.T, files map[string]string, manifest map[string]string, compress bool) []byte {
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(&buf)
+		out = gz
+	}
+	w := tar.NewWriter(out)
+	add := func(name string, content []byte) {
+		assert.NoError(t, w.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := w.Write(content)
+		assert.NoError(t, err)
+	}
+	for name, content := range files {
+		add(name, []byte(content))
+	}
+	b, err := json.Marshal(manifest)
+	assert.NoError(t, err)
+	add(ManifestFile, b)
+	assert.NoError(t, w.Close())
+	if gz != nil {
+		assert.NoError(t, gz.Close())
+	}
+	return buf.Bytes()
+}
+
+func serveBundle(data []byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(data) }))
+}
+
+func sha(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_NewFS(t *testing.T) {
+	files := map[string]string{
+		"config.json":             `["Test"]`,
+		"scripts/Test.sql":        "CREATE TABLE test (id INT)",
+		"scripts/Test.revert.sql": "DROP TABLE test",
+	}
+	validManifest := map[string]string{}
+	for name, content := range files {
+		validManifest[name] = sha(content)
+	}
+
+	t.Run("Test with valid bundle", func(t *testing.T) {
+		data := createZipBundle(t, files, validManifest)
+		server := serveBundle(data)
+		defer server.Close()
+
+		fsys, err := NewFS(context.Background(), server.Client(), server.URL+"/bundle.zip", sha(string(data)))
+		assert.NoError(t, err)
+		f, err := fsys.Open("scripts/Test.sql")
+		assert.NoError(t, err)
+		content, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, "CREATE TABLE test (id INT)", string(content))
+	})
+	t.Run("Test with tampered file", func(t *testing.T) {
+		manifest := map[string]string{}
+		for name, sum := range validManifest {
+			manifest[name] = sum
+		}
+		manifest["scripts/Test.sql"] = sha("CREATE TABLE other (id INT)")
+		data := createZipBundle(t, files, manifest)
+		server := serveBundle(data)
+		defer server.Close()
+
+		_, err := NewFS(context.Background(), server.Client(), server.URL+"/bundle.zip", sha(string(data)))
+		assert.ErrorContains(t, err, "checksum mismatch for scripts/Test.sql")
+	})
+	t.Run("Test without manifest", func(t *testing.T) {
+		data := createZipBundle(t, files, nil)
+		server := serveBundle(data)
+		defer server.Close()
+
+		_, err := NewFS(context.Background(), server.Client(), server.URL+"/bundle.zip", sha(string(data)))
+		assert.ErrorContains(t, err, "does not contain manifest.json")
+	})
+	t.Run("Test with plain http", func(t *testing.T) {
+		_, err := NewFS(context.Background(), http.DefaultClient, "http://example.com/bundle.zip", sha(""))
+		assert.ErrorContains(t, err, "must use https")
+	})
+	t.Run("Test without digest", func(t *testing.T) {
+		_, err := NewFS(context.Background(), http.DefaultClient, "https://example.com/bundle.zip", "")
+		assert.EqualError(t, err, "bundle digest is required")
+	})
+	t.Run("Test with replaced bundle and manifest", func(t *testing.T) {
+		pinned := createZipBundle(t, files, validManifest)
+		// the attacker controls the manifest, it matches the tampered files
+		tampered := map[string]string{"config.json": `["Test"]`, "scripts/Test.sql": "DROP TABLE users"}
+		manifest := map[string]string{}
+		for name, content := range tampered {
+			manifest[name] = sha(content)
+		}
+		server := serveBundle(createZipBundle(t, tampered, manifest))
+		defer server.Close()
+
+		_, err := NewFS(context.Background(), server.Client(), server.URL+"/bundle.zip", sha(string(pinned)))
+		assert.ErrorContains(t, err, "bundle digest mismatch")
+	})
+	t.Run("Test with bundle exceeding the maximum size", func(t *testing.T) {
+		data := createZipBundle(t, files, validManifest)
+		server := serveBundle(data)
+		defer server.Close()
+
+		_, err := NewFS(context.Background(), server.Client(), server.URL+"/bundle.zip", sha(string(data)), WithMaxSize(int64(len(data)-1)))
+		assert.EqualError(t, err, fmt.Sprintf("bundle exceeds the maximum size of %d bytes", len(data)-1))
+
+		_, err = NewFS(context.Background(), server.Client(), server.URL+"/bundle.zip", sha(string(data)), WithMaxSize(int64(len(data))))
+		assert.NoError(t, err)
+	})
+	for _, compress := range []bool{false, true} {
+		name := "Test with tar bundle"
+		if compress {
+			name = "Test with tar.gz bundle"
+		}
+		t.Run(name, func(t *testing.T) {
+			data := createTarBundle(t, files, validManifest, compress)
+			server := serveBundle(data)
+			defer server.Close()
+
+			fsys, err := NewFS(context.Background(), server.Client(), server.URL+"/bundle", sha(string(data)))
+			assert.NoError(t, err)
+			f, err := fsys.Open("scripts/Test.revert.sql")
+			assert.NoError(t, err)
+			content, err := io.ReadAll(f)
+			assert.NoError(t, err)
+			assert.Equal(t, "DROP TABLE test", string(content))
+		})
+		t.Run(name+" with tampered file", func(t *testing.T) {
+			manifest := map[string]string{}
+			for name, sum := range validManifest {
+				manifest[name] = sum
+			}
+			manifest["config.json"] = sha(`["Other"]`)
+			data := createTarBundle(t, files, manifest, compress)
+			server := serveBundle(data)
+			defer server.Close()
+
+			_, err := NewFS(context.Background(), server.Client(), server.URL+"/bundle", sha(string(data)))
+			assert.ErrorContains(t, err, "checksum mismatch for config.json")
+		})
+	}
+}
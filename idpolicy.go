@@ -0,0 +1,101 @@
+package migrago
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IDPolicy is a naming convention for migration IDs, it is enforced when the migrations are loaded
+type IDPolicy struct {
+	// Pattern has to match the IDs without the namespace of the source
+	Pattern *regexp.Regexp
+	// Description explains the convention in violation errors, e.g. "<yyyymmddhhmmss>_<name>"
+	Description string
+	// generate builds a new ID from a name, nil uses the name as ID
+	generate func(name string, existing []string, now time.Time) string
+}
+
+var (
+	// IDPolicyTimestamp requires IDs like 20261016120000_add_users, new IDs are prefixed with the current UTC time
+	IDPolicyTimestamp = IDPolicy{
+		Pattern:     regexp.MustCompile(`^\d{14}_[a-z0-9_]+$`),
+		Description: "<yyyymmddhhmmss>_<name>",
+		generate: func(name string, _ []string, now time.Time) string {
+			return now.UTC().Format("20060102150405") + "_" + name
+		},
+	}
+	// IDPolicySequential requires IDs like 0007_add_users, new IDs get the next number
+	IDPolicySequential = IDPolicy{
+		Pattern:     regexp.MustCompile(`^\d{4,}_[a-z0-9_]+$`),
+		Description: "<nnnn>_<name>",
+		generate: func(name string, existing []string, _ time.Time) string {
+			next := 1
+			for _, id := range existing {
+				prefix, _, _ := strings.Cut(id, "_")
+				if n, err := strconv.Atoi(prefix); err == nil && n >= next {
+					next = n + 1
+				}
+			}
+			return fmt.Sprintf("%04d_%s", next, name)
+		},
+	}
+)
+
+// ParseIDPolicy returns the preset "timestamp" or "sequential", any other value is used as regular expression
+func ParseIDPolicy(policy string) (IDPolicy, error) {
+	switch policy {
+	case "timestamp":
+		return IDPolicyTimestamp, nil
+	case "sequential":
+		return IDPolicySequential, nil
+	}
+	pattern, err := regexp.Compile(policy)
+	if err != nil {
+		return IDPolicy{}, fmt.Errorf("invalid ID policy: %w", err)
+	}
+	return IDPolicy{Pattern: pattern, Description: pattern.String()}, nil
+}
+
+// Check returns an error listing all IDs which violate the policy
+func (p IDPolicy) Check(migrationIds ...string) error {
+	var violations []string
+	for _, id := range migrationIds {
+		if !p.Pattern.MatchString(id) {
+			violations = append(violations, id)
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%d migration IDs do not match the naming policy %s: %s", len(violations), p.Description, strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+// NewID builds the ID of a new migration for the scaffolding from a free text name, e.g. "Add users" becomes
+// 0007_add_users with the sequential policy. Policies with a custom pattern use the name as it is.
+func (p IDPolicy) NewID(name string, existing []string, now time.Time) (string, error) {
+	id := name
+	if p.generate != nil {
+		id = p.generate(slug(name), existing, now)
+	}
+	if err := p.Check(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// slug converts a name to lower snake case
+func slug(name string) string {
+	var b strings.Builder
+	for _, field := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < '0' || r > '9')
+	}) {
+		if b.Len() > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteString(field)
+	}
+	return b.String()
+}
@@ -0,0 +1,39 @@
+package migrago
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IDPolicy(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+	id, err := IDPolicyTimestamp.NewID("Add users", nil, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "20261016123000_add_users", id)
+
+	id, err = IDPolicySequential.NewID("add-teams", []string{"0001_init", "0007_users", "hotfix"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "0008_add_teams", id)
+
+	policy, err := ParseIDPolicy(`^[A-Z]+-\d+$`)
+	assert.NoError(t, err)
+	_, err = policy.NewID("add users", nil, now)
+	assert.ErrorContains(t, err, "do not match the naming policy")
+	_, err = ParseIDPolicy("[")
+	assert.ErrorContains(t, err, "invalid ID policy")
+
+	assert.EqualError(t, IDPolicySequential.Check("0001_init", "users", "02_teams"),
+		"2 migration IDs do not match the naming policy <nnnn>_<name>: users, 02_teams")
+}
+
+func Test_getMigrationsIDPolicy(t *testing.T) {
+	fs := CreateFSForMigrations([]Migration{
+		{Id: "0001_init", Script: "SELECT 1", RevertScript: "SELECT 1"},
+		{Id: "AddUsers", Script: "SELECT 1", RevertScript: "SELECT 1"},
+	})
+	service := NewMigrationService("config.json", "scripts", fs, nil, WithIDPolicy(IDPolicySequential))
+	_, _, err := service.getMigrations()
+	assert.ErrorContains(t, err, "1 migration IDs do not match the naming policy <nnnn>_<name>: AddUsers")
+}
@@ -0,0 +1,43 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Statement is a statement of a migration script passed to the interceptors
+type Statement struct {
+	MigrationId string
+	// SQL is the statement, interceptors may rewrite it before passing it on
+	SQL string
+	// Revert is set for revert scripts, they are executed as a single statement
+	Revert bool
+}
+
+// StatementExecutor executes a statement, the result is nil if the statement returns none,
+// e.g. if it was run on the Citus workers or submitted as Vitess online DDL
+type StatementExecutor func(ctx context.Context, statement Statement) (sql.Result, error)
+
+// StatementInterceptor sees every statement of the migration scripts before its execution and its result after,
+// e.g. to inject tablespace clauses, reject statements violating a policy or collect telemetry. It executes the
+// statement by calling next, possibly with a rewritten statement, or fails the migration by returning an error
+// without calling next. Statements run by an online schema change tool and backfill batches are not intercepted.
+type StatementInterceptor func(ctx context.Context, statement Statement, next StatementExecutor) (sql.Result, error)
+
+// intercept wraps the executor in the interceptors of the service, the first registered interceptor is the outermost
+func (m MigrationService) intercept(exec StatementExecutor) StatementExecutor {
+	for i := len(m.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := m.interceptors[i], exec
+		exec = func(ctx context.Context, statement Statement) (sql.Result, error) {
+			return interceptor(ctx, statement, next)
+		}
+	}
+	return exec
+}
+
+// execStatement executes a statement of a migration script through the interceptors
+func (m MigrationService) execStatement(ctx context.Context, e execer, statement Statement) (sql.Result, error) {
+	return m.intercept(func(ctx context.Context, statement Statement) (sql.Result, error) {
+		return e.ExecContext(ctx, statement.SQL)
+	})(ctx, statement)
+}
@@ -0,0 +1,55 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_intercept(t *testing.T) {
+	var calls []string
+	trace := func(name string) StatementInterceptor {
+		return func(ctx context.Context, statement Statement, next StatementExecutor) (sql.Result, error) {
+			calls = append(calls, name+" before "+statement.SQL)
+			result, err := next(ctx, statement)
+			calls = append(calls, name+" after")
+			return result, err
+		}
+	}
+	rewrite := func(ctx context.Context, statement Statement, next StatementExecutor) (sql.Result, error) {
+		statement.SQL += " TABLESPACE fast"
+		return next(ctx, statement)
+	}
+	m := NewMigrationService("config.json", "scripts", nil, nil, WithStatementInterceptors(trace("outer"), rewrite, trace("inner")))
+	_, err := m.intercept(func(ctx context.Context, statement Statement) (sql.Result, error) {
+		calls = append(calls, "exec "+statement.SQL)
+		return nil, nil
+	})(context.Background(), Statement{MigrationId: "1", SQL: "CREATE TABLE t (id int)"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"outer before CREATE TABLE t (id int)",
+		"inner before CREATE TABLE t (id int) TABLESPACE fast",
+		"exec CREATE TABLE t (id int) TABLESPACE fast",
+		"inner after",
+		"outer after",
+	}, calls)
+
+	policy := func(ctx context.Context, statement Statement, next StatementExecutor) (sql.Result, error) {
+		if strings.HasPrefix(statement.SQL, "DROP") {
+			return nil, errors.New("DROP is not allowed")
+		}
+		return next(ctx, statement)
+	}
+	executed := false
+	m = NewMigrationService("config.json", "scripts", nil, nil, WithStatementInterceptors(policy))
+	_, err = m.intercept(func(ctx context.Context, statement Statement) (sql.Result, error) {
+		executed = true
+		return nil, nil
+	})(context.Background(), Statement{MigrationId: "1", SQL: "DROP TABLE t"})
+	assert.ErrorContains(t, err, "DROP is not allowed")
+	assert.False(t, executed)
+}
@@ -0,0 +1,20 @@
+package migrago
+
+// stopRequested checks if the stop channel is closed, the run then stops before the next migration
+func (m MigrationService) stopRequested() bool {
+	select {
+	case <-m.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// interrupted creates the error of a run stopped before the remaining migrations
+func interrupted(reason string, remaining []Migration) *InterruptedError {
+	ids := make([]string, len(remaining))
+	for i, migration := range remaining {
+		ids[i] = migration.Id
+	}
+	return &InterruptedError{Reason: reason, Remaining: ids}
+}
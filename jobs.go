@@ -0,0 +1,214 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultJobStaleTimeout is the time after the last heartbeat of a running job until it counts as orphaned
+const DefaultJobStaleTimeout = 5 * time.Minute
+
+// JobStatus is the state of an async migration scheduled by ExecuteMigration
+type JobStatus struct {
+	Id          string
+	ScheduledAt time.Time
+	// StartedAt and FinishedAt are zero if the job did not start or finish yet
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Error is the error of the last failed attempt, failed jobs are retried by the next RunAsyncJobs. Jobs of
+	// workers which stopped without finishing them are marked failed once their heartbeat is older than the
+	// stale timeout.
+	Error string
+}
+
+// scheduleJobs inserts the async migrations into the job table, already scheduled migrations are kept
+func (m MigrationService) scheduleJobs(ctx context.Context, migrations []Migration) error {
+	for _, migration := range migrations {
+		inserted, err := m.insertIgnore(ctx, m.conn, "changelog_job", []string{"id"}, migration.Id)
+		if err != nil {
+			return fmt.Errorf("failed to schedule migration %s: %w", migration.Id, err)
+		}
+		if inserted {
+			m.log().InfoContext(ctx, "async migration scheduled", "id", migration.Id)
+		}
+	}
+	return nil
+}
+
+// jobStaleTimeout returns the configured stale timeout of running jobs or DefaultJobStaleTimeout
+func (m MigrationService) jobStaleTimeout() time.Duration {
+	if m.jobStale > 0 {
+		return m.jobStale
+	}
+	return DefaultJobStaleTimeout
+}
+
+// failOrphanedJobs marks running jobs whose heartbeat is older than the stale timeout as failed, so the worker
+// which crashed while running them does not block them forever
+func (m MigrationService) failOrphanedJobs(ctx context.Context) error {
+	query, args := m.rebind(`UPDATE changelog_job SET error = 'worker stopped without finishing the job'
+		WHERE finishedAt IS NULL AND startedAt IS NOT NULL AND error IS NULL
+		AND COALESCE(heartbeatAt, startedAt) < CURRENT_TIMESTAMP - $1::BIGINT * INTERVAL '1 millisecond'
+		RETURNING id`, m.jobStaleTimeout().Milliseconds())
+	rows, err := m.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query changelog_job: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		m.log().WarnContext(ctx, "async migration orphaned by a stopped worker, retrying it", "id", id)
+	}
+	return rows.Err()
+}
+
+// claimJob marks the next unfinished job as started and returns its id, ok is false if there is no job left.
+// Jobs locked by another worker are skipped.
+func (m MigrationService) claimJob(ctx context.Context) (id string, ok bool, err error) {
+	if err := m.failOrphanedJobs(ctx); err != nil {
+		return "", false, err
+	}
+	err = m.conn.QueryRowContext(ctx, `UPDATE changelog_job SET startedAt = CURRENT_TIMESTAMP, heartbeatAt = CURRENT_TIMESTAMP, error = NULL
+		WHERE id = (
+			SELECT id FROM changelog_job WHERE finishedAt IS NULL AND (startedAt IS NULL OR error IS NOT NULL)
+			ORDER BY scheduledAt, id LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING id`).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return id, true, nil
+}
+
+// heartbeatJob refreshes the heartbeat of a running job until the returned function is called
+func (m MigrationService) heartbeatJob(ctx context.Context, id string) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(max(m.jobStaleTimeout()/3, time.Millisecond))
+		defer ticker.Stop()
+		query, args := m.rebind(`UPDATE changelog_job SET heartbeatAt = CURRENT_TIMESTAMP WHERE id = $1`, id)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := m.conn.ExecContext(ctx, query, args...); err != nil {
+					m.log().WarnContext(ctx, "failed to refresh the heartbeat of async migration", "id", id, "error", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// finishJob stores the outcome of a job
+func (m MigrationService) finishJob(ctx context.Context, id string, jobErr error) error {
+	var err error
+	if jobErr != nil {
+		query, args := m.rebind(`UPDATE changelog_job SET error = $2 WHERE id = $1`, id, jobErr.Error())
+		_, err = m.conn.ExecContext(ctx, query, args...)
+	} else {
+		query, args := m.rebind(`UPDATE changelog_job SET finishedAt = CURRENT_TIMESTAMP WHERE id = $1`, id)
+		_, err = m.conn.ExecContext(ctx, query, args...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update changelog_job: %w", err)
+	}
+	return nil
+}
+
+// RunAsyncJobs executes the async migrations scheduled by ExecuteMigration one after another and returns
+// the number of executed jobs. It stops at the first failing job, which is retried by the next call.
+func (m MigrationService) RunAsyncJobs(ctx context.Context) (int, error) {
+	m, err := m.ensurePrimary(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.prepareDatabase(ctx); err != nil {
+		return 0, err
+	}
+	_, migrations, err := m.getMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	executed := 0
+	for {
+		id, ok, err := m.claimJob(ctx)
+		if err != nil || !ok {
+			return executed, err
+		}
+		stopHeartbeat := m.heartbeatJob(ctx, id)
+		jobErr := m.runJob(ctx, id, migrations)
+		stopHeartbeat()
+		if err := m.finishJob(context.WithoutCancel(ctx), id, jobErr); err != nil {
+			return executed, err
+		}
+		if jobErr != nil {
+			return executed, fmt.Errorf("async migration %s failed: %w", id, jobErr)
+		}
+		executed++
+	}
+}
+
+// runJob executes the migration of a job unless it is already applied
+func (m MigrationService) runJob(ctx context.Context, id string, migrations map[string]Migration) error {
+	migration, ok := migrations[id]
+	if !ok {
+		return fmt.Errorf("migration %s not found in the configuration", id)
+	}
+	var applied bool
+	query, args := m.rebind(`SELECT EXISTS (SELECT 1 FROM changelog WHERE id = $1)`, id)
+	if err := m.conn.QueryRowContext(ctx, query, args...).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to query changelog: %w", err)
+	}
+	if applied {
+		return nil
+	}
+	m.log().InfoContext(ctx, "executing async migration", "id", id)
+	return m.executeSingleMigration(ctx, migration)
+}
+
+// getJobs returns all jobs in scheduling order
+func (m MigrationService) getJobs(ctx context.Context) ([]JobStatus, error) {
+	rows, err := m.conn.QueryContext(ctx, `SELECT id, scheduledAt, startedAt, finishedAt, error FROM changelog_job ORDER BY scheduledAt, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changelog_job: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []JobStatus
+	for rows.Next() {
+		var job JobStatus
+		var startedAt, finishedAt sql.NullTime
+		var jobErr sql.NullString
+		if err := rows.Scan(&job.Id, &job.ScheduledAt, &startedAt, &finishedAt, &jobErr); err != nil {
+			return nil, err
+		}
+		job.ScheduledAt = job.ScheduledAt.UTC()
+		if startedAt.Valid {
+			job.StartedAt = startedAt.Time.UTC()
+		}
+		if finishedAt.Valid {
+			job.FinishedAt = finishedAt.Time.UTC()
+		}
+		job.Error = jobErr.String
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
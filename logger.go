@@ -0,0 +1,17 @@
+package migrago
+
+import (
+	"io"
+	"log/slog"
+)
+
+// discardLogger is used if no logger is configured
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// log returns the configured logger
+func (m MigrationService) log() *slog.Logger {
+	if m.logger == nil {
+		return discardLogger
+	}
+	return m.logger
+}
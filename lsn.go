@@ -0,0 +1,19 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// currentLSN returns the current write-ahead log position of the database, it is empty for other databases than Postgres
+func (m MigrationService) currentLSN(ctx context.Context, tx *sql.Tx) (string, error) {
+	if !m.postgres() {
+		return "", nil
+	}
+	var lsn string
+	if err := tx.QueryRowContext(ctx, `SELECT pg_current_wal_lsn()`).Scan(&lsn); err != nil {
+		return "", fmt.Errorf("failed to query the current WAL LSN: %w", err)
+	}
+	return lsn, nil
+}
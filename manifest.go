@@ -0,0 +1,105 @@
+package migrago
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Manifest lists migrations in execution order with the paths and precomputed checksums of their scripts.
+// It is generated by "migrago gen-manifest" from the config file and the scripts of a FileSource.
+type Manifest []ManifestEntry
+
+// ManifestEntry is a migration of a Manifest
+type ManifestEntry struct {
+	// Id is empty for the entries of Variants
+	Id string
+	// Script and RevertScript are the paths of the scripts in the file system, RevertScript is empty
+	// if the migration has no revert script
+	Script       string
+	RevertScript string
+	// Checksum is the checksum of the script
+	Checksum string
+	// Directives contains the "-- migrago:" lines of the script
+	Directives []string
+	// Description is the first comment line of the script
+	Description string
+	// Variants contains the dialect specific scripts by dialect name, it is used when Script is empty
+	Variants map[string]ManifestEntry
+}
+
+// Manifest lists the migrations of the source in execution order with the paths and checksums of their scripts
+func (s FileSource) Manifest() (Manifest, error) {
+	migrationIds, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	manifest := make(Manifest, 0, len(migrationIds))
+	for _, id := range migrationIds {
+		dir, err := s.scriptDir(id)
+		if err != nil {
+			return nil, err
+		}
+		migration, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		entry := ManifestEntry{Id: id}
+		if len(migration.Variants) == 0 {
+			if entry.Directives, entry.Description, err = scriptDirectives(migration); err != nil {
+				return nil, err
+			}
+			entry.Script = path.Join(dir, id+".sql")
+			entry.Checksum = migration.Checksum
+			if !migration.NoRevertScript {
+				entry.RevertScript = path.Join(dir, id+".revert.sql")
+			}
+			manifest = append(manifest, entry)
+			continue
+		}
+
+		entry.Variants = make(map[string]ManifestEntry, len(migration.Variants))
+		for dialect, variant := range migration.Variants {
+			directives, description, err := scriptDirectives(Migration{Script: variant.Script})
+			if err != nil {
+				return nil, err
+			}
+			variantEntry := ManifestEntry{
+				Script:      path.Join(dir, id+"."+dialect+".sql"),
+				Checksum:    variant.Checksum,
+				Directives:  directives,
+				Description: description,
+			}
+			if !variant.NoRevertScript {
+				variantEntry.RevertScript = path.Join(dir, id+"."+dialect+".revert.sql")
+			}
+			entry.Variants[dialect] = variantEntry
+		}
+		manifest = append(manifest, entry)
+	}
+	return manifest, nil
+}
+
+// Dialects returns the names of the dialect variants of the entry in lexical order
+func (e ManifestEntry) Dialects() []string {
+	dialects := make([]string, 0, len(e.Variants))
+	for dialect := range e.Variants {
+		dialects = append(dialects, dialect)
+	}
+	slices.Sort(dialects)
+	return dialects
+}
+
+// scriptDirectives returns the directive lines and the description of the script of a migration,
+// streamed scripts are read once more
+func scriptDirectives(migration Migration) ([]string, string, error) {
+	if migration.OpenScript == nil {
+		return directiveLines(strings.NewReader(migration.Script))
+	}
+	r, err := migration.OpenScript()
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+	return directiveLines(r)
+}
@@ -0,0 +1,99 @@
+package migrago
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ManifestSource is a Source reading the migrations of a Manifest, usually generated by "migrago gen-manifest".
+// The checksums and directives are taken from the manifest, so listing and loading the migrations does not read
+// any script. The scripts are read when a migration is executed and have to match the checksum of the manifest.
+type ManifestSource struct {
+	manifest Manifest
+	index    map[string]int
+	fs       fs.FS
+}
+
+// ManifestSource constructor, the paths of the manifest entries are relative to the root of fs
+func NewManifestSource(manifest Manifest, fs fs.FS) ManifestSource {
+	index := make(map[string]int, len(manifest))
+	for i, entry := range manifest {
+		index[entry.Id] = i
+	}
+	return ManifestSource{
+		manifest: manifest,
+		index:    index,
+		fs:       fs,
+	}
+}
+
+// List returns the migration IDs of the manifest
+func (s ManifestSource) List() ([]string, error) {
+	migrationIds := make([]string, len(s.manifest))
+	for i, entry := range s.manifest {
+		migrationIds[i] = entry.Id
+	}
+	return migrationIds, nil
+}
+
+// Load returns the migration without reading its scripts
+func (s ManifestSource) Load(migrationId string) (Migration, error) {
+	i, ok := s.index[migrationId]
+	if !ok {
+		return Migration{}, fmt.Errorf("migration %s is not in the manifest", migrationId)
+	}
+	return lazyMigration(migrationId, s.fs, s.manifest[i]), nil
+}
+
+// manifestScripts reads the scripts of a manifest entry on demand
+type manifestScripts struct {
+	fs    fs.FS
+	entry ManifestEntry
+}
+
+// lazyMigration returns a migration whose scripts are read from fsys when they are needed
+func lazyMigration(migrationId string, fsys fs.FS, entry ManifestEntry) Migration {
+	scripts := &manifestScripts{fs: fsys, entry: entry}
+	migration := Migration{Id: migrationId, lazy: scripts}
+	if len(entry.Variants) == 0 {
+		migration.Checksum = entry.Checksum
+		migration.NoRevertScript = entry.RevertScript == ""
+		migration.OpenScript = scripts.open
+	}
+	return migration
+}
+
+// open opens the script of the entry, e.g. to check the statements of a migration which is not executed
+func (s *manifestScripts) open() (io.ReadCloser, error) {
+	f, err := s.fs.Open(s.entry.Script)
+	if err != nil {
+		return nil, err
+	}
+	return normalizedFile{Reader: normalizeEncoding(f), Closer: f}, nil
+}
+
+// loadScripts reads the scripts of a migration loaded from a manifest into memory before it is executed,
+// other migrations are returned unchanged. A script which was changed after the manifest was generated is rejected.
+func (mig Migration) loadScripts() (Migration, error) {
+	if mig.lazy == nil {
+		return mig, nil
+	}
+	entry := mig.lazy.entry
+	script, checksum, err := readScript(mig.lazy.fs, entry.Script)
+	if err != nil {
+		return mig, fmt.Errorf("migration %s: %w", mig.Id, err)
+	}
+	if checksum != entry.Checksum {
+		return mig, fmt.Errorf("migration %s: script %s does not match the checksum %s of the manifest, regenerate the manifest", mig.Id, entry.Script, entry.Checksum)
+	}
+	if entry.RevertScript != "" {
+		if mig.RevertScript, err = readFileContent(mig.lazy.fs, entry.RevertScript); err != nil {
+			return mig, fmt.Errorf("migration %s: %w", mig.Id, err)
+		}
+	}
+	mig.Script = script
+	mig.OpenScript = nil
+	mig.lazy = nil
+	return mig, nil
+}
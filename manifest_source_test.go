@@ -0,0 +1,99 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ManifestSource(t *testing.T) {
+	fs := fstest.MapFS{
+		"config.json":                            {Data: []byte(`{"migrations": ["0001_init", "0002_teams"]}`)},
+		"scripts/0001_init.sql":                  {Data: []byte("-- migrago:heavy\nCREATE SCHEMA app")},
+		"scripts/0001_init.revert.sql":           {Data: []byte("DROP SCHEMA app")},
+		"scripts/0002_teams.postgres.sql":        {Data: []byte("CREATE TABLE teams ()")},
+		"scripts/0002_teams.postgres.revert.sql": {Data: []byte("DROP TABLE teams")},
+		"scripts/0002_teams.mysql.sql":           {Data: []byte("CREATE TABLE teams ()")},
+	}
+	manifest, err := NewFileSource("config.json", "scripts", fs).Manifest()
+	assert.NoError(t, err)
+	source := NewManifestSource(manifest, fs)
+	service := MigrationService{}
+
+	migrationIds, err := source.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0001_init", "0002_teams"}, migrationIds)
+	_, err = source.Load("0003_unknown")
+	assert.EqualError(t, err, "migration 0003_unknown is not in the manifest")
+
+	t.Run("Directives and checksums are taken from the manifest", func(t *testing.T) {
+		// The scripts are not needed to load the migrations
+		empty := NewManifestSource(manifest, fstest.MapFS{})
+		migration, err := service.loadMigration(namedSource{source: empty}, "0001_init")
+		assert.NoError(t, err)
+		assert.Equal(t, manifest[0].Checksum, migration.Checksum)
+		assert.True(t, migration.Metadata.Heavy)
+		assert.Empty(t, migration.Script)
+
+		migration, err = service.loadMigration(namedSource{source: empty}, "0002_teams")
+		assert.NoError(t, err)
+		assert.Equal(t, manifest[1].Variants["postgres"].Checksum, migration.Checksum)
+		assert.False(t, migration.NoRevertScript)
+	})
+
+	t.Run("Scripts are read before execution", func(t *testing.T) {
+		migration, err := service.loadMigration(namedSource{source: source}, "0001_init")
+		assert.NoError(t, err)
+		migration, err = migration.loadScripts()
+		assert.NoError(t, err)
+		assert.Equal(t, "-- migrago:heavy\nCREATE SCHEMA app", migration.Script)
+		assert.Equal(t, "DROP SCHEMA app", migration.RevertScript)
+		assert.Nil(t, migration.OpenScript)
+	})
+
+	t.Run("Scripts changed after generating the manifest are rejected", func(t *testing.T) {
+		changed := fstest.MapFS{"scripts/0001_init.sql": {Data: []byte("CREATE SCHEMA other")}}
+		migration, err := service.loadMigration(namedSource{source: NewManifestSource(manifest, changed)}, "0001_init")
+		assert.NoError(t, err)
+		_, err = migration.loadScripts()
+		assert.ErrorContains(t, err, "does not match the checksum")
+	})
+}
+
+func Test_ManifestSourceMarkApplied(t *testing.T) {
+	t.Run("Test a migration marked as applied stores the revert script of the manifest", func(t *testing.T) {
+		ctx := context.Background()
+		fs := fstest.MapFS{
+			"config.json":                    {Data: []byte(`{"migrations": ["0001_users", "0002_orders"]}`)},
+			"scripts/0001_users.sql":         {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+			"scripts/0001_users.revert.sql":  {Data: []byte("DROP TABLE users;")},
+			"scripts/0002_orders.sql":        {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY);")},
+			"scripts/0002_orders.revert.sql": {Data: []byte("DROP TABLE orders;")},
+		}
+		manifest, err := NewFileSource("config.json", "scripts", fs).Manifest()
+		assert.NoError(t, err)
+		db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+		assert.NoError(t, err)
+		defer db.Close()
+
+		service := NewMigrationServiceFromSource(NewManifestSource(manifest, fs), db, WithDialect(SQLiteDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		_, err = db.ExecContext(ctx, `DELETE FROM changelog WHERE id = '0002_orders'`)
+		assert.NoError(t, err)
+		assert.NoError(t, service.MarkApplied(ctx, "0002_orders"))
+		var revertScript string
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT revertscript FROM changelog WHERE id = '0002_orders'`).Scan(&revertScript))
+		assert.Equal(t, "DROP TABLE orders;", revertScript)
+
+		// The removed migration is reverted with the stored script
+		service = NewMigrationServiceFromSource(NewManifestSource(manifest[:1], fs), db, WithDialect(SQLiteDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var exists bool
+		assert.NoError(t, db.QueryRowContext(ctx, SQLiteDialect{}.TableExistsQuery("orders")).Scan(&exists))
+		assert.False(t, exists)
+	})
+}
@@ -0,0 +1,36 @@
+package migrago
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileSourceManifest(t *testing.T) {
+	fs := fstest.MapFS{
+		"config.json":                     {Data: []byte(`{"directories": ["users"], "migrations": ["0001_init", "0002_users", "0003_teams"]}`)},
+		"scripts/0001_init.sql":           {Data: []byte("-- migrago:heavy\nCREATE SCHEMA app")},
+		"scripts/0001_init.revert.sql":    {Data: []byte("DROP SCHEMA app")},
+		"scripts/users/0002_users.sql":    {Data: []byte("CREATE TABLE users ()")},
+		"scripts/0003_teams.postgres.sql": {Data: []byte("CREATE TABLE teams ()")},
+		"scripts/0003_teams.mysql.sql":    {Data: []byte("CREATE TABLE teams ()")},
+	}
+	manifest, err := NewFileSource("config.json", "scripts", fs).Manifest()
+	assert.NoError(t, err)
+	assert.Equal(t, Manifest{
+		{
+			Id:           "0001_init",
+			Script:       "scripts/0001_init.sql",
+			RevertScript: "scripts/0001_init.revert.sql",
+			Checksum:     calculateChecksum("-- migrago:heavy\nCREATE SCHEMA app"),
+			Directives:   []string{"-- migrago:heavy"},
+		},
+		{Id: "0002_users", Script: "scripts/users/0002_users.sql", Checksum: calculateChecksum("CREATE TABLE users ()")},
+		{Id: "0003_teams", Variants: map[string]ManifestEntry{
+			"mysql":    {Script: "scripts/0003_teams.mysql.sql", Checksum: calculateChecksum("CREATE TABLE teams ()")},
+			"postgres": {Script: "scripts/0003_teams.postgres.sql", Checksum: calculateChecksum("CREATE TABLE teams ()")},
+		}},
+	}, manifest)
+	assert.Equal(t, []string{"mysql", "postgres"}, manifest[2].Dialects())
+}
@@ -0,0 +1,135 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// MarkApplied inserts a migration into the changelog without executing its script,
+// e.g. to reconcile the state after a migration was applied manually during an incident
+func (m MigrationService) MarkApplied(ctx context.Context, migrationId string) error {
+	if err := m.prepareDatabase(ctx); err != nil {
+		return err
+	}
+
+	_, migrations, err := m.getMigrations()
+	if err != nil {
+		return err
+	}
+	migration, ok := migrations[migrationId]
+	if !ok {
+		return fmt.Errorf("migration %s not found in the configuration", migrationId)
+	}
+
+	existingMigrations, err := m.getExistingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(existingMigrations, func(e Migration) bool { return e.Id == migrationId }) {
+		return fmt.Errorf("migration %s is already applied", migrationId)
+	}
+
+	tx, err := m.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.insertChangelog(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	m.log().Info("migration marked as applied", "id", migrationId)
+	return tx.Commit()
+}
+
+// RerunMigration reverts (if possible) and re-applies a single migration and updates the changelog,
+// e.g. when the original run succeeded but produced wrong results due to environment issues
+func (m MigrationService) RerunMigration(ctx context.Context, migrationId string) error {
+	if err := m.prepareDatabase(ctx); err != nil {
+		return err
+	}
+
+	_, migrations, err := m.getMigrations()
+	if err != nil {
+		return err
+	}
+	migration, ok := migrations[migrationId]
+	if !ok {
+		return fmt.Errorf("migration %s not found in the configuration", migrationId)
+	}
+
+	existingMigrations, err := m.getExistingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(existingMigrations, func(e Migration) bool { return e.Id == migrationId })
+	if index < 0 {
+		return m.applyInPhase(ctx, migration)
+	}
+
+	applied := existingMigrations[index]
+	if err := m.checkRevertable(applied); err != nil {
+		// The script is executed again on top of the current state
+		m.log().Warn("migration is re-applied without revert", "id", migrationId, "reason", err)
+		return m.reapplySingleMigration(ctx, migration)
+	}
+	if err := m.revertInPhase(ctx, applied); err != nil {
+		return err
+	}
+	return m.applyInPhase(ctx, migration)
+}
+
+// reapplySingleMigration executes the script of an applied migration again like a pending one and replaces its
+// changelog entry
+func (m MigrationService) reapplySingleMigration(ctx context.Context, migration Migration) error {
+	m.replaceChangelog = true
+	return m.applyInPhase(ctx, migration)
+}
+
+// ApplyThrough executes the pending migrations in execution order up to and including the given one,
+// the later migrations stay pending
+func (m MigrationService) ApplyThrough(ctx context.Context, migrationId string) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	index := slices.IndexFunc(status.Pending, func(migration MigrationStatus) bool { return migration.Id == migrationId })
+	if index < 0 {
+		return fmt.Errorf("migration %s is not pending", migrationId)
+	}
+	m.excludeIds = make(map[string]bool)
+	for _, migration := range status.Pending[index+1:] {
+		m.excludeIds[migration.Id] = true
+	}
+	return m.ExecuteMigration(ctx)
+}
+
+// RevertThrough reverts the migrations applied after the given one and the migration itself, newest first.
+// The reverted migrations are applied again by the next run if they are still in the configuration.
+func (m MigrationService) RevertThrough(ctx context.Context, migrationId string) error {
+	if err := m.prepareDatabase(ctx); err != nil {
+		return err
+	}
+	existingMigrations, err := m.getExistingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	// The existing migrations are ordered newest first, which is the revert order
+	index := slices.IndexFunc(existingMigrations, func(e Migration) bool { return e.Id == migrationId })
+	if index < 0 {
+		return fmt.Errorf("migration %s is not applied", migrationId)
+	}
+	reverts := existingMigrations[:index+1]
+	for _, migration := range reverts {
+		if err := m.checkRevertable(migration); err != nil {
+			return err
+		}
+	}
+	for _, migration := range reverts {
+		if err := m.revertInPhase(ctx, migration); err != nil {
+			return err
+		}
+	}
+	m.log().Info("migrations reverted", "through", migrationId, "reverted", len(reverts))
+	return nil
+}
@@ -2,26 +2,52 @@ package migrago
 
 import (
 	"context"
-	"crypto/md5"
 	"database/sql"
-	"encoding/hex"
-	"encoding/json"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
-	"path/filepath"
+	"log/slog"
+	"runtime"
 	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 // MigrationService constructor
-func NewMigrationService(configFile, scriptPath string, fs fs.FS, conn *sql.DB) MigrationService {
-	return MigrationService{
-		configFile: configFile,
-		scriptPath: scriptPath,
-		fs:         fs,
-		conn:       conn,
+func NewMigrationService(configFile, scriptPath string, fs fs.FS, conn *sql.DB, opts ...Option) MigrationService {
+	m := MigrationService{
+		conn:              conn,
+		pool:              defaultPoolConfig,
+		heartbeatInterval: DefaultHeartbeatInterval,
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	// The own migrations of the service are executed after all additional sources
+	source := NewFileSource(configFile, scriptPath, fs)
+	if m.streamThreshold > 0 {
+		source = source.WithStreamingThreshold(m.streamThreshold)
+	}
+	m.sources = append(m.sources, namedSource{source: source})
+	return m
+}
+
+// NewMigrationServiceFromSource creates a MigrationService which loads its migrations from a custom source
+func NewMigrationServiceFromSource(source Source, conn *sql.DB, opts ...Option) MigrationService {
+	m := MigrationService{
+		conn:              conn,
+		pool:              defaultPoolConfig,
+		heartbeatInterval: DefaultHeartbeatInterval,
 	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	m.sources = append(m.sources, namedSource{source: source})
+	return m
 }
 
 type Migration struct {
@@ -29,171 +55,634 @@ type Migration struct {
 	Script       string
 	RevertScript string
 	Checksum     string
+	Metadata     Metadata
+	// InstalledAt is only set for migrations loaded from the changelog, it is always in UTC
+	InstalledAt time.Time
+	// LSNBefore and LSNAfter are the WAL positions before and after the script was executed,
+	// they are empty for migrations marked as applied without execution
+	LSNBefore string
+	LSNAfter  string
+	// Duration is the execution time of the script, it is zero for migrations marked as applied without execution
+	Duration time.Duration
+	// NoRevertScript is set if the migration has no revert script, it is stored as NULL in the changelog
+	NoRevertScript bool
+	// OpenScript is set for large scripts, which are streamed statement by statement instead of being held in Script
+	OpenScript func() (io.ReadCloser, error)
+	// Variants contains dialect specific scripts by dialect name, it is used when Script is empty
+	Variants map[string]ScriptVariant
+	// lazy is set for migrations of a ManifestSource, their scripts are read when they are executed
+	lazy *manifestScripts
+}
+
+// Metadata contains optional settings of a migration
+type Metadata struct {
+	// Description is a human-readable summary of the migration
+	Description string `yaml:"description"`
+	// Irreversible migrations are never reverted automatically, also settable with "-- migrago:irreversible"
+	Irreversible bool `yaml:"irreversible"`
+	// Dangerous migrations have to be confirmed by the ConfirmFunc, also settable with "-- migrago:dangerous"
+	Dangerous bool `yaml:"dangerous"`
+	// Backfill executes the script in batches, also settable with "-- migrago:backfill table=<table> key=<column>"
+	Backfill *Backfill `yaml:"backfill"`
+	// Async migrations are scheduled by ExecuteMigration and executed by RunAsyncJobs, also settable with "-- migrago:async".
+	// They are only supported on Postgres and CockroachDB.
+	Async bool `yaml:"async"`
+	// Heavy migrations only start inside the maintenance window, also settable with "-- migrago:heavy"
+	Heavy bool `yaml:"heavy"`
+	// OnlineDDL migrations are executed by the online schema change tool, also settable with "-- migrago:online-ddl"
+	OnlineDDL bool `yaml:"onlineDDL"`
+	// NoTransaction migrations are executed statement by statement in autocommit mode, also settable with "-- migrago:no-transaction"
+	NoTransaction bool `yaml:"noTransaction"`
+	// Priority overrides the position in the execution order: the migrations of a source are sorted by priority,
+	// which defaults to the 1-based position in the configuration, so 1.5 runs between the first and the second
+	// migration. Also settable with "-- migrago:priority 1.5"
+	Priority *float64 `yaml:"priority"`
+	// Requires are server version requirements like "postgres>=15", also settable with "-- migrago:requires postgres>=15"
+	Requires []string `yaml:"requires"`
+	// Refresh are the materialized views refreshed after the script, also settable with "-- migrago:refresh <view> [concurrently]"
+	Refresh []MaterializedView `yaml:"refresh"`
+	// Citus contains the settings for Citus clusters
+	Citus Citus `yaml:"citus"`
+	// Assert are invariants checked after the script in its transaction, also settable with
+	// "-- migrago:assert <query> <operator> <value>", e.g. "-- migrago:assert SELECT count(*) FROM orders WHERE status IS NULL == 0"
+	Assert []Assertion `yaml:"assert"`
+	// SupersededBy deprecates the migration in favor of a replacement, e.g. after squashing: fresh installs only
+	// apply the replacement, which is recorded without execution where all migrations it supersedes are applied.
+	// There is no directive, because it would change the checksum of the applied script, the FileSource reads it
+	// from the "superseded" object of the config file.
+	SupersededBy string `yaml:"supersededBy"`
+}
+
+// ScriptVariant is the script of a migration for a specific dialect
+type ScriptVariant struct {
+	Script         string
+	RevertScript   string
+	NoRevertScript bool
+	// Checksum is calculated from the script if it is left empty
+	Checksum string
+}
+
+// forDialect selects the variant of the dialect as script of the migration
+func (mig Migration) forDialect(dialect string) (Migration, error) {
+	if mig.lazy != nil && len(mig.lazy.entry.Variants) > 0 {
+		entry, ok := mig.lazy.entry.Variants[dialect]
+		if !ok {
+			return mig, fmt.Errorf("migration %s has no script for dialect %s", mig.Id, dialect)
+		}
+		return lazyMigration(mig.Id, mig.lazy.fs, entry), nil
+	}
+	if mig.Script != "" || len(mig.Variants) == 0 {
+		return mig, nil
+	}
+	variant, ok := mig.Variants[dialect]
+	if !ok {
+		return mig, fmt.Errorf("migration %s has no script for dialect %s", mig.Id, dialect)
+	}
+	mig.Script = variant.Script
+	mig.RevertScript = variant.RevertScript
+	mig.NoRevertScript = variant.NoRevertScript
+	mig.Checksum = variant.Checksum
+	return mig, nil
 }
 
 type MigrationService struct {
-	configFile string
-	scriptPath string
-	fs         fs.FS
+	sources    []namedSource
 	conn       *sql.DB
+	revision   string
+	logger     *slog.Logger
+	production bool
+	devForce   bool
+	// reapplyEdited re-applies edited migrations like devForce, it is set by Rerun and allowed in strict mode
+	reapplyEdited bool
+	// replaceChangelog replaces the changelog entry of a migration instead of failing if it exists, it is set by
+	// RerunMigration to apply a migration again without reverting it
+	replaceChangelog bool
+
+	revertPolicy RevertPolicy
+	strict       bool
+	confirmFunc  ConfirmFunc
+	skipIds      []string
+
+	allowOutOfOrder bool
+	concurrency     int
+	streamThreshold int64
+
+	optionalRevertScripts   bool
+	allowIrreversibleRevert bool
+
+	revertStore       WritableObjectStore
+	revertStorePrefix string
+	revertCipher      RevertScriptCipher
+
+	appName         string
+	sessionSettings []sessionSetting
+	// runId is set during ExecuteMigration to identify the migration sessions
+	runId string
+
+	// pool and tls are only applied to connections opened by NewMigrationServiceFromDSN
+	pool     poolConfig
+	tls      *TLSConfig
+	tokens   TokenSource
+	ownsConn bool
+	// connector opens the connections of conn if it was opened by NewMigrationServiceFromDSN, it is used for
+	// connections outside of its limited pool
+	connector driver.Connector
+
+	heartbeatInterval time.Duration
+	stop              <-chan struct{}
+	maxRunDuration    time.Duration
+	jobStale          time.Duration
+
+	slowThreshold time.Duration
+	slowNotify    SlowMigrationFunc
+
+	throttleProbe ThrottleProbe
+	window        MaintenanceWindow
+
+	onlineSchemaChange *OnlineSchemaChange
+	vitess             *VitessOnlineDDL
+	requires           Requirements
+	versionPolicy      VersionPolicy
+	primaryResolver    PrimaryResolver
+	idPolicy           *IDPolicy
+	phaseTimeouts      PhaseTimeouts
+	interceptors       []StatementInterceptor
+	dialect            Dialect
+	txRetry            TransactionRetry
+	statementProgress  bool
+	// excludeIds are the pending migrations of later releases, which are not executed by ApplyRelease
+	excludeIds map[string]bool
 }
 
-// readConfigFile reads the configuration file (JSON) and returns a list of migration IDs
-func (m MigrationService) readConfigFile() ([]string, error) {
-	f, err := m.fs.Open(m.configFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open config file: %w", err)
+// dialectName returns the name of the connected database, used to pick dialect specific scripts
+func (m MigrationService) dialectName() string {
+	return m.sqlDialect().Name()
+}
+
+// getMigrations retrieves the migrations of all sources and reads their contents.
+// It returns the migrations of every source in execution order and all migrations merged by ID.
+func (m MigrationService) getMigrations() (sourceMigrations [][]Migration, migrations map[string]Migration, err error) {
+	namespaces := make(map[string]bool)
+	migrations = make(map[string]Migration)
+	for _, s := range m.sources {
+		if namespaces[s.namespace] {
+			return nil, nil, fmt.Errorf("duplicate source namespace %q", s.namespace)
+		}
+		namespaces[s.namespace] = true
+
+		var migrationIds []string
+		migrationIds, err = s.source.List()
+		if err != nil {
+			return nil, nil, err
+		}
+		if m.idPolicy != nil {
+			if err = m.idPolicy.Check(migrationIds...); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		var loaded []Migration
+		loaded, err = m.loadMigrations(s, migrationIds)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = s.markSuperseded(loaded); err != nil {
+			return nil, nil, err
+		}
+
+		current := make([]Migration, 0, len(loaded))
+		seen := make(map[string]bool, len(loaded))
+		for _, migration := range loaded {
+			if seen[migration.Id] {
+				return nil, nil, fmt.Errorf("duplicate migration %s in source %q", migration.Id, s.namespace)
+			}
+			if _, ok := migrations[migration.Id]; ok {
+				return nil, nil, fmt.Errorf("migration %s of source %q conflicts with another source", migration.Id, s.namespace)
+			}
+			seen[migration.Id] = true
+			current = append(current, migration)
+			migrations[migration.Id] = migration
+		}
+		sortByPriority(current)
+		sourceMigrations = append(sourceMigrations, current)
 	}
-	defer f.Close()
+	err = checkSuperseded(migrations)
+	return
+}
 
-	var migrationIds []string
-	if err := json.NewDecoder(f).Decode(&migrationIds); err != nil {
-		return nil, fmt.Errorf("failed to decode config file: %w", err)
+// loadMigrations loads and checksums the migrations of a source concurrently, the order of the IDs is kept
+func (m MigrationService) loadMigrations(s namedSource, migrationIds []string) ([]Migration, error) {
+	loaded := make([]Migration, len(migrationIds))
+	g := new(errgroup.Group)
+	g.SetLimit(m.loadConcurrency())
+	for i, id := range migrationIds {
+		g.Go(func() error {
+			migration, err := m.loadMigration(s, id)
+			loaded[i] = migration
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
-	return migrationIds, nil
+	return loaded, nil
 }
 
-// readFileContent reads the content of a file
-func readFileContent(fs fs.FS, path string) (string, error) {
-	f, err := fs.Open(path)
+// loadMigration loads a single migration, selects the script of the dialect and parses its directives
+func (m MigrationService) loadMigration(s namedSource, migrationId string) (Migration, error) {
+	migration, err := s.source.Load(migrationId)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file %s: %w", path, err)
+		return Migration{}, err
+	}
+	migration.Id = s.qualifiedId(migrationId)
+	if migration, err = migration.forDialect(m.dialectName()); err != nil {
+		return Migration{}, err
+	}
+	if migration.Script, err = normalizeScript(migration.Script); err != nil {
+		return Migration{}, fmt.Errorf("migration %s: %w", migration.Id, err)
+	}
+	if migration.RevertScript, err = normalizeScript(migration.RevertScript); err != nil {
+		return Migration{}, fmt.Errorf("migration %s: %w", migration.Id, err)
+	}
+	if migration.Checksum == "" {
+		migration.Checksum = calculateChecksum(migration.Script)
+	}
+	if err := migration.applyDirectives(); err != nil {
+		return Migration{}, err
+	}
+	if m.vitess != nil {
+		if err := m.checkNoForeignKeys(migration); err != nil {
+			return Migration{}, err
+		}
+	}
+	if backfill := migration.Metadata.Backfill; backfill != nil {
+		if err := backfill.validate(); err != nil {
+			return Migration{}, fmt.Errorf("migration %s: %w", migration.Id, err)
+		}
 	}
-	defer f.Close()
+	if (migration.Metadata.Backfill != nil || migration.Metadata.Async) && !m.postgresCompatible() {
+		return Migration{}, fmt.Errorf("migration %s: backfills and async migrations are only supported on Postgres and CockroachDB, not on %s",
+			migration.Id, m.dialectName())
+	}
+	for _, assertion := range migration.Metadata.Assert {
+		if err := assertion.validate(); err != nil {
+			return Migration{}, fmt.Errorf("migration %s: %w", migration.Id, err)
+		}
+	}
+	if err := migration.validateRequires(); err != nil {
+		return Migration{}, err
+	}
+	if len(migration.Metadata.Refresh) > 0 && (migration.Metadata.Backfill != nil || migration.Metadata.OnlineDDL) {
+		return Migration{}, fmt.Errorf("migration %s: backfills and online schema changes can not refresh materialized views", migration.Id)
+	}
+	if migration.NoRevertScript && !m.optionalRevertScripts {
+		return Migration{}, fmt.Errorf("missing revert script for migration %s", migration.Id)
+	}
+	return migration, nil
+}
 
-	fileContent, err := io.ReadAll(f)
-	if err != nil {
-		return "", fmt.Errorf("failed to read file content: %w", err)
+// loadConcurrency returns the maximum number of migrations loaded at the same time
+func (m MigrationService) loadConcurrency() int {
+	if m.concurrency > 0 {
+		return m.concurrency
 	}
-	return string(fileContent), nil
+	return runtime.GOMAXPROCS(0)
 }
 
-// extractMigration extracts a migration and calculates the checksum of the script
-func (m MigrationService) extractMigration(migrationId string) (Migration, error) {
-	script, err := readFileContent(m.fs, filepath.Join(m.scriptPath, migrationId+".sql"))
-	if err != nil {
-		return Migration{}, err
+// prepareDatabase creates the changelog and its auxiliary tables if they do not exist and upgrades them
+func (m MigrationService) prepareDatabase(ctx context.Context) error {
+	for _, statement := range m.sqlDialect().ChangelogDDL() {
+		if _, err := m.conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to prepare changelog tables: %w", err)
+		}
 	}
+	return nil
+}
 
-	revertScript, err := readFileContent(m.fs, filepath.Join(m.scriptPath, migrationId+".revert.sql"))
+// executeSingleMigration executes a single migration and updates the local list of existing migrations
+func (m MigrationService) executeSingleMigration(ctx context.Context, migration Migration) error {
+	migration, err := migration.loadScripts()
 	if err != nil {
-		return Migration{}, err
+		return err
+	}
+	if err := m.confirmMigration(ctx, migration); err != nil {
+		return err
+	}
+	if migration.Metadata.Backfill != nil {
+		return m.executeBackfill(ctx, migration)
+	}
+	if migration.Metadata.OnlineDDL {
+		return m.executeOnlineSchemaChange(ctx, migration)
+	}
+	if migration.Metadata.NoTransaction {
+		return m.executeWithoutTransaction(ctx, migration)
+	}
+	if m.vitess != nil {
+		return m.executeVitessMigration(ctx, migration)
+	}
+	if m.nonTransactional(migration) {
+		// E.g. the first schema change would commit the transaction on MySQL, the migration is recorded after its last statement
+		return m.executeWithoutTransaction(ctx, migration)
 	}
 
-	checksum := md5.Sum([]byte(script))
-	return Migration{
-		Id:           migrationId,
-		Script:       script,
-		RevertScript: revertScript,
-		Checksum:     hex.EncodeToString(checksum[:]),
-	}, nil
+	return m.retryTx(ctx, migration.Id, func() error {
+		tx, err := m.beginTx(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Execute the migration script
+		recorded, err := m.execScriptRecorded(ctx, tx, migration)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		// Insert the migration into the changelog
+		if err := m.insertChangelog(ctx, tx, recorded); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		// Commit the transaction
+		return tx.Commit()
+	})
+}
+
+// execScriptRecorded executes the script of a migration and records the WAL positions before and after it,
+// so point-in-time recovery targets can be chosen relative to the schema change, and the duration of the script
+func (m MigrationService) execScriptRecorded(ctx context.Context, tx *sql.Tx, migration Migration) (Migration, error) {
+	var err error
+	if migration.LSNBefore, err = m.currentLSN(ctx, tx); err != nil {
+		return migration, err
+	}
+	start := time.Now()
+	if err := m.execScript(ctx, tx, migration); err != nil {
+		return migration, err
+	}
+	if !migration.Metadata.Citus.isZero() {
+		if err := applyCitus(ctx, tx, migration); err != nil {
+			return migration, err
+		}
+	}
+	if err := refreshViews(ctx, tx, migration); err != nil {
+		return migration, err
+	}
+	if err := checkAssertions(ctx, tx, migration); err != nil {
+		return migration, err
+	}
+	migration.Duration = time.Since(start)
+	if migration.LSNAfter, err = m.currentLSN(ctx, tx); err != nil {
+		return migration, err
+	}
+	m.checkSlow(ctx, migration)
+	return migration, nil
 }
 
-// getMigrations retrieves the migrations from the configuration file and reads their contents in parallel
-func (m MigrationService) getMigrations() (migrations map[string]Migration, err error) {
-	var migrationIds []string
-	migrationIds, err = m.readConfigFile()
+// execScript executes the script of a migration statement by statement and reports the progress,
+// streamed scripts are split while they are read
+func (m MigrationService) execScript(ctx context.Context, tx *sql.Tx, migration Migration) error {
+	var statements []string
+	if migration.OpenScript == nil {
+		// In-memory scripts are split upfront, so the progress can be reported as n of m
+		err := m.splitScript(strings.NewReader(migration.Script), func(statement string) error {
+			statements = append(statements, statement)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to split migration script: %w", err)
+		}
+	}
+
+	// The backend is cancelled explicitly if the driver ignores the cancellation of the context
+	pid, err := m.backendPID(ctx, tx)
 	if err != nil {
-		return
+		return err
 	}
 
-	migrations = make(map[string]Migration)
-	for _, v := range migrationIds {
-		migrations[v], err = m.extractMigration(v)
+	progress := newScriptProgress(m.log(), migration.Id, len(statements), m.heartbeatInterval)
+	execStatement := m.intercept(func(ctx context.Context, statement Statement) (sql.Result, error) {
+		if migration.Metadata.Citus.Workers {
+			return nil, runOnWorkers(ctx, tx, statement.SQL)
+		}
+		return tx.ExecContext(ctx, statement.SQL)
+	})
+	exec := func(statement string) error {
+		stopHeartbeat := progress.heartbeat(ctx)
+		stopWatch := m.watchCancel(ctx, pid)
+		_, err := execStatement(ctx, Statement{MigrationId: migration.Id, SQL: statement})
+		stopWatch()
+		stopHeartbeat()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("migration %s cancelled, the transaction is rolled back: %w", migration.Id, ctxErr)
+		}
 		if err != nil {
-			return
+			return fmt.Errorf("failed to execute migration script: %w", err)
 		}
+		progress.statementDone(ctx)
+		return nil
 	}
-	return
-}
 
-// prepareDatabase creates the changelog table if it does not exist
-func (m MigrationService) prepareDatabase(ctx context.Context) error {
-	_, err := m.conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS changelog (
-		id VARCHAR(255) PRIMARY KEY,
-		checksum VARCHAR(255) NOT NULL,
-		installedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		revertscript TEXT
-	)`)
-	return err
+	if migration.OpenScript == nil {
+		for _, statement := range statements {
+			if err := exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return readMigrationScript(migration, m.splitScript, exec)
 }
 
-// executeSingleMigration executes a single migration and updates the local list of existing migrations
-func (m MigrationService) executeSingleMigration(ctx context.Context, migration Migration) error {
-	tx, err := m.conn.BeginTx(ctx, nil)
+// insertChangelog inserts an applied migration into the changelog, a missing revert script is stored as NULL
+// and large revert scripts are stored compressed or in the revert store. It fails with ErrAppliedConcurrently
+// if another runner recorded the migration in the meantime, the entry of a re-applied migration is replaced.
+func (m MigrationService) insertChangelog(ctx context.Context, tx *sql.Tx, migration Migration) error {
+	// Migrations recorded without execution, e.g. by MarkApplied, still need the revert script of the manifest
+	migration, err := migration.loadScripts()
 	if err != nil {
 		return err
 	}
-
-	// Execute the migration script
-	_, err = tx.ExecContext(ctx, migration.Script)
+	encoded, err := m.encodeChangelogRevertScript(ctx, migration)
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to execute migration script: %w", err)
+		return err
 	}
-
-	// Insert the migration into the changelog
-	_, err = tx.ExecContext(ctx, `INSERT INTO changelog (id, checksum, revertscript) VALUES ($1, $2, $3)`, migration.Id, migration.Checksum, migration.RevertScript)
+	if m.replaceChangelog {
+		query, args := m.rebind(`DELETE FROM changelog WHERE id = $1`, migration.Id)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to delete from changelog: %w", err)
+		}
+	}
+	revertScript := sql.NullString{String: encoded, Valid: !migration.NoRevertScript}
+	lsnBefore := sql.NullString{String: migration.LSNBefore, Valid: migration.LSNBefore != ""}
+	lsnAfter := sql.NullString{String: migration.LSNAfter, Valid: migration.LSNAfter != ""}
+	duration := sql.NullInt64{Int64: migration.Duration.Milliseconds(), Valid: migration.Duration > 0}
+	description := sql.NullString{String: migration.Metadata.Description, Valid: migration.Metadata.Description != ""}
+	// A racing runner which inserted the same ID first blocks the insert until it commits, the conflict
+	// is detected by the affected rows and the transaction of this runner is rolled back by the caller
+	inserted, err := m.insertIgnore(ctx, tx, "changelog",
+		[]string{"id", "checksum", "revertscript", "irreversible", "lsnBefore", "lsnAfter", "durationMs", "description"},
+		migration.Id, migration.Checksum, revertScript, migration.Metadata.Irreversible, lsnBefore, lsnAfter, duration, description)
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("failed to insert into changelog: %w", err)
 	}
-
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return err
+	if !inserted {
+		return fmt.Errorf("migration %s: %w", migration.Id, ErrAppliedConcurrently)
 	}
+	return nil
+}
 
+// checkRevertable checks if an applied migration can be reverted
+func (m MigrationService) checkRevertable(migration Migration) error {
+	if migration.Metadata.Irreversible && !m.allowIrreversibleRevert {
+		return fmt.Errorf("migration %s is irreversible and can not be reverted without WithAllowIrreversibleRevert", migration.Id)
+	}
+	if migration.NoRevertScript {
+		return fmt.Errorf("migration %s can not be reverted: no revert script stored in the changelog", migration.Id)
+	}
 	return nil
 }
 
 // revertSingleMigration executes the revert script and removes the migration from the changelog
 func (m MigrationService) revertSingleMigration(ctx context.Context, migration Migration) error {
-	tx, err := m.conn.BeginTx(ctx, nil)
-	if err != nil {
+	if err := m.checkRevertable(migration); err != nil {
 		return err
 	}
-
-	_, err = tx.ExecContext(ctx, migration.RevertScript)
+	migration, err := m.resolveRevertScript(ctx, migration)
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to execute revert script: %w", err)
+		return err
 	}
-
-	_, err = tx.ExecContext(ctx, `DELETE FROM changelog WHERE id = $1`, migration.Id)
-	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to delete from changelog: %w", err)
+	if err := m.confirmRevert(ctx, migration); err != nil {
+		return err
 	}
 
-	return tx.Commit()
+	return m.retryTx(ctx, migration.Id, func() error {
+		tx, err := m.beginTx(ctx)
+		if err != nil {
+			return err
+		}
+
+		// The revert script is executed at once on Postgres, statement by statement elsewhere
+		err = m.splitRevertScript(migration.RevertScript, func(script string) error {
+			_, err := m.execStatement(ctx, tx, Statement{MigrationId: migration.Id, SQL: script, Revert: true})
+			return err
+		})
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to execute revert script: %w", err)
+		}
+
+		query, args := m.rebind(`DELETE FROM changelog WHERE id = $1`, migration.Id)
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to delete from changelog: %w", err)
+		}
+
+		return tx.Commit()
+	})
 }
 
-// checkExistingChangelogs checks the existing migrations in the database
-func (m MigrationService) checkExistingChangelogs(ctx context.Context, existingMigrations *[]Migration, migrations map[string]Migration) error {
+// planReverts checks the existing migrations in the database and returns the migrations which have to be
+// reverted (in execution order) and the existing migrations which are kept
+func (m MigrationService) planReverts(existingMigrations []Migration, migrations map[string]Migration) (reverts []Migration, remaining []Migration, err error) {
+	// In strict mode the database is never changed implicitly, so all unknown migrations are reported at once
+	if m.strict {
+		var unknown []Migration
+		for _, dbMigration := range existingMigrations {
+			if _, ok := migrations[dbMigration.Id]; !ok {
+				unknown = append(unknown, dbMigration)
+			}
+		}
+		if len(unknown) > 0 {
+			return nil, nil, &UnknownMigrationsError{Migrations: unknown}
+		}
+	}
+
 	var notReverted bool
-	copyExistingMigrations := make([]Migration, len(*existingMigrations))
-	copy(copyExistingMigrations, *existingMigrations)
-	for _, dbMigration := range copyExistingMigrations {
+	remaining = make([]Migration, 0, len(existingMigrations))
+	for _, dbMigration := range existingMigrations {
 		if migration, ok := migrations[dbMigration.Id]; ok {
 			if dbMigration.Checksum != migration.Checksum {
-				return fmt.Errorf("checksum mismatch for migration %s: file: %s, database: %s", dbMigration.Id, migration.Checksum, dbMigration.Checksum)
+				// In dev force mode an edited migration is reverted and applied again,
+				// as long as no newer migration has to be kept on top of it
+				if !(m.devForce || m.reapplyEdited) || notReverted {
+					return nil, nil, &ChecksumMismatchError{Id: dbMigration.Id, File: migration.Checksum, Database: dbMigration.Checksum}
+				}
+				reverts = append(reverts, dbMigration)
+				continue
 			}
 			notReverted = true
+			remaining = append(remaining, dbMigration)
+		} else if m.revertPolicy == RevertNever {
+			return nil, nil, fmt.Errorf("migration %s was removed from the configuration, but the revert policy is %s", dbMigration.Id, m.revertPolicy)
+		} else if m.revertPolicy == RevertManual {
+			m.log().Warn("migration was removed from the configuration and has to be reverted manually", "id", dbMigration.Id)
+			remaining = append(remaining, dbMigration)
 		} else if notReverted {
-			return errors.New("not revertable migration found")
+			return nil, nil, errors.New("not revertable migration found")
 		} else {
-			if err := m.revertSingleMigration(ctx, dbMigration); err != nil {
-				return err
-			}
+			reverts = append(reverts, dbMigration)
+		}
+	}
+
+	// Fail before anything is reverted if one of the reverts is impossible
+	for _, migration := range reverts {
+		if err := m.checkRevertable(migration); err != nil {
+			return nil, nil, err
+		}
+	}
+	return reverts, remaining, nil
+}
+
+// checkExistingChangelogs reverts the migrations removed from the configuration and removes them from the local list
+func (m MigrationService) checkExistingChangelogs(ctx context.Context, existingMigrations *[]Migration, migrations map[string]Migration) error {
+	reverts, remaining, err := m.planReverts(*existingMigrations, migrations)
+	if err != nil {
+		return err
+	}
+	for _, migration := range reverts {
+		if err := m.revertInPhase(ctx, migration); err != nil {
+			return err
 		}
 	}
+	*existingMigrations = remaining
 	return nil
 }
 
+// PreviewReverts lists the migrations that would be reverted by ExecuteMigration, in the order
+// their revert scripts would run, without reverting or writing anything
+func (m MigrationService) PreviewReverts(ctx context.Context) ([]Migration, error) {
+	_, migrations, err := m.getMigrations()
+	if err != nil {
+		return nil, err
+	}
+	// Like Status the preview never writes, without changelog there is nothing to revert
+	state, err := m.readChangelogState(ctx)
+	if err != nil || !state.changelog {
+		return nil, err
+	}
+	existingMigrations, err := m.readExistingMigrations(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	reverts, _, err := m.planReverts(existingMigrations, migrations)
+	if err != nil {
+		return nil, err
+	}
+	for i, migration := range reverts {
+		if reverts[i], err = m.resolveRevertScript(ctx, migration); err != nil {
+			return nil, err
+		}
+	}
+	return reverts, nil
+}
+
 // getExistingMigrations retrieves the already executed migrations from the database
 func (m MigrationService) getExistingMigrations(ctx context.Context) ([]Migration, error) {
-	rows, err := m.conn.QueryContext(ctx, `SELECT id, checksum, revertscript FROM changelog ORDER BY installedAt DESC`)
+	rows, err := m.conn.QueryContext(ctx, `SELECT id, checksum, installedAt, revertscript, irreversible, lsnBefore, lsnAfter, durationMs, description
+		FROM changelog ORDER BY sequence DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -202,48 +691,190 @@ func (m MigrationService) getExistingMigrations(ctx context.Context) ([]Migratio
 	var existingMigrations []Migration
 	for rows.Next() {
 		var dbMigration Migration
-		if err := rows.Scan(&dbMigration.Id, &dbMigration.Checksum, &dbMigration.RevertScript); err != nil {
+		var revertScript, lsnBefore, lsnAfter, description sql.NullString
+		var durationMs sql.NullInt64
+		if err := rows.Scan(&dbMigration.Id, &dbMigration.Checksum, &dbMigration.InstalledAt, &revertScript, &dbMigration.Metadata.Irreversible, &lsnBefore, &lsnAfter, &durationMs, &description); err != nil {
 			return nil, err
 		}
+		dbMigration.Metadata.Description = description.String
+		dbMigration.Duration = time.Duration(durationMs.Int64) * time.Millisecond
+		dbMigration.InstalledAt = dbMigration.InstalledAt.UTC()
+		dbMigration.LSNBefore = lsnBefore.String
+		dbMigration.LSNAfter = lsnAfter.String
+		if dbMigration.RevertScript, err = m.decodeChangelogRevertScript(ctx, revertScript.String); err != nil {
+			return nil, fmt.Errorf("migration %s: %w", dbMigration.Id, err)
+		}
+		dbMigration.NoRevertScript = !revertScript.Valid
 		existingMigrations = append(existingMigrations, dbMigration)
 	}
 	return existingMigrations, nil
 }
 
+// getPendingMigrations returns the migrations which are neither applied nor archived, in execution order.
+// Applied migrations are looked up by ID, the archive is only queried for the remaining IDs.
+func (m MigrationService) getPendingMigrations(ctx context.Context, sourceMigrations [][]Migration, existingMigrations []Migration) ([]Migration, error) {
+	pending := unappliedMigrations(sourceMigrations, existingMigrations)
+	archived, err := m.getArchivedIds(ctx, pending)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(pending, func(migration Migration) bool { return archived[migration.Id] }), nil
+}
+
+// unappliedMigrations returns the migrations of the sources which are not in the changelog, in execution order
+func unappliedMigrations(sourceMigrations [][]Migration, existingMigrations []Migration) []Migration {
+	applied := make(map[string]bool, len(existingMigrations))
+	for _, migration := range existingMigrations {
+		applied[migration.Id] = true
+	}
+	var pending []Migration
+	for _, migrations := range sourceMigrations {
+		for _, migration := range migrations {
+			if !applied[migration.Id] {
+				pending = append(pending, migration)
+			}
+		}
+	}
+	return pending
+}
+
 // ExecuteMigration orchestrates the migration execution process
-func (m MigrationService) ExecuteMigration(ctx context.Context) error {
+func (m MigrationService) ExecuteMigration(ctx context.Context) (err error) {
+	start := time.Now()
+	if m.production && m.devForce {
+		return errors.New("dev force is not allowed in production mode")
+	}
+	if m.strict && m.devForce {
+		return errors.New("dev force is not allowed in strict mode")
+	}
+
+	// The preparation has its own deadline, every migration and every revert as well
+	prepare := startPhase(ctx, "prepare", "", m.phaseTimeouts.Prepare)
+	defer prepare.cancel()
+
+	// Fail fast on replicas instead of in the middle of a transaction
+	if m, err = m.ensurePrimary(prepare.ctx); err != nil {
+		return prepare.err(err)
+	}
+
+	// Check the prerequisites and privileges before anything is changed
+	if err := m.checkRequirements(prepare.ctx); err != nil {
+		return prepare.err(err)
+	}
+
 	// Step 1: Prepare the database by creating the changelog table
-	if err := m.prepareDatabase(ctx); err != nil {
-		return err
+	if err := m.prepareDatabase(prepare.ctx); err != nil {
+		return prepare.err(err)
 	}
 
-	// Step 2: Get all migrations from the configuration
-	migrations, err := m.getMigrations()
+	// Record the run in the audit table, the outcome is stored when the run is finished
+	runId, err := m.startRun(prepare.ctx)
+	if err != nil {
+		return prepare.err(err)
+	}
+	m.runId = runId
+	defer func() {
+		// The outcome is also recorded if the run was cancelled
+		if finishErr := m.finishRun(context.WithoutCancel(ctx), runId, err); err == nil {
+			err = finishErr
+		}
+	}()
+
+	// Step 2: Get all migrations from the configuration of every source
+	sourceMigrations, migrations, err := m.getMigrations()
 	if err != nil {
 		return err
 	}
 
+	// Renamed migrations take over the changelog entries of their previous IDs
+	if err := m.renameAliases(prepare.ctx, migrations); err != nil {
+		return prepare.err(err)
+	}
+
 	// Step 3: Retrieve the already executed migrations from the database
-	existingMigrations, err := m.getExistingMigrations(ctx)
+	existingMigrations, err := m.getExistingMigrations(prepare.ctx)
 	if err != nil {
-		return err
+		return prepare.err(err)
 	}
+	prepare.cancel()
 
 	// Step 4: Check existing changelogs for potential reverts or checksum mismatches
 	if err := m.checkExistingChangelogs(ctx, &existingMigrations, migrations); err != nil {
 		return err
 	}
 
-	// Step 5: Execute pending migrations
-	for _, migration := range migrations {
-		// Skip migrations that are already applied
-		if slices.ContainsFunc(existingMigrations, func(e Migration) bool { return e.Id == migration.Id }) {
-			continue
+	// Collect the pending migrations in execution order
+	pending, err := m.getPendingMigrations(ctx, sourceMigrations, existingMigrations)
+	if err != nil {
+		return err
+	}
+	if len(m.skipIds) > 0 {
+		// Skip migrations excluded by the operator for this run
+		pending = slices.DeleteFunc(pending, func(migration Migration) bool {
+			if !slices.Contains(m.skipIds, migration.Id) {
+				return false
+			}
+			m.log().Warn("pending migration skipped", "id", migration.Id)
+			return true
+		})
+	}
+
+	if m.excludeIds != nil {
+		pending = slices.DeleteFunc(pending, func(migration Migration) bool { return m.excludeIds[migration.Id] })
+	}
+
+	// Deprecated migrations are not applied anymore, replacements of applied ones are only recorded
+	pending, superseding, err := planSuperseded(sourceMigrations, pending)
+	if err != nil {
+		return err
+	}
+	if err := m.recordSuperseding(ctx, superseding); err != nil {
+		return err
+	}
+
+	// Migrations for other server versions are skipped or fail the run
+	if pending, err = m.filterVersionRequirements(ctx, pending); err != nil {
+		return err
+	}
+
+	// Async migrations are scheduled as jobs for RunAsyncJobs instead of being executed
+	var async []Migration
+	pending = slices.DeleteFunc(pending, func(migration Migration) bool {
+		if migration.Metadata.Async {
+			async = append(async, migration)
+		}
+		return migration.Metadata.Async
+	})
+
+	// Materialized views declared by several migrations are refreshed once, after the last of them
+	planRefreshes(pending)
+
+	// Detect pending migrations which sort before already applied ones
+	if !m.allowOutOfOrder {
+		// Migrations with version requirements may be applied later, after an upgrade of the server
+		ordered := slices.DeleteFunc(slices.Clone(pending), func(migration Migration) bool { return len(migration.Metadata.Requires) > 0 })
+		if gaps := detectGaps(ordered, existingMigrations); len(gaps) > 0 {
+			return &GapError{Gaps: gaps}
 		}
-		// Execute new migrations and update the local list
-		if err := m.executeSingleMigration(ctx, migration); err != nil {
+	}
+
+	// Step 5: Execute pending migrations source by source, strictly in the order of the configuration
+	for i, migration := range pending {
+		if m.stopRequested() {
+			return interrupted("stop requested", pending[i:])
+		}
+		if m.maxRunDuration > 0 && time.Since(start) >= m.maxRunDuration {
+			return interrupted(fmt.Sprintf("run time budget of %s exceeded", m.maxRunDuration), pending[i:])
+		}
+		// The following migrations may depend on the heavy one, so they are deferred as well
+		if m.outsideWindow(migration) {
+			return interrupted(fmt.Sprintf("heavy migration %s deferred to the maintenance window", migration.Id), pending[i:])
+		}
+		if err := m.applyInPhase(ctx, migration); err != nil {
 			return err
 		}
 	}
-	return nil
+
+	// Step 6: Schedule the async migrations after the migrations they may depend on are applied
+	return m.scheduleJobs(ctx, async)
 }
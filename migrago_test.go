@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/fs"
+	"slices"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/docker/go-connections/nat"
 	_ "github.com/lib/pq"
@@ -17,6 +19,15 @@ import (
 )
 
 func CreateTestPostgresContainer(t *testing.T, ctx context.Context) (*sql.DB, error) {
+	dsn, err := CreateTestPostgresDSN(t, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("postgres", dsn)
+}
+
+// CreateTestPostgresDSN starts a Postgres container and returns its DSN, for tests which open the connection themselves
+func CreateTestPostgresDSN(t *testing.T, ctx context.Context) (string, error) {
 	req := testcontainers.ContainerRequest{
 		Image:        "postgres:16.3",
 		ExposedPorts: []string{"5432/tcp"},
@@ -34,26 +45,52 @@ func CreateTestPostgresContainer(t *testing.T, ctx context.Context) (*sql.DB, er
 		Started:          true,
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	t.Cleanup(func() {
 		container.Terminate(ctx)
 	})
 	ip, err := container.Host(ctx)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	port, err := container.MappedPort(ctx, "5432")
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	dsn := fmt.Sprintf("user=postgres password=postgres dbname=postgres host=%s port=%s sslmode=disable", ip, port.Port())
 	t.Logf("Postgres-dsn: %s", dsn)
-	d, err := sql.Open("postgres", dsn)
+	return dsn, nil
+}
+
+func CreateTestCockroachContainer(t *testing.T, ctx context.Context) (*sql.DB, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        "cockroachdb/cockroach:v23.2.5",
+		Cmd:          []string{"start-single-node", "--insecure"},
+		ExposedPorts: []string{"26257/tcp"},
+		WaitingFor: wait.ForSQL(nat.Port("26257"), "postgres", func(host string, port nat.Port) string {
+			return fmt.Sprintf("user=root dbname=defaultdb host=%s port=%s sslmode=disable", host, port.Port())
+		}),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		container.Terminate(ctx)
+	})
+	ip, err := container.Host(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return d, nil
+	port, err := container.MappedPort(ctx, "26257")
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("postgres", fmt.Sprintf("user=root dbname=defaultdb host=%s port=%s sslmode=disable", ip, port.Port()))
 }
 
 func CreateFSForMigrations(migrations []Migration) fs.FS {
@@ -503,3 +540,1772 @@ func Test_ExecuteMigration(t *testing.T) {
 		assert.Equal(t, 2, count)
 	})
 }
+
+func Test_ExecuteMigrationDevForce(t *testing.T) {
+	t.Run("Test dev force re-applies edited migration", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		_, err = d.Exec("CREATE TABLE IF NOT EXISTS changelog (id VARCHAR(255) PRIMARY KEY, checksum VARCHAR(255) NOT NULL, installedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, revertscript TEXT)")
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO changelog (id, checksum, revertscript) VALUES ($1, $2, $3)", "Test", "9c23564a026f0826f2a05b8423aa21f8", "DROP TABLE test")
+		assert.NoError(t, err)
+		_, err = d.Exec("CREATE TABLE IF NOT EXISTS test (id VARCHAR(255))")
+		assert.NoError(t, err)
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithDevForce())
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var checksum string
+		err = d.QueryRow("SELECT checksum FROM changelog").Scan(&checksum)
+		assert.NoError(t, err)
+		assert.Equal(t, "9c23564a026f0826f2a05b8423aa21f9", checksum)
+		var count int
+		err = d.QueryRow("SELECT count(*) FROM information_schema.columns WHERE table_name = 'test' AND column_name = 'name'").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+	t.Run("Test dev force is rejected in production mode", func(t *testing.T) {
+		fs := CreateFSForMigrations([]Migration{})
+		service := NewMigrationService("config.json", "scripts", fs, nil, WithDevForce(), WithProductionMode())
+		err := service.ExecuteMigration(context.Background())
+		assert.ErrorContains(t, err, "not allowed in production mode")
+	})
+}
+
+func Test_RerunStrictMode(t *testing.T) {
+	t.Run("Test Rerun re-applies edited migration in strict mode", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		migration := Migration{Id: "Test", Script: "CREATE TABLE test (id serial PRIMARY KEY)", RevertScript: "DROP TABLE test"}
+		err = NewMigrationService("config.json", "scripts", CreateFSForMigrations([]Migration{migration}), d, WithStrictMode()).Rerun(ctx)
+		assert.NoError(t, err)
+
+		migration.Script = "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50))"
+		err = NewMigrationService("config.json", "scripts", CreateFSForMigrations([]Migration{migration}), d, WithStrictMode()).Rerun(ctx, "scripts/Test.sql")
+		assert.NoError(t, err)
+
+		var count int
+		err = d.QueryRow("SELECT count(*) FROM information_schema.columns WHERE table_name = 'test' AND column_name = 'name'").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func Test_RunAudit(t *testing.T) {
+	t.Run("Test run is recorded with source revision", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		fs := CreateFSForMigrations([]Migration{})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithSourceRevision("4b825dc642cb6eb9a060e54bf8d69288fbee4904"))
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var revision string
+		var finished bool
+		err = d.QueryRow("SELECT sourceRevision, finishedAt IS NOT NULL FROM changelog_run").Scan(&revision, &finished)
+		assert.NoError(t, err)
+		assert.Equal(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", revision)
+		assert.True(t, finished)
+	})
+}
+
+func Test_ExecuteMigrationMultipleSources(t *testing.T) {
+	t.Run("Test with additional source", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		platform := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test2 (id serial PRIMARY KEY, test_id INT REFERENCES test (id))",
+				RevertScript: "DROP TABLE test2",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithSource("platform", NewFileSource("config.json", "scripts", platform)))
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var ids []string
+		rows, err := d.Query("SELECT id FROM changelog ORDER BY id")
+		assert.NoError(t, err)
+		for rows.Next() {
+			var id string
+			assert.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		assert.Equal(t, []string{"Test", "platform/Test"}, ids)
+	})
+	t.Run("Test with duplicate namespace", func(t *testing.T) {
+		fs := CreateFSForMigrations([]Migration{})
+		service := NewMigrationService("config.json", "scripts", fs, nil,
+			WithSource("platform", NewFileSource("config.json", "scripts", fs)),
+			WithSource("platform", NewFileSource("config.json", "scripts", fs)),
+		)
+		_, _, err := service.getMigrations()
+		assert.ErrorContains(t, err, "duplicate source namespace")
+	})
+}
+
+func Test_ExecuteMigrationIrreversible(t *testing.T) {
+	t.Run("Test irreversible migration is not reverted", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "-- migrago:irreversible\nCREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		err = NewMigrationService("config.json", "scripts", fs, d).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var irreversible bool
+		err = d.QueryRow("SELECT irreversible FROM changelog WHERE id = 'Test'").Scan(&irreversible)
+		assert.NoError(t, err)
+		assert.True(t, irreversible)
+
+		empty := CreateFSForMigrations([]Migration{})
+		err = NewMigrationService("config.json", "scripts", empty, d).ExecuteMigration(ctx)
+		assert.ErrorContains(t, err, "migration Test is irreversible")
+
+		err = NewMigrationService("config.json", "scripts", empty, d, WithAllowIrreversibleRevert()).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+	})
+}
+
+func Test_planReverts(t *testing.T) {
+	migrations := map[string]Migration{
+		"Test": {Id: "Test", Checksum: "9c23564a026f0826f2a05b8423aa21f9"},
+	}
+	existing := []Migration{
+		{Id: "Test3", Checksum: "9c23564a026f0826f2a05b8423aa21f7", RevertScript: "DROP TABLE test3"},
+		{Id: "Test2", Checksum: "9c23564a026f0826f2a05b8423aa21f8", RevertScript: "DROP TABLE test2"},
+		{Id: "Test", Checksum: "9c23564a026f0826f2a05b8423aa21f9", RevertScript: "DROP TABLE test"},
+	}
+	service := NewMigrationService("config.json", "scripts", nil, nil)
+
+	t.Run("Test reverts are planned newest first", func(t *testing.T) {
+		reverts, remaining, err := service.planReverts(existing, migrations)
+		assert.NoError(t, err)
+		assert.Equal(t, []Migration{existing[0], existing[1]}, reverts)
+		assert.Equal(t, []Migration{existing[2]}, remaining)
+	})
+	t.Run("Test irreversible migration fails before any revert", func(t *testing.T) {
+		irreversible := slices.Clone(existing)
+		irreversible[1].Metadata.Irreversible = true
+		_, _, err := service.planReverts(irreversible, migrations)
+		assert.ErrorContains(t, err, "migration Test2 is irreversible")
+	})
+	t.Run("Test revert policy never fails", func(t *testing.T) {
+		service := NewMigrationService("config.json", "scripts", nil, nil, WithRevertPolicy(RevertNever))
+		_, _, err := service.planReverts(existing, migrations)
+		assert.ErrorContains(t, err, "migration Test3 was removed from the configuration, but the revert policy is never")
+	})
+	t.Run("Test revert policy manual keeps removed migrations", func(t *testing.T) {
+		service := NewMigrationService("config.json", "scripts", nil, nil, WithRevertPolicy(RevertManual))
+		reverts, remaining, err := service.planReverts(existing, migrations)
+		assert.NoError(t, err)
+		assert.Empty(t, reverts)
+		assert.Equal(t, existing, remaining)
+	})
+	t.Run("Test strict mode reports all unknown migrations", func(t *testing.T) {
+		service := NewMigrationService("config.json", "scripts", nil, nil, WithStrictMode())
+		_, _, err := service.planReverts(existing, migrations)
+		var unknownErr *UnknownMigrationsError
+		assert.ErrorAs(t, err, &unknownErr)
+		assert.Equal(t, []Migration{existing[0], existing[1]}, unknownErr.Migrations)
+	})
+	t.Run("Test edited migrations are re-applied by Rerun in strict mode", func(t *testing.T) {
+		service := NewMigrationService("config.json", "scripts", nil, nil, WithStrictMode())
+		service.reapplyEdited = true
+		edited := []Migration{{Id: "Test", Checksum: "9c23564a026f0826f2a05b8423aa21f8", RevertScript: "DROP TABLE test"}}
+		reverts, remaining, err := service.planReverts(edited, migrations)
+		assert.NoError(t, err)
+		assert.Equal(t, edited, reverts)
+		assert.Empty(t, remaining)
+	})
+}
+
+func Test_MarkApplied(t *testing.T) {
+	t.Run("Test mark migration as applied", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.MarkApplied(ctx, "Test")
+		assert.NoError(t, err)
+
+		var checksum string
+		err = d.QueryRow("SELECT checksum FROM changelog WHERE id = 'Test'").Scan(&checksum)
+		assert.NoError(t, err)
+		assert.Equal(t, "9c23564a026f0826f2a05b8423aa21f9", checksum)
+		var exists bool
+		err = d.QueryRow("SELECT EXISTS(SELECT * FROM information_schema.tables WHERE table_name = 'test')").Scan(&exists)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		err = service.MarkApplied(ctx, "Test")
+		assert.ErrorContains(t, err, "already applied")
+		err = service.MarkApplied(ctx, "Unknown")
+		assert.ErrorContains(t, err, "not found in the configuration")
+	})
+}
+
+func Test_RerunMigration(t *testing.T) {
+	t.Run("Test rerun applied migration", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL); INSERT INTO test (name) VALUES ('initial')",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		_, err = d.Exec("DELETE FROM test")
+		assert.NoError(t, err)
+
+		err = service.RerunMigration(ctx, "Test")
+		assert.NoError(t, err)
+
+		var count int
+		err = d.QueryRow("SELECT count(*) FROM test").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		err = d.QueryRow("SELECT count(*) FROM changelog").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func Test_ExecuteMigrationSkipIDs(t *testing.T) {
+	t.Run("Test skipped migration is not executed", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "CREATE TABLE test2 (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test2",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithSkipIDs("Test2"))
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var count int
+		err = d.QueryRow("SELECT count(*) FROM information_schema.tables WHERE table_name IN ('test', 'test2')").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		err = d.QueryRow("SELECT count(*) FROM changelog WHERE id = 'Test2'").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
+func Test_ExecuteMigrationOrder(t *testing.T) {
+	t.Run("Test migrations are executed in config order", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		var migrations []Migration
+		// Every table references the previous one and the IDs sort in reverse order
+		for i := 0; i < 10; i++ {
+			script := fmt.Sprintf("CREATE TABLE step%d (id serial PRIMARY KEY)", i)
+			if i > 0 {
+				script = fmt.Sprintf("CREATE TABLE step%d (id serial PRIMARY KEY, prev INT REFERENCES step%d (id))", i, i-1)
+			}
+			migrations = append(migrations, Migration{
+				Id:           fmt.Sprintf("Step%d", 9-i),
+				Script:       script,
+				RevertScript: fmt.Sprintf("DROP TABLE step%d", i),
+			})
+		}
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+	})
+}
+
+func Test_ExecuteMigrationStreaming(t *testing.T) {
+	t.Run("Test streamed script is executed", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL);\nINSERT INTO test (name) VALUES ('a;b');\nINSERT INTO test (name) VALUES ('c');",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithStreamingThreshold(16))
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var count int
+		err = d.QueryRow("SELECT count(*) FROM test").Scan(&count)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+}
+
+func Test_ChangelogSequence(t *testing.T) {
+	t.Run("Test existing changelog entries are numbered in install order", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		_, err = d.Exec("CREATE TABLE IF NOT EXISTS changelog (id VARCHAR(255) PRIMARY KEY, checksum VARCHAR(255) NOT NULL, installedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, revertscript TEXT)")
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO changelog (id, checksum, revertscript, installedAt) VALUES ($1, $2, $3, '2024-01-02')", "A", "9c23564a026f0826f2a05b8423aa21f9", "DROP TABLE test")
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO changelog (id, checksum, revertscript, installedAt) VALUES ($1, $2, $3, '2024-01-01')", "B", "7eae18bb7a8410194e677a451c0bad70", "DROP TABLE test2")
+		assert.NoError(t, err)
+
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations([]Migration{}), d)
+		err = service.prepareDatabase(ctx)
+		assert.NoError(t, err)
+		existing, err := service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "A", existing[0].Id)
+		assert.Equal(t, "B", existing[1].Id)
+
+		_, err = d.Exec("INSERT INTO changelog (id, checksum, revertscript, installedAt) VALUES ($1, $2, $3, '2023-01-01')", "C", "9c23564a026f0826f2a05b8423aa21f8", "DROP TABLE test3")
+		assert.NoError(t, err)
+		existing, err = service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "C", existing[0].Id)
+	})
+	t.Run("Test a current changelog is not locked by the upgrades", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations([]Migration{}), d)
+		assert.NoError(t, service.prepareDatabase(ctx))
+
+		// A concurrent writer of the changelog conflicts with the ACCESS EXCLUSIVE lock of an ALTER TABLE
+		tx, err := d.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.Exec("LOCK TABLE changelog IN ROW EXCLUSIVE MODE")
+		assert.NoError(t, err)
+		prepareCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		assert.NoError(t, service.prepareDatabase(prepareCtx))
+	})
+}
+
+func Test_Status(t *testing.T) {
+	t.Run("Test status reports applied, pending and unknown migrations in UTC", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		_, err = d.Exec("CREATE TABLE IF NOT EXISTS changelog (id VARCHAR(255) PRIMARY KEY, checksum VARCHAR(255) NOT NULL, installedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, revertscript TEXT)")
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO changelog (id, checksum, revertscript, installedAt) VALUES ($1, $2, $3, '2024-01-01 12:00:00')", "Test", "9c23564a026f0826f2a05b8423aa21f9", "DROP TABLE test")
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO changelog (id, checksum, revertscript) VALUES ($1, $2, $3)", "Test3", "9c23564a026f0826f2a05b8423aa21f7", "DROP TABLE test3")
+		assert.NoError(t, err)
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "CREATE TABLE test2 (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test2",
+			},
+		})
+		status, err := NewMigrationService("config.json", "scripts", fs, d).Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 1)
+		assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), status.Applied[0].InstalledAt)
+		assert.Equal(t, []MigrationStatus{{Id: "Test2", Checksum: "7eae18bb7a8410194e677a451c0bad70"}}, status.Pending)
+		assert.Len(t, status.Unknown, 1)
+		assert.Equal(t, "Test3", status.Unknown[0].Id)
+	})
+	t.Run("Test descriptions are stored in the changelog", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		migrations := []Migration{
+			{
+				Id:           "Test",
+				Script:       "-- create the test table\nCREATE TABLE test (id serial PRIMARY KEY)",
+				RevertScript: "DROP TABLE test",
+			},
+		}
+		err = NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		var description string
+		assert.NoError(t, d.QueryRow("SELECT description FROM changelog WHERE id = 'Test'").Scan(&description))
+		assert.Equal(t, "create the test table", description)
+
+		migrations = append(migrations, Migration{
+			Id:           "Test2",
+			Script:       "-- create the second test table\nCREATE TABLE test2 (id serial PRIMARY KEY)",
+			RevertScript: "DROP TABLE test2",
+		})
+		status, err := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d).Status(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "create the test table", status.Applied[0].Description)
+		assert.Equal(t, "create the second test table", status.Pending[0].Description)
+	})
+}
+
+func Test_Prune(t *testing.T) {
+	t.Run("Test archived migrations are not executed again", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		_, err = d.Exec("UPDATE changelog SET installedAt = '2020-01-01'")
+		assert.NoError(t, err)
+
+		archived, err := service.Prune(ctx, 24*time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, archived)
+		existing, err := service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, existing)
+
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, status.Pending)
+	})
+	t.Run("Test recent migrations are kept", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		archived, err := service.Prune(ctx, 24*time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, archived)
+	})
+}
+
+func Test_ChangelogLSN(t *testing.T) {
+	t.Run("Test the WAL positions around the script are recorded", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var after bool
+		err = d.QueryRow("SELECT lsnAfter > lsnBefore FROM changelog WHERE id = 'Test'").Scan(&after)
+		assert.NoError(t, err)
+		assert.True(t, after)
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, status.Applied[0].LSNBefore)
+		assert.NotEmpty(t, status.Applied[0].LSNAfter)
+	})
+}
+
+func Test_SessionSettings(t *testing.T) {
+	t.Run("Test application_name and session settings are set for migrations", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test AS SELECT current_setting('application_name') AS name, current_setting('lock_timeout') AS lock_timeout",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		err = NewMigrationService("config.json", "scripts", fs, d, WithSessionSetting("lock_timeout", "5s")).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var name, lockTimeout, runId string
+		err = d.QueryRow("SELECT name, lock_timeout FROM test").Scan(&name, &lockTimeout)
+		assert.NoError(t, err)
+		err = d.QueryRow("SELECT id FROM changelog_run").Scan(&runId)
+		assert.NoError(t, err)
+		assert.Equal(t, "migrago:"+runId, name)
+		assert.Equal(t, "5s", lockTimeout)
+
+		// The settings are transaction local
+		err = d.QueryRow("SELECT current_setting('lock_timeout')").Scan(&lockTimeout)
+		assert.NoError(t, err)
+		assert.Equal(t, "0", lockTimeout)
+	})
+}
+
+func Test_ExecuteMigrationCancel(t *testing.T) {
+	t.Run("Test a cancelled migration is stopped and rolled back", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY); SELECT pg_sleep(60)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		runCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		err = NewMigrationService("config.json", "scripts", fs, d).ExecuteMigration(runCtx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		var running int
+		err = d.QueryRow("SELECT count(*) FROM pg_stat_activity WHERE query LIKE 'SELECT pg_sleep%'").Scan(&running)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, running)
+		var exists bool
+		err = d.QueryRow("SELECT to_regclass('test') IS NOT NULL").Scan(&exists)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		var runError string
+		err = d.QueryRow("SELECT error FROM changelog_run").Scan(&runError)
+		assert.NoError(t, err)
+		assert.Contains(t, runError, "cancelled")
+	})
+}
+
+func Test_ExecuteMigrationStop(t *testing.T) {
+	t.Run("Test a stopped run reports the remaining migrations and can be resumed", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "CREATE TABLE test2 (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test2",
+			},
+		})
+		stop := make(chan struct{})
+		close(stop)
+		err = NewMigrationService("config.json", "scripts", fs, d, WithStopChannel(stop)).ExecuteMigration(ctx)
+		var interruptedErr *InterruptedError
+		assert.ErrorAs(t, err, &interruptedErr)
+		assert.Equal(t, []string{"Test", "Test2"}, interruptedErr.Remaining)
+
+		err = NewMigrationService("config.json", "scripts", fs, d).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		existing, err := NewMigrationService("config.json", "scripts", fs, d).getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, existing, 2)
+	})
+}
+
+func Test_ExecuteMigrationMaxRunDuration(t *testing.T) {
+	t.Run("Test no migration is started after the budget is used up", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY); SELECT pg_sleep(1)",
+				RevertScript: "DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "CREATE TABLE test2 (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test2",
+			},
+		})
+		err = NewMigrationService("config.json", "scripts", fs, d, WithMaxRunDuration(500*time.Millisecond)).ExecuteMigration(ctx)
+		var interruptedErr *InterruptedError
+		assert.ErrorAs(t, err, &interruptedErr)
+		assert.Equal(t, []string{"Test2"}, interruptedErr.Remaining)
+	})
+}
+
+func Test_Explain(t *testing.T) {
+	t.Run("Test the DML statements of pending migrations are explained without executing them", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		migrations := []Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		}
+		err = NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO test (name) VALUES ('a')")
+		assert.NoError(t, err)
+
+		migrations = append(migrations, Migration{
+			Id:           "Test2",
+			Script:       "UPDATE test SET name = 'b'; CREATE TABLE test2 (id serial PRIMARY KEY); INSERT INTO test2 DEFAULT VALUES",
+			RevertScript: "DROP TABLE test2",
+		})
+		plans, err := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d).Explain(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, plans, 2)
+		assert.Equal(t, "Test2", plans[0].MigrationId)
+		assert.Equal(t, "ModifyTable", plans[0].NodeType)
+		assert.Empty(t, plans[0].Error)
+		assert.Contains(t, plans[1].Error, `relation "test2" does not exist`)
+
+		var name string
+		err = d.QueryRow("SELECT name FROM test").Scan(&name)
+		assert.NoError(t, err)
+		assert.Equal(t, "a", name)
+	})
+}
+
+func Test_ExecuteMigrationBackfill(t *testing.T) {
+	t.Run("Test a backfill updates all rows in batches", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		_, err = d.Exec("CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) NOT NULL)")
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO test (name) SELECT 'name' || i FROM generate_series(1, 25) i")
+		assert.NoError(t, err)
+
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Backfill",
+				Script:       "-- migrago:backfill table=test key=id batch-size=10\nUPDATE test SET name = upper(name) WHERE id BETWEEN $1 AND $2",
+				RevertScript: "UPDATE test SET name = lower(name)",
+			},
+		})
+		err = NewMigrationService("config.json", "scripts", fs, d).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var lower int
+		err = d.QueryRow("SELECT count(*) FROM test WHERE name <> upper(name)").Scan(&lower)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, lower)
+		existing, err := NewMigrationService("config.json", "scripts", fs, d).getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, existing, 1)
+	})
+}
+
+func Test_ExecuteMigrationBackfillResume(t *testing.T) {
+	t.Run("Test an interrupted backfill resumes after the checkpoint", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		_, err = d.Exec("CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) NOT NULL)")
+		assert.NoError(t, err)
+		_, err = d.Exec("INSERT INTO test (name) SELECT 'name' || i FROM generate_series(1, 25) i")
+		assert.NoError(t, err)
+
+		script := "-- migrago:backfill table=test key=id batch-size=10\nUPDATE test SET name = upper(name) WHERE id BETWEEN $1 AND $2"
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations([]Migration{
+			{Id: "Backfill", Script: script, RevertScript: "UPDATE test SET name = lower(name)"},
+		}), d)
+		assert.NoError(t, service.prepareDatabase(ctx))
+		_, err = d.Exec("INSERT INTO changelog_backfill (id, checksum, lastKey, batches, rowsAffected) VALUES ('Backfill', $1, '20', 2, 20)", calculateChecksum(script))
+		assert.NoError(t, err)
+
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var updated int
+		err = d.QueryRow("SELECT count(*) FROM test WHERE name = upper(name)").Scan(&updated)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, updated)
+		var checkpoints int
+		err = d.QueryRow("SELECT count(*) FROM changelog_backfill").Scan(&checkpoints)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, checkpoints)
+	})
+}
+
+func Test_RunAsyncJobs(t *testing.T) {
+	t.Run("Test async migrations are scheduled and executed by RunAsyncJobs", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "-- migrago:async\nINSERT INTO test (name) VALUES ('async')",
+				RevertScript: "DELETE FROM test WHERE name = 'async'",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, status.Pending)
+		assert.Len(t, status.Jobs, 1)
+		assert.True(t, status.Jobs[0].StartedAt.IsZero())
+
+		executed, err := service.RunAsyncJobs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, executed)
+		status, err = service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.False(t, status.Jobs[0].FinishedAt.IsZero())
+
+		executed, err = service.RunAsyncJobs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, executed)
+	})
+	t.Run("Test jobs of a crashed worker are retried once their heartbeat is stale", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "-- migrago:async\nINSERT INTO test (name) VALUES ('async')",
+				RevertScript: "DELETE FROM test WHERE name = 'async'",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithJobStaleTimeout(time.Minute))
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		// A worker claimed the job and stopped without finishing it or recording an error
+		_, err = d.ExecContext(ctx, `UPDATE changelog_job SET startedAt = CURRENT_TIMESTAMP, heartbeatAt = CURRENT_TIMESTAMP WHERE id = 'Test2'`)
+		assert.NoError(t, err)
+		executed, err := service.RunAsyncJobs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, executed)
+
+		_, err = d.ExecContext(ctx, `UPDATE changelog_job SET heartbeatAt = CURRENT_TIMESTAMP - INTERVAL '2 minutes' WHERE id = 'Test2'`)
+		assert.NoError(t, err)
+		executed, err = service.RunAsyncJobs(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, executed)
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.False(t, status.Jobs[0].FinishedAt.IsZero())
+		assert.Empty(t, status.Jobs[0].Error)
+	})
+}
+
+func Test_ExecuteMigrationNoTransaction(t *testing.T) {
+	t.Run("Test enum values added without transaction can be used by the next migration", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TYPE mood AS ENUM ('sad', 'happy'); CREATE TABLE test (id serial PRIMARY KEY, mood mood)",
+				RevertScript: "DROP TABLE test; DROP TYPE mood",
+			}, {
+				Id:           "Test2",
+				Script:       "-- migrago:no-transaction\nALTER TYPE mood ADD VALUE IF NOT EXISTS 'ok'; CREATE INDEX CONCURRENTLY test_mood ON test (mood)",
+				RevertScript: "DROP INDEX test_mood",
+			}, {
+				Id:           "Test3",
+				Script:       "INSERT INTO test (mood) VALUES ('ok')",
+				RevertScript: "DELETE FROM test WHERE mood = 'ok'",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 3)
+	})
+}
+
+func Test_ExecuteMigrationRefresh(t *testing.T) {
+	t.Run("Test materialized views are refreshed after the migrations changing their sources", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50)); CREATE MATERIALIZED VIEW test_count AS SELECT count(*) AS n FROM test",
+				RevertScript: "DROP MATERIALIZED VIEW test_count; DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "-- migrago:refresh test_count\nINSERT INTO test (name) VALUES ('a')",
+				RevertScript: "DELETE FROM test WHERE name = 'a'",
+			}, {
+				Id:           "Test3",
+				Script:       "-- migrago:refresh test_count\nINSERT INTO test (name) VALUES ('b')",
+				RevertScript: "DELETE FROM test WHERE name = 'b'",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		var n int
+		assert.NoError(t, d.QueryRowContext(ctx, "SELECT n FROM test_count").Scan(&n))
+		assert.Equal(t, 2, n)
+	})
+}
+
+func Test_ExecuteMigrationPreflight(t *testing.T) {
+	t.Run("Test unmet requirements are reported before any migration is executed", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithRequirements(Requirements{
+			Extensions: []string{"pgcrypto", "does_not_exist"},
+			Collations: []string{"C", "missing"},
+			Settings:   map[string]string{"server_encoding": "UTF8", "wal_level": "logical"},
+		}))
+		err = service.ExecuteMigration(ctx)
+		var preflightErr *PreflightError
+		assert.ErrorAs(t, err, &preflightErr)
+		assert.Equal(t, []string{
+			"extension does_not_exist is not available on the server",
+			"collation missing does not exist",
+			`setting wal_level is "replica", required is "logical"`,
+		}, preflightErr.Problems)
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Pending, 1)
+	})
+	t.Run("Test missing privileges on the target schemas are reported", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		_, err = d.ExecContext(ctx, `CREATE ROLE other; CREATE ROLE migrator LOGIN PASSWORD 'migrator';
+			CREATE SCHEMA app AUTHORIZATION migrator; CREATE TABLE app.foreign_owned (id INT); ALTER TABLE app.foreign_owned OWNER TO other;
+			CREATE SCHEMA locked; GRANT USAGE ON SCHEMA locked TO migrator`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every connection of the pool is switched to the migration role
+		d.SetMaxOpenConns(1)
+		if _, err := d.ExecContext(ctx, `SET ROLE migrator; SET search_path = app`); err != nil {
+			t.Fatal(err)
+		}
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE app.test (id serial PRIMARY KEY)",
+				RevertScript: "DROP TABLE app.test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithRequirements(Requirements{Schemas: []string{"app", "locked", "missing"}}))
+		err = service.ExecuteMigration(ctx)
+		var preflightErr *PreflightError
+		assert.ErrorAs(t, err, &preflightErr)
+		assert.Equal(t, []string{
+			"can not ALTER or DROP app.foreign_owned, it is owned by other",
+			"missing CREATE privilege on schema locked",
+			"schema missing does not exist",
+		}, preflightErr.Problems)
+	})
+}
+
+func Test_ExecuteMigrationVersionRequirement(t *testing.T) {
+	migrations := []Migration{
+		{
+			Id:           "Test",
+			Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+			RevertScript: "DROP TABLE test",
+		}, {
+			Id:           "Test2",
+			Script:       "-- migrago:requires postgres>=99\nALTER TABLE test ADD COLUMN future TEXT",
+			RevertScript: "ALTER TABLE test DROP COLUMN future",
+		}, {
+			Id:           "Test3",
+			Script:       "-- migrago:requires postgres>=12\nINSERT INTO test (name) VALUES ('a')",
+			RevertScript: "DELETE FROM test WHERE name = 'a'",
+		},
+	}
+	t.Run("Test unmet version requirements fail the run", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d)
+		err = service.ExecuteMigration(ctx)
+		assert.ErrorContains(t, err, "Test2 requires postgres>=99")
+	})
+	t.Run("Test unmet version requirements are skipped by policy", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d, WithVersionPolicy(VersionSkip))
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.Equal(t, "Test2", status.Pending[0].Id)
+
+		// The skipped migration is not reported as gap
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+	})
+}
+
+func Test_Preflight(t *testing.T) {
+	t.Run("Test preflight reports the server and the changelog without changing anything", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		report, err := service.Preflight(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "postgres", report.ServerType)
+		assert.Greater(t, report.VersionNum, 100000)
+		assert.False(t, report.ChangelogExists)
+		assert.True(t, report.ChangelogUpgradable)
+
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		report, err = service.Preflight(ctx)
+		assert.NoError(t, err)
+		assert.True(t, report.ChangelogExists)
+
+		service = NewMigrationService("config.json", "scripts", fs, d, WithRequirements(Requirements{Extensions: []string{"does_not_exist"}}))
+		report, err = service.Preflight(ctx)
+		var preflightErr *PreflightError
+		assert.ErrorAs(t, err, &preflightErr)
+		assert.Equal(t, []string{"extension does_not_exist is not available on the server"}, report.Problems)
+	})
+}
+
+func Test_Check(t *testing.T) {
+	t.Run("Test check reports pending migrations until the schema is up to date", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		var pendingErr *PendingMigrationsError
+		assert.ErrorAs(t, service.Check(ctx), &pendingErr)
+		assert.Equal(t, []string{"Test"}, pendingErr.Pending)
+
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.Check(ctx))
+
+		d.Close()
+		assert.ErrorContains(t, service.Check(ctx), "changelog unreachable")
+	})
+}
+
+func Test_RollbackToTag(t *testing.T) {
+	t.Run("Test rollback to a tag reverts the migrations applied after it", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		migrations := []Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		}
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d)
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.Tag(ctx, "v1"))
+		assert.ErrorContains(t, service.Tag(ctx, "v1"), "tag v1 already exists")
+
+		migrations = append(migrations, Migration{
+			Id:           "Test2",
+			Script:       "INSERT INTO test (name) VALUES ('a')",
+			RevertScript: "DELETE FROM test WHERE name = 'a'",
+		}, Migration{
+			Id:           "Test3",
+			Script:       "ALTER TABLE test ADD COLUMN age INT",
+			RevertScript: "ALTER TABLE test DROP COLUMN age",
+		})
+		service = NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), d)
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.Tag(ctx, "v2"))
+
+		assert.NoError(t, service.RollbackToTag(ctx, "v1"))
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 1)
+		assert.Len(t, status.Pending, 2)
+		var count int
+		assert.NoError(t, d.QueryRowContext(ctx, "SELECT count(*) FROM test").Scan(&count))
+		assert.Equal(t, 0, count)
+
+		assert.ErrorContains(t, service.RollbackToTag(ctx, "v2"), "unknown tag v2")
+	})
+}
+
+func Test_Releases(t *testing.T) {
+	t.Run("Test releases are applied, reported and rolled back as a whole", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			}, {
+				Id:           "Test2",
+				Script:       "INSERT INTO test (name) VALUES ('a')",
+				RevertScript: "DELETE FROM test WHERE name = 'a'",
+			}, {
+				Id:           "Test3",
+				Script:       "ALTER TABLE test ADD COLUMN age INT",
+				RevertScript: "ALTER TABLE test DROP COLUMN age",
+			},
+		}).(fstest.MapFS)
+		fs["config.json"] = &fstest.MapFile{Data: []byte(`{"releases": [
+			{"name": "v1", "migrations": ["Test", "Test2"]},
+			{"name": "v2", "migrations": ["Test3"]}
+		]}`)}
+		service := NewMigrationService("config.json", "scripts", fs, d)
+
+		current, err := service.CurrentRelease(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "", current)
+
+		assert.NoError(t, service.ApplyRelease(ctx, "v1"))
+		current, err = service.CurrentRelease(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", current)
+
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		current, err = service.CurrentRelease(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", current)
+
+		assert.NoError(t, service.RollbackRelease(ctx, "v2"))
+		current, err = service.CurrentRelease(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", current)
+	})
+}
+
+func Test_StatusReadOnly(t *testing.T) {
+	t.Run("Test status reports a missing changelog without creating it", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.True(t, status.Uninitialized)
+		assert.Len(t, status.Pending, 1)
+		var exists bool
+		assert.NoError(t, d.QueryRowContext(ctx, "SELECT to_regclass('changelog') IS NOT NULL").Scan(&exists))
+		assert.False(t, exists)
+
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		// A monitoring role can only read the changelog
+		_, err = d.ExecContext(ctx, `CREATE ROLE monitoring; GRANT SELECT ON ALL TABLES IN SCHEMA public TO monitoring`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.SetMaxOpenConns(1)
+		if _, err := d.ExecContext(ctx, `SET ROLE monitoring`); err != nil {
+			t.Fatal(err)
+		}
+		status, err = service.Status(ctx)
+		assert.NoError(t, err)
+		assert.False(t, status.Uninitialized)
+		assert.Len(t, status.Applied, 1)
+		assert.Empty(t, status.Pending)
+	})
+}
+
+func Test_Orchestrator(t *testing.T) {
+	t.Run("Test targets share the run id and dependents of a failed target are skipped", func(t *testing.T) {
+		ctx := context.Background()
+		oltp, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer oltp.Close()
+		warehouse, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer warehouse.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		broken := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+
+		o, err := NewOrchestrator(
+			Target{Name: "warehouse", Service: NewMigrationService("config.json", "scripts", fs, warehouse), After: []string{"oltp"}},
+			Target{Name: "oltp", Service: NewMigrationService("config.json", "scripts", fs, oltp)},
+		)
+		assert.NoError(t, err)
+		runId, results, err := o.Run(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		for _, db := range []*sql.DB{oltp, warehouse} {
+			var count int
+			assert.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM changelog_run WHERE id = $1", runId).Scan(&count))
+			assert.Equal(t, 1, count)
+		}
+
+		o, err = NewOrchestrator(
+			Target{Name: "oltp", Service: NewMigrationService("config.json", "scripts", broken, oltp)},
+			Target{Name: "warehouse", Service: NewMigrationService("config.json", "scripts", fs, warehouse), After: []string{"oltp"}},
+		)
+		assert.NoError(t, err)
+		_, results, err = o.Run(ctx)
+		var orchestrationErr *OrchestrationError
+		assert.ErrorAs(t, err, &orchestrationErr)
+		assert.Error(t, results[0].Err)
+		assert.True(t, results[1].Skipped)
+	})
+	t.Run("Test two-phase runs migrate no target if one fails to prepare", func(t *testing.T) {
+		ctx := context.Background()
+		first, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer first.Close()
+		second, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer second.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		broken := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY); INSERT INTO missing VALUES (1)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+
+		o, err := NewOrchestrator(
+			Target{Name: "first", Service: NewMigrationService("config.json", "scripts", fs, first)},
+			Target{Name: "second", Service: NewMigrationService("config.json", "scripts", broken, second)},
+		)
+		assert.NoError(t, err)
+		_, results, err := o.RunTwoPhase(ctx)
+		var orchestrationErr *OrchestrationError
+		assert.ErrorAs(t, err, &orchestrationErr)
+		assert.True(t, results[0].Skipped)
+		assert.ErrorContains(t, results[1].Err, "prepare failed")
+		for _, db := range []*sql.DB{first, second} {
+			var exists bool
+			assert.NoError(t, db.QueryRowContext(ctx, "SELECT to_regclass('test') IS NOT NULL").Scan(&exists))
+			assert.False(t, exists)
+		}
+
+		o, err = NewOrchestrator(
+			Target{Name: "first", Service: NewMigrationService("config.json", "scripts", fs, first)},
+			Target{Name: "second", Service: NewMigrationService("config.json", "scripts", fs, second)},
+		)
+		assert.NoError(t, err)
+		runId, results, err := o.RunTwoPhase(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		for _, db := range []*sql.DB{first, second} {
+			var count int
+			assert.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM changelog WHERE id = 'Test'").Scan(&count))
+			assert.Equal(t, 1, count)
+			assert.NoError(t, db.QueryRowContext(ctx, "SELECT count(*) FROM changelog_run WHERE id = $1", runId).Scan(&count))
+			assert.Equal(t, 1, count)
+		}
+	})
+}
+
+func Test_ExecuteMigrationPrimaryOnly(t *testing.T) {
+	t.Run("Test read-only connections fail fast or are replaced by the resolved primary", func(t *testing.T) {
+		ctx := context.Background()
+		replica, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer replica.Close()
+		primary, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer primary.Close()
+		replica.SetMaxOpenConns(1)
+		if _, err := replica.ExecContext(ctx, `SET default_transaction_read_only = on`); err != nil {
+			t.Fatal(err)
+		}
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		err = NewMigrationService("config.json", "scripts", fs, replica).ExecuteMigration(ctx)
+		assert.ErrorIs(t, err, ErrNotPrimary)
+
+		resolver := func(ctx context.Context) (*sql.DB, error) { return primary, nil }
+		err = NewMigrationService("config.json", "scripts", fs, replica, WithPrimaryResolver(resolver)).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		var exists bool
+		assert.NoError(t, primary.QueryRowContext(ctx, "SELECT to_regclass('test') IS NOT NULL").Scan(&exists))
+		assert.True(t, exists)
+	})
+}
+
+func Test_ExecuteMigrationConcurrentInsert(t *testing.T) {
+	t.Run("Test a migration recorded by a racing runner is rolled back", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(nil), d)
+		assert.NoError(t, service.prepareDatabase(ctx))
+		_, err = d.ExecContext(ctx, "INSERT INTO changelog (id, checksum, revertscript) VALUES ('Test', '9c23564a026f0826f2a05b8423aa21f9', 'DROP TABLE test')")
+		assert.NoError(t, err)
+
+		// The second runner loaded the changelog before the first one committed
+		tx, err := service.beginTx(ctx)
+		assert.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "CREATE TABLE test (id serial PRIMARY KEY)")
+		assert.NoError(t, err)
+		err = service.insertChangelog(ctx, tx, Migration{Id: "Test", Checksum: "9c23564a026f0826f2a05b8423aa21f9", RevertScript: "DROP TABLE test"})
+		assert.ErrorIs(t, err, ErrAppliedConcurrently)
+		assert.NoError(t, tx.Rollback())
+	})
+}
+
+func Test_ExecuteMigrationPhaseTimeouts(t *testing.T) {
+	t.Run("Test a migration exceeding its timeout fails without using the deadline of the run", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY); SELECT pg_sleep(60)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d, WithPhaseTimeouts(PhaseTimeouts{Migration: time.Second}))
+		err = service.ExecuteMigration(ctx)
+		var timeoutErr *PhaseTimeoutError
+		assert.ErrorAs(t, err, &timeoutErr)
+		assert.Equal(t, "migration", timeoutErr.Phase)
+		assert.Equal(t, "Test", timeoutErr.Id)
+
+		var exists bool
+		err = d.QueryRow("SELECT to_regclass('test') IS NOT NULL").Scan(&exists)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func Test_ApplyAndRevertThrough(t *testing.T) {
+	t.Run("Test migrations are applied and reverted up to the selected one", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "Test", Script: "CREATE TABLE test (id serial PRIMARY KEY)", RevertScript: "DROP TABLE test"},
+			{Id: "Test2", Script: "CREATE TABLE test2 (id serial PRIMARY KEY)", RevertScript: "DROP TABLE test2"},
+			{Id: "Test3", Script: "CREATE TABLE test3 (id serial PRIMARY KEY)", RevertScript: "DROP TABLE test3"},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		assert.NoError(t, service.ApplyThrough(ctx, "Test2"))
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.Equal(t, "Test3", status.Pending[0].Id)
+		assert.ErrorContains(t, service.ApplyThrough(ctx, "Test"), "migration Test is not pending")
+
+		applied, err := service.AppliedMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "DROP TABLE test2", applied[0].RevertScript)
+
+		assert.NoError(t, service.RevertThrough(ctx, "Test"))
+		status, err = service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, status.Applied)
+		assert.Len(t, status.Pending, 3)
+		assert.ErrorContains(t, service.RevertThrough(ctx, "Test3"), "migration Test3 is not applied")
+	})
+}
+
+func Test_Aliases(t *testing.T) {
+	t.Run("Test renamed migrations take over the changelog entry of their previous ID", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "CREATE TABLE test (id serial PRIMARY KEY)",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		err = NewMigrationService("config.json", "scripts", fs, d).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		fs = fstest.MapFS{
+			"config.json":                    {Data: []byte(`{"aliases": {"Test": "TestRenamed"}, "migrations": ["TestRenamed"]}`)},
+			"scripts/TestRenamed.sql":        {Data: []byte("CREATE TABLE test (id serial PRIMARY KEY)")},
+			"scripts/TestRenamed.revert.sql": {Data: []byte("DROP TABLE test")},
+		}
+		service := NewMigrationService("config.json", "scripts", fs, d)
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, status.Pending)
+		assert.Empty(t, status.Unknown)
+
+		err = service.ExecuteMigration(ctx)
+		assert.NoError(t, err)
+		existing, err := service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, existing, 1)
+		assert.Equal(t, "TestRenamed", existing[0].Id)
+	})
+}
+
+func Test_Superseded(t *testing.T) {
+	migrations := []Migration{
+		{Id: "Test", Script: "CREATE TABLE test (id serial PRIMARY KEY)", RevertScript: "DROP TABLE test"},
+		{Id: "Test2", Script: "ALTER TABLE test ADD COLUMN name TEXT NOT NULL", RevertScript: "ALTER TABLE test DROP COLUMN name"},
+		{Id: "TestSquash", Script: "CREATE TABLE test (id serial PRIMARY KEY, name TEXT NOT NULL)", RevertScript: "DROP TABLE test"},
+	}
+	squashed := func() fs.FS {
+		fs := CreateFSForMigrations(migrations)
+		fs.(fstest.MapFS)["config.json"].Data = []byte(`{"superseded": {"Test": "TestSquash", "Test2": "TestSquash"}, "migrations": ["Test", "Test2", "TestSquash"]}`)
+		return fs
+	}
+
+	t.Run("Test fresh installs only apply the replacement", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		service := NewMigrationService("config.json", "scripts", squashed(), d)
+		assert.NoError(t, service.VerifyReplacements(ctx))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		existing, err := service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, existing, 1)
+		assert.Equal(t, "TestSquash", existing[0].Id)
+	})
+	t.Run("Test the replacement of applied migrations is recorded without execution", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		err = NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations[:2]), d).ExecuteMigration(ctx)
+		assert.NoError(t, err)
+
+		service := NewMigrationService("config.json", "scripts", squashed(), d)
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		existing, err := service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, existing, 3)
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, status.Pending)
+	})
+	t.Run("Test replacements creating other objects are reported", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := squashed()
+		fs.(fstest.MapFS)["scripts/TestSquash.sql"].Data = []byte("CREATE TABLE test (id serial PRIMARY KEY, name TEXT)")
+		var verifyErr *VerifyError
+		assert.ErrorAs(t, NewMigrationService("config.json", "scripts", fs, d).VerifyReplacements(ctx), &verifyErr)
+		assert.Equal(t, []string{
+			"replacement TestSquash does not create column test.name text not null",
+			"replacement TestSquash additionally creates column test.name text",
+		}, verifyErr.Problems)
+	})
+}
+
+func Test_ExecuteMigrationAssertions(t *testing.T) {
+	t.Run("Test violated assertions roll back the migration", func(t *testing.T) {
+		ctx := context.Background()
+		d, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer d.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "Test",
+				Script:       "-- migrago:assert SELECT count(*) FROM test == 2\nCREATE TABLE test (id serial PRIMARY KEY);\nINSERT INTO test DEFAULT VALUES;",
+				RevertScript: "DROP TABLE test",
+			},
+		})
+		err = NewMigrationService("config.json", "scripts", fs, d).ExecuteMigration(ctx)
+		var assertionErr *AssertionError
+		assert.ErrorAs(t, err, &assertionErr)
+		assert.Equal(t, "1", assertionErr.Actual)
+		var exists bool
+		assert.NoError(t, d.QueryRow("SELECT to_regclass('test') IS NOT NULL").Scan(&exists))
+		assert.False(t, exists)
+
+		fs.(fstest.MapFS)["scripts/Test.sql"].Data = []byte("-- migrago:assert SELECT count(*) FROM test == 1\nCREATE TABLE test (id serial PRIMARY KEY);\nINSERT INTO test DEFAULT VALUES;")
+		assert.NoError(t, NewMigrationService("config.json", "scripts", fs, d).ExecuteMigration(ctx))
+	})
+}
+
+func Test_DiffChangelogs(t *testing.T) {
+	t.Run("Test missing migrations and checksum differences between two databases are reported", func(t *testing.T) {
+		ctx := context.Background()
+		staging, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer staging.Close()
+		production, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer production.Close()
+		stagingFS := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id int)", RevertScript: "DROP TABLE users"},
+			{Id: "0002_orders", Script: "CREATE TABLE orders (id int)", RevertScript: "DROP TABLE orders"},
+			{Id: "0003_items", Script: "CREATE TABLE items (id int)", RevertScript: "DROP TABLE items"},
+		})
+		productionFS := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id int)", RevertScript: "DROP TABLE users"},
+			{Id: "0002_orders", Script: "CREATE TABLE orders (id bigint)", RevertScript: "DROP TABLE orders"},
+		})
+		stagingService := NewMigrationService("config.json", "scripts", stagingFS, staging)
+		productionService := NewMigrationService("config.json", "scripts", productionFS, production)
+		assert.NoError(t, stagingService.ExecuteMigration(ctx))
+		assert.NoError(t, productionService.ExecuteMigration(ctx))
+
+		diff, err := DiffChangelogs(ctx, stagingService, productionService)
+		assert.NoError(t, err)
+		assert.Len(t, diff.OnlyLeft, 1)
+		assert.Equal(t, "0003_items", diff.OnlyLeft[0].Id)
+		assert.Empty(t, diff.OnlyRight)
+		assert.Len(t, diff.Checksums, 1)
+		assert.Equal(t, "0002_orders", diff.Checksums[0].Id)
+		assert.Empty(t, diff.Reordered)
+
+		diff, err = DiffChangelogs(ctx, stagingService, stagingService)
+		assert.NoError(t, err)
+		assert.True(t, diff.Empty())
+	})
+}
+
+func Test_CockroachDB(t *testing.T) {
+	t.Run("Test migrations are applied and reverted with the CockroachDB dialect", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := CreateTestCockroachContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INT PRIMARY KEY)", RevertScript: "DROP TABLE users"},
+			{Id: "0002_orders", Script: "CREATE TABLE orders (id INT PRIMARY KEY)", RevertScript: "DROP TABLE orders"},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, db, WithDialect(CockroachDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.Empty(t, status.Pending)
+
+		fs = CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INT PRIMARY KEY)", RevertScript: "DROP TABLE users"},
+		})
+		service = NewMigrationService("config.json", "scripts", fs, db, WithDialect(CockroachDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var exists bool
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT to_regclass('orders') IS NOT NULL`).Scan(&exists))
+		assert.False(t, exists)
+	})
+}
+
+func Test_StatementProgress(t *testing.T) {
+	t.Run("Test a failed migration without transaction resumes after its last successful statement", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := CreateTestPostgresContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "0001_tables",
+				Script:       "-- migrago:no-transaction\nCREATE TABLE a (id int);\nINSERT INTO b VALUES (1);\nCREATE TABLE c (id int);",
+				RevertScript: "DROP TABLE c; DROP TABLE a",
+			},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, db, WithStatementProgress())
+		assert.ErrorContains(t, service.ExecuteMigration(ctx), `relation "b" does not exist`)
+		var statements int
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT statements FROM changelog_progress WHERE id = '0001_tables'`).Scan(&statements))
+		assert.Equal(t, 1, statements)
+
+		// CREATE TABLE a would fail if it was executed again
+		_, err = db.ExecContext(ctx, `CREATE TABLE b (id int)`)
+		assert.NoError(t, err)
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var remaining int
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM changelog_progress`).Scan(&remaining))
+		assert.Zero(t, remaining)
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM b`).Scan(&remaining))
+		assert.Equal(t, 1, remaining)
+	})
+}
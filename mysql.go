@@ -0,0 +1,85 @@
+package migrago
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// mysqlImplicitCommitKeywords start the statements which commit the current transaction
+var mysqlImplicitCommitKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE", "GRANT", "REVOKE"}
+
+// MySQLDialect is the dialect of MySQL and MariaDB. The DSN of the driver needs parseTime=true to read the
+// timestamps of the changelog.
+type MySQLDialect struct{}
+
+func (MySQLDialect) Name() string {
+	return "mysql"
+}
+
+func (MySQLDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (MySQLDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText:
+		return "LONGTEXT"
+	case ColumnInteger:
+		return "BIGINT"
+	case ColumnTimestamp:
+		return "DATETIME(6)"
+	case ColumnCreatedAt:
+		return "DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)"
+	case ColumnFlag:
+		return "BOOLEAN NOT NULL DEFAULT FALSE"
+	case ColumnSequence:
+		// An auto increment column has to be a key, the primary key is the ID
+		return "BIGINT NOT NULL AUTO_INCREMENT UNIQUE"
+	default:
+		// The log positions of MySQL are not recorded
+		return "VARCHAR(255)"
+	}
+}
+
+// ChangelogDDL creates the changelog tables of the current version, there are no earlier MySQL changelogs to upgrade
+func (d MySQLDialect) ChangelogDDL() []string {
+	return createChangelogTables(d)
+}
+
+func (MySQLDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = '%s'`, table)
+}
+
+func (MySQLDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT LOWER(column_name) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = '%s'`, table)
+}
+
+// InsertIgnore updates the primary key to itself on a duplicate, unlike INSERT IGNORE it does not hide other errors
+func (d MySQLDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s = %s`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)), columns[0], columns[0])
+}
+
+func (MySQLDialect) SessionSettingStatement() string {
+	return ""
+}
+
+// LockStatement is empty, InnoDB blocks a concurrent insert of the same ID into the changelog like Postgres
+func (MySQLDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for the schema and privilege changes, they commit the transaction implicitly
+func (MySQLDialect) NonTransactional(statement string) bool {
+	return slices.Contains(mysqlImplicitCommitKeywords, firstKeyword(statement))
+}
+
+func (MySQLDialect) VersionQuery() string {
+	return `SELECT VERSION()`
+}
+
+// SplitRules respect backslash escapes in strings, # comments and backtick quoted identifiers
+func (MySQLDialect) SplitRules() SplitRules {
+	return mysqlSplitRules
+}
@@ -0,0 +1,120 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func CreateTestMySQLContainer(t *testing.T, ctx context.Context) (*sql.DB, error) {
+	dsn := func(host string, port nat.Port) string {
+		return fmt.Sprintf("root:migrago@tcp(%s:%s)/migrago?parseTime=true", host, port.Port())
+	}
+	req := testcontainers.ContainerRequest{
+		Image:        "mysql:8.0",
+		ExposedPorts: []string{"3306/tcp"},
+		Env: map[string]string{
+			"MYSQL_ROOT_PASSWORD": "migrago",
+			"MYSQL_DATABASE":      "migrago",
+		},
+		WaitingFor: wait.ForSQL(nat.Port("3306"), "mysql", dsn).WithStartupTimeout(2 * time.Minute),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		container.Terminate(ctx)
+	})
+	ip, err := container.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	port, err := container.MappedPort(ctx, "3306")
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("mysql", dsn(ip, port))
+}
+
+func Test_MySQLDialect(t *testing.T) {
+	d := MySQLDialect{}
+	assert.Equal(t, `INSERT INTO changelog_job (id, scheduledAt) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = id`, d.InsertIgnore("changelog_job", "id", "scheduledAt"))
+	assert.Contains(t, d.ChangelogDDL()[0], "sequence BIGINT NOT NULL AUTO_INCREMENT UNIQUE")
+
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(d))
+	assert.Equal(t, "mysql", service.dialectName())
+	query, args := service.rebind(`UPDATE changelog_run SET finishedAt = CURRENT_TIMESTAMP, error = $2 WHERE id = $1`, "run", "failed")
+	assert.Equal(t, `UPDATE changelog_run SET finishedAt = CURRENT_TIMESTAMP, error = ? WHERE id = ?`, query)
+	assert.Equal(t, []any{"failed", "run"}, args)
+}
+
+func Test_nonTransactional(t *testing.T) {
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(MySQLDialect{}))
+	assert.False(t, service.nonTransactional(Migration{Script: "INSERT INTO users VALUES (1);\nUPDATE users SET name = 'a';"}))
+	assert.True(t, service.nonTransactional(Migration{Script: "INSERT INTO users VALUES (1);\n-- new column\nalter\ttable users ADD COLUMN name TEXT;"}))
+	assert.False(t, MigrationService{}.nonTransactional(Migration{Script: "CREATE TABLE users (id INT);"}))
+}
+
+func Test_MySQL(t *testing.T) {
+	t.Run("Test migrations are applied and reverted with the MySQL dialect", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := CreateTestMySQLContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INT PRIMARY KEY, name TEXT);", RevertScript: "DROP TABLE users;"},
+			{Id: "0002_admin", Script: "INSERT INTO users VALUES (1, 'it\\'s; me'); # the admin;\nUPDATE users SET name = 'admin' WHERE id = 1;", RevertScript: "DELETE FROM users WHERE id = 1;"},
+			{Id: "0003_orders", Script: "CREATE TABLE orders (id INT PRIMARY KEY);\nCREATE INDEX orders_id ON orders (id);", RevertScript: "DROP TABLE orders;"},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, db, WithDialect(MySQLDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 3)
+		assert.Empty(t, status.Pending)
+		var name string
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT name FROM users WHERE id = 1`).Scan(&name))
+		assert.Equal(t, "admin", name)
+
+		fs = CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INT PRIMARY KEY, name TEXT);", RevertScript: "DROP TABLE users;"},
+		})
+		service = NewMigrationService("config.json", "scripts", fs, db, WithDialect(MySQLDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var exists bool
+		assert.NoError(t, db.QueryRowContext(ctx, MySQLDialect{}.TableExistsQuery("orders")).Scan(&exists))
+		assert.False(t, exists)
+		var count int
+		assert.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count))
+		assert.Zero(t, count)
+
+		fs = fstest.MapFS{
+			"config.json":                      {Data: []byte(`{"aliases": {"0001_users": "0001_accounts"}, "migrations": ["0001_accounts"]}`)},
+			"scripts/0001_accounts.sql":        {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY, name TEXT);")},
+			"scripts/0001_accounts.revert.sql": {Data: []byte("DROP TABLE users;")},
+		}
+		service = NewMigrationService("config.json", "scripts", fs, db, WithDialect(MySQLDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		existing, err := service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, existing, 1)
+		assert.Equal(t, "0001_accounts", existing[0].Id)
+	})
+}
@@ -0,0 +1,68 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// executeWithoutTransaction executes the statements of a migration marked with "-- migrago:no-transaction" one by one
+// in autocommit mode, e.g. for ALTER TYPE ... ADD VALUE before Postgres 12 or CREATE INDEX CONCURRENTLY. The changelog
+// row is recorded after the last statement, a failed migration is not rolled back, so the statements have to be idempotent
+// unless WithStatementProgress resumes it.
+func (m MigrationService) executeWithoutTransaction(ctx context.Context, migration Migration) error {
+	start := time.Now()
+	if err := m.execAutocommit(ctx, migration); err != nil {
+		return err
+	}
+	migration.Duration = time.Since(start)
+	m.checkSlow(ctx, migration)
+
+	tx, err := m.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	// The statements are committed already, a violated assertion keeps the migration out of the changelog
+	if err := checkAssertions(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := m.insertChangelog(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := m.clearProgress(ctx, tx, migration.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// execAutocommit executes the statements of a migration on a dedicated connection, it is returned to the pool before
+// the changelog is written, so a pool with a single connection suffices
+func (m MigrationService) execAutocommit(ctx context.Context, migration Migration) error {
+	conn, err := m.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done, err := m.resumeProgress(ctx, conn, migration)
+	if err != nil {
+		return err
+	}
+	var n int
+	err = readMigrationScript(migration, m.splitScript, func(statement string) error {
+		if n++; n <= done {
+			// Executed by an earlier attempt
+			return nil
+		}
+		if _, err := m.execStatement(ctx, conn, Statement{MigrationId: migration.Id, SQL: statement}); err != nil {
+			return fmt.Errorf("failed to execute migration script: %w", err)
+		}
+		return m.recordProgress(ctx, conn, migration, n)
+	})
+	if err != nil {
+		return err
+	}
+	return refreshViews(ctx, conn, migration)
+}
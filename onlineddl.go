@@ -0,0 +1,162 @@
+package migrago
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// alterTablePattern splits an ALTER TABLE statement into the table and the alter clause
+var alterTablePattern = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\S+)\s+(.+)$`)
+
+// OnlineSchemaChange delegates migrations marked with "-- migrago:online-ddl" to an external tool like gh-ost
+// or pt-online-schema-change instead of executing a blocking ALTER TABLE. The script of such a migration
+// has to be a single ALTER TABLE statement.
+type OnlineSchemaChange struct {
+	// Command is the executable of the tool
+	Command string
+	// Args are text/template strings with the fields .Database, .Table and .Alter (the alter clause)
+	Args []string
+	// Database is passed to the arguments as .Database
+	Database string
+}
+
+// GhOst invokes gh-ost, extraArgs are appended (e.g. --host or --assume-rbr)
+func GhOst(database string, extraArgs ...string) OnlineSchemaChange {
+	return OnlineSchemaChange{
+		Command:  "gh-ost",
+		Args:     append([]string{"--database={{.Database}}", "--table={{.Table}}", "--alter={{.Alter}}", "--execute"}, extraArgs...),
+		Database: database,
+	}
+}
+
+// PtOnlineSchemaChange invokes pt-online-schema-change, extraArgs are appended (e.g. --host or --user)
+func PtOnlineSchemaChange(database string, extraArgs ...string) OnlineSchemaChange {
+	return OnlineSchemaChange{
+		Command:  "pt-online-schema-change",
+		Args:     append([]string{"--alter={{.Alter}}", "D={{.Database}},t={{.Table}}", "--execute"}, extraArgs...),
+		Database: database,
+	}
+}
+
+// command builds the arguments of the tool for an ALTER TABLE statement
+func (o OnlineSchemaChange) command(statement string) ([]string, error) {
+	match := alterTablePattern.FindStringSubmatch(strings.TrimSpace(statement))
+	if match == nil {
+		return nil, fmt.Errorf("online schema changes need a single ALTER TABLE statement")
+	}
+	data := struct{ Database, Table, Alter string }{
+		Database: o.Database,
+		Table:    strings.Trim(match[1], "`\""),
+		Alter:    strings.Join(strings.Fields(match[2]), " "),
+	}
+	args := make([]string, len(o.Args))
+	for i, arg := range o.Args {
+		tmpl, err := template.New("arg").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument template %q: %w", arg, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("invalid argument template %q: %w", arg, err)
+		}
+		args[i] = b.String()
+	}
+	return args, nil
+}
+
+// runOnlineSchemaChange executes the ALTER TABLE statement of the migration with the online schema change tool,
+// the output of the tool is logged line by line to report its progress
+func (m MigrationService) runOnlineSchemaChange(ctx context.Context, migration Migration) error {
+	if m.onlineSchemaChange == nil {
+		return fmt.Errorf("migration %s needs an online schema change tool, configure WithOnlineSchemaChange", migration.Id)
+	}
+	var statements []string
+	if err := m.scriptStatements(migration, func(statement string) error {
+		statements = append(statements, stripComments(statement, m.splitRules().HashComments))
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(statements) != 1 {
+		return fmt.Errorf("migration %s: online schema changes need a single ALTER TABLE statement", migration.Id)
+	}
+	args, err := m.onlineSchemaChange.command(statements[0])
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", migration.Id, err)
+	}
+
+	cmd := exec.CommandContext(ctx, m.onlineSchemaChange.Command, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	m.log().InfoContext(ctx, "starting online schema change", "id", migration.Id, "command", m.onlineSchemaChange.Command)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", m.onlineSchemaChange.Command, err)
+	}
+	var wg sync.WaitGroup
+	for _, r := range []io.Reader{stdout, stderr} {
+		wg.Add(1)
+		go func(r io.Reader) {
+			defer wg.Done()
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				m.log().InfoContext(ctx, "online schema change", "id", migration.Id, "output", scanner.Text())
+			}
+		}(r)
+	}
+	wg.Wait()
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("online schema change of migration %s failed: %w", migration.Id, err)
+	}
+	return nil
+}
+
+// executeOnlineSchemaChange runs the online schema change tool and records the migration in the changelog on success
+func (m MigrationService) executeOnlineSchemaChange(ctx context.Context, migration Migration) error {
+	start := time.Now()
+	if err := m.runOnlineSchemaChange(ctx, migration); err != nil {
+		return err
+	}
+	migration.Duration = time.Since(start)
+	m.checkSlow(ctx, migration)
+
+	tx, err := m.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	// The schema change can not be rolled back anymore, a violated assertion only leaves it unrecorded
+	if err := checkAssertions(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := m.insertChangelog(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// stripComments removes the comment lines of a statement, lines starting with # as well if hashComments is set
+func stripComments(statement string, hashComments bool) string {
+	var lines []string
+	for _, line := range strings.Split(statement, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "--") && !(hashComments && strings.HasPrefix(trimmed, "#")) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,45 @@
+package migrago
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OnlineSchemaChangeCommand(t *testing.T) {
+	args, err := GhOst("app", "--host=db").command("ALTER TABLE `users`\n\tADD COLUMN age INT")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--database=app", "--table=users", "--alter=ADD COLUMN age INT", "--execute", "--host=db"}, args)
+
+	args, err = PtOnlineSchemaChange("app").command("alter table users drop column age")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--alter=drop column age", "D=app,t=users", "--execute"}, args)
+
+	_, err = GhOst("app").command("CREATE TABLE users (id INT)")
+	assert.ErrorContains(t, err, "single ALTER TABLE statement")
+}
+
+func Test_runOnlineSchemaChange(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	migration := Migration{Id: "Test", Script: "-- migrago:online-ddl\nALTER TABLE users ADD COLUMN age INT;"}
+
+	tool := OnlineSchemaChange{Command: "echo", Args: []string{"{{.Table}}:{{.Alter}}"}}
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithOnlineSchemaChange(tool), WithLogger(logger))
+	assert.NoError(t, service.runOnlineSchemaChange(context.Background(), migration))
+	assert.Contains(t, buf.String(), `output="users:ADD COLUMN age INT"`)
+
+	tool = OnlineSchemaChange{Command: "false"}
+	service = NewMigrationService("config.json", "scripts", nil, nil, WithOnlineSchemaChange(tool))
+	assert.ErrorContains(t, service.runOnlineSchemaChange(context.Background(), migration), "online schema change of migration Test failed")
+
+	err := NewMigrationService("config.json", "scripts", nil, nil).runOnlineSchemaChange(context.Background(), migration)
+	assert.ErrorContains(t, err, "configure WithOnlineSchemaChange")
+
+	migration.Script = "ALTER TABLE users ADD COLUMN age INT; ALTER TABLE users ADD COLUMN name TEXT"
+	service = NewMigrationService("config.json", "scripts", nil, nil, WithOnlineSchemaChange(GhOst("app")))
+	assert.ErrorContains(t, service.runOnlineSchemaChange(context.Background(), migration), "single ALTER TABLE statement")
+}
@@ -0,0 +1,328 @@
+package migrago
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Option configures optional behaviour of a MigrationService
+type Option func(*MigrationService)
+
+// WithProductionMode marks the service as running against a production database,
+// which disables all development only options
+func WithProductionMode() Option {
+	return func(m *MigrationService) {
+		m.production = true
+	}
+}
+
+// WithDevForce reverts and re-applies an already executed migration when its script
+// was edited, instead of failing with a checksum mismatch. Only the newest applied
+// migrations can be forced and the option is rejected in production mode.
+func WithDevForce() Option {
+	return func(m *MigrationService) {
+		m.devForce = true
+	}
+}
+
+// WithSourceRevision records the revision (e.g. a git commit) the migrations were loaded from in the run audit
+func WithSourceRevision(revision string) Option {
+	return func(m *MigrationService) {
+		m.revision = revision
+	}
+}
+
+// WithSource adds another set of migrations (e.g. shared platform migrations) to the run.
+// The IDs of the source are prefixed with "namespace/" in the changelog. Additional sources
+// are executed in the order they are added, before the migrations of the service itself.
+func WithSource(namespace string, source Source) Option {
+	return func(m *MigrationService) {
+		m.sources = append(m.sources, namedSource{namespace: namespace, source: source})
+	}
+}
+
+// WithOptionalRevertScripts allows migrations without a revert script. They are stored
+// with a NULL revert script in the changelog and can not be reverted automatically.
+func WithOptionalRevertScripts() Option {
+	return func(m *MigrationService) {
+		m.optionalRevertScripts = true
+	}
+}
+
+// WithAllowIrreversibleRevert is the operator override to revert migrations marked as irreversible
+func WithAllowIrreversibleRevert() Option {
+	return func(m *MigrationService) {
+		m.allowIrreversibleRevert = true
+	}
+}
+
+// WithRevertScriptStore uploads revert scripts to the object store (below prefix) instead of storing them
+// in the changelog, which then only keeps a reference and the SHA-256 of the script.
+// The store is also needed to revert migrations applied with this option.
+func WithRevertScriptStore(store WritableObjectStore, prefix string) Option {
+	return func(m *MigrationService) {
+		m.revertStore = store
+		m.revertStorePrefix = prefix
+	}
+}
+
+// WithRevertScriptEncryption encrypts the revert scripts in the changelog. The same cipher is needed
+// to revert the migrations later, scripts stored with WithRevertScriptStore are not encrypted by the service.
+func WithRevertScriptEncryption(cipher RevertScriptCipher) Option {
+	return func(m *MigrationService) {
+		m.revertCipher = cipher
+	}
+}
+
+// WithApplicationName overrides the application_name of the migration sessions, default is "migrago:<run-id>"
+func WithApplicationName(name string) Option {
+	return func(m *MigrationService) {
+		m.appName = name
+	}
+}
+
+// WithSessionSetting sets a run-time parameter (e.g. lock_timeout or statement_timeout) for every
+// migration and revert transaction. Settings are applied in the order they are added.
+func WithSessionSetting(name, value string) Option {
+	return func(m *MigrationService) {
+		m.sessionSettings = append(m.sessionSettings, sessionSetting{name: name, value: value})
+	}
+}
+
+// WithMaxOpenConns limits the open connections of a connection opened by NewMigrationServiceFromDSN, default is 1
+func WithMaxOpenConns(n int) Option {
+	return func(m *MigrationService) {
+		m.pool.maxOpenConns = n
+	}
+}
+
+// WithMaxIdleConns limits the idle connections of a connection opened by NewMigrationServiceFromDSN, default is 1
+func WithMaxIdleConns(n int) Option {
+	return func(m *MigrationService) {
+		m.pool.maxIdleConns = n
+	}
+}
+
+// WithConnMaxLifetime closes connections opened by NewMigrationServiceFromDSN after the duration, default is unlimited
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(m *MigrationService) {
+		m.pool.connMaxLifetime = d
+	}
+}
+
+// WithTLS configures TLS for a connection opened by NewMigrationServiceFromDSN without
+// hand-crafting driver specific DSN parameters, it is only supported for the postgres driver
+func WithTLS(config TLSConfig) Option {
+	return func(m *MigrationService) {
+		m.tls = &config
+	}
+}
+
+// WithTokenAuth authenticates connections opened by NewMigrationServiceFromDSN with access tokens instead of the
+// password of the DSN, e.g. Azure AD tokens of a managed identity. Tokens are refreshed before they expire.
+func WithTokenAuth(source TokenSource) Option {
+	return func(m *MigrationService) {
+		m.tokens = source
+	}
+}
+
+// WithHeartbeatInterval sets the interval of the "still running migration" logs while a single statement
+// executes, default is DefaultHeartbeatInterval. A non-positive interval disables the heartbeat.
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(m *MigrationService) {
+		m.heartbeatInterval = interval
+	}
+}
+
+// WithJobStaleTimeout sets the time after the last heartbeat of a running async migration until the next
+// RunAsyncJobs marks it failed and retries it, default is DefaultJobStaleTimeout
+func WithJobStaleTimeout(d time.Duration) Option {
+	return func(m *MigrationService) {
+		m.jobStale = d
+	}
+}
+
+// WithStopChannel stops the run cleanly once the channel is closed: the current migration is finished and
+// ExecuteMigration returns an InterruptedError with the remaining migrations. Cancel the context instead
+// to abort the current migration.
+func WithStopChannel(stop <-chan struct{}) Option {
+	return func(m *MigrationService) {
+		m.stop = stop
+	}
+}
+
+// WithMaxRunDuration limits the run time of ExecuteMigration: once the budget is used up, no further migration
+// is started and an InterruptedError with the remaining migrations is returned. A running migration is not aborted.
+func WithMaxRunDuration(d time.Duration) Option {
+	return func(m *MigrationService) {
+		m.maxRunDuration = d
+	}
+}
+
+// WithSlowMigrationThreshold logs a warning for every migration whose script takes longer than the threshold
+// and calls notify (if not nil) for it
+func WithSlowMigrationThreshold(threshold time.Duration, notify SlowMigrationFunc) Option {
+	return func(m *MigrationService) {
+		m.slowThreshold = threshold
+		m.slowNotify = notify
+	}
+}
+
+// WithThrottleProbe asks the probe before every backfill batch how long to wait,
+// e.g. NewReplicationLagProbe to pause backfills while replicas lag behind
+func WithThrottleProbe(probe ThrottleProbe) Option {
+	return func(m *MigrationService) {
+		m.throttleProbe = probe
+	}
+}
+
+// WithMaintenanceWindow restricts migrations marked as heavy to the window. Outside the window the run stops
+// before the first heavy migration and returns an InterruptedError with the deferred migrations.
+func WithMaintenanceWindow(window MaintenanceWindow) Option {
+	return func(m *MigrationService) {
+		m.window = window
+	}
+}
+
+// WithOnlineSchemaChange executes migrations marked with "-- migrago:online-ddl" with an external tool,
+// e.g. GhOst or PtOnlineSchemaChange. The migration is recorded in the changelog after the tool succeeded.
+func WithOnlineSchemaChange(tool OnlineSchemaChange) Option {
+	return func(m *MigrationService) {
+		m.onlineSchemaChange = &tool
+	}
+}
+
+// WithVitessOnlineDDL enables the Vitess/PlanetScale mode: migrations with foreign keys are rejected, DDL statements
+// are submitted as online DDL and the changelog row is recorded once all DDL workflows of a migration are complete
+func WithVitessOnlineDDL(config VitessOnlineDDL) Option {
+	return func(m *MigrationService) {
+		m.vitess = &config
+	}
+}
+
+// WithRequirements declares extensions, collations and settings which are checked before a run,
+// in addition to the requirements declared in the config files of the sources
+func WithRequirements(requirements Requirements) Option {
+	return func(m *MigrationService) {
+		m.requires = m.requires.merge(requirements)
+	}
+}
+
+// WithVersionPolicy sets what happens to migrations whose "-- migrago:requires" server version is not met
+func WithVersionPolicy(policy VersionPolicy) Option {
+	return func(m *MigrationService) {
+		m.versionPolicy = policy
+	}
+}
+
+// WithPrimaryResolver is used if the connection points at a read replica, the run continues on the resolved primary
+// instead of failing with ErrNotPrimary
+func WithPrimaryResolver(resolver PrimaryResolver) Option {
+	return func(m *MigrationService) {
+		m.primaryResolver = resolver
+	}
+}
+
+// WithIDPolicy rejects migrations whose IDs do not follow the naming convention, e.g. WithIDPolicy(IDPolicyTimestamp)
+func WithIDPolicy(policy IDPolicy) Option {
+	return func(m *MigrationService) {
+		m.idPolicy = &policy
+	}
+}
+
+// WithPhaseTimeouts sets separate timeouts for the preparation, every migration and every revert of a run
+func WithPhaseTimeouts(timeouts PhaseTimeouts) Option {
+	return func(m *MigrationService) {
+		m.phaseTimeouts = timeouts
+	}
+}
+
+// WithLogger sets the logger used to report the progress of a run
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *MigrationService) {
+		m.logger = logger
+	}
+}
+
+// WithRevertPolicy defines how migrations removed from the configuration are handled, default is RevertAuto
+func WithRevertPolicy(policy RevertPolicy) Option {
+	return func(m *MigrationService) {
+		m.revertPolicy = policy
+	}
+}
+
+// WithStrictMode fails the run with an UnknownMigrationsError if the changelog contains migrations
+// which are not in the configuration, instead of reverting them. Dev force is rejected in strict mode.
+func WithStrictMode() Option {
+	return func(m *MigrationService) {
+		m.strict = true
+	}
+}
+
+// WithConfirmFunc sets a hook which has to confirm reverts, drops and migrations flagged as dangerous before they are executed
+func WithConfirmFunc(fn ConfirmFunc) Option {
+	return func(m *MigrationService) {
+		m.confirmFunc = fn
+	}
+}
+
+// WithSkipIDs excludes pending migrations from the run without changing the configuration,
+// e.g. to hold back a known-bad migration. Already applied migrations are not affected.
+func WithSkipIDs(ids ...string) Option {
+	return func(m *MigrationService) {
+		m.skipIds = append(m.skipIds, ids...)
+	}
+}
+
+// WithAllowOutOfOrder executes pending migrations even if migrations sorting after them are already applied
+func WithAllowOutOfOrder() Option {
+	return func(m *MigrationService) {
+		m.allowOutOfOrder = true
+	}
+}
+
+// WithLoadConcurrency limits the number of migrations which are loaded and checksummed at the same time, default is GOMAXPROCS
+func WithLoadConcurrency(n int) Option {
+	return func(m *MigrationService) {
+		m.concurrency = n
+	}
+}
+
+// WithStreamingThreshold streams scripts of the service larger than threshold bytes statement by statement
+// instead of reading them into memory, default is DefaultStreamingThreshold
+func WithStreamingThreshold(threshold int64) Option {
+	return func(m *MigrationService) {
+		m.streamThreshold = threshold
+	}
+}
+
+// WithStatementInterceptors adds interceptors which see every statement of the migration scripts before and after its
+// execution, the first interceptor is the outermost one
+func WithStatementInterceptors(interceptors ...StatementInterceptor) Option {
+	return func(m *MigrationService) {
+		m.interceptors = append(m.interceptors, interceptors...)
+	}
+}
+
+// WithDialect adapts the changelog tables and the statements of the service to another database system than Postgres
+func WithDialect(dialect Dialect) Option {
+	return func(m *MigrationService) {
+		m.dialect = dialect
+	}
+}
+
+// WithTransactionRetry configures the retries of migration transactions which fail with retryable errors,
+// e.g. serialization failures of CockroachDB. Only dialects implementing RetryDialect retry.
+func WithTransactionRetry(retry TransactionRetry) Option {
+	return func(m *MigrationService) {
+		m.txRetry = retry
+	}
+}
+
+// WithStatementProgress records the statements executed by migrations without transaction, so a failed migration is
+// resumed after its last successful statement instead of being executed from the start
+func WithStatementProgress() Option {
+	return func(m *MigrationService) {
+		m.statementProgress = true
+	}
+}
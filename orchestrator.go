@@ -0,0 +1,207 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Target is a database migrated by an Orchestrator
+type Target struct {
+	Name    string
+	Service MigrationService
+	// After are the names of the targets which have to be migrated successfully before this target
+	After []string
+}
+
+// TargetResult is the outcome of a target of an orchestrated run
+type TargetResult struct {
+	Name string
+	// Err is the error of the run of the target
+	Err error
+	// Skipped is set if a target it depends on failed, the target was not migrated
+	Skipped  bool
+	Duration time.Duration
+}
+
+// OrchestrationError is returned if a target failed or was skipped, it contains the results of all targets
+type OrchestrationError struct {
+	RunId   string
+	Results []TargetResult
+}
+
+func (e *OrchestrationError) Error() string {
+	var details []string
+	for _, result := range e.Results {
+		switch {
+		case result.Skipped:
+			details = append(details, result.Name+": skipped")
+		case result.Err != nil:
+			details = append(details, fmt.Sprintf("%s: %v", result.Name, result.Err))
+		}
+	}
+	return fmt.Sprintf("run %s failed: %s", e.RunId, strings.Join(details, "; "))
+}
+
+// Orchestrator migrates several databases, e.g. an OLTP database and a reporting warehouse, in one run.
+// All targets record the same run ID in their changelog_run table.
+type Orchestrator struct {
+	targets []Target
+}
+
+// NewOrchestrator orders the targets by their dependencies, targets without dependencies between them keep their order
+func NewOrchestrator(targets ...Target) (Orchestrator, error) {
+	names := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if names[target.Name] {
+			return Orchestrator{}, fmt.Errorf("duplicate target %s", target.Name)
+		}
+		names[target.Name] = true
+	}
+	for _, target := range targets {
+		for _, after := range target.After {
+			if !names[after] {
+				return Orchestrator{}, fmt.Errorf("target %s depends on unknown target %s", target.Name, after)
+			}
+		}
+	}
+
+	ordered := make([]Target, 0, len(targets))
+	done := make(map[string]bool, len(targets))
+	for len(ordered) < len(targets) {
+		index := slices.IndexFunc(targets, func(target Target) bool {
+			return !done[target.Name] && !slices.ContainsFunc(target.After, func(after string) bool { return !done[after] })
+		})
+		if index < 0 {
+			return Orchestrator{}, fmt.Errorf("cyclic dependencies between targets")
+		}
+		done[targets[index].Name] = true
+		ordered = append(ordered, targets[index])
+	}
+	return Orchestrator{targets: ordered}, nil
+}
+
+// Run executes the migrations of all targets in dependency order under a shared run ID. A failed target does not
+// stop independent targets, but the targets depending on it are skipped. The results are returned in execution order,
+// an *OrchestrationError if any target failed.
+func (o Orchestrator) Run(ctx context.Context) (string, []TargetResult, error) {
+	runId, err := newRunId()
+	if err != nil {
+		return "", nil, err
+	}
+	return o.run(ctx, runId)
+}
+
+// RunTwoPhase prepares all targets before any of them is migrated, so a target which would fail does not leave the
+// others migrated. The prepare phase runs the preflight checks, verifies the checksums of the applied migrations and
+// executes the pending migrations in a transaction which is rolled back. If a target fails to prepare, no target is
+// migrated and the other targets are reported as skipped. Otherwise the targets are migrated like by Run.
+//
+// The rolled back execution takes the locks of the pending migrations and stops at the first migration which can
+// not run in a transaction, e.g. one without transaction, an online schema change or a backfill.
+func (o Orchestrator) RunTwoPhase(ctx context.Context) (string, []TargetResult, error) {
+	runId, err := newRunId()
+	if err != nil {
+		return "", nil, err
+	}
+	results := make([]TargetResult, 0, len(o.targets))
+	prepared := true
+	for _, target := range o.targets {
+		result := TargetResult{Name: target.Name}
+		start := time.Now()
+		if err := target.Service.prepareTarget(ctx); err != nil {
+			result.Err = fmt.Errorf("prepare failed: %w", err)
+			prepared = false
+		}
+		result.Duration = time.Since(start)
+		target.Service.log().Info("target prepared", "run", runId, "target", target.Name, "error", result.Err)
+		results = append(results, result)
+	}
+	if !prepared {
+		for i := range results {
+			results[i].Skipped = results[i].Err == nil
+		}
+		return runId, results, &OrchestrationError{RunId: runId, Results: results}
+	}
+	return o.run(ctx, runId)
+}
+
+// run migrates the targets under the given run ID
+func (o Orchestrator) run(ctx context.Context, runId string) (string, []TargetResult, error) {
+	failed := make(map[string]bool)
+	results := make([]TargetResult, 0, len(o.targets))
+	for _, target := range o.targets {
+		result := TargetResult{Name: target.Name}
+		if slices.ContainsFunc(target.After, func(after string) bool { return failed[after] }) {
+			result.Skipped = true
+		} else {
+			service := target.Service
+			service.runId = runId
+			start := time.Now()
+			result.Err = service.ExecuteMigration(ctx)
+			result.Duration = time.Since(start)
+		}
+		if result.Skipped || result.Err != nil {
+			failed[target.Name] = true
+		}
+		target.Service.log().Info("target finished", "run", runId, "target", target.Name, "skipped", result.Skipped, "error", result.Err)
+		results = append(results, result)
+	}
+	if len(failed) > 0 {
+		return runId, results, &OrchestrationError{RunId: runId, Results: results}
+	}
+	return runId, results, nil
+}
+
+// prepareTarget checks that a run would succeed without changing the database: the preflight checks and the checksums
+// of the applied migrations, then the pending migrations are executed in a transaction which is rolled back
+func (m MigrationService) prepareTarget(ctx context.Context) error {
+	if _, err := m.Preflight(ctx); err != nil {
+		return err
+	}
+	if _, err := m.PreviewReverts(ctx); err != nil {
+		return err
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	_, migrations, err := m.getMigrations()
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, pending := range status.Pending {
+		migration := migrations[pending.Id]
+		if migration.Metadata.Async {
+			// Async migrations are executed as jobs after the run, no pending migration depends on them
+			continue
+		}
+		if migration, err = migration.loadScripts(); err != nil {
+			return err
+		}
+		if migration.Metadata.NoTransaction || migration.Metadata.OnlineDDL || migration.Metadata.Backfill != nil || m.vitess != nil || m.nonTransactional(migration) {
+			// The following migrations may depend on this one, so they can not be checked either
+			m.log().Warn("prepare stopped before migration which can not run in a transaction", "id", migration.Id)
+			return nil
+		}
+		err := readMigrationScript(migration, m.splitScript, func(statement string) error {
+			_, err := m.execStatement(ctx, tx, Statement{MigrationId: migration.Id, SQL: statement})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to execute migration %s: %w", migration.Id, err)
+		}
+		if err := checkAssertions(ctx, tx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
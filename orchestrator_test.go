@@ -0,0 +1,28 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewOrchestrator(t *testing.T) {
+	o, err := NewOrchestrator(
+		Target{Name: "warehouse", After: []string{"oltp"}},
+		Target{Name: "search"},
+		Target{Name: "oltp"},
+	)
+	assert.NoError(t, err)
+	var names []string
+	for _, target := range o.targets {
+		names = append(names, target.Name)
+	}
+	assert.Equal(t, []string{"search", "oltp", "warehouse"}, names)
+
+	_, err = NewOrchestrator(Target{Name: "a", After: []string{"b"}}, Target{Name: "b", After: []string{"a"}})
+	assert.ErrorContains(t, err, "cyclic dependencies")
+	_, err = NewOrchestrator(Target{Name: "a", After: []string{"c"}})
+	assert.ErrorContains(t, err, "unknown target c")
+	_, err = NewOrchestrator(Target{Name: "a"}, Target{Name: "a"})
+	assert.ErrorContains(t, err, "duplicate target a")
+}
@@ -0,0 +1,64 @@
+package migrago
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PhaseTimeouts limits the phases of a run, a zero timeout is unlimited. Each phase runs with a child context of the
+// context of the run, so e.g. a stuck revert fails with a PhaseTimeoutError instead of silently consuming the whole
+// deployment timeout.
+type PhaseTimeouts struct {
+	// Prepare covers the primary and preflight checks, the changelog tables and reading the applied migrations
+	Prepare time.Duration
+	// Migration limits the execution of every single migration, async jobs are not limited
+	Migration time.Duration
+	// Revert limits every single revert
+	Revert time.Duration
+}
+
+// errPhaseDeadline is the cause of a context which was cancelled by the timeout of its phase
+var errPhaseDeadline = errors.New("phase deadline exceeded")
+
+// phase is a part of a run with its own deadline
+type phase struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	name    string
+	id      string
+	timeout time.Duration
+}
+
+// startPhase derives the context of a phase, cancel has to be called once the phase is finished
+func startPhase(parent context.Context, name, id string, timeout time.Duration) phase {
+	p := phase{name: name, id: id, timeout: timeout}
+	if timeout > 0 {
+		p.ctx, p.cancel = context.WithTimeoutCause(parent, timeout, errPhaseDeadline)
+	} else {
+		p.ctx, p.cancel = context.WithCancel(parent)
+	}
+	return p
+}
+
+// err wraps an error of the phase in a PhaseTimeoutError if the phase ran out of time
+func (p phase) err(err error) error {
+	if err != nil && errors.Is(context.Cause(p.ctx), errPhaseDeadline) {
+		return &PhaseTimeoutError{Phase: p.name, Id: p.id, Timeout: p.timeout, Err: err}
+	}
+	return err
+}
+
+// applyInPhase executes a migration within the migration timeout
+func (m MigrationService) applyInPhase(ctx context.Context, migration Migration) error {
+	p := startPhase(ctx, "migration", migration.Id, m.phaseTimeouts.Migration)
+	defer p.cancel()
+	return p.err(m.executeSingleMigration(p.ctx, migration))
+}
+
+// revertInPhase reverts a migration within the revert timeout
+func (m MigrationService) revertInPhase(ctx context.Context, migration Migration) error {
+	p := startPhase(ctx, "revert", migration.Id, m.phaseTimeouts.Revert)
+	defer p.cancel()
+	return p.err(m.revertSingleMigration(p.ctx, migration))
+}
@@ -0,0 +1,34 @@
+package migrago
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_phaseErr(t *testing.T) {
+	p := startPhase(context.Background(), "revert", "0002_users", time.Millisecond)
+	<-p.ctx.Done()
+	p.cancel()
+	err := p.err(context.DeadlineExceeded)
+	var timeoutErr *PhaseTimeoutError
+	assert.ErrorAs(t, err, &timeoutErr)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.EqualError(t, err, "revert phase of 0002_users exceeded its timeout of 1ms: context deadline exceeded")
+
+	// the deadline of the run is not reported as timeout of the phase
+	parent, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	p = startPhase(parent, "prepare", "", time.Hour)
+	<-p.ctx.Done()
+	p.cancel()
+	assert.False(t, errors.As(p.err(context.DeadlineExceeded), &timeoutErr))
+
+	p = startPhase(context.Background(), "migration", "0001_init", 0)
+	defer p.cancel()
+	_, ok := p.ctx.Deadline()
+	assert.False(t, ok)
+}
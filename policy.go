@@ -0,0 +1,27 @@
+package migrago
+
+// RevertPolicy defines how applied migrations are handled which were removed from the configuration
+type RevertPolicy int
+
+const (
+	// RevertAuto reverts removed migrations automatically (default)
+	RevertAuto RevertPolicy = iota
+	// RevertNever fails the run if a removed migration is found
+	RevertNever
+	// RevertManual reports removed migrations and keeps them applied, so an operator can revert them manually
+	RevertManual
+)
+
+// String returns the name of the policy
+func (p RevertPolicy) String() string {
+	switch p {
+	case RevertAuto:
+		return "auto"
+	case RevertNever:
+		return "never"
+	case RevertManual:
+		return "manual"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,185 @@
+package migrago
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PostgresDialect is the default dialect. CockroachDB and Redshift speak the Postgres protocol, but use
+// CockroachDialect and RedshiftDialect instead
+type PostgresDialect struct{}
+
+func (PostgresDialect) Name() string {
+	return "postgres"
+}
+
+func (PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (PostgresDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText:
+		return "TEXT"
+	case ColumnInteger:
+		return "BIGINT"
+	case ColumnTimestamp:
+		return "TIMESTAMPTZ"
+	case ColumnCreatedAt:
+		return "TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	case ColumnFlag:
+		return "BOOLEAN NOT NULL DEFAULT FALSE"
+	case ColumnSequence:
+		return "BIGSERIAL NOT NULL"
+	case ColumnLSN:
+		return "PG_LSN"
+	default:
+		return "VARCHAR(255)"
+	}
+}
+
+// ChangelogDDL creates the tables of the first release and upgrades them column by column,
+// so changelogs created by every earlier version end up with the same schema
+func (PostgresDialect) ChangelogDDL() []string {
+	statements := []string{`CREATE TABLE IF NOT EXISTS changelog (
+		id VARCHAR(255) PRIMARY KEY,
+		checksum VARCHAR(255) NOT NULL,
+		installedAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		revertscript TEXT
+	)`}
+	statements = append(statements, postgresChangelogUpgrades...)
+	statements = append(statements, `CREATE TABLE IF NOT EXISTS changelog_run (
+		id VARCHAR(255) PRIMARY KEY,
+		sourceRevision VARCHAR(255),
+		startedAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		finishedAt TIMESTAMPTZ,
+		error TEXT
+	)`)
+	statements = append(statements, postgresRunUpgrades...)
+	return append(statements, `CREATE TABLE IF NOT EXISTS changelog_archive (
+		id VARCHAR(255) PRIMARY KEY,
+		checksum VARCHAR(255) NOT NULL,
+		installedAt TIMESTAMPTZ NOT NULL,
+		revertscript TEXT,
+		irreversible BOOLEAN NOT NULL DEFAULT FALSE,
+		sequence BIGINT NOT NULL,
+		archivedAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`, `CREATE TABLE IF NOT EXISTS changelog_backfill (
+		id VARCHAR(255) PRIMARY KEY,
+		checksum VARCHAR(255) NOT NULL,
+		lastKey TEXT,
+		batches BIGINT NOT NULL,
+		rowsAffected BIGINT NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`, `CREATE TABLE IF NOT EXISTS changelog_job (
+		id VARCHAR(255) PRIMARY KEY,
+		scheduledAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		startedAt TIMESTAMPTZ,
+		finishedAt TIMESTAMPTZ,
+		error TEXT,
+		heartbeatAt TIMESTAMPTZ
+	)`, postgresAddColumn("changelog_job", "heartbeatAt", "TIMESTAMPTZ"), `CREATE TABLE IF NOT EXISTS changelog_progress (
+		id VARCHAR(255) PRIMARY KEY,
+		checksum VARCHAR(255) NOT NULL,
+		statements BIGINT NOT NULL,
+		updatedAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`, `CREATE TABLE IF NOT EXISTS changelog_tag (
+		name VARCHAR(255) PRIMARY KEY,
+		sequence BIGINT NOT NULL,
+		createdAt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+}
+
+func (PostgresDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT to_regclass('%s') IS NOT NULL`, table)
+}
+
+func (PostgresDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = '%s'`, table)
+}
+
+func (d PostgresDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO NOTHING`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)), columns[0])
+}
+
+func (PostgresDialect) SessionSettingStatement() string {
+	return `SELECT set_config($1, $2, true)`
+}
+
+// LockStatement is empty, a concurrent insert of the same ID into the changelog blocks until the first run commits
+func (PostgresDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is false, statements which can not run in a transaction are marked with "-- migrago:no-transaction"
+func (PostgresDialect) NonTransactional(string) bool {
+	return false
+}
+
+func (PostgresDialect) VersionQuery() string {
+	return `SELECT current_setting('server_version')`
+}
+
+// placeholders returns the comma separated bind parameters 1 to n of the dialect
+func placeholders(d Dialect, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = d.Placeholder(i + 1)
+	}
+	return strings.Join(params, ", ")
+}
+
+// postgresChangelogUpgrades adds the columns of newer versions to existing changelog tables. Every step checks the
+// catalog first, so a current changelog is neither locked nor scanned by the ALTER TABLE and UPDATE statements.
+var postgresChangelogUpgrades = []string{
+	postgresAddColumn("changelog", "irreversible", "BOOLEAN NOT NULL DEFAULT FALSE"),
+	// The sequence orders the changelog independent of the timestamp resolution and clock skew,
+	// existing entries are numbered in the order they were installed
+	`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'changelog'
+			AND column_name = 'sequence' AND is_nullable = 'NO') THEN
+			ALTER TABLE changelog ADD COLUMN IF NOT EXISTS sequence BIGINT;
+			CREATE SEQUENCE IF NOT EXISTS changelog_sequence_seq OWNED BY changelog.sequence;
+			UPDATE changelog c SET sequence = o.sequence FROM (
+				SELECT id, row_number() OVER (ORDER BY installedAt, id) + (SELECT COALESCE(MAX(sequence), 0) FROM changelog) AS sequence
+				FROM changelog WHERE sequence IS NULL
+			) o WHERE c.id = o.id;
+			PERFORM setval('changelog_sequence_seq', GREATEST((SELECT COALESCE(MAX(sequence), 0) FROM changelog), (SELECT last_value FROM changelog_sequence_seq)));
+			ALTER TABLE changelog ALTER COLUMN sequence SET DEFAULT nextval('changelog_sequence_seq');
+			ALTER TABLE changelog ALTER COLUMN sequence SET NOT NULL;
+		END IF;
+	END $$`,
+	// Timestamps are stored with time zone, existing values are interpreted in the session time zone they were written in
+	`DO $$ BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'changelog'
+			AND column_name = 'installedat' AND data_type = 'timestamp without time zone') THEN
+			ALTER TABLE changelog ALTER COLUMN installedAt TYPE TIMESTAMPTZ;
+		END IF;
+	END $$`,
+	postgresAddColumn("changelog", "lsnBefore", "PG_LSN"),
+	postgresAddColumn("changelog", "lsnAfter", "PG_LSN"),
+	postgresAddColumn("changelog", "durationMs", "BIGINT"),
+	postgresAddColumn("changelog", "description", "TEXT"),
+}
+
+// postgresAddColumn adds a column to an existing table if it is missing, unlike ADD COLUMN IF NOT EXISTS it does not
+// lock the table if the column exists
+func postgresAddColumn(table, column, definition string) string {
+	return fmt.Sprintf(`DO $$ BEGIN
+		IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = '%s'
+			AND column_name = '%s') THEN
+			ALTER TABLE %s ADD COLUMN %s %s;
+		END IF;
+	END $$`, table, strings.ToLower(column), table, column, definition)
+}
+
+// postgresRunUpgrades adds the columns of newer versions to existing run audit tables, every statement is idempotent
+var postgresRunUpgrades = []string{
+	`DO $$ BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'changelog_run'
+			AND column_name = 'startedat' AND data_type = 'timestamp without time zone') THEN
+			ALTER TABLE changelog_run ALTER COLUMN startedAt TYPE TIMESTAMPTZ, ALTER COLUMN finishedAt TYPE TIMESTAMPTZ;
+		END IF;
+	END $$`,
+}
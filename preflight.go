@@ -0,0 +1,332 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Requirements are prerequisites of the migrations which are checked before a run, so a missing extension
+// is reported upfront instead of failing in the middle of the run
+type Requirements struct {
+	// Extensions have to be installed or creatable by the current user
+	Extensions []string `json:"extensions"`
+	// Collations have to exist
+	Collations []string `json:"collations"`
+	// Settings are server settings which need the given value, e.g. {"wal_level": "logical"}
+	Settings map[string]string `json:"settings"`
+	// Schemas are the schemas the migrations change, the migration role needs USAGE and CREATE on them
+	// and has to own their objects to alter or drop them. The current schema is checked by default.
+	Schemas []string `json:"schemas"`
+}
+
+// RequirementsSource is implemented by sources which declare requirements, e.g. the FileSource with a config file
+// of the form {"requires": {"extensions": ["postgis"]}, "migrations": ["..."]}
+type RequirementsSource interface {
+	Requirements() (Requirements, error)
+}
+
+// merge adds the requirements of another source
+func (r Requirements) merge(other Requirements) Requirements {
+	for _, extension := range other.Extensions {
+		if !slices.Contains(r.Extensions, extension) {
+			r.Extensions = append(r.Extensions, extension)
+		}
+	}
+	for _, collation := range other.Collations {
+		if !slices.Contains(r.Collations, collation) {
+			r.Collations = append(r.Collations, collation)
+		}
+	}
+	for _, schema := range other.Schemas {
+		if !slices.Contains(r.Schemas, schema) {
+			r.Schemas = append(r.Schemas, schema)
+		}
+	}
+	for name, value := range other.Settings {
+		if r.Settings == nil {
+			r.Settings = map[string]string{}
+		}
+		r.Settings[name] = value
+	}
+	return r
+}
+
+// requirements collects the requirements of the service and all sources
+func (m MigrationService) requirements() (Requirements, error) {
+	requirements := m.requires
+	for _, s := range m.sources {
+		rs, ok := s.source.(RequirementsSource)
+		if !ok {
+			continue
+		}
+		r, err := rs.Requirements()
+		if err != nil {
+			return Requirements{}, err
+		}
+		requirements = requirements.merge(r)
+	}
+	return requirements, nil
+}
+
+// checkRequirements checks all requirements and returns a PreflightError with every unmet one
+func (m MigrationService) checkRequirements(ctx context.Context) error {
+	problems, err := m.requirementProblems(ctx)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &PreflightError{Problems: problems}
+	}
+	return nil
+}
+
+// requirementProblems lists the unmet requirements and missing privileges
+func (m MigrationService) requirementProblems(ctx context.Context) ([]string, error) {
+	requirements, err := m.requirements()
+	if err != nil {
+		return nil, err
+	}
+	if !m.postgres() {
+		// Extensions, collations, settings and the privileges are checked in the Postgres catalogs
+		if len(requirements.Extensions) > 0 || len(requirements.Collations) > 0 || len(requirements.Settings) > 0 || len(requirements.Schemas) > 0 {
+			return []string{fmt.Sprintf("requirements are not supported for dialect %s", m.dialectName())}, nil
+		}
+		return nil, nil
+	}
+	var problems []string
+	for _, extension := range requirements.Extensions {
+		var installed, creatable bool
+		err := m.conn.QueryRowContext(ctx, `SELECT e.installed_version IS NOT NULL,
+			(SELECT rolsuper FROM pg_roles WHERE rolname = current_user)
+			OR (has_database_privilege(current_database(), 'CREATE')
+				AND EXISTS (SELECT 1 FROM pg_available_extension_versions v WHERE v.name = e.name AND v.trusted))
+			FROM pg_available_extensions e WHERE e.name = $1`, extension).Scan(&installed, &creatable)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			problems = append(problems, fmt.Sprintf("extension %s is not available on the server", extension))
+		case err != nil:
+			return nil, fmt.Errorf("failed to check extension %s: %w", extension, err)
+		case !installed && !creatable:
+			problems = append(problems, fmt.Sprintf("extension %s is not installed and the current user can not create it", extension))
+		}
+	}
+	for _, collation := range requirements.Collations {
+		var exists bool
+		if err := m.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_collation WHERE collname = $1)`, collation).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check collation %s: %w", collation, err)
+		}
+		if !exists {
+			problems = append(problems, fmt.Sprintf("collation %s does not exist", collation))
+		}
+	}
+	names := make([]string, 0, len(requirements.Settings))
+	for name := range requirements.Settings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		var value sql.NullString
+		if err := m.conn.QueryRowContext(ctx, `SELECT current_setting($1, true)`, name).Scan(&value); err != nil {
+			return nil, fmt.Errorf("failed to check setting %s: %w", name, err)
+		}
+		switch {
+		case !value.Valid:
+			problems = append(problems, fmt.Sprintf("setting %s does not exist", name))
+		case value.String != requirements.Settings[name]:
+			problems = append(problems, fmt.Sprintf("setting %s is %q, required is %q", name, value.String, requirements.Settings[name]))
+		}
+	}
+	privilegeProblems, err := m.checkPrivileges(ctx, requirements.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, privilegeProblems...), nil
+}
+
+// maxReportedObjects limits the objects listed per schema whose ownership is missing
+const maxReportedObjects = 10
+
+// checkPrivileges checks that the migration role can change the schemas and write the changelog,
+// the ownership of existing objects is only checked for explicitly declared schemas
+func (m MigrationService) checkPrivileges(ctx context.Context, schemas []string) ([]string, error) {
+	var problems []string
+	checkOwnership := len(schemas) > 0
+	if !checkOwnership {
+		var current sql.NullString
+		if err := m.conn.QueryRowContext(ctx, `SELECT current_schema()`).Scan(&current); err != nil {
+			return nil, fmt.Errorf("failed to query current schema: %w", err)
+		}
+		if !current.Valid {
+			return []string{"no current schema, the search_path is empty or its schemas do not exist"}, nil
+		}
+		schemas = []string{current.String}
+	}
+	for _, schema := range schemas {
+		var exists, usage, create bool
+		err := m.conn.QueryRowContext(ctx, `SELECT n.oid IS NOT NULL,
+			COALESCE(has_schema_privilege(n.oid, 'USAGE'), false), COALESCE(has_schema_privilege(n.oid, 'CREATE'), false)
+			FROM (SELECT 1) dummy LEFT JOIN pg_namespace n ON n.nspname = $1`, schema).Scan(&exists, &usage, &create)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check privileges on schema %s: %w", schema, err)
+		}
+		if !exists {
+			problems = append(problems, fmt.Sprintf("schema %s does not exist", schema))
+			continue
+		}
+		if !usage {
+			problems = append(problems, fmt.Sprintf("missing USAGE privilege on schema %s", schema))
+		}
+		if !create {
+			problems = append(problems, fmt.Sprintf("missing CREATE privilege on schema %s", schema))
+		}
+		if checkOwnership {
+			notOwned, err := m.notOwnedObjects(ctx, schema)
+			if err != nil {
+				return nil, err
+			}
+			problems = append(problems, notOwned...)
+		}
+	}
+
+	var changelogPrivileges sql.NullBool
+	err := m.conn.QueryRowContext(ctx, `SELECT has_table_privilege(to_regclass('changelog'), 'SELECT, INSERT, UPDATE, DELETE')`).Scan(&changelogPrivileges)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check privileges on changelog: %w", err)
+	}
+	// A missing changelog is created by the run, which needs the CREATE privilege checked above
+	if changelogPrivileges.Valid && !changelogPrivileges.Bool {
+		problems = append(problems, "missing SELECT, INSERT, UPDATE or DELETE privilege on table changelog")
+	}
+	return problems, nil
+}
+
+// notOwnedObjects lists the objects of a schema the migration role can not alter or drop, because it does not own them
+func (m MigrationService) notOwnedObjects(ctx context.Context, schema string) ([]string, error) {
+	rows, err := m.conn.QueryContext(ctx, `SELECT c.relname, pg_get_userbyid(c.relowner) FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f') AND NOT pg_has_role(c.relowner, 'USAGE')
+		ORDER BY c.relname`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ownership in schema %s: %w", schema, err)
+	}
+	defer rows.Close()
+	var problems []string
+	count := 0
+	for rows.Next() {
+		var name, owner string
+		if err := rows.Scan(&name, &owner); err != nil {
+			return nil, err
+		}
+		count++
+		if count <= maxReportedObjects {
+			problems = append(problems, fmt.Sprintf("can not ALTER or DROP %s.%s, it is owned by %s", schema, name, owner))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if count > maxReportedObjects {
+		problems = append(problems, fmt.Sprintf("%d more objects in schema %s are owned by other roles", count-maxReportedObjects, schema))
+	}
+	return problems, nil
+}
+
+// PreflightReport describes the database a MigrationService connects to and whether it is ready for a run
+type PreflightReport struct {
+	// ServerType is the detected database system, e.g. postgres or cockroachdb
+	ServerType string
+	// Version is the full version string of the server
+	Version string
+	// VersionNum is the version in the format of server_version_num, e.g. 160002
+	VersionNum int
+	// ChangelogExists is false before the first run, the changelog is created by the run
+	ChangelogExists bool
+	// ChangelogUpgradable is set if the migration role can add the columns of newer versions to the changelog
+	ChangelogUpgradable bool
+	// Problems are the unmet requirements and missing privileges
+	Problems []string
+}
+
+// Preflight checks the connection, the server and the changelog without changing anything, e.g. in a readiness probe.
+// It returns a PreflightError with the report if a run would fail because of unmet requirements.
+func (m MigrationService) Preflight(ctx context.Context) (PreflightReport, error) {
+	var report PreflightReport
+	if err := m.conn.PingContext(ctx); err != nil {
+		return report, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	inspect := m.preflightPostgres
+	if !m.postgres() {
+		inspect = m.preflightDialect
+	}
+	if err := inspect(ctx, &report); err != nil {
+		return report, err
+	}
+
+	problems, err := m.requirementProblems(ctx)
+	if err != nil {
+		return report, err
+	}
+	report.Problems = append(report.Problems, problems...)
+	if len(report.Problems) > 0 {
+		return report, &PreflightError{Problems: report.Problems}
+	}
+	return report, nil
+}
+
+// preflightPostgres detects the Postgres compatible system and checks that the migration role owns the changelog
+func (m MigrationService) preflightPostgres(ctx context.Context, report *PreflightReport) error {
+	if err := m.conn.QueryRowContext(ctx, `SELECT version(), current_setting('server_version_num')::int`).Scan(&report.Version, &report.VersionNum); err != nil {
+		return fmt.Errorf("failed to query server version: %w", err)
+	}
+	report.ServerType = serverType(report.Version)
+
+	var owner sql.NullString
+	var owned sql.NullBool
+	err := m.conn.QueryRowContext(ctx, `SELECT pg_get_userbyid(relowner), pg_has_role(relowner, 'USAGE') FROM pg_class WHERE oid = to_regclass('changelog')`).
+		Scan(&owner, &owned)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to query changelog: %w", err)
+	}
+	report.ChangelogExists = owner.Valid
+	report.ChangelogUpgradable = !owner.Valid || owned.Bool
+	if !report.ChangelogUpgradable {
+		report.Problems = append(report.Problems, fmt.Sprintf("changelog can not be upgraded, it is owned by %s", owner.String))
+	}
+	return nil
+}
+
+// preflightDialect reports the version of other databases than Postgres and whether the changelog exists,
+// the changelog is not upgraded column by column there
+func (m MigrationService) preflightDialect(ctx context.Context, report *PreflightReport) error {
+	if err := m.conn.QueryRowContext(ctx, m.sqlDialect().VersionQuery()).Scan(&report.Version); err != nil {
+		return fmt.Errorf("failed to query server version: %w", err)
+	}
+	versionNum, err := m.serverVersion(ctx)
+	if err != nil {
+		return err
+	}
+	report.ServerType, report.VersionNum = m.dialectName(), versionNum
+	if err := m.conn.QueryRowContext(ctx, m.sqlDialect().TableExistsQuery("changelog")).Scan(&report.ChangelogExists); err != nil {
+		return fmt.Errorf("failed to query changelog: %w", err)
+	}
+	report.ChangelogUpgradable = true
+	return nil
+}
+
+// serverType detects Postgres compatible systems from their version string
+func serverType(version string) string {
+	switch {
+	case strings.Contains(version, "CockroachDB"):
+		return "cockroachdb"
+	case strings.Contains(version, "Redshift"):
+		return "redshift"
+	case strings.Contains(version, "YugabyteDB") || strings.Contains(version, "-YB-"):
+		return "yugabytedb"
+	default:
+		return "postgres"
+	}
+}
@@ -0,0 +1,44 @@
+package migrago
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileSourceRequirements(t *testing.T) {
+	fs := fstest.MapFS{
+		"config.json": {Data: []byte(`{"requires": {"extensions": ["postgis"], "settings": {"wal_level": "logical"}}, "migrations": ["Test"]}`)},
+	}
+	source := NewFileSource("config.json", "scripts", fs)
+	ids, err := source.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Test"}, ids)
+
+	requirements, err := source.Requirements()
+	assert.NoError(t, err)
+	assert.Equal(t, Requirements{Extensions: []string{"postgis"}, Settings: map[string]string{"wal_level": "logical"}}, requirements)
+
+	// The list form has no requirements
+	source = NewFileSource("config.json", "scripts", fstest.MapFS{"config.json": {Data: []byte(`["Test"]`)}})
+	requirements, err = source.Requirements()
+	assert.NoError(t, err)
+	assert.Equal(t, Requirements{}, requirements)
+}
+
+func Test_RequirementsMerge(t *testing.T) {
+	service := NewMigrationService("config.json", "scripts", fstest.MapFS{
+		"config.json": {Data: []byte(`{"requires": {"extensions": ["postgis", "uuid-ossp"]}, "migrations": []}`)},
+	}, nil, WithRequirements(Requirements{Extensions: []string{"uuid-ossp"}, Collations: []string{"de-x-icu"}}),
+		WithRequirements(Requirements{Schemas: []string{"app"}}))
+	requirements, err := service.requirements()
+	assert.NoError(t, err)
+	assert.Equal(t, Requirements{Extensions: []string{"uuid-ossp", "postgis"}, Collations: []string{"de-x-icu"}, Schemas: []string{"app"}}, requirements)
+}
+
+func Test_serverType(t *testing.T) {
+	assert.Equal(t, "postgres", serverType("PostgreSQL 16.2 on x86_64-pc-linux-gnu"))
+	assert.Equal(t, "cockroachdb", serverType("CockroachDB CCL v23.2.1 (x86_64-pc-linux-gnu)"))
+	assert.Equal(t, "yugabytedb", serverType("PostgreSQL 11.2-YB-2.20.1.0-b0 on x86_64-pc-linux-gnu"))
+}
@@ -0,0 +1,60 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrNotPrimary is returned if the connection points at a read replica or a read-only server
+var ErrNotPrimary = errors.New("database is not the primary")
+
+// PrimaryResolver returns a connection to the current primary, e.g. after a failover. The MigrationService
+// does not close the returned connection.
+type PrimaryResolver func(ctx context.Context) (*sql.DB, error)
+
+// checkPrimary reports why the connection can not execute migrations, it is empty for a writable primary
+func checkPrimary(ctx context.Context, conn *sql.DB) (string, error) {
+	var inRecovery bool
+	var readOnly string
+	err := conn.QueryRowContext(ctx, `SELECT pg_is_in_recovery(), current_setting('default_transaction_read_only')`).Scan(&inRecovery, &readOnly)
+	if err != nil {
+		return "", fmt.Errorf("failed to check for primary: %w", err)
+	}
+	switch {
+	case inRecovery:
+		return "the server is a read replica (in recovery)", nil
+	case readOnly == "on":
+		return "transactions are read-only (default_transaction_read_only)", nil
+	}
+	return "", nil
+}
+
+// ensurePrimary fails fast if the connection points at a replica, with a PrimaryResolver the service
+// switches to the resolved primary instead. Other databases than Postgres are not checked.
+func (m MigrationService) ensurePrimary(ctx context.Context) (MigrationService, error) {
+	if !m.postgres() {
+		return m, nil
+	}
+	reason, err := checkPrimary(ctx, m.conn)
+	if err != nil || reason == "" {
+		return m, err
+	}
+	if m.primaryResolver == nil {
+		return m, fmt.Errorf("%w: %s", ErrNotPrimary, reason)
+	}
+	m.log().Warn("connection does not point at the primary, resolving it", "reason", reason)
+	conn, err := m.primaryResolver(ctx)
+	if err != nil {
+		return m, fmt.Errorf("failed to resolve primary: %w", err)
+	}
+	if reason, err = checkPrimary(ctx, conn); err != nil {
+		return m, err
+	}
+	if reason != "" {
+		return m, fmt.Errorf("%w: resolved connection: %s", ErrNotPrimary, reason)
+	}
+	m.conn = conn
+	return m, nil
+}
@@ -0,0 +1,45 @@
+package migrago
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// parsePriority parses the argument of a "-- migrago:priority 1.5" directive
+func parsePriority(args string) (*float64, error) {
+	priority, err := strconv.ParseFloat(strings.TrimSpace(args), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid priority %q", args)
+	}
+	return &priority, nil
+}
+
+// sortByPriority orders the migrations of a source by their priority, which defaults to the 1-based position
+// in the configuration. Ties are broken by the position, so the order is deterministic.
+func sortByPriority(migrations []Migration) {
+	if !slices.ContainsFunc(migrations, func(migration Migration) bool { return migration.Metadata.Priority != nil }) {
+		return
+	}
+	type ranked struct {
+		priority  float64
+		position  int
+		migration Migration
+	}
+	ranking := make([]ranked, len(migrations))
+	for i, migration := range migrations {
+		priority := float64(i + 1)
+		if migration.Metadata.Priority != nil {
+			priority = *migration.Metadata.Priority
+		}
+		ranking[i] = ranked{priority: priority, position: i, migration: migration}
+	}
+	slices.SortFunc(ranking, func(a, b ranked) int {
+		return cmp.Or(cmp.Compare(a.priority, b.priority), cmp.Compare(a.position, b.position))
+	})
+	for i, r := range ranking {
+		migrations[i] = r.migration
+	}
+}
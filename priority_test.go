@@ -0,0 +1,35 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sortByPriority(t *testing.T) {
+	priority := func(p float64) Metadata { return Metadata{Priority: &p} }
+	migrations := []Migration{
+		{Id: "0001_init"},
+		{Id: "0002_users"},
+		{Id: "0003_teams"},
+		{Id: "0005_hotfix", Metadata: priority(1.5)},
+		{Id: "0004_hotfix", Metadata: priority(1.5)},
+		{Id: "0006_first", Metadata: priority(0)},
+	}
+	sortByPriority(migrations)
+	ids := make([]string, len(migrations))
+	for i, migration := range migrations {
+		ids[i] = migration.Id
+	}
+	// Ties are broken by the position in the configuration
+	assert.Equal(t, []string{"0006_first", "0001_init", "0005_hotfix", "0004_hotfix", "0002_users", "0003_teams"}, ids)
+}
+
+func Test_parsePriority(t *testing.T) {
+	migration := Migration{Id: "Test", Script: "-- migrago:priority 2.5\nSELECT 1"}
+	assert.NoError(t, migration.applyDirectives())
+	assert.Equal(t, 2.5, *migration.Metadata.Priority)
+
+	migration = Migration{Id: "Test", Script: "-- migrago:priority high\nSELECT 1"}
+	assert.ErrorContains(t, migration.applyDirectives(), `invalid priority "high"`)
+}
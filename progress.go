@@ -0,0 +1,78 @@
+package migrago
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// progressThreshold is the run time of a script after which its progress is logged at info level,
+// shorter scripts only log their progress at debug level
+const progressThreshold = 10 * time.Second
+
+// DefaultHeartbeatInterval is the interval of the "still running" logs during a single statement
+const DefaultHeartbeatInterval = time.Minute
+
+// scriptProgress reports the executed statements of a migration script
+type scriptProgress struct {
+	logger *slog.Logger
+	id     string
+	// total is the number of statements, 0 if unknown (streamed scripts)
+	total int
+	done  int
+	start time.Time
+	// heartbeatInterval is the interval of the heartbeat logs, heartbeats are disabled if it is not positive
+	heartbeatInterval time.Duration
+}
+
+// newScriptProgress starts the progress reporting of a script
+func newScriptProgress(logger *slog.Logger, id string, total int, heartbeatInterval time.Duration) *scriptProgress {
+	return &scriptProgress{logger: logger, id: id, total: total, start: time.Now(), heartbeatInterval: heartbeatInterval}
+}
+
+// heartbeat logs periodically that the next statement is still running until the returned function is called,
+// so slow statements can be distinguished from stuck ones
+func (p *scriptProgress) heartbeat(ctx context.Context) (stop func()) {
+	if p.heartbeatInterval <= 0 {
+		return func() {}
+	}
+	statementStart := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.logger.InfoContext(ctx, "still running migration", "id", p.id, "statement", p.done+1,
+					"elapsed", time.Since(p.start).Round(time.Second), "statementElapsed", time.Since(statementStart).Round(time.Second))
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// statementDone reports that the next statement of the script was executed
+func (p *scriptProgress) statementDone(ctx context.Context) {
+	p.done++
+	elapsed := time.Since(p.start)
+	level := slog.LevelDebug
+	if elapsed >= progressThreshold {
+		level = slog.LevelInfo
+	}
+	attrs := []any{"id", p.id, "statement", p.done}
+	if p.total > 0 {
+		attrs = append(attrs, "of", p.total)
+	}
+	attrs = append(attrs, "elapsed", elapsed.Round(time.Millisecond))
+	p.logger.Log(ctx, level, "migration statement executed", attrs...)
+}
@@ -0,0 +1,73 @@
+package migrago
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_scriptProgress(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	// Fast scripts only report at debug level
+	progress := newScriptProgress(logger, "Test", 2, 0)
+	progress.statementDone(context.Background())
+	assert.Empty(t, buf.String())
+
+	// Long running scripts report every statement
+	progress.start = time.Now().Add(-time.Minute)
+	progress.statementDone(context.Background())
+	assert.Contains(t, buf.String(), "id=Test statement=2 of=2 elapsed=1m0")
+
+	buf.Reset()
+	progress = newScriptProgress(logger, "Streamed", 0, 0)
+	progress.start = time.Now().Add(-time.Minute)
+	progress.statementDone(context.Background())
+	assert.Contains(t, buf.String(), "id=Streamed statement=1 elapsed=")
+}
+
+func Test_scriptProgressHeartbeat(t *testing.T) {
+	var buf syncBuffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	progress := newScriptProgress(logger, "Test", 1, 10*time.Millisecond)
+	stop := progress.heartbeat(context.Background())
+	time.Sleep(35 * time.Millisecond)
+	stop()
+	assert.Contains(t, buf.String(), `msg="still running migration" id=Test statement=1`)
+
+	// No heartbeats after the statement finished
+	logged := buf.String()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, logged, buf.String())
+
+	buf = syncBuffer{}
+	progress = newScriptProgress(logger, "Test", 1, 0)
+	stop = progress.heartbeat(context.Background())
+	stop()
+	assert.Empty(t, buf.String())
+}
+
+// syncBuffer is a bytes.Buffer which can be written by the heartbeat goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
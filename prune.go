@@ -0,0 +1,75 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Prune moves changelog entries installed more than olderThan ago to the changelog_archive table and returns
+// the number of archived entries. Archived migrations still count as applied, so they are never executed again,
+// but they are not checked for checksum changes and are never reverted.
+func (m MigrationService) Prune(ctx context.Context, olderThan time.Duration) (int, error) {
+	if olderThan <= 0 {
+		return 0, fmt.Errorf("prune age must be positive, got %s", olderThan)
+	}
+	if err := m.prepareDatabase(ctx); err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	query, args := m.rebind(`INSERT INTO changelog_archive (id, checksum, installedAt, revertscript, irreversible, sequence)
+		SELECT id, checksum, installedAt, revertscript, irreversible, sequence FROM changelog WHERE installedAt < $1`, cutoff)
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive changelog: %w", err)
+	}
+	query, args = m.rebind(`DELETE FROM changelog WHERE installedAt < $1`, cutoff)
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive changelog: %w", err)
+	}
+	archived, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive changelog: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to archive changelog: %w", err)
+	}
+	m.log().Info("changelog pruned", "archived", archived, "cutoff", cutoff.UTC())
+	return int(archived), nil
+}
+
+// getArchivedIds returns the IDs of the given migrations which are in the changelog archive
+func (m MigrationService) getArchivedIds(ctx context.Context, migrations []Migration) (map[string]bool, error) {
+	archived := make(map[string]bool)
+	if len(migrations) == 0 {
+		return archived, nil
+	}
+	placeholders := make([]string, len(migrations))
+	ids := make([]any, len(migrations))
+	for i, migration := range migrations {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		ids[i] = migration.Id
+	}
+	query, args := m.rebind(`SELECT id FROM changelog_archive WHERE id IN (`+strings.Join(placeholders, ", ")+`)`, ids...)
+	rows, err := m.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changelog_archive: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		archived[id] = true
+	}
+	return archived, rows.Err()
+}
@@ -0,0 +1,72 @@
+package migrago
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redshiftNonTransactionalPattern matches the statements Redshift refuses inside of a transaction block
+var redshiftNonTransactionalPattern = regexp.MustCompile(`(?i)^(VACUUM|(CREATE|DROP)\s+(DATABASE|EXTERNAL\s+(TABLE|SCHEMA))|ALTER\s+TABLE\s+\S+\s+(APPEND|ALTER\s+COLUMN\s+\S+\s+TYPE))\b`)
+
+// RedshiftDialect is the dialect of Amazon Redshift with the postgres driver. Redshift is based on an old Postgres
+// and supports neither ON CONFLICT, sequences nor unlimited TEXT columns, primary keys are not enforced.
+type RedshiftDialect struct {
+	PostgresDialect
+}
+
+func (RedshiftDialect) Name() string {
+	return "redshift"
+}
+
+func (d RedshiftDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText:
+		// TEXT is an alias of VARCHAR(256)
+		return "VARCHAR(65535)"
+	case ColumnCreatedAt:
+		return "TIMESTAMPTZ NOT NULL DEFAULT GETDATE()"
+	case ColumnSequence:
+		// Identity values are unique and increase with every single row insert, but may have gaps
+		return "BIGINT IDENTITY(1, 1)"
+	case ColumnLSN:
+		return "VARCHAR(255)"
+	default:
+		return d.PostgresDialect.ColumnType(t)
+	}
+}
+
+func (d RedshiftDialect) ChangelogDDL() []string {
+	return createChangelogTables(d)
+}
+
+func (RedshiftDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = '%s'`, table)
+}
+
+// InsertIgnore inserts the row unless the primary key exists, concurrent runs are serialized by LockStatement
+func (d RedshiftDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s = $1)`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)), table, columns[0])
+}
+
+// LockStatement locks the changelog until the transaction ends, without it concurrent transactions writing the
+// changelog fail with serializable isolation violations
+func (RedshiftDialect) LockStatement() string {
+	return "LOCK changelog"
+}
+
+// NonTransactional is true for the statements which can not run in a transaction block, other DDL is transactional
+func (RedshiftDialect) NonTransactional(statement string) bool {
+	return redshiftNonTransactionalPattern.MatchString(strings.TrimSpace(statement))
+}
+
+// VersionQuery extracts the Redshift version, version() starts with the version of Postgres Redshift was forked from
+func (RedshiftDialect) VersionQuery() string {
+	return `SELECT REGEXP_SUBSTR(version(), 'Redshift ([0-9.]+)', 1, 1, 'e')`
+}
+
+// Rewrite replaces CURRENT_TIMESTAMP, it is a leader node only function in Redshift
+func (RedshiftDialect) Rewrite(statement string) string {
+	return strings.ReplaceAll(statement, "CURRENT_TIMESTAMP", "GETDATE()")
+}
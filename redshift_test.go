@@ -0,0 +1,22 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RedshiftDialect(t *testing.T) {
+	d := RedshiftDialect{}
+	assert.Contains(t, d.ChangelogDDL()[0], "\trevertscript VARCHAR(65535),\n")
+	assert.Equal(t, `INSERT INTO changelog_job (id, scheduledAt) SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM changelog_job WHERE id = $1)`,
+		d.InsertIgnore("changelog_job", "id", "scheduledAt"))
+	assert.Equal(t, "LOCK changelog", d.LockStatement())
+	assert.True(t, d.NonTransactional("alter table events alter column name type VARCHAR(512)"))
+	assert.True(t, d.NonTransactional("VACUUM events"))
+	assert.False(t, d.NonTransactional("ALTER TABLE events ADD COLUMN name VARCHAR(256)"))
+
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(d))
+	query, _ := service.rebind(`UPDATE changelog_run SET finishedAt = CURRENT_TIMESTAMP, error = $2 WHERE id = $1`)
+	assert.Equal(t, `UPDATE changelog_run SET finishedAt = GETDATE(), error = $2 WHERE id = $1`, query)
+}
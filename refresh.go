@@ -0,0 +1,71 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MaterializedView is refreshed after the script of a migration which changed its sources
+type MaterializedView struct {
+	// Name is the (optionally schema qualified) view
+	Name string `yaml:"name"`
+	// Concurrently refreshes the view without locking out reads, the view needs a unique index
+	Concurrently bool `yaml:"concurrently"`
+}
+
+// parseRefresh parses the arguments of a "-- migrago:refresh <view> [concurrently]" directive
+func parseRefresh(args string) (MaterializedView, error) {
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 1:
+		return MaterializedView{Name: fields[0]}, nil
+	case len(fields) == 2 && fields[1] == "concurrently":
+		return MaterializedView{Name: fields[0], Concurrently: true}, nil
+	}
+	return MaterializedView{}, fmt.Errorf("invalid refresh directive %q, expected <view> [concurrently]", args)
+}
+
+// refreshStatement returns the REFRESH MATERIALIZED VIEW statement of the view
+func (v MaterializedView) refreshStatement() string {
+	if v.Concurrently {
+		return "REFRESH MATERIALIZED VIEW CONCURRENTLY " + quoteIdentifier(v.Name)
+	}
+	return "REFRESH MATERIALIZED VIEW " + quoteIdentifier(v.Name)
+}
+
+// planRefreshes refreshes a view only once per run: after the last pending migration which declares it,
+// so the refresh sees the changes of all migrations which altered its sources
+func planRefreshes(pending []Migration) {
+	seen := map[string]bool{}
+	for i := len(pending) - 1; i >= 0; i-- {
+		views := pending[i].Metadata.Refresh
+		if len(views) == 0 {
+			continue
+		}
+		var planned []MaterializedView
+		for _, view := range views {
+			if !seen[view.Name] {
+				seen[view.Name] = true
+				planned = append(planned, view)
+			}
+		}
+		pending[i].Metadata.Refresh = planned
+	}
+}
+
+// execer executes statements, it is implemented by *sql.Tx and *sql.Conn
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// refreshViews refreshes the materialized views of a migration
+func refreshViews(ctx context.Context, exec execer, migration Migration) error {
+	for _, view := range migration.Metadata.Refresh {
+		if _, err := exec.ExecContext(ctx, view.refreshStatement()); err != nil {
+			return fmt.Errorf("failed to refresh materialized view %s: %w", view.Name, err)
+		}
+	}
+	return nil
+}
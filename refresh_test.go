@@ -0,0 +1,33 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseRefresh(t *testing.T) {
+	view, err := parseRefresh("reporting.daily_sales concurrently")
+	assert.NoError(t, err)
+	assert.Equal(t, MaterializedView{Name: "reporting.daily_sales", Concurrently: true}, view)
+	assert.Equal(t, `REFRESH MATERIALIZED VIEW CONCURRENTLY "reporting"."daily_sales"`, view.refreshStatement())
+
+	view, err = parseRefresh("daily_sales")
+	assert.NoError(t, err)
+	assert.Equal(t, `REFRESH MATERIALIZED VIEW "daily_sales"`, view.refreshStatement())
+
+	_, err = parseRefresh("daily_sales now")
+	assert.Error(t, err)
+}
+
+func Test_planRefreshes(t *testing.T) {
+	pending := []Migration{
+		{Id: "Test", Metadata: Metadata{Refresh: []MaterializedView{{Name: "sales"}, {Name: "users"}}}},
+		{Id: "Test2"},
+		{Id: "Test3", Metadata: Metadata{Refresh: []MaterializedView{{Name: "sales", Concurrently: true}}}},
+	}
+	planRefreshes(pending)
+	assert.Equal(t, []MaterializedView{{Name: "users"}}, pending[0].Metadata.Refresh)
+	assert.Empty(t, pending[1].Metadata.Refresh)
+	assert.Equal(t, []MaterializedView{{Name: "sales", Concurrently: true}}, pending[2].Metadata.Refresh)
+}
@@ -0,0 +1,156 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Release groups the migrations of an application version, e.g. {"name": "v1.4.0", "migrations": ["..."]}
+type Release struct {
+	Name       string   `json:"name"`
+	Migrations []string `json:"migrations"`
+}
+
+// ReleaseSource is implemented by sources which group their migrations into releases, e.g. the FileSource with
+// a config file of the form {"releases": [{"name": "v1.4.0", "migrations": ["..."]}]}
+type ReleaseSource interface {
+	Releases() ([]Release, error)
+}
+
+// releases collects the releases of all sources in execution order, the migration IDs are qualified with the namespace
+func (m MigrationService) releases() ([]Release, error) {
+	var releases []Release
+	for _, s := range m.sources {
+		rs, ok := s.source.(ReleaseSource)
+		if !ok {
+			continue
+		}
+		sourceReleases, err := rs.Releases()
+		if err != nil {
+			return nil, err
+		}
+		for _, release := range sourceReleases {
+			if slices.ContainsFunc(releases, func(r Release) bool { return r.Name == release.Name }) {
+				return nil, fmt.Errorf("duplicate release %s", release.Name)
+			}
+			qualified := Release{Name: release.Name, Migrations: make([]string, len(release.Migrations))}
+			for i, id := range release.Migrations {
+				qualified.Migrations[i] = s.qualifiedId(id)
+			}
+			releases = append(releases, qualified)
+		}
+	}
+	return releases, nil
+}
+
+// findRelease returns the index of the release with the given name
+func findRelease(releases []Release, name string) (int, error) {
+	index := slices.IndexFunc(releases, func(release Release) bool { return release.Name == name })
+	if index < 0 {
+		return 0, fmt.Errorf("unknown release %s", name)
+	}
+	return index, nil
+}
+
+// ApplyRelease executes the pending migrations up to and including the release, the migrations of later releases stay pending
+func (m MigrationService) ApplyRelease(ctx context.Context, name string) error {
+	releases, err := m.releases()
+	if err != nil {
+		return err
+	}
+	index, err := findRelease(releases, name)
+	if err != nil {
+		return err
+	}
+	m.excludeIds = make(map[string]bool)
+	for _, release := range releases[index+1:] {
+		for _, id := range release.Migrations {
+			m.excludeIds[id] = true
+		}
+	}
+	return m.ExecuteMigration(ctx)
+}
+
+// RollbackRelease reverts the applied migrations of the release and of all later releases, newest first
+func (m MigrationService) RollbackRelease(ctx context.Context, name string) error {
+	releases, err := m.releases()
+	if err != nil {
+		return err
+	}
+	index, err := findRelease(releases, name)
+	if err != nil {
+		return err
+	}
+	revert := make(map[string]bool)
+	for _, release := range releases[index:] {
+		for _, id := range release.Migrations {
+			revert[id] = true
+		}
+	}
+
+	if err := m.prepareDatabase(ctx); err != nil {
+		return err
+	}
+	existingMigrations, err := m.getExistingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	// The existing migrations are ordered newest first, which is the revert order
+	reverts := slices.DeleteFunc(existingMigrations, func(migration Migration) bool { return !revert[migration.Id] })
+	for _, migration := range reverts {
+		if err := m.checkRevertable(migration); err != nil {
+			return err
+		}
+	}
+	for _, migration := range reverts {
+		if err := m.revertInPhase(ctx, migration); err != nil {
+			return err
+		}
+	}
+	m.log().Info("release rolled back", "release", name, "reverted", len(reverts))
+	return nil
+}
+
+// CurrentRelease returns the newest release which is applied completely together with all releases before it,
+// it is empty if the first release is not applied completely
+func (m MigrationService) CurrentRelease(ctx context.Context) (string, error) {
+	releases, err := m.releases()
+	if err != nil {
+		return "", err
+	}
+	if err := m.prepareDatabase(ctx); err != nil {
+		return "", err
+	}
+	existingMigrations, err := m.getExistingMigrations(ctx)
+	if err != nil {
+		return "", err
+	}
+	applied := make(map[string]bool, len(existingMigrations))
+	for _, migration := range existingMigrations {
+		applied[migration.Id] = true
+	}
+	var notApplied []Migration
+	for _, release := range releases {
+		for _, id := range release.Migrations {
+			if !applied[id] {
+				notApplied = append(notApplied, Migration{Id: id})
+			}
+		}
+	}
+	// Archived migrations count as applied
+	archived, err := m.getArchivedIds(ctx, notApplied)
+	if err != nil {
+		return "", err
+	}
+
+	current := ""
+	for _, release := range releases {
+		complete := !slices.ContainsFunc(release.Migrations, func(id string) bool { return !applied[id] && !archived[id] })
+		if !complete {
+			break
+		}
+		current = release.Name
+	}
+	return current, nil
+}
@@ -0,0 +1,32 @@
+package migrago
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_releases(t *testing.T) {
+	config := fstest.MapFS{
+		"config.json": {Data: []byte(`{"migrations": ["0001_init"], "releases": [
+			{"name": "v1.0.0", "migrations": ["0002_users"]},
+			{"name": "v1.1.0", "migrations": ["0003_teams", "0004_members"]}
+		]}`)},
+	}
+	source := NewFileSource("config.json", "scripts", config)
+	ids, err := source.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0001_init", "0002_users", "0003_teams", "0004_members"}, ids)
+
+	service := NewMigrationServiceFromSource(staticSource{}, nil, WithSource("platform", source))
+	releases, err := service.releases()
+	assert.NoError(t, err)
+	assert.Equal(t, []Release{
+		{Name: "v1.0.0", Migrations: []string{"platform/0002_users"}},
+		{Name: "v1.1.0", Migrations: []string{"platform/0003_teams", "platform/0004_members"}},
+	}, releases)
+
+	_, err = findRelease(releases, "v2.0.0")
+	assert.ErrorContains(t, err, "unknown release v2.0.0")
+}
@@ -0,0 +1,55 @@
+package migrago
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// Rerun is meant to be called from hot-reload tools (air, fresh, ...) after files changed.
+// It executes the migrations again if one of the changed files belongs to the migration set
+// and does nothing otherwise. An empty list of changed files always triggers a run.
+// Outside of production mode edited migrations are re-applied like with WithDevForce, also in strict mode,
+// which still fails the run if the changelog contains unknown migrations.
+func (m MigrationService) Rerun(ctx context.Context, changedFiles ...string) error {
+	if len(changedFiles) > 0 && !m.containsMigrationFile(changedFiles) {
+		return nil
+	}
+	if !m.production {
+		m.reapplyEdited = true
+	}
+	return m.ExecuteMigration(ctx)
+}
+
+// containsMigrationFile checks if at least one of the given paths is a config file or a migration script of any source
+func (m MigrationService) containsMigrationFile(files []string) bool {
+	for _, s := range m.sources {
+		// Changes of custom sources can not be detected, so they are always executed
+		fileSource, ok := s.source.(FileSource)
+		if !ok || fileSource.containsMigrationFile(files) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsMigrationFile checks if at least one of the given paths is the config file or a migration script of the source
+func (s FileSource) containsMigrationFile(files []string) bool {
+	configFile := fsPath(s.configFile)
+	scriptPath := fsPath(s.scriptPath)
+	for _, file := range files {
+		file = fsPath(file)
+		if file == configFile || strings.HasSuffix(file, "/"+configFile) {
+			return true
+		}
+		if path.Ext(file) != ".sql" {
+			continue
+		}
+		// scripts can be in nested directories declared in the config
+		dir := path.Dir(file) + "/"
+		if scriptPath == "." || strings.HasPrefix(dir, scriptPath+"/") || strings.Contains(dir, "/"+scriptPath+"/") {
+			return true
+		}
+	}
+	return false
+}
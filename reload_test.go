@@ -0,0 +1,18 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_containsMigrationFile(t *testing.T) {
+	service := NewMigrationService("config.json", "scripts", nil, nil)
+	assert.True(t, service.containsMigrationFile([]string{"config.json"}))
+	assert.True(t, service.containsMigrationFile([]string{"/app/migration/config.json"}))
+	assert.True(t, service.containsMigrationFile([]string{"main.go", "/app/migration/scripts/Test.sql"}))
+	assert.True(t, service.containsMigrationFile([]string{"scripts/Test.revert.sql"}))
+	assert.True(t, service.containsMigrationFile([]string{"/app/migration/scripts/billing/invoices/Test.sql"}))
+	assert.False(t, service.containsMigrationFile([]string{"main.go", "/app/other/Test.sql"}))
+	assert.False(t, service.containsMigrationFile([]string{"/app/migration/scripts/README.md"}))
+}
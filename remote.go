@@ -0,0 +1,66 @@
+package migrago
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// ObjectStore is a remote storage (S3, GCS, ...) migration files can be loaded from.
+// Implementations should return an error wrapping fs.ErrNotExist for missing objects.
+type ObjectStore interface {
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// remoteFS exposes an ObjectStore as fs.FS, so it can be passed to NewMigrationService
+type remoteFS struct {
+	// ctx is used for every download, fs.FS has no way to pass it per call
+	ctx    context.Context
+	store  ObjectStore
+	prefix string
+}
+
+// NewRemoteFS creates a fs.FS which downloads files from the object store on open.
+// All file names are prefixed with prefix to build the object key. The fs.FS is bound to ctx, so it should be created
+// for a single load of the migrations, e.g. one run; once ctx is done every Open fails.
+func NewRemoteFS(ctx context.Context, store ObjectStore, prefix string) fs.FS {
+	return remoteFS{ctx: ctx, store: store, prefix: prefix}
+}
+
+// Open downloads the object and returns it as an in-memory file
+func (r remoteFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	body, err := r.store.GetObject(r.ctx, path.Join(r.prefix, name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	return &remoteFile{name: path.Base(name), Reader: bytes.NewReader(data), size: int64(len(data))}, nil
+}
+
+// remoteFile is a downloaded object
+type remoteFile struct {
+	*bytes.Reader
+	name string
+	size int64
+}
+
+func (f *remoteFile) Stat() (fs.FileInfo, error) { return f, nil }
+func (f *remoteFile) Close() error               { return nil }
+func (f *remoteFile) Name() string               { return f.name }
+func (f *remoteFile) Size() int64                { return f.size }
+func (f *remoteFile) Mode() fs.FileMode          { return 0o444 }
+func (f *remoteFile) ModTime() time.Time         { return time.Time{} }
+func (f *remoteFile) IsDir() bool                { return false }
+func (f *remoteFile) Sys() any                   { return nil }
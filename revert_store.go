@@ -0,0 +1,88 @@
+package migrago
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// objectMarker prefixes revert scripts which are stored in an object store,
+// the changelog only contains "migrago:object:<sha256>:<key>"
+const objectMarker = "migrago:object:"
+
+// WritableObjectStore is an ObjectStore revert scripts can be uploaded to
+type WritableObjectStore interface {
+	ObjectStore
+	PutObject(ctx context.Context, key string, body io.Reader) error
+}
+
+// encodeChangelogRevertScript returns the value stored in the revertscript column of the changelog.
+// With a revert store the script is uploaded and only a reference is stored, with a cipher the value is encrypted.
+func (m MigrationService) encodeChangelogRevertScript(ctx context.Context, migration Migration) (string, error) {
+	stored, err := m.storeRevertScript(ctx, migration)
+	if err != nil {
+		return "", err
+	}
+	return m.encryptRevertScript(ctx, stored)
+}
+
+// decodeChangelogRevertScript reverses encodeChangelogRevertScript except for downloading externally stored scripts,
+// which is deferred to resolveRevertScript
+func (m MigrationService) decodeChangelogRevertScript(ctx context.Context, stored string) (string, error) {
+	decrypted, err := m.decryptRevertScript(ctx, stored)
+	if err != nil {
+		return "", err
+	}
+	return decodeRevertScript(decrypted)
+}
+
+// storeRevertScript uploads the revert script to the revert store and returns the reference,
+// without a store the (compressed) script itself is returned
+func (m MigrationService) storeRevertScript(ctx context.Context, migration Migration) (string, error) {
+	if m.revertStore == nil || migration.NoRevertScript {
+		return encodeRevertScript(migration.RevertScript)
+	}
+	sum := sha256.Sum256([]byte(migration.RevertScript))
+	checksum := hex.EncodeToString(sum[:])
+	// The key is content addressed, so uploading the same script twice is harmless
+	key := path.Join(m.revertStorePrefix, checksum+".sql")
+	if err := m.revertStore.PutObject(ctx, key, strings.NewReader(migration.RevertScript)); err != nil {
+		return "", fmt.Errorf("failed to upload revert script of migration %s: %w", migration.Id, err)
+	}
+	return objectMarker + checksum + ":" + key, nil
+}
+
+// resolveRevertScript downloads the revert script of an applied migration if it is stored in the revert store
+func (m MigrationService) resolveRevertScript(ctx context.Context, migration Migration) (Migration, error) {
+	reference, ok := strings.CutPrefix(migration.RevertScript, objectMarker)
+	if !ok {
+		return migration, nil
+	}
+	checksum, key, ok := strings.Cut(reference, ":")
+	if !ok {
+		return migration, fmt.Errorf("migration %s: invalid revert script reference %q", migration.Id, migration.RevertScript)
+	}
+	if m.revertStore == nil {
+		return migration, fmt.Errorf("revert script of migration %s is stored in %s, configure WithRevertScriptStore to revert it", migration.Id, key)
+	}
+
+	body, err := m.revertStore.GetObject(ctx, key)
+	if err != nil {
+		return migration, fmt.Errorf("failed to download revert script of migration %s: %w", migration.Id, err)
+	}
+	defer body.Close()
+	hash := sha256.New()
+	script, err := io.ReadAll(io.TeeReader(body, hash))
+	if err != nil {
+		return migration, fmt.Errorf("failed to download revert script of migration %s: %w", migration.Id, err)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != checksum {
+		return migration, fmt.Errorf("revert script of migration %s in %s does not match the checksum in the changelog", migration.Id, key)
+	}
+	migration.RevertScript = string(script)
+	return migration, nil
+}
@@ -0,0 +1,63 @@
+package migrago
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore is an in-memory WritableObjectStore
+type memoryStore map[string]string
+
+func (s memoryStore) GetObject(_ context.Context, key string) (io.ReadCloser, error) {
+	content, ok := s[key]
+	if !ok {
+		return nil, fmt.Errorf("object %s: %w", key, fs.ErrNotExist)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func (s memoryStore) PutObject(_ context.Context, key string, body io.Reader) error {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, body); err != nil {
+		return err
+	}
+	s[key] = buf.String()
+	return nil
+}
+
+func Test_RevertScriptStore(t *testing.T) {
+	ctx := context.Background()
+	store := memoryStore{}
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithRevertScriptStore(store, "reverts"))
+	migration := Migration{Id: "Test", RevertScript: "DROP TABLE test"}
+
+	stored, err := service.encodeChangelogRevertScript(ctx, migration)
+	assert.NoError(t, err)
+	assert.Equal(t, objectMarker+"e2784f58d5f1164262ba560f1116e20e8075a82a2a4430cd0a8966cb3f69e6cf:reverts/e2784f58d5f1164262ba560f1116e20e8075a82a2a4430cd0a8966cb3f69e6cf.sql", stored)
+	assert.Len(t, store, 1)
+
+	resolved, err := service.resolveRevertScript(ctx, Migration{Id: "Test", RevertScript: stored})
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE test", resolved.RevertScript)
+
+	for key := range store {
+		store[key] = "DROP TABLE other"
+	}
+	_, err = service.resolveRevertScript(ctx, Migration{Id: "Test", RevertScript: stored})
+	assert.ErrorContains(t, err, "does not match the checksum")
+
+	_, err = NewMigrationService("config.json", "scripts", nil, nil).resolveRevertScript(ctx, Migration{Id: "Test", RevertScript: stored})
+	assert.ErrorContains(t, err, "configure WithRevertScriptStore")
+
+	// Without a store the revert script stays in the changelog
+	stored, err = NewMigrationService("config.json", "scripts", nil, nil).encodeChangelogRevertScript(ctx, migration)
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE test", stored)
+}
@@ -0,0 +1,53 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// sessionSetting is a run-time parameter (GUC) set for every migration transaction
+type sessionSetting struct {
+	name  string
+	value string
+}
+
+// applicationName returns the application_name of the migration sessions, "migrago:<run-id>" during a run
+func (m MigrationService) applicationName() string {
+	if m.appName != "" {
+		return m.appName
+	}
+	if m.runId != "" {
+		return "migrago:" + m.runId
+	}
+	return "migrago"
+}
+
+// beginTx starts a transaction with the lock of the dialect, the application_name and the session settings applied.
+// The settings are transaction local, so they do not leak into other users of the connection pool.
+func (m MigrationService) beginTx(ctx context.Context) (*sql.Tx, error) {
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	d := m.sqlDialect()
+	if lock := d.LockStatement(); lock != "" {
+		if _, err := tx.ExecContext(ctx, lock); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to lock changelog: %w", err)
+		}
+	}
+	statement := d.SessionSettingStatement()
+	if statement == "" {
+		return tx, nil
+	}
+	settings := append([]sessionSetting{{name: "application_name", value: m.applicationName()}}, m.sessionSettings...)
+	for _, setting := range settings {
+		query, args := m.rebind(statement, setting.name, setting.value)
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to set %s: %w", setting.name, err)
+		}
+	}
+	return tx, nil
+}
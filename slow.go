@@ -0,0 +1,20 @@
+package migrago
+
+import (
+	"context"
+	"time"
+)
+
+// SlowMigrationFunc is notified about migrations exceeding the slow migration threshold, e.g. to call a webhook
+type SlowMigrationFunc func(ctx context.Context, id string, duration time.Duration)
+
+// checkSlow warns if the script of the migration took longer than the slow migration threshold
+func (m MigrationService) checkSlow(ctx context.Context, migration Migration) {
+	if m.slowThreshold <= 0 || migration.Duration < m.slowThreshold {
+		return
+	}
+	m.log().WarnContext(ctx, "slow migration", "id", migration.Id, "duration", migration.Duration.Round(time.Millisecond), "threshold", m.slowThreshold)
+	if m.slowNotify != nil {
+		m.slowNotify(ctx, migration.Id, migration.Duration)
+	}
+}
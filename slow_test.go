@@ -0,0 +1,25 @@
+package migrago
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkSlow(t *testing.T) {
+	var notified []string
+	notify := func(_ context.Context, id string, _ time.Duration) {
+		notified = append(notified, id)
+	}
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithSlowMigrationThreshold(time.Second, notify))
+
+	service.checkSlow(context.Background(), Migration{Id: "Fast", Duration: time.Millisecond})
+	service.checkSlow(context.Background(), Migration{Id: "Slow", Duration: 2 * time.Second})
+	assert.Equal(t, []string{"Slow"}, notified)
+
+	// Without threshold nothing is reported
+	NewMigrationService("config.json", "scripts", nil, nil).checkSlow(context.Background(), Migration{Id: "Slow", Duration: time.Hour})
+	assert.Len(t, notified, 1)
+}
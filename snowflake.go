@@ -0,0 +1,127 @@
+package migrago
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// snowflakeImplicitCommitKeywords start the statements which commit the current transaction of Snowflake
+var snowflakeImplicitCommitKeywords = []string{"CREATE", "ALTER", "DROP", "UNDROP", "GRANT", "REVOKE", "COMMENT"}
+
+// SnowflakeDialect is the dialect of Snowflake with the driver github.com/snowflakedb/gosnowflake. DDL commits the
+// transaction implicitly, so migrations with DDL statements are executed statement by statement.
+type SnowflakeDialect struct {
+	// Warehouse executes the migrations, the default warehouse of the user if it is empty
+	Warehouse string
+	// Role is the role of the migrations, the default role of the user if it is empty
+	Role string
+	// Parameters are session parameters such as QUERY_TAG or STATEMENT_TIMEOUT_IN_SECONDS
+	Parameters map[string]string
+}
+
+func (SnowflakeDialect) Name() string {
+	return "snowflake"
+}
+
+func (SnowflakeDialect) Placeholder(int) string {
+	return "?"
+}
+
+// ColumnType places the defaults before NOT NULL, Snowflake rejects the other order
+func (SnowflakeDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText:
+		return "VARCHAR"
+	case ColumnInteger:
+		return "NUMBER(38, 0)"
+	case ColumnTimestamp:
+		return "TIMESTAMP_TZ"
+	case ColumnCreatedAt:
+		return "TIMESTAMP_TZ DEFAULT CURRENT_TIMESTAMP() NOT NULL"
+	case ColumnFlag:
+		return "BOOLEAN DEFAULT FALSE NOT NULL"
+	case ColumnSequence:
+		return "NUMBER(38, 0) AUTOINCREMENT START 1 INCREMENT 1 ORDER NOT NULL"
+	default:
+		return "VARCHAR(255)"
+	}
+}
+
+func (d SnowflakeDialect) ChangelogDDL() []string {
+	return createChangelogTables(d)
+}
+
+// TableExistsQuery compares with the upper-case name, unquoted identifiers are stored in upper case
+func (SnowflakeDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = UPPER('%s')`, table)
+}
+
+func (SnowflakeDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT LOWER(column_name) FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = UPPER('%s')`, table)
+}
+
+// InsertIgnore merges the row into the table, primary keys are not enforced by Snowflake
+func (SnowflakeDialect) InsertIgnore(table string, columns ...string) string {
+	return mergeInsert(table, columns)
+}
+
+// SessionSettingStatement is empty, the session parameters are set in the DSN by ConfigureDSN
+func (SnowflakeDialect) SessionSettingStatement() string {
+	return ""
+}
+
+func (SnowflakeDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for DDL, it commits the transaction implicitly
+func (SnowflakeDialect) NonTransactional(statement string) bool {
+	return slices.Contains(snowflakeImplicitCommitKeywords, firstKeyword(statement))
+}
+
+func (SnowflakeDialect) VersionQuery() string {
+	return `SELECT CURRENT_VERSION()`
+}
+
+// SplitRules respect backslash escapes in strings
+func (SnowflakeDialect) SplitRules() SplitRules {
+	return SplitRules{BackslashEscapes: true}
+}
+
+// ConfigureDSN adds the warehouse, the role and the session parameters to the query of the DSN, so they apply to
+// every connection of the pool
+func (d SnowflakeDialect) ConfigureDSN(dsn string) (string, error) {
+	base, rawQuery, _ := strings.Cut(dsn, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse DSN parameters: %w", err)
+	}
+	if d.Warehouse != "" {
+		query.Set("warehouse", d.Warehouse)
+	}
+	if d.Role != "" {
+		query.Set("role", d.Role)
+	}
+	for name, value := range d.Parameters {
+		query.Set(name, value)
+	}
+	if len(query) == 0 {
+		return base, nil
+	}
+	return base + "?" + query.Encode(), nil
+}
+
+// mergeInsert returns a MERGE which inserts the row bound to ? placeholders unless a row with the same first column
+// exists, for databases which do not enforce primary keys
+func mergeInsert(table string, columns []string) string {
+	selected := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		selected[i] = "? AS " + column
+		values[i] = "s." + column
+	}
+	return fmt.Sprintf(`MERGE INTO %s t USING (SELECT %s) s ON t.%s = s.%s WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)`,
+		table, strings.Join(selected, ", "), columns[0], columns[0], strings.Join(columns, ", "), strings.Join(values, ", "))
+}
@@ -0,0 +1,23 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SnowflakeDialect(t *testing.T) {
+	d := SnowflakeDialect{Warehouse: "MIGRATIONS", Parameters: map[string]string{"QUERY_TAG": "migrago"}}
+	assert.Equal(t, `MERGE INTO changelog_job t USING (SELECT ? AS id, ? AS scheduledAt) s ON t.id = s.id WHEN NOT MATCHED THEN INSERT (id, scheduledAt) VALUES (s.id, s.scheduledAt)`,
+		d.InsertIgnore("changelog_job", "id", "scheduledAt"))
+	assert.Contains(t, d.ChangelogDDL()[0], "installedAt TIMESTAMP_TZ DEFAULT CURRENT_TIMESTAMP() NOT NULL,")
+	assert.True(t, d.NonTransactional("CREATE OR REPLACE VIEW v AS SELECT 1"))
+	assert.False(t, d.NonTransactional("INSERT INTO t VALUES (1)"))
+
+	dsn, err := d.ConfigureDSN("user:secret@account/db/public?role=ANALYST")
+	assert.NoError(t, err)
+	assert.Equal(t, "user:secret@account/db/public?QUERY_TAG=migrago&role=ANALYST&warehouse=MIGRATIONS", dsn)
+	dsn, err = SnowflakeDialect{}.ConfigureDSN("user:secret@account/db")
+	assert.NoError(t, err)
+	assert.Equal(t, "user:secret@account/db", dsn)
+}
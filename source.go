@@ -0,0 +1,381 @@
+package migrago
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// Source provides the migrations for a MigrationService. Custom implementations can load
+// migrations from anywhere, e.g. another database or a config service.
+type Source interface {
+	// List returns the IDs of all migrations in execution order
+	List() ([]string, error)
+	// Load returns the migration with the given ID. The checksum is calculated from the script if it is left empty.
+	// Load is called concurrently for different IDs.
+	Load(migrationId string) (Migration, error)
+}
+
+// namedSource is a source whose migration IDs are prefixed with a namespace
+type namedSource struct {
+	namespace string
+	source    Source
+}
+
+// qualifiedId prefixes the migration ID with the namespace of the source
+func (s namedSource) qualifiedId(migrationId string) string {
+	if s.namespace == "" {
+		return migrationId
+	}
+	return s.namespace + "/" + migrationId
+}
+
+// DefaultStreamingThreshold is the size above which scripts are streamed instead of being read into memory
+const DefaultStreamingThreshold = 64 << 20
+
+// FileSource is the default Source, it reads a JSON config file with the migration IDs
+// and the scripts <id>.sql and <id>.revert.sql from the script directory or the subdirectories declared in the config
+type FileSource struct {
+	configFile      string
+	scriptPath      string
+	fs              fs.FS
+	streamThreshold int64
+	// directories are the script directories of the config resolved by List, shared by the copies of the source
+	directories *atomic.Pointer[[]string]
+}
+
+// FileSource constructor, the paths may use the separator of the operating system
+func NewFileSource(configFile, scriptPath string, fs fs.FS) FileSource {
+	return FileSource{
+		configFile:      fsPath(configFile),
+		scriptPath:      fsPath(scriptPath),
+		fs:              fs,
+		streamThreshold: DefaultStreamingThreshold,
+		directories:     new(atomic.Pointer[[]string]),
+	}
+}
+
+// WithStreamingThreshold returns a copy of the source which streams scripts larger than threshold bytes
+func (s FileSource) WithStreamingThreshold(threshold int64) FileSource {
+	s.streamThreshold = threshold
+	return s
+}
+
+// fileConfig is the configuration file, either a list of migration IDs or an object with the IDs, the requirements,
+// the releases and the script directories
+type fileConfig struct {
+	Requires   Requirements `json:"requires"`
+	Migrations []string     `json:"migrations"`
+	// Releases are executed after Migrations, in their order
+	Releases []Release `json:"releases"`
+	// Directories are nested directories below the script directory which contain scripts, e.g. one per domain
+	Directories []string `json:"directories"`
+	// Aliases maps the previous IDs of renamed migrations to their current IDs
+	Aliases map[string]string `json:"aliases"`
+	// Superseded maps the IDs of deprecated migrations to the IDs of their replacements
+	Superseded map[string]string `json:"superseded"`
+}
+
+// fsPath converts a path of the operating system to a path of an fs.FS, which always uses forward slashes
+func fsPath(name string) string {
+	return path.Clean(filepath.ToSlash(name))
+}
+
+// readConfig reads the configuration file (JSON) and validates it against the schema
+func (s FileSource) readConfig() (fileConfig, error) {
+	content, err := fs.ReadFile(s.fs, s.configFile)
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("failed to open config file: %w", err)
+	}
+	if err := validateConfig(s.configFile, content); err != nil {
+		return fileConfig{}, fmt.Errorf("invalid config file: %w", err)
+	}
+	var config fileConfig
+	if trimmed := strings.TrimSpace(string(content)); strings.HasPrefix(trimmed, "{") {
+		err = json.Unmarshal(content, &config)
+	} else {
+		err = json.Unmarshal(content, &config.Migrations)
+	}
+	if err != nil {
+		return fileConfig{}, fmt.Errorf("failed to decode config file: %w", err)
+	}
+	return config, nil
+}
+
+// List reads the configuration file (JSON) and returns a list of migration IDs
+func (s FileSource) List() ([]string, error) {
+	config, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	if s.directories != nil {
+		s.directories.Store(&config.Directories)
+	}
+	migrationIds := config.Migrations
+	for _, release := range config.Releases {
+		migrationIds = append(migrationIds, release.Migrations...)
+	}
+	return migrationIds, nil
+}
+
+// Releases returns the releases declared in the configuration file
+func (s FileSource) Releases() ([]Release, error) {
+	config, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Releases, nil
+}
+
+// Aliases returns the previous IDs of renamed migrations declared in the configuration file
+func (s FileSource) Aliases() (map[string]string, error) {
+	config, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Aliases, nil
+}
+
+// Superseded returns the replacements of deprecated migrations declared in the configuration file
+func (s FileSource) Superseded() (map[string]string, error) {
+	config, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Superseded, nil
+}
+
+// Requirements returns the requirements declared in the configuration file
+func (s FileSource) Requirements() (Requirements, error) {
+	config, err := s.readConfig()
+	if err != nil {
+		return Requirements{}, err
+	}
+	return config.Requires, nil
+}
+
+// readFileContent reads the content of a file with normalized encoding
+func readFileContent(fs fs.FS, path string) (string, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	fileContent, err := io.ReadAll(normalizeEncoding(f))
+	if err != nil {
+		return "", fmt.Errorf("failed to read file content: %w", err)
+	}
+	return string(fileContent), nil
+}
+
+// readScript reads a script with normalized encoding and calculates its checksum in the same pass
+func readScript(fsys fs.FS, path string) (script, checksum string, err error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var content strings.Builder
+	if info, err := f.Stat(); err == nil {
+		content.Grow(int(info.Size()))
+	}
+	hash := md5.New()
+	if _, err := io.Copy(&content, io.TeeReader(normalizeEncoding(f), hash)); err != nil {
+		return "", "", fmt.Errorf("failed to read file content: %w", err)
+	}
+	return content.String(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readRevertScript reads an optional revert script, missing is true if the file does not exist
+func readRevertScript(fsys fs.FS, path string) (script string, missing bool, err error) {
+	script, err = readFileContent(fsys, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", true, nil
+	}
+	return script, false, err
+}
+
+// scriptDir returns the directory containing the scripts of a migration, either the script directory itself or
+// exactly one of the directories declared in the config
+func (s FileSource) scriptDir(migrationId string) (string, error) {
+	found, err := s.hasScripts(s.scriptPath, migrationId)
+	if found || err != nil {
+		return s.scriptPath, err
+	}
+	configDirs, err := s.configDirectories()
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	for _, dir := range configDirs {
+		dir = path.Join(s.scriptPath, filepath.ToSlash(dir))
+		if found, err := s.hasScripts(dir, migrationId); err != nil {
+			return "", err
+		} else if found {
+			dirs = append(dirs, dir)
+		}
+	}
+	switch len(dirs) {
+	case 0:
+		// the error is reported when the variants are loaded
+		return s.scriptPath, nil
+	case 1:
+		return dirs[0], nil
+	}
+	return "", fmt.Errorf("migration %s has scripts in several directories: %s", migrationId, strings.Join(dirs, ", "))
+}
+
+// configDirectories returns the script directories resolved by List, the config is only read if List was not called before
+func (s FileSource) configDirectories() ([]string, error) {
+	if s.directories != nil {
+		if dirs := s.directories.Load(); dirs != nil {
+			return *dirs, nil
+		}
+	}
+	config, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Directories, nil
+}
+
+// hasScripts checks if the directory contains <id>.sql or dialect variants <id>.<dialect>.sql of a migration
+func (s FileSource) hasScripts(dir, migrationId string) (bool, error) {
+	if _, err := fs.Stat(s.fs, path.Join(dir, migrationId+".sql")); err == nil {
+		return true, nil
+	}
+	entries, err := fs.ReadDir(s.fs, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read script directory: %w", err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasPrefix(name, migrationId+".") && strings.HasSuffix(name, ".sql") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Load extracts a migration and calculates the checksum of the script.
+// If there is no <id>.sql, the dialect variants <id>.<dialect>.sql are loaded instead.
+func (s FileSource) Load(migrationId string) (Migration, error) {
+	dir, err := s.scriptDir(migrationId)
+	if err != nil {
+		return Migration{}, err
+	}
+	scriptFile := path.Join(dir, migrationId+".sql")
+	info, err := fs.Stat(s.fs, scriptFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s.loadVariants(dir, migrationId)
+	}
+	if err != nil {
+		return Migration{}, fmt.Errorf("failed to stat file %s: %w", scriptFile, err)
+	}
+	if info.Size() > s.streamThreshold {
+		return s.loadStreamed(dir, migrationId, scriptFile)
+	}
+
+	script, checksum, err := readScript(s.fs, scriptFile)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	revertScript, noRevertScript, err := readRevertScript(s.fs, path.Join(dir, migrationId+".revert.sql"))
+	if err != nil {
+		return Migration{}, err
+	}
+
+	return Migration{
+		Id:             migrationId,
+		Script:         script,
+		RevertScript:   revertScript,
+		NoRevertScript: noRevertScript,
+		Checksum:       checksum,
+	}, nil
+}
+
+// loadStreamed loads a large migration without reading the script into memory, the checksum is calculated while streaming
+func (s FileSource) loadStreamed(dir, migrationId, scriptFile string) (Migration, error) {
+	open := func() (io.ReadCloser, error) {
+		f, err := s.fs.Open(scriptFile)
+		if err != nil {
+			return nil, err
+		}
+		return normalizedFile{Reader: normalizeEncoding(f), Closer: f}, nil
+	}
+	f, err := open()
+	if err != nil {
+		return Migration{}, fmt.Errorf("failed to open file %s: %w", scriptFile, err)
+	}
+	defer f.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return Migration{}, fmt.Errorf("failed to read file content: %w", err)
+	}
+
+	revertScript, noRevertScript, err := readRevertScript(s.fs, path.Join(dir, migrationId+".revert.sql"))
+	if err != nil {
+		return Migration{}, err
+	}
+
+	return Migration{
+		Id:             migrationId,
+		RevertScript:   revertScript,
+		NoRevertScript: noRevertScript,
+		Checksum:       hex.EncodeToString(hash.Sum(nil)),
+		OpenScript:     open,
+	}, nil
+}
+
+// loadVariants loads all dialect specific scripts <id>.<dialect>.sql and <id>.<dialect>.revert.sql of a migration
+func (s FileSource) loadVariants(dir, migrationId string) (Migration, error) {
+	entries, err := fs.ReadDir(s.fs, dir)
+	if err != nil {
+		return Migration{}, fmt.Errorf("failed to read script directory: %w", err)
+	}
+
+	variants := make(map[string]ScriptVariant)
+	for _, entry := range entries {
+		name := entry.Name()
+		dialect, ok := strings.CutPrefix(name, migrationId+".")
+		if !ok || entry.IsDir() || strings.HasSuffix(name, ".revert.sql") {
+			continue
+		}
+		if dialect, ok = strings.CutSuffix(dialect, ".sql"); !ok || dialect == "revert" || strings.Contains(dialect, ".") {
+			continue
+		}
+
+		var variant ScriptVariant
+		if variant.Script, variant.Checksum, err = readScript(s.fs, path.Join(dir, name)); err != nil {
+			return Migration{}, err
+		}
+		if variant.RevertScript, variant.NoRevertScript, err = readRevertScript(s.fs, path.Join(dir, migrationId+"."+dialect+".revert.sql")); err != nil {
+			return Migration{}, err
+		}
+		variants[dialect] = variant
+	}
+	if len(variants) == 0 {
+		return Migration{}, fmt.Errorf("no script found for migration %s", migrationId)
+	}
+	return Migration{Id: migrationId, Variants: variants}, nil
+}
+
+// calculateChecksum calculates the MD5 checksum of a script
+func calculateChecksum(script string) string {
+	checksum := md5.Sum([]byte(script))
+	return hex.EncodeToString(checksum[:])
+}
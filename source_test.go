@@ -0,0 +1,178 @@
+package migrago
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// staticSource is a custom Source with in-memory migrations
+type staticSource []Migration
+
+func (s staticSource) List() ([]string, error) {
+	ids := make([]string, len(s))
+	for i, migration := range s {
+		ids[i] = migration.Id
+	}
+	return ids, nil
+}
+
+func (s staticSource) Load(migrationId string) (Migration, error) {
+	for _, migration := range s {
+		if migration.Id == migrationId {
+			return migration, nil
+		}
+	}
+	return Migration{}, assert.AnError
+}
+
+func Test_FileSource(t *testing.T) {
+	fs := CreateFSForMigrations([]Migration{
+		{
+			Id:           "Test",
+			Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+			RevertScript: "DROP TABLE test",
+		},
+	})
+	source := NewFileSource("config.json", "scripts", fs)
+	ids, err := source.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Test"}, ids)
+
+	migration, err := source.Load("Test")
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE test", migration.RevertScript)
+	assert.Equal(t, "9c23564a026f0826f2a05b8423aa21f9", migration.Checksum)
+}
+
+func Test_CustomSource(t *testing.T) {
+	service := NewMigrationServiceFromSource(staticSource{
+		{
+			Id:           "Test",
+			Script:       "CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)",
+			RevertScript: "DROP TABLE test",
+		},
+	}, nil)
+	_, migrations, err := service.getMigrations()
+	assert.NoError(t, err)
+	assert.Equal(t, "9c23564a026f0826f2a05b8423aa21f9", migrations["Test"].Checksum)
+}
+
+func Test_FileSourceVariants(t *testing.T) {
+	fs := fstest.MapFS{
+		"config.json":                           {Data: []byte(`["001_users"]`)},
+		"scripts/001_users.postgres.sql":        {Data: []byte("CREATE TABLE users (id serial PRIMARY KEY)")},
+		"scripts/001_users.postgres.revert.sql": {Data: []byte("DROP TABLE users")},
+		"scripts/001_users.mysql.sql":           {Data: []byte("CREATE TABLE users (id INT AUTO_INCREMENT PRIMARY KEY)")},
+		"scripts/001_users.mysql.revert.sql":    {Data: []byte("DROP TABLE users")},
+	}
+	service := NewMigrationService("config.json", "scripts", fs, nil)
+	_, migrations, err := service.getMigrations()
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (id serial PRIMARY KEY)", migrations["001_users"].Script)
+	assert.Equal(t, calculateChecksum("CREATE TABLE users (id serial PRIMARY KEY)"), migrations["001_users"].Checksum)
+
+	delete(fs, "scripts/001_users.postgres.sql")
+	_, _, err = service.getMigrations()
+	assert.ErrorContains(t, err, "has no script for dialect postgres")
+}
+
+func Test_OptionalRevertScripts(t *testing.T) {
+	fs := fstest.MapFS{
+		"config.json":      {Data: []byte(`["Test"]`)},
+		"scripts/Test.sql": {Data: []byte("CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50) UNIQUE NOT NULL)")},
+	}
+	_, _, err := NewMigrationService("config.json", "scripts", fs, nil).getMigrations()
+	assert.ErrorContains(t, err, "missing revert script for migration Test")
+
+	_, migrations, err := NewMigrationService("config.json", "scripts", fs, nil, WithOptionalRevertScripts()).getMigrations()
+	assert.NoError(t, err)
+	assert.True(t, migrations["Test"].NoRevertScript)
+
+	err = NewMigrationService("config.json", "scripts", fs, nil).revertSingleMigration(context.Background(), migrations["Test"])
+	assert.ErrorContains(t, err, "can not be reverted")
+}
+
+func Test_getMigrationsOrder(t *testing.T) {
+	ids := []string{"zeta", "alpha", "mu", "beta", "omega", "gamma"}
+	var migrations []Migration
+	for _, id := range ids {
+		migrations = append(migrations, Migration{Id: id, Script: "SELECT 1", RevertScript: "SELECT 1"})
+	}
+	service := NewMigrationService("config.json", "scripts", CreateFSForMigrations(migrations), nil)
+	for i := 0; i < 10; i++ {
+		sourceMigrations, _, err := service.getMigrations()
+		assert.NoError(t, err)
+		var order []string
+		for _, migration := range sourceMigrations[0] {
+			order = append(order, migration.Id)
+		}
+		assert.Equal(t, ids, order)
+	}
+}
+
+func Test_FileSourceStreaming(t *testing.T) {
+	script := "-- migrago:irreversible\nCREATE TABLE test (id serial PRIMARY KEY);\nINSERT INTO test DEFAULT VALUES;"
+	fs := CreateFSForMigrations([]Migration{{Id: "Test", Script: script, RevertScript: "DROP TABLE test"}})
+	service := NewMigrationService("config.json", "scripts", fs, nil, WithStreamingThreshold(16))
+
+	_, migrations, err := service.getMigrations()
+	assert.NoError(t, err)
+	migration := migrations["Test"]
+	assert.Empty(t, migration.Script)
+	assert.NotNil(t, migration.OpenScript)
+	assert.Equal(t, calculateChecksum(script), migration.Checksum)
+	assert.True(t, migration.Metadata.Irreversible)
+}
+
+func Test_FileSourceDirectories(t *testing.T) {
+	fs := fstest.MapFS{
+		"migration/config.json":                                       {Data: []byte(`{"directories": ["billing/invoices", "users"], "migrations": ["0001_init", "0002_invoices", "0003_users", "0004_both"]}`)},
+		"migration/scripts/0001_init.sql":                             {Data: []byte("CREATE SCHEMA app")},
+		"migration/scripts/billing/invoices/0002_invoices.sql":        {Data: []byte("CREATE TABLE invoices ()")},
+		"migration/scripts/billing/invoices/0002_invoices.revert.sql": {Data: []byte("DROP TABLE invoices")},
+		"migration/scripts/users/0003_users.postgres.sql":             {Data: []byte("CREATE TABLE users ()")},
+		"migration/scripts/users/0004_both.sql":                       {Data: []byte("SELECT 1")},
+		"migration/scripts/billing/invoices/0004_both.sql":            {Data: []byte("SELECT 1")},
+	}
+	// paths are converted to fs.FS paths, e.g. on Windows
+	source := NewFileSource(filepath.Join("migration", "config.json"), filepath.Join("migration", "scripts"), fs)
+
+	migration, err := source.Load("0001_init")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE SCHEMA app", migration.Script)
+
+	migration, err = source.Load("0002_invoices")
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE invoices", migration.RevertScript)
+
+	migration, err = source.Load("0003_users")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users ()", migration.Variants["postgres"].Script)
+
+	_, err = source.Load("0004_both")
+	assert.EqualError(t, err, "migration 0004_both has scripts in several directories: migration/scripts/billing/invoices, migration/scripts/users")
+
+	_, err = source.Load("0005_missing")
+	assert.EqualError(t, err, "no script found for migration 0005_missing")
+}
+
+func Test_FileSourceDirectoriesResolvedOnce(t *testing.T) {
+	fs := fstest.MapFS{
+		"config.json":                  {Data: []byte(`{"directories": ["users"], "migrations": ["0001_users"]}`)},
+		"scripts/users/0001_users.sql": {Data: []byte("CREATE TABLE users ()")},
+	}
+	source := NewFileSource("config.json", "scripts", fs)
+	migrationIds, err := source.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0001_users"}, migrationIds)
+
+	// Load uses the directories resolved by List instead of reading the config again
+	delete(fs, "config.json")
+	migration, err := source.WithStreamingThreshold(DefaultStreamingThreshold).Load("0001_users")
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users ()", migration.Script)
+}
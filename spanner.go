@@ -0,0 +1,102 @@
+package migrago
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// spannerDDLKeywords start the statements which the driver executes as schema update operations
+var spannerDDLKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "GRANT", "REVOKE", "ANALYZE"}
+
+// spannerTimestampPattern matches CURRENT_TIMESTAMP with and without parentheses
+var spannerTimestampPattern = regexp.MustCompile(`CURRENT_TIMESTAMP(\(\))?`)
+
+// SpannerDialect is the dialect of Google Cloud Spanner with the GoogleSQL dialect and the driver
+// github.com/googleapis/go-sql-spanner. The driver executes DDL outside of transactions as schema update operations
+// and waits for them, so migrations with DDL run statement by statement; DML runs in read-write transactions.
+type SpannerDialect struct{}
+
+func (SpannerDialect) Name() string {
+	return "spanner"
+}
+
+func (SpannerDialect) Placeholder(n int) string {
+	return fmt.Sprintf("@p%d", n)
+}
+
+func (SpannerDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText:
+		return "STRING(MAX)"
+	case ColumnInteger:
+		return "INT64"
+	case ColumnTimestamp:
+		return "TIMESTAMP"
+	case ColumnCreatedAt:
+		return "TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP())"
+	case ColumnFlag:
+		return "BOOL NOT NULL DEFAULT (FALSE)"
+	case ColumnSequence:
+		// Sequences of Spanner are bit-reversed to spread the writes, the microseconds of the insert keep the order
+		return "INT64 NOT NULL DEFAULT (UNIX_MICROS(CURRENT_TIMESTAMP()))"
+	case ColumnLSN:
+		return "STRING(MAX)"
+	default:
+		return "STRING(255)"
+	}
+}
+
+// ChangelogDDL creates the changelog tables, the primary key of a Spanner table follows the column list
+func (d SpannerDialect) ChangelogDDL() []string {
+	statements := createChangelogTables(d)
+	for i, table := range changelogTables {
+		key := fmt.Sprintf("PRIMARY KEY (%s)", table.columns[0].name)
+		statements[i] = strings.Replace(statements[i], ",\n\t"+key+"\n)", "\n) "+key, 1)
+	}
+	return statements
+}
+
+func (SpannerDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = '' AND table_name = '%s'`, table)
+}
+
+func (SpannerDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT LOWER(column_name) FROM information_schema.columns WHERE table_schema = '' AND table_name = '%s'`, table)
+}
+
+// InsertIgnore inserts the row unless the primary key exists, Spanner aborts one of two concurrent transactions
+// inserting the same key and the driver retries it
+func (d SpannerDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT OR IGNORE INTO %s (%s) VALUES (%s)`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)))
+}
+
+func (SpannerDialect) SessionSettingStatement() string {
+	return ""
+}
+
+func (SpannerDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for DDL, it can not be part of a read-write transaction
+func (SpannerDialect) NonTransactional(statement string) bool {
+	return slices.Contains(spannerDDLKeywords, firstKeyword(statement))
+}
+
+// VersionQuery returns 0, Spanner is not versioned
+func (SpannerDialect) VersionQuery() string {
+	return `SELECT '0'`
+}
+
+// SplitRules are the rules of MySQL, GoogleSQL also escapes with backslashes and accepts # comments
+func (SpannerDialect) SplitRules() SplitRules {
+	return mysqlSplitRules
+}
+
+// Rewrite adds the parentheses GoogleSQL requires to CURRENT_TIMESTAMP
+func (SpannerDialect) Rewrite(statement string) string {
+	return spannerTimestampPattern.ReplaceAllString(statement, "CURRENT_TIMESTAMP()")
+}
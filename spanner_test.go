@@ -0,0 +1,23 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SpannerDialect(t *testing.T) {
+	d := SpannerDialect{}
+	statements := d.ChangelogDDL()
+	assert.Len(t, statements, len(changelogTables))
+	assert.Contains(t, statements[0], "\tinstalledAt TIMESTAMP NOT NULL DEFAULT (CURRENT_TIMESTAMP()),\n")
+	assert.Contains(t, statements[0], "\tdescription STRING(MAX)\n) PRIMARY KEY (id)")
+	assert.NotContains(t, statements[0], ",\n\tPRIMARY KEY")
+	assert.Equal(t, `INSERT OR IGNORE INTO changelog_job (id) VALUES (@p1)`, d.InsertIgnore("changelog_job", "id"))
+	assert.True(t, d.NonTransactional("create index events_name on events (name)"))
+	assert.False(t, d.NonTransactional("UPDATE events SET name = 'x' WHERE true"))
+
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(d))
+	query, _ := service.rebind(`UPDATE changelog_run SET finishedAt = CURRENT_TIMESTAMP, error = $2 WHERE id = $1`)
+	assert.Equal(t, `UPDATE changelog_run SET finishedAt = CURRENT_TIMESTAMP(), error = @p2 WHERE id = @p1`, query)
+}
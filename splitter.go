@@ -0,0 +1,254 @@
+package migrago
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SplitRules are the lexical rules the statements of a script are split with, the zero value splits Postgres scripts
+type SplitRules struct {
+	// BackslashEscapes escape quotes with a backslash in every quoted string, e.g. 'it\'s' on MySQL
+	BackslashEscapes bool
+	// HashComments start line comments with # besides --
+	HashComments bool
+	// BacktickQuotes quote identifiers with backticks
+	BacktickQuotes bool
+}
+
+// mysqlSplitRules are the rules of MySQL and of the databases with a MySQL-like lexer, e.g. GoogleSQL
+var mysqlSplitRules = SplitRules{BackslashEscapes: true, HashComments: true, BacktickQuotes: true}
+
+// splitStatements reads SQL from r and calls fn for every statement, so scripts can be
+// executed without holding them in memory. Quotes, dollar quotes, comments and the BEGIN ATOMIC ... END bodies of
+// SQL functions are respected.
+func splitStatements(r io.Reader, rules SplitRules, fn func(statement string) error) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var stmt strings.Builder
+	var quote rune       // ', ", ` or 0
+	var escapes bool     // backslash escapes in E'...' strings or with BackslashEscapes
+	var dollarTag string // active $tag$ or empty
+	var dollarStart int  // length of the statement after the opening tag
+	var lineComment bool
+	var blockDepth int
+	var word, previousWord strings.Builder
+	var atomicDepth int // nesting of BEGIN ATOMIC and CASE ... END, semicolons inside do not end the statement
+
+	endWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		w := strings.ToUpper(word.String())
+		switch {
+		case atomicDepth == 0 && w == "ATOMIC" && previousWord.String() == "BEGIN":
+			atomicDepth = 1
+		case atomicDepth > 0 && w == "CASE":
+			atomicDepth++
+		case atomicDepth > 0 && w == "END":
+			atomicDepth--
+		}
+		previousWord.Reset()
+		previousWord.WriteString(w)
+		word.Reset()
+	}
+	emit := func() error {
+		s := strings.TrimSpace(stmt.String())
+		stmt.Reset()
+		previousWord.Reset()
+		atomicDepth = 0
+		if s == "" || isOnlyComments(s, rules.HashComments) {
+			return nil
+		}
+		return fn(s)
+	}
+
+	for {
+		c, _, err := reader.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return emit()
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case lineComment:
+			stmt.WriteRune(c)
+			if c == '\n' {
+				lineComment = false
+			}
+			continue
+		case blockDepth > 0:
+			stmt.WriteRune(c)
+			if c == '*' && peek(reader) == '/' {
+				reader.ReadRune()
+				stmt.WriteRune('/')
+				blockDepth--
+			} else if c == '/' && peek(reader) == '*' {
+				reader.ReadRune()
+				stmt.WriteRune('*')
+				blockDepth++
+			}
+			continue
+		case quote != 0:
+			stmt.WriteRune(c)
+			if escapes && c == '\\' {
+				if next, _, err := reader.ReadRune(); err == nil {
+					stmt.WriteRune(next)
+				}
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		case dollarTag != "":
+			stmt.WriteRune(c)
+			if c == '$' && stmt.Len()-dollarStart >= len(dollarTag) && strings.HasSuffix(stmt.String(), dollarTag) {
+				dollarTag = ""
+			}
+			continue
+		}
+
+		if isIdentifierRune(c) {
+			word.WriteRune(c)
+		} else {
+			endWord()
+		}
+		switch c {
+		case ';':
+			if atomicDepth > 0 {
+				break
+			}
+			if err := emit(); err != nil {
+				return err
+			}
+			continue
+		case '\'', '"':
+			quote = c
+			escapes = rules.BackslashEscapes || c == '\'' && isEscapeStringPrefix(stmt.String())
+		case '`':
+			if rules.BacktickQuotes {
+				quote = c
+				escapes = false
+			}
+		case '-':
+			if peek(reader) == '-' {
+				lineComment = true
+			}
+		case '#':
+			lineComment = rules.HashComments
+		case '/':
+			if peek(reader) == '*' {
+				reader.ReadRune()
+				stmt.WriteString("/*")
+				blockDepth++
+				continue
+			}
+		case '$':
+			// a$b$ is an identifier, not the start of a dollar quote
+			if endsWithIdentifier(stmt.String()) {
+				break
+			}
+			if tag, ok := readDollarTag(reader); ok {
+				stmt.WriteString(tag)
+				dollarTag = tag
+				dollarStart = stmt.Len()
+				continue
+			}
+		}
+		stmt.WriteRune(c)
+	}
+}
+
+// splitBatches reads SQL from r and calls fn for every batch, batches end at lines consisting of the separator only.
+// Like sqlcmd the separator is not recognized by quotes and comments, it must not start a line inside them.
+func splitBatches(r io.Reader, separator string, fn func(batch string) error) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var batch strings.Builder
+	emit := func() error {
+		s := strings.TrimSpace(batch.String())
+		batch.Reset()
+		if s == "" || isOnlyComments(s, false) {
+			return nil
+		}
+		return fn(s)
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if strings.EqualFold(strings.TrimSpace(line), separator) {
+			if err := emit(); err != nil {
+				return err
+			}
+		} else {
+			batch.WriteString(line)
+		}
+		if err != nil {
+			return emit()
+		}
+	}
+}
+
+// peek returns the next rune without consuming it
+func peek(reader *bufio.Reader) rune {
+	c, _, err := reader.ReadRune()
+	if err != nil {
+		return 0
+	}
+	reader.UnreadRune()
+	return c
+}
+
+// readDollarTag reads the rest of a dollar quote tag like $$ or $body$, the leading $ is already consumed
+func readDollarTag(reader *bufio.Reader) (string, bool) {
+	buf, _ := reader.Peek(64)
+	for i, b := range buf {
+		if b == '$' {
+			tag := "$" + string(buf[:i+1])
+			reader.Discard(i + 1)
+			return tag, true
+		}
+		if !(b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || i > 0 && b >= '0' && b <= '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// isIdentifierRune checks if the rune can be part of an unquoted identifier or keyword, $ only after the first rune
+func isIdentifierRune(c rune) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c > unicode.MaxASCII && unicode.IsLetter(c)
+}
+
+// endsWithIdentifier checks if the statement ends with an identifier, a $ following it is part of the identifier
+func endsWithIdentifier(s string) bool {
+	c, _ := utf8.DecodeLastRuneInString(s)
+	return c == '$' || isIdentifierRune(c)
+}
+
+// isEscapeStringPrefix checks if a quote following the statement starts an escape string like E'...'
+func isEscapeStringPrefix(s string) bool {
+	if !strings.HasSuffix(s, "E") && !strings.HasSuffix(s, "e") {
+		return false
+	}
+	if len(s) == 1 {
+		return true
+	}
+	b := s[len(s)-2]
+	return !(b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9')
+}
+
+// isOnlyComments checks if a statement consists only of comment lines, hash starts them as well if hashComments is set
+func isOnlyComments(s string, hashComments bool) bool {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") && !(hashComments && strings.HasPrefix(line, "#")) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package migrago
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_splitStatements(t *testing.T) {
+	script := `-- migrago:irreversible
+CREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50));
+INSERT INTO test (name) VALUES ('a;b'), ('it''s');
+/* block; comment /* nested; */ */ SELECT 1;
+CREATE FUNCTION f() RETURNS trigger AS $body$
+BEGIN
+	RETURN NEW; -- inner;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT $$$$;
+SELECT E'it\'s;', name FROM test WHERE type = 'x\';
+SELECT $$a;b$$, "we;ird", $1
+-- trailing comment;`
+
+	var statements []string
+	err := splitStatements(strings.NewReader(script), SplitRules{}, func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"-- migrago:irreversible\nCREATE TABLE test (id serial PRIMARY KEY, name VARCHAR(50))",
+		"INSERT INTO test (name) VALUES ('a;b'), ('it''s')",
+		"/* block; comment /* nested; */ */ SELECT 1",
+		"CREATE FUNCTION f() RETURNS trigger AS $body$\nBEGIN\n\tRETURN NEW; -- inner;\nEND;\n$body$ LANGUAGE plpgsql",
+		"SELECT $$$$",
+		"SELECT E'it\\'s;', name FROM test WHERE type = 'x\\'",
+		"SELECT $$a;b$$, \"we;ird\", $1\n-- trailing comment;",
+	}, statements)
+}
+
+func Test_splitStatementsAtomicBodies(t *testing.T) {
+	script := `CREATE FUNCTION add(a integer, b integer) RETURNS integer
+LANGUAGE SQL
+BEGIN ATOMIC
+	INSERT INTO calls VALUES (CASE WHEN a > b THEN 'a;' ELSE 'b' END);
+	SELECT a + b;
+end;
+CREATE PROCEDURE p() begin atomic SELECT 1; SELECT 2; END;
+SELECT 'begin atomic';`
+
+	var statements []string
+	err := splitStatements(strings.NewReader(script), SplitRules{}, func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE FUNCTION add(a integer, b integer) RETURNS integer\nLANGUAGE SQL\nBEGIN ATOMIC\n\tINSERT INTO calls VALUES (CASE WHEN a > b THEN 'a;' ELSE 'b' END);\n\tSELECT a + b;\nend",
+		"CREATE PROCEDURE p() begin atomic SELECT 1; SELECT 2; END",
+		"SELECT 'begin atomic'",
+	}, statements)
+}
+
+func Test_splitStatementsDollarIdentifiers(t *testing.T) {
+	script := "CREATE TABLE a$b$c (id int);\nCREATE TABLE y (price$ int);\nSELECT $tag$x;y$tag$ FROM a$b$c;"
+
+	var statements []string
+	err := splitStatements(strings.NewReader(script), SplitRules{}, func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE a$b$c (id int)",
+		"CREATE TABLE y (price$ int)",
+		"SELECT $tag$x;y$tag$ FROM a$b$c",
+	}, statements)
+}
+
+func Test_splitBatches(t *testing.T) {
+	script := "CREATE TABLE test (id INT);\nINSERT INTO test VALUES (1);\nGO\n-- only a comment\n  go  \nCREATE PROCEDURE p AS\nBEGIN\n\tSELECT 1; -- go\nEND\nGO"
+	var batches []string
+	err := splitBatches(strings.NewReader(script), "GO", func(batch string) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE test (id INT);\nINSERT INTO test VALUES (1);",
+		"CREATE PROCEDURE p AS\nBEGIN\n\tSELECT 1; -- go\nEND",
+	}, batches)
+}
+
+func Test_splitStatementsMySQL(t *testing.T) {
+	script := "INSERT INTO test (name) VALUES ('it\\'s; fine'), (\"say \\\"hi;\\\"\");\n" +
+		"# comment; with semicolon\n" +
+		"SELECT `we;ird` FROM test # trailing; comment\nWHERE name = 'a\\\\';\n" +
+		"SELECT 1; # only a comment;"
+
+	var statements []string
+	err := splitStatements(strings.NewReader(script), mysqlSplitRules, func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"INSERT INTO test (name) VALUES ('it\\'s; fine'), (\"say \\\"hi;\\\"\")",
+		"# comment; with semicolon\nSELECT `we;ird` FROM test # trailing; comment\nWHERE name = 'a\\\\'",
+		"SELECT 1",
+	}, statements)
+
+	// The dialect selects the rules
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(MySQLDialect{}))
+	assert.True(t, service.nonTransactional(Migration{Script: "# add the column\nALTER TABLE test ADD COLUMN age INT;"}))
+	statements = nil
+	assert.NoError(t, service.splitScript(strings.NewReader("SELECT 'it\\'s;'; SELECT 2"), func(statement string) error {
+		statements = append(statements, statement)
+		return nil
+	}))
+	assert.Equal(t, []string{"SELECT 'it\\'s;'", "SELECT 2"}, statements)
+}
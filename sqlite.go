@@ -0,0 +1,83 @@
+package migrago
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLiteDialect is the dialect of SQLite, e.g. with the CGO free driver modernc.org/sqlite. SQLite allows a single
+// writer, so the pool of the connection should be limited to one connection, NewMigrationServiceFromDSN does so.
+type SQLiteDialect struct{}
+
+func (SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+func (SQLiteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (SQLiteDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText, ColumnLSN:
+		return "TEXT"
+	case ColumnInteger:
+		return "INTEGER"
+	case ColumnTimestamp:
+		return "DATETIME"
+	case ColumnCreatedAt:
+		return "DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	case ColumnFlag:
+		return "BOOLEAN NOT NULL DEFAULT 0"
+	case ColumnSequence:
+		// Only the rowid can be incremented automatically, the sequence is set by the changelog_sequence trigger
+		return "INTEGER"
+	default:
+		return "VARCHAR(255)"
+	}
+}
+
+// ChangelogDDL creates the changelog tables and numbers the changelog with the AUTOINCREMENT counter of an otherwise
+// empty table, so sequence numbers are never reused like those of a Postgres sequence
+func (d SQLiteDialect) ChangelogDDL() []string {
+	return append(createChangelogTables(d),
+		`CREATE TABLE IF NOT EXISTS changelog_sequence (value INTEGER PRIMARY KEY AUTOINCREMENT)`,
+		`CREATE TRIGGER IF NOT EXISTS changelog_sequence AFTER INSERT ON changelog WHEN NEW.sequence IS NULL BEGIN
+			INSERT INTO changelog_sequence (value) VALUES (NULL);
+			UPDATE changelog SET sequence = last_insert_rowid() WHERE id = NEW.id;
+			DELETE FROM changelog_sequence;
+		END`)
+}
+
+func (SQLiteDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = '%s'`, table)
+}
+
+func (SQLiteDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT LOWER(name) FROM pragma_table_info('%s')`, table)
+}
+
+func (d SQLiteDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO NOTHING`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)), columns[0])
+}
+
+func (SQLiteDialect) SessionSettingStatement() string {
+	return ""
+}
+
+// LockStatement is empty, the database is locked by the first write of a transaction until it ends
+func (SQLiteDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for PRAGMA and VACUUM. Most pragmas, e.g. foreign_keys, are no-ops inside a transaction,
+// so a migration which rebuilds a table with disabled foreign keys is executed statement by statement.
+func (SQLiteDialect) NonTransactional(statement string) bool {
+	keyword := firstKeyword(statement)
+	return keyword == "PRAGMA" || keyword == "VACUUM"
+}
+
+func (SQLiteDialect) VersionQuery() string {
+	return `SELECT sqlite_version()`
+}
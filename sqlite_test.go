@@ -0,0 +1,163 @@
+package migrago
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func Test_SQLiteDialect(t *testing.T) {
+	d := SQLiteDialect{}
+	statements := d.ChangelogDDL()
+	assert.Len(t, statements, len(changelogTables)+2)
+	assert.Contains(t, statements[0], "sequence INTEGER,")
+	assert.Equal(t, `INSERT INTO changelog_tag (name, sequence) VALUES (?, ?) ON CONFLICT (name) DO NOTHING`, d.InsertIgnore("changelog_tag", "name", "sequence"))
+	assert.Equal(t, "sqlite", driverDialect("sqlite").Name())
+	assert.Equal(t, "postgres", driverDialect("pgx").Name())
+
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(d))
+	assert.True(t, service.nonTransactional(Migration{Script: "PRAGMA foreign_keys = OFF;\nCREATE TABLE users_new (id INTEGER);"}))
+	assert.False(t, service.nonTransactional(Migration{Script: "CREATE TABLE users (id INTEGER);"}))
+}
+
+func Test_SQLite(t *testing.T) {
+	t.Run("Test migrations are applied and reverted with the SQLite dialect", func(t *testing.T) {
+		ctx := context.Background()
+		dsn := "file:" + filepath.Join(t.TempDir(), "test.db")
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INTEGER PRIMARY KEY);", RevertScript: "DROP TABLE users;"},
+			{Id: "0002_orders", Script: "CREATE TABLE orders (id INTEGER PRIMARY KEY);\nCREATE INDEX orders_id ON orders (id);", RevertScript: "DROP INDEX orders_id;\nDROP TABLE orders;"},
+		})
+		service, err := NewMigrationServiceFromDSN("sqlite", dsn, "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.Empty(t, status.Pending)
+		var sequence int
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT sequence FROM changelog WHERE id = '0002_orders'`).Scan(&sequence))
+		assert.Equal(t, 2, sequence)
+		var unfinished int
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM changelog_run WHERE finishedAt IS NULL`).Scan(&unfinished))
+		assert.Equal(t, 0, unfinished)
+
+		fs = CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INTEGER PRIMARY KEY);", RevertScript: "DROP TABLE users;"},
+		})
+		service, err = NewMigrationServiceFromDSN("sqlite", dsn, "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var exists bool
+		assert.NoError(t, service.conn.QueryRowContext(ctx, SQLiteDialect{}.TableExistsQuery("orders")).Scan(&exists))
+		assert.False(t, exists)
+	})
+	t.Run("Test a failed migration is rolled back", func(t *testing.T) {
+		ctx := context.Background()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INTEGER PRIMARY KEY);\nINSERT INTO missing VALUES (1);", RevertScript: "DROP TABLE users;"},
+		})
+		service, err := NewMigrationServiceFromDSN("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"), "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.ErrorContains(t, service.ExecuteMigration(ctx), "no such table: missing")
+		var runError string
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT error FROM changelog_run WHERE finishedAt IS NOT NULL`).Scan(&runError))
+		assert.Contains(t, runError, "no such table: missing")
+		var exists bool
+		assert.NoError(t, service.conn.QueryRowContext(ctx, SQLiteDialect{}.TableExistsQuery("users")).Scan(&exists))
+		assert.False(t, exists)
+	})
+	t.Run("Test a failed migration without transaction resumes after its last successful statement", func(t *testing.T) {
+		ctx := context.Background()
+		fs := CreateFSForMigrations([]Migration{
+			{
+				Id:           "0001_tables",
+				Script:       "-- migrago:no-transaction\nCREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);\nINSERT INTO c VALUES (1);\nCREATE TABLE d (id INTEGER);",
+				RevertScript: "DROP TABLE d; DROP TABLE b; DROP TABLE a;",
+			},
+		})
+		service, err := NewMigrationServiceFromDSN("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"), "config.json", "scripts", fs, WithStatementProgress())
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.ErrorContains(t, service.ExecuteMigration(ctx), "no such table: c")
+		var statements int
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT statements FROM changelog_progress WHERE id = '0001_tables'`).Scan(&statements))
+		assert.Equal(t, 2, statements)
+
+		// CREATE TABLE a and b would fail if they were executed again
+		_, err = service.conn.ExecContext(ctx, `CREATE TABLE c (id INTEGER)`)
+		assert.NoError(t, err)
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var remaining int
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM changelog_progress`).Scan(&remaining))
+		assert.Zero(t, remaining)
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM c`).Scan(&remaining))
+		assert.Equal(t, 1, remaining)
+	})
+	t.Run("Test renamed migrations take over the changelog entry of their previous ID", func(t *testing.T) {
+		ctx := context.Background()
+		dsn := "file:" + filepath.Join(t.TempDir(), "test.db")
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INTEGER PRIMARY KEY);", RevertScript: "DROP TABLE users;"},
+			{Id: "0002_orders", Script: "CREATE TABLE orders (id INTEGER PRIMARY KEY);", RevertScript: "DROP TABLE orders;"},
+		})
+		service, err := NewMigrationServiceFromDSN("sqlite", dsn, "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		fs = fstest.MapFS{
+			"config.json":                      {Data: []byte(`{"aliases": {"0001_users": "0001_accounts"}, "migrations": ["0001_accounts", "0002_orders"]}`)},
+			"scripts/0001_accounts.sql":        {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+			"scripts/0001_accounts.revert.sql": {Data: []byte("DROP TABLE users;")},
+			"scripts/0002_orders.sql":          {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY);")},
+			"scripts/0002_orders.revert.sql":   {Data: []byte("DROP TABLE orders;")},
+		}
+		service, err = NewMigrationServiceFromDSN("sqlite", dsn, "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		existing, err := service.getExistingMigrations(ctx)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"0001_accounts", "0002_orders"}, []string{existing[0].Id, existing[1].Id})
+
+		// an entry whose current ID is already recorded is kept
+		tx, err := service.conn.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.ExecContext(ctx, `INSERT INTO changelog (id, checksum) VALUES ('0001_users', 'previous')`)
+		assert.NoError(t, err)
+		renamed, err := service.renameId(ctx, tx, "changelog", "0001_users", "0001_accounts")
+		assert.NoError(t, err)
+		assert.False(t, renamed)
+		var checksum string
+		assert.NoError(t, tx.QueryRowContext(ctx, `SELECT checksum FROM changelog WHERE id = '0001_users'`).Scan(&checksum))
+		assert.Equal(t, "previous", checksum)
+	})
+	t.Run("Test an irreversible migration is re-applied like a pending one", func(t *testing.T) {
+		ctx := context.Background()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "-- migrago:irreversible\nPRAGMA foreign_keys = OFF;\nCREATE TABLE IF NOT EXISTS users (id INTEGER);\nINSERT INTO users VALUES (1);", RevertScript: "DROP TABLE users;"},
+		})
+		service, err := NewMigrationServiceFromDSN("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"), "config.json", "scripts", fs)
+		assert.NoError(t, err)
+		defer service.Close()
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.RerunMigration(ctx, "0001_users"))
+
+		var users, entries int
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&users))
+		assert.Equal(t, 2, users)
+		assert.NoError(t, service.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM changelog`).Scan(&entries))
+		assert.Equal(t, 1, entries)
+	})
+}
@@ -0,0 +1,89 @@
+package migrago
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sqlServerNonTransactionalPattern matches the statements SQL Server refuses inside of a transaction
+var sqlServerNonTransactionalPattern = regexp.MustCompile(`(?i)\b((CREATE|ALTER|DROP)\s+(DATABASE|FULLTEXT\s+(CATALOG|INDEX))|BACKUP|RESTORE|RECONFIGURE)\b`)
+
+// SQLServerDialect is the dialect of Microsoft SQL Server and Azure SQL, e.g. with the driver
+// github.com/microsoft/go-mssqldb. Scripts are split into batches at GO lines like sqlcmd does, schema changes are
+// part of the migration transaction.
+type SQLServerDialect struct{}
+
+func (SQLServerDialect) Name() string {
+	return "sqlserver"
+}
+
+func (SQLServerDialect) Placeholder(n int) string {
+	return fmt.Sprintf("@p%d", n)
+}
+
+func (SQLServerDialect) ColumnType(t ColumnType) string {
+	switch t {
+	case ColumnText:
+		return "NVARCHAR(MAX)"
+	case ColumnInteger:
+		return "BIGINT"
+	case ColumnTimestamp:
+		return "DATETIMEOFFSET"
+	case ColumnCreatedAt:
+		return "DATETIMEOFFSET NOT NULL DEFAULT SYSDATETIMEOFFSET()"
+	case ColumnFlag:
+		return "BIT NOT NULL DEFAULT 0"
+	case ColumnSequence:
+		return "BIGINT IDENTITY(1, 1) NOT NULL"
+	default:
+		return "NVARCHAR(255)"
+	}
+}
+
+// ChangelogDDL creates the changelog tables which do not exist, SQL Server has no CREATE TABLE IF NOT EXISTS
+func (d SQLServerDialect) ChangelogDDL() []string {
+	statements := createChangelogTables(d)
+	for i, table := range changelogTables {
+		statements[i] = strings.Replace(statements[i], "CREATE TABLE IF NOT EXISTS ",
+			fmt.Sprintf("IF OBJECT_ID(N'%s', N'U') IS NULL CREATE TABLE ", table.name), 1)
+	}
+	return statements
+}
+
+func (SQLServerDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf(`SELECT CAST(CASE WHEN OBJECT_ID(N'%s', N'U') IS NULL THEN 0 ELSE 1 END AS BIT)`, table)
+}
+
+func (SQLServerDialect) ColumnsQuery(table string) string {
+	return fmt.Sprintf(`SELECT LOWER(name) FROM sys.columns WHERE object_id = OBJECT_ID(N'%s', N'U')`, table)
+}
+
+// InsertIgnore inserts the row unless the primary key exists, the range lock keeps a concurrent insert of the same
+// key waiting until the transaction ends
+func (d SQLServerDialect) InsertIgnore(table string, columns ...string) string {
+	return fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s WHERE NOT EXISTS (SELECT 1 FROM %s WITH (UPDLOCK, HOLDLOCK) WHERE %s = %s)`,
+		table, strings.Join(columns, ", "), placeholders(d, len(columns)), table, columns[0], d.Placeholder(1))
+}
+
+// SessionSettingStatement is empty, the application name is set with "app name" in the connection string
+func (SQLServerDialect) SessionSettingStatement() string {
+	return ""
+}
+
+func (SQLServerDialect) LockStatement() string {
+	return ""
+}
+
+// NonTransactional is true for database, full-text catalog and server changes, other DDL is transactional
+func (SQLServerDialect) NonTransactional(statement string) bool {
+	return sqlServerNonTransactionalPattern.MatchString(statement)
+}
+
+func (SQLServerDialect) VersionQuery() string {
+	return `SELECT CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128))`
+}
+
+func (SQLServerDialect) BatchSeparator() string {
+	return "GO"
+}
@@ -0,0 +1,102 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	_ "github.com/microsoft/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func CreateTestSQLServerContainer(t *testing.T, ctx context.Context) (*sql.DB, error) {
+	dsn := func(host string, port nat.Port) string {
+		return fmt.Sprintf("sqlserver://sa:Migrago-Passw0rd@%s:%s?database=master", host, port.Port())
+	}
+	req := testcontainers.ContainerRequest{
+		Image:        "mcr.microsoft.com/mssql/server:2022-latest",
+		ExposedPorts: []string{"1433/tcp"},
+		Env: map[string]string{
+			"ACCEPT_EULA":       "Y",
+			"MSSQL_SA_PASSWORD": "Migrago-Passw0rd",
+		},
+		WaitingFor: wait.ForSQL(nat.Port("1433"), "sqlserver", dsn).WithStartupTimeout(2 * time.Minute),
+	}
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		container.Terminate(ctx)
+	})
+	ip, err := container.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+	port, err := container.MappedPort(ctx, "1433")
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlserver", dsn(ip, port))
+}
+
+func Test_SQLServerDialect(t *testing.T) {
+	d := SQLServerDialect{}
+	assert.True(t, strings.HasPrefix(d.ChangelogDDL()[0], "IF OBJECT_ID(N'changelog', N'U') IS NULL CREATE TABLE changelog ("))
+	assert.Equal(t, `INSERT INTO changelog_tag (name, sequence) SELECT @p1, @p2 WHERE NOT EXISTS (SELECT 1 FROM changelog_tag WITH (UPDLOCK, HOLDLOCK) WHERE name = @p1)`,
+		d.InsertIgnore("changelog_tag", "name", "sequence"))
+	assert.True(t, d.NonTransactional("ALTER DATABASE current SET READ_COMMITTED_SNAPSHOT ON"))
+	assert.False(t, d.NonTransactional("ALTER TABLE users ADD name NVARCHAR(50)"))
+
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithDialect(d))
+	query, _ := service.rebind(`DELETE FROM changelog WHERE id = $1`)
+	assert.Equal(t, `DELETE FROM changelog WHERE id = @p1`, query)
+	var batches []string
+	err := service.splitRevertScript("DROP PROCEDURE p\nGO\nDROP TABLE test", func(batch string) error {
+		batches = append(batches, batch)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"DROP PROCEDURE p", "DROP TABLE test"}, batches)
+}
+
+func Test_SQLServer(t *testing.T) {
+	t.Run("Test migrations are applied and reverted with the SQL Server dialect", func(t *testing.T) {
+		ctx := context.Background()
+		db, err := CreateTestSQLServerContainer(t, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		fs := CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INT PRIMARY KEY)\nGO\nCREATE VIEW user_ids AS SELECT id FROM users\nGO", RevertScript: "DROP VIEW user_ids\nGO\nDROP TABLE users"},
+			{Id: "0002_orders", Script: "CREATE TABLE orders (id INT PRIMARY KEY)", RevertScript: "DROP TABLE orders"},
+		})
+		service := NewMigrationService("config.json", "scripts", fs, db, WithDialect(SQLServerDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+
+		status, err := service.Status(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, status.Applied, 2)
+		assert.Empty(t, status.Pending)
+
+		fs = CreateFSForMigrations([]Migration{
+			{Id: "0001_users", Script: "CREATE TABLE users (id INT PRIMARY KEY)\nGO\nCREATE VIEW user_ids AS SELECT id FROM users\nGO", RevertScript: "DROP VIEW user_ids\nGO\nDROP TABLE users"},
+		})
+		service = NewMigrationService("config.json", "scripts", fs, db, WithDialect(SQLServerDialect{}))
+		assert.NoError(t, service.ExecuteMigration(ctx))
+		var exists bool
+		assert.NoError(t, db.QueryRowContext(ctx, SQLServerDialect{}.TableExistsQuery("orders")).Scan(&exists))
+		assert.False(t, exists)
+	})
+}
@@ -0,0 +1,64 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// resumeProgress returns the number of statements of the migration executed by an earlier, failed attempt with the
+// same checksum, a changed migration is executed from the start
+func (m MigrationService) resumeProgress(ctx context.Context, conn *sql.Conn, migration Migration) (int, error) {
+	if !m.statementProgress {
+		return 0, nil
+	}
+	var checksum string
+	var statements int
+	query, args := m.rebind(`SELECT checksum, statements FROM changelog_progress WHERE id = $1`, migration.Id)
+	err := conn.QueryRowContext(ctx, query, args...).
+		Scan(&checksum, &statements)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		insert := m.sqlDialect().InsertIgnore("changelog_progress", "id", "checksum", "statements")
+		if _, err := conn.ExecContext(ctx, insert, migration.Id, migration.Checksum, 0); err != nil {
+			return 0, fmt.Errorf("failed to insert into changelog_progress: %w", err)
+		}
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("failed to query changelog_progress: %w", err)
+	case checksum != migration.Checksum:
+		m.log().Warn("migration changed after a failed attempt, executing it from the start", "id", migration.Id)
+		return 0, m.recordProgress(ctx, conn, migration, 0)
+	}
+	if statements > 0 {
+		m.log().Info("resuming migration after a failed attempt", "id", migration.Id, "executed", statements)
+	}
+	return statements, nil
+}
+
+// recordProgress stores the number of executed statements of the migration
+func (m MigrationService) recordProgress(ctx context.Context, conn *sql.Conn, migration Migration, statements int) error {
+	if !m.statementProgress {
+		return nil
+	}
+	query, args := m.rebind(`UPDATE changelog_progress SET checksum = $1, statements = $2, updatedAt = CURRENT_TIMESTAMP WHERE id = $3`,
+		migration.Checksum, statements, migration.Id)
+	_, err := conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update changelog_progress: %w", err)
+	}
+	return nil
+}
+
+// clearProgress removes the progress of a migration once it is recorded in the changelog
+func (m MigrationService) clearProgress(ctx context.Context, tx *sql.Tx, id string) error {
+	if !m.statementProgress {
+		return nil
+	}
+	query, args := m.rebind(`DELETE FROM changelog_progress WHERE id = $1`, id)
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete from changelog_progress: %w", err)
+	}
+	return nil
+}
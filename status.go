@@ -0,0 +1,223 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MigrationStatus is the state of a single migration
+type MigrationStatus struct {
+	Id       string
+	Checksum string
+	// Description is the human-readable summary of the migration, e.g. the first comment line of its script
+	Description string
+	// InstalledAt is the time in UTC the migration was applied, zero for pending migrations
+	InstalledAt time.Time
+	// LSNBefore and LSNAfter are the WAL positions around the execution of the script, empty if unknown
+	LSNBefore string
+	LSNAfter  string
+	// Duration is the execution time of the script, zero if unknown
+	Duration time.Duration
+}
+
+// Status is the state of the database compared to the configuration
+type Status struct {
+	// Applied contains the applied migrations, newest first, archived migrations are not included
+	Applied []MigrationStatus
+	// Pending contains the migrations of the configuration which are not applied yet, in execution order
+	Pending []MigrationStatus
+	// Unknown contains applied migrations which are not in the configuration anymore
+	Unknown []MigrationStatus
+	// Jobs contains the async migrations scheduled by ExecuteMigration, they are not in Pending
+	Jobs []JobStatus
+	// Uninitialized is set if the changelog does not exist yet, all migrations are pending
+	Uninitialized bool
+}
+
+// Status compares the changelog with the configuration without executing any migration. It never writes,
+// so it works with read-only credentials: a missing changelog is reported as Uninitialized.
+func (m MigrationService) Status(ctx context.Context) (Status, error) {
+	sourceMigrations, migrations, err := m.getMigrations()
+	if err != nil {
+		return Status{}, err
+	}
+	state, err := m.readChangelogState(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	if !state.changelog {
+		status := Status{Uninitialized: true}
+		for _, migrations := range sourceMigrations {
+			for _, migration := range migrations {
+				if migration.Metadata.SupersededBy == "" {
+					status.Pending = append(status.Pending, pendingStatus(migration))
+				}
+			}
+		}
+		return status, nil
+	}
+	existingMigrations, err := m.readExistingMigrations(ctx, state)
+	if err != nil {
+		return Status{}, err
+	}
+	// Renamed migrations are only renamed in the changelog by the next run
+	aliases, err := m.aliases()
+	if err != nil {
+		return Status{}, err
+	}
+	if err := checkAliases(aliases, migrations); err != nil {
+		return Status{}, err
+	}
+	existingMigrations = applyAliases(existingMigrations, aliases)
+
+	var status Status
+	for _, migration := range existingMigrations {
+		s := appliedStatus(migration)
+		if configured, ok := migrations[migration.Id]; ok {
+			// Migrations applied by older versions have no description in the changelog
+			if s.Description == "" {
+				s.Description = configured.Metadata.Description
+			}
+			status.Applied = append(status.Applied, s)
+		} else {
+			status.Unknown = append(status.Unknown, s)
+		}
+	}
+	pending := unappliedMigrations(sourceMigrations, existingMigrations)
+	if state.archive {
+		archived, err := m.getArchivedIds(ctx, pending)
+		if err != nil {
+			return Status{}, err
+		}
+		pending = slices.DeleteFunc(pending, func(migration Migration) bool { return archived[migration.Id] })
+	}
+	// Deprecated migrations are never applied anymore
+	pending = slices.DeleteFunc(pending, func(migration Migration) bool { return migration.Metadata.SupersededBy != "" })
+	if state.jobs {
+		if status.Jobs, err = m.getJobs(ctx); err != nil {
+			return Status{}, err
+		}
+	}
+	scheduled := make(map[string]bool, len(status.Jobs))
+	for _, job := range status.Jobs {
+		scheduled[job.Id] = true
+	}
+	for _, migration := range pending {
+		if !scheduled[migration.Id] {
+			status.Pending = append(status.Pending, pendingStatus(migration))
+		}
+	}
+	return status, nil
+}
+
+// pendingStatus returns the status of a migration which is not applied yet
+func pendingStatus(migration Migration) MigrationStatus {
+	return MigrationStatus{Id: migration.Id, Checksum: migration.Checksum, Description: migration.Metadata.Description}
+}
+
+// Migrations returns the configured migrations of all sources in execution order without accessing the database,
+// the Script of streamed migrations is empty
+func (m MigrationService) Migrations() ([]Migration, error) {
+	sourceMigrations, _, err := m.getMigrations()
+	if err != nil {
+		return nil, err
+	}
+	var migrations []Migration
+	for _, current := range sourceMigrations {
+		migrations = append(migrations, current...)
+	}
+	return migrations, nil
+}
+
+// AppliedMigrations returns the changelog entries newest first, including their recorded revert scripts.
+// Like Status it never writes, without changelog no migration is applied.
+func (m MigrationService) AppliedMigrations(ctx context.Context) ([]Migration, error) {
+	state, err := m.readChangelogState(ctx)
+	if err != nil || !state.changelog {
+		return nil, err
+	}
+	existingMigrations, err := m.readExistingMigrations(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := m.aliases()
+	if err != nil {
+		return nil, err
+	}
+	existingMigrations = applyAliases(existingMigrations, aliases)
+	for i, migration := range existingMigrations {
+		if existingMigrations[i], err = m.resolveRevertScript(ctx, migration); err != nil {
+			return nil, err
+		}
+	}
+	return existingMigrations, nil
+}
+
+// changelogState describes which changelog tables exist, read-only operations never create or upgrade them
+type changelogState struct {
+	changelog bool
+	// upgraded is set if the changelog has the columns of the current version
+	upgraded bool
+	archive  bool
+	jobs     bool
+}
+
+// readChangelogState checks which changelog tables exist without creating them
+func (m MigrationService) readChangelogState(ctx context.Context) (changelogState, error) {
+	var state changelogState
+	d := m.sqlDialect()
+	for table, exists := range map[string]*bool{"changelog": &state.changelog, "changelog_archive": &state.archive, "changelog_job": &state.jobs} {
+		if err := m.conn.QueryRowContext(ctx, d.TableExistsQuery(table)).Scan(exists); err != nil {
+			return changelogState{}, fmt.Errorf("failed to query %s: %w", table, err)
+		}
+	}
+	if !state.changelog {
+		return state, nil
+	}
+	rows, err := m.conn.QueryContext(ctx, d.ColumnsQuery("changelog"))
+	if err != nil {
+		return changelogState{}, fmt.Errorf("failed to query changelog: %w", err)
+	}
+	defer rows.Close()
+	missing := []string{"irreversible", "sequence", "lsnbefore", "lsnafter", "durationms", "description"}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return changelogState{}, err
+		}
+		missing = slices.DeleteFunc(missing, func(name string) bool { return name == column })
+	}
+	state.upgraded = len(missing) == 0
+	return state, rows.Err()
+}
+
+// readExistingMigrations reads the changelog, changelogs of older versions are read with the columns of the first release
+func (m MigrationService) readExistingMigrations(ctx context.Context, state changelogState) ([]Migration, error) {
+	if state.upgraded {
+		return m.getExistingMigrations(ctx)
+	}
+	rows, err := m.conn.QueryContext(ctx, `SELECT id, checksum, installedAt, revertscript FROM changelog ORDER BY installedAt DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var existingMigrations []Migration
+	for rows.Next() {
+		var dbMigration Migration
+		var revertScript sql.NullString
+		if err := rows.Scan(&dbMigration.Id, &dbMigration.Checksum, &dbMigration.InstalledAt, &revertScript); err != nil {
+			return nil, err
+		}
+		dbMigration.InstalledAt = dbMigration.InstalledAt.UTC()
+		if dbMigration.RevertScript, err = m.decodeChangelogRevertScript(ctx, revertScript.String); err != nil {
+			return nil, fmt.Errorf("migration %s: %w", dbMigration.Id, err)
+		}
+		dbMigration.NoRevertScript = !revertScript.Valid
+		existingMigrations = append(existingMigrations, dbMigration)
+	}
+	return existingMigrations, rows.Err()
+}
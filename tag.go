@@ -0,0 +1,99 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Tag records a named checkpoint after the migrations applied so far, e.g. the release of a deploy.
+// Tag names are unique.
+func (m MigrationService) Tag(ctx context.Context, name string) error {
+	if name == "" {
+		return errors.New("tag name must not be empty")
+	}
+	if err := m.prepareDatabase(ctx); err != nil {
+		return err
+	}
+	var sequence int64
+	if err := m.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(sequence), 0) FROM changelog`).Scan(&sequence); err != nil {
+		return fmt.Errorf("failed to query changelog: %w", err)
+	}
+	inserted, err := m.insertIgnore(ctx, m.conn, "changelog_tag", []string{"name", "sequence"}, name, sequence)
+	if err != nil {
+		return fmt.Errorf("failed to insert into changelog_tag: %w", err)
+	}
+	if !inserted {
+		return fmt.Errorf("tag %s already exists", name)
+	}
+	m.log().Info("changelog tagged", "tag", name)
+	return nil
+}
+
+// RollbackToTag reverts all migrations applied after the tag, newest first, and removes the tags recorded after them.
+// The reverted migrations are applied again by the next run if they are still in the configuration.
+func (m MigrationService) RollbackToTag(ctx context.Context, name string) error {
+	if err := m.prepareDatabase(ctx); err != nil {
+		return err
+	}
+	var sequence int64
+	query, args := m.rebind(`SELECT sequence FROM changelog_tag WHERE name = $1`, name)
+	err := m.conn.QueryRowContext(ctx, query, args...).Scan(&sequence)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("unknown tag %s", name)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query changelog_tag: %w", err)
+	}
+
+	after, err := m.appliedAfter(ctx, sequence)
+	if err != nil {
+		return err
+	}
+	existingMigrations, err := m.getExistingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	// The existing migrations are ordered newest first, which is the revert order
+	reverts := slices.DeleteFunc(existingMigrations, func(migration Migration) bool { return !after[migration.Id] })
+
+	// Fail before anything is reverted if one of the reverts is impossible
+	for _, migration := range reverts {
+		if err := m.checkRevertable(migration); err != nil {
+			return err
+		}
+	}
+	for _, migration := range reverts {
+		if err := m.revertInPhase(ctx, migration); err != nil {
+			return err
+		}
+	}
+
+	query, args = m.rebind(`DELETE FROM changelog_tag WHERE sequence > $1`, sequence)
+	if _, err := m.conn.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to delete from changelog_tag: %w", err)
+	}
+	m.log().Info("rolled back to tag", "tag", name, "reverted", len(reverts))
+	return nil
+}
+
+// appliedAfter returns the IDs of the migrations applied after the changelog sequence
+func (m MigrationService) appliedAfter(ctx context.Context, sequence int64) (map[string]bool, error) {
+	query, args := m.rebind(`SELECT id FROM changelog WHERE sequence > $1`, sequence)
+	rows, err := m.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changelog: %w", err)
+	}
+	defer rows.Close()
+	after := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		after[id] = true
+	}
+	return after, rows.Err()
+}
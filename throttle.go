@@ -0,0 +1,88 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ThrottleProbe decides before every backfill batch how long the backfill has to wait,
+// e.g. because the replication lag or the load of the database is too high
+type ThrottleProbe interface {
+	// Delay returns the time to wait before the probe is asked again, zero to continue immediately
+	Delay(ctx context.Context) (time.Duration, error)
+}
+
+// ThrottleProbeFunc adapts a function to a ThrottleProbe
+type ThrottleProbeFunc func(ctx context.Context) (time.Duration, error)
+
+// Delay calls the function
+func (f ThrottleProbeFunc) Delay(ctx context.Context) (time.Duration, error) {
+	return f(ctx)
+}
+
+// replicationLagProbe delays backfills while a replica of the primary lags behind
+type replicationLagProbe struct {
+	conn   *sql.DB
+	maxLag time.Duration
+}
+
+// NewReplicationLagProbe creates a ThrottleProbe which pauses backfills while the replay lag of any
+// replica (from pg_stat_replication on the primary) exceeds maxLag
+func NewReplicationLagProbe(conn *sql.DB, maxLag time.Duration) ThrottleProbe {
+	return replicationLagProbe{conn: conn, maxLag: maxLag}
+}
+
+// Delay returns the current lag if it exceeds the maximum lag
+func (p replicationLagProbe) Delay(ctx context.Context) (time.Duration, error) {
+	var seconds float64
+	err := p.conn.QueryRowContext(ctx, `SELECT COALESCE(EXTRACT(EPOCH FROM max(replay_lag)), 0) FROM pg_stat_replication`).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query the replication lag: %w", err)
+	}
+	lag := time.Duration(seconds * float64(time.Second))
+	if lag <= p.maxLag {
+		return 0, nil
+	}
+	return lag, nil
+}
+
+// throttle waits before the next batch of a backfill: the fixed sleep of the backfill, then as long
+// as the throttle probe asks for it
+func (m MigrationService) throttle(ctx context.Context, migration Migration) error {
+	if err := sleep(ctx, migration.Metadata.Backfill.Sleep); err != nil {
+		return err
+	}
+	if m.throttleProbe == nil {
+		return nil
+	}
+	for {
+		delay, err := m.throttleProbe.Delay(ctx)
+		if err != nil {
+			return err
+		}
+		if delay <= 0 {
+			return nil
+		}
+		m.log().InfoContext(ctx, "backfill throttled", "id", migration.Id, "delay", delay)
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for the duration or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,30 @@
+package migrago
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_throttle(t *testing.T) {
+	var calls int
+	probe := ThrottleProbeFunc(func(context.Context) (time.Duration, error) {
+		calls++
+		if calls < 3 {
+			return time.Millisecond, nil
+		}
+		return 0, nil
+	})
+	service := NewMigrationService("config.json", "scripts", nil, nil, WithThrottleProbe(probe))
+	migration := Migration{Id: "Backfill", Metadata: Metadata{Backfill: &Backfill{Table: "test", Key: "id", Sleep: time.Millisecond}}}
+
+	assert.NoError(t, service.throttle(context.Background(), migration))
+	assert.Equal(t, 3, calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	migration.Metadata.Backfill.Sleep = time.Hour
+	assert.ErrorIs(t, service.throttle(ctx, migration), context.Canceled)
+}
@@ -0,0 +1,86 @@
+package migrago
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// TLSMode defines how the server certificate is verified
+type TLSMode string
+
+const (
+	// TLSDisable connects without TLS
+	TLSDisable TLSMode = "disable"
+	// TLSRequire uses TLS without verifying the server certificate
+	TLSRequire TLSMode = "require"
+	// TLSVerifyCA verifies the server certificate against the CA bundle
+	TLSVerifyCA TLSMode = "verify-ca"
+	// TLSVerifyFull verifies the server certificate and the host name
+	TLSVerifyFull TLSMode = "verify-full"
+)
+
+// TLSConfig is the TLS configuration of a connection opened by NewMigrationServiceFromDSN
+type TLSConfig struct {
+	Mode TLSMode
+	// CAFile is the path of the PEM encoded CA bundle used to verify the server certificate
+	CAFile string
+	// CertFile and KeyFile are the paths of the PEM encoded client certificate and key
+	CertFile string
+	KeyFile  string
+}
+
+// params returns the connection parameters of the configuration in the order they are applied
+func (c TLSConfig) params() [][2]string {
+	var params [][2]string
+	if c.Mode != "" {
+		params = append(params, [2]string{"sslmode", string(c.Mode)})
+	}
+	if c.CAFile != "" {
+		params = append(params, [2]string{"sslrootcert", c.CAFile})
+	}
+	if c.CertFile != "" {
+		params = append(params, [2]string{"sslcert", c.CertFile})
+	}
+	if c.KeyFile != "" {
+		params = append(params, [2]string{"sslkey", c.KeyFile})
+	}
+	return params
+}
+
+// applyTLS adds the TLS configuration to the DSN, parameters already in the DSN are overridden
+func applyTLS(driverName, dsn string, config TLSConfig) (string, error) {
+	if driverName != "postgres" {
+		return "", fmt.Errorf("TLS options are not supported for driver %s, configure TLS in the DSN", driverName)
+	}
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return "", fmt.Errorf("TLS client certificate and key have to be set together")
+	}
+	return setDSNParams(dsn, config.params())
+}
+
+// setDSNParams sets connection parameters of a Postgres DSN, parameters already in the DSN are overridden
+func setDSNParams(dsn string, params [][2]string) (string, error) {
+	// URL form: postgres://user@host/db?sslmode=...
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DSN: %w", err)
+		}
+		query := u.Query()
+		for _, param := range params {
+			query.Set(param[0], param[1])
+		}
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	// Key/value form: host=... dbname=..., later parameters override earlier ones
+	var b strings.Builder
+	b.WriteString(dsn)
+	for _, param := range params {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(param[1])
+		fmt.Fprintf(&b, " %s='%s'", param[0], value)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
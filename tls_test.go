@@ -0,0 +1,25 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyTLS(t *testing.T) {
+	config := TLSConfig{Mode: TLSVerifyFull, CAFile: "/etc/ssl/ca.pem", CertFile: "/etc/ssl/client.pem", KeyFile: "/etc/ssl/client.key"}
+
+	dsn, err := applyTLS("postgres", "postgres://user@localhost/test?sslmode=disable", config)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://user@localhost/test?sslcert=%2Fetc%2Fssl%2Fclient.pem&sslkey=%2Fetc%2Fssl%2Fclient.key&sslmode=verify-full&sslrootcert=%2Fetc%2Fssl%2Fca.pem", dsn)
+
+	dsn, err = applyTLS("postgres", "host=localhost dbname=test", TLSConfig{Mode: TLSVerifyCA, CAFile: "/etc/ssl/it's.pem"})
+	assert.NoError(t, err)
+	assert.Equal(t, `host=localhost dbname=test sslmode='verify-ca' sslrootcert='/etc/ssl/it\'s.pem'`, dsn)
+
+	_, err = applyTLS("postgres", "host=localhost", TLSConfig{CertFile: "/etc/ssl/client.pem"})
+	assert.ErrorContains(t, err, "certificate and key")
+
+	_, err = applyTLS("mysql", "user@tcp(localhost)/test", config)
+	assert.ErrorContains(t, err, "not supported for driver mysql")
+}
@@ -0,0 +1,70 @@
+package migrago
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// tokenRefreshMargin is how long before its expiry a cached token is replaced, so a connection is never opened with a
+// token which expires during the login
+const tokenRefreshMargin = 5 * time.Minute
+
+// TokenSource provides short-lived access tokens which are used as password, e.g. Azure AD tokens of a managed
+// identity from the azure package
+type TokenSource interface {
+	Token(ctx context.Context) (token string, expiry time.Time, err error)
+}
+
+// cachedTokenSource reuses a token until shortly before its expiry
+type cachedTokenSource struct {
+	source TokenSource
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (c *cachedTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Until(c.expiry) > tokenRefreshMargin {
+		return c.token, c.expiry, nil
+	}
+	token, expiry, err := c.source.Token(ctx)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get access token: %w", err)
+	}
+	c.token, c.expiry = token, expiry
+	return token, expiry, nil
+}
+
+// tokenConnector opens every connection with the current token as password. The server only checks the token at
+// the login, so established connections outlive it and reconnects of long runs use a refreshed token.
+type tokenConnector struct {
+	dsn    string
+	tokens *cachedTokenSource
+}
+
+func (c *tokenConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	token, _, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := setDSNParams(c.dsn, [][2]string{{"password", token}})
+	if err != nil {
+		return nil, err
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c *tokenConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
@@ -0,0 +1,49 @@
+package migrago
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTokenSource returns a new token on every call
+type countingTokenSource struct {
+	calls  int
+	expiry time.Duration
+}
+
+func (s *countingTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	s.calls++
+	return "token", time.Now().Add(s.expiry), nil
+}
+
+func Test_cachedTokenSource(t *testing.T) {
+	source := &countingTokenSource{expiry: time.Hour}
+	cached := &cachedTokenSource{source: source}
+	for range 3 {
+		token, _, err := cached.Token(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "token", token)
+	}
+	assert.Equal(t, 1, source.calls)
+
+	// Tokens about to expire are refreshed
+	source = &countingTokenSource{expiry: time.Minute}
+	cached = &cachedTokenSource{source: source}
+	cached.Token(context.Background())
+	cached.Token(context.Background())
+	assert.Equal(t, 2, source.calls)
+}
+
+func Test_NewMigrationServiceFromDSNTokenAuth(t *testing.T) {
+	service, err := NewMigrationServiceFromDSN("postgres", "postgres://user@localhost/test", "config.json", "scripts", nil,
+		WithTokenAuth(&countingTokenSource{expiry: time.Hour}))
+	assert.NoError(t, err)
+	assert.NoError(t, service.Close())
+
+	_, err = NewMigrationServiceFromDSN("mysql", "user@tcp(localhost)/test", "config.json", "scripts", nil,
+		WithTokenAuth(&countingTokenSource{expiry: time.Hour}))
+	assert.ErrorContains(t, err, "not supported for driver mysql")
+}
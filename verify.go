@@ -0,0 +1,148 @@
+package migrago
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ChecksumsFile is the default name of the file with the checksums of the committed migrations, it is stored next to
+// the config file and committed together with the scripts
+const ChecksumsFile = "migrago.sum"
+
+// Checksums maps migration IDs to the checksums of their scripts, e.g. of the scripts committed to version control
+type Checksums map[string]string
+
+// ReadChecksums reads checksums in the format "<id> <checksum>", one migration per line
+func ReadChecksums(r io.Reader) (Checksums, error) {
+	checksums := make(Checksums)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid checksum line %d: %q", line, text)
+		}
+		checksums[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+	return checksums, nil
+}
+
+// WriteChecksums writes the checksums of the migrations in execution order in the format of ReadChecksums
+func WriteChecksums(w io.Writer, migrations []Migration) error {
+	for _, migration := range migrations {
+		if _, err := fmt.Fprintf(w, "%s %s\n", migration.Id, migration.Checksum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyLocal checks the migrations without a database, e.g. in a pre-commit hook: the config files, the scripts,
+// the directives and the naming policy are checked by loading all migrations, in addition scripts which are not
+// referenced by a config and migrations which were modified or removed since their checksums were committed
+// are reported. All problems are reported at once as VerifyError.
+func (m MigrationService) VerifyLocal(committed Checksums) error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return &VerifyError{Problems: []string{err.Error()}}
+	}
+
+	var problems []string
+	configured := make(map[string]bool, len(migrations))
+	for _, migration := range migrations {
+		configured[migration.Id] = true
+		if checksum, ok := committed[migration.Id]; ok && checksum != migration.Checksum {
+			problems = append(problems, fmt.Sprintf("migration %s was modified after it was committed (checksum %s, committed %s)", migration.Id, migration.Checksum, checksum))
+		}
+	}
+	aliases, err := m.aliases()
+	if err != nil {
+		return &VerifyError{Problems: []string{err.Error()}}
+	}
+	var removed []string
+	for id := range committed {
+		// Renamed migrations are not removed
+		if _, renamed := aliases[id]; !configured[id] && !renamed {
+			removed = append(removed, id)
+		}
+	}
+	slices.Sort(removed)
+	for _, id := range removed {
+		problems = append(problems, fmt.Sprintf("committed migration %s was removed from the configuration", id))
+	}
+
+	for _, s := range m.sources {
+		fileSource, ok := s.source.(FileSource)
+		if !ok {
+			continue
+		}
+		unreferenced, err := fileSource.unreferencedScripts()
+		if err != nil {
+			return err
+		}
+		for _, script := range unreferenced {
+			problems = append(problems, fmt.Sprintf("script %s is not referenced by the config", script))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &VerifyError{Problems: problems}
+	}
+	return nil
+}
+
+// unreferencedScripts returns the scripts in the script directories which do not belong to a configured migration
+func (s FileSource) unreferencedScripts() ([]string, error) {
+	config, err := s.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	for _, id := range config.Migrations {
+		referenced[id] = true
+	}
+	for _, release := range config.Releases {
+		for _, id := range release.Migrations {
+			referenced[id] = true
+		}
+	}
+
+	var unreferenced []string
+	dirs := []string{s.scriptPath}
+	for _, dir := range config.Directories {
+		dirs = append(dirs, path.Join(s.scriptPath, filepath.ToSlash(dir)))
+	}
+	for _, dir := range dirs {
+		entries, err := fs.ReadDir(s.fs, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read script directory: %w", err)
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".sql")
+			if entry.IsDir() || !ok {
+				continue
+			}
+			// <id>.sql, <id>.revert.sql, <id>.<dialect>.sql and <id>.<dialect>.revert.sql
+			id := strings.TrimSuffix(name, ".revert")
+			if i := strings.LastIndex(id, "."); !referenced[id] && i >= 0 {
+				id = id[:i]
+			}
+			if !referenced[id] {
+				unreferenced = append(unreferenced, path.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return unreferenced, nil
+}
@@ -0,0 +1,61 @@
+package migrago
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Checksums(t *testing.T) {
+	var sums strings.Builder
+	assert.NoError(t, WriteChecksums(&sums, []Migration{{Id: "0001_init", Checksum: "a"}, {Id: "0002_users", Checksum: "b"}}))
+	assert.Equal(t, "0001_init a\n0002_users b\n", sums.String())
+
+	checksums, err := ReadChecksums(strings.NewReader(sums.String() + "\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Checksums{"0001_init": "a", "0002_users": "b"}, checksums)
+
+	_, err = ReadChecksums(strings.NewReader("0001_init a\n0002_users\n"))
+	assert.EqualError(t, err, `invalid checksum line 2: "0002_users"`)
+}
+
+func Test_VerifyLocal(t *testing.T) {
+	fs := fstest.MapFS{
+		"config.json":                            {Data: []byte(`{"directories": ["users"], "migrations": ["0001_init", "0002_users", "0003_teams"]}`)},
+		"scripts/0001_init.sql":                  {Data: []byte("CREATE SCHEMA app")},
+		"scripts/0001_init.revert.sql":           {Data: []byte("DROP SCHEMA app")},
+		"scripts/users/0002_users.sql":           {Data: []byte("CREATE TABLE users ()")},
+		"scripts/users/0002_users.revert.sql":    {Data: []byte("DROP TABLE users")},
+		"scripts/0003_teams.postgres.sql":        {Data: []byte("CREATE TABLE teams ()")},
+		"scripts/0003_teams.postgres.revert.sql": {Data: []byte("DROP TABLE teams")},
+		"scripts/0003_teams.mysql.sql":           {Data: []byte("CREATE TABLE teams ()")},
+		"scripts/0004_draft.sql":                 {Data: []byte("SELECT 1")},
+		"scripts/users/README.md":                {Data: []byte("users")},
+	}
+	service := NewMigrationService("config.json", "scripts", fs, nil)
+	migrations, err := service.Migrations()
+	assert.NoError(t, err)
+	committed := Checksums{}
+	for _, migration := range migrations {
+		committed[migration.Id] = migration.Checksum
+	}
+	committed["0000_removed"] = "c"
+	committed["0001_init"] = "d"
+
+	err = service.VerifyLocal(committed)
+	var verifyErr *VerifyError
+	assert.ErrorAs(t, err, &verifyErr)
+	assert.Equal(t, []string{
+		"migration 0001_init was modified after it was committed (checksum " + migrations[0].Checksum + ", committed d)",
+		"committed migration 0000_removed was removed from the configuration",
+		"script scripts/0004_draft.sql is not referenced by the config",
+	}, verifyErr.Problems)
+
+	delete(fs, "scripts/0004_draft.sql")
+	assert.NoError(t, service.VerifyLocal(nil))
+
+	service = NewMigrationService("config.json", "scripts", fs, nil, WithIDPolicy(IDPolicyTimestamp))
+	assert.ErrorContains(t, service.VerifyLocal(nil), "do not match the naming policy")
+}
@@ -0,0 +1,145 @@
+package migrago
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// versionRequirementPattern matches a "-- migrago:requires postgres>=15" requirement
+var versionRequirementPattern = regexp.MustCompile(`^([a-z]+)(>=|<=|>|<|=)(\d+(?:\.\d+){0,2})$`)
+
+// VersionPolicy decides what happens to migrations whose server version requirement is not met
+type VersionPolicy int
+
+const (
+	// VersionFail fails the run, it is the default
+	VersionFail VersionPolicy = iota
+	// VersionSkip leaves the migration pending, it is executed once the server is upgraded
+	VersionSkip
+)
+
+// versionRequirement is a server version constraint of a migration
+type versionRequirement struct {
+	dialect  string
+	operator string
+	version  int
+}
+
+// parseVersionRequirement parses a requirement like postgres>=15 or postgres<14.5
+func parseVersionRequirement(requirement string) (versionRequirement, error) {
+	match := versionRequirementPattern.FindStringSubmatch(strings.ReplaceAll(requirement, " ", ""))
+	if match == nil {
+		return versionRequirement{}, fmt.Errorf("invalid version requirement %q, expected e.g. postgres>=15", requirement)
+	}
+	version, err := versionNum(match[3])
+	if err != nil {
+		return versionRequirement{}, err
+	}
+	return versionRequirement{dialect: match[1], operator: match[2], version: version}, nil
+}
+
+// versionNum converts a version to the format of server_version_num, e.g. 15 to 150000, 14.5 to 140005 and 9.6 to 90600
+func versionNum(version string) (int, error) {
+	parts := make([]int, 3)
+	for i, part := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid version %q", version)
+		}
+		parts[i] = n
+	}
+	if parts[0] >= 10 {
+		// Since Postgres 10 the second part is the minor version
+		return parts[0]*10000 + parts[1], nil
+	}
+	return parts[0]*10000 + parts[1]*100 + parts[2], nil
+}
+
+// satisfiedBy checks the requirement against a server version in the format of server_version_num
+func (r versionRequirement) satisfiedBy(server int) bool {
+	switch r.operator {
+	case ">=":
+		return server >= r.version
+	case ">":
+		return server > r.version
+	case "<=":
+		return server <= r.version
+	case "<":
+		return server < r.version
+	default:
+		return server == r.version
+	}
+}
+
+// validateRequires checks the syntax of the version requirements of a migration
+func (mig Migration) validateRequires() error {
+	for _, requirement := range mig.Metadata.Requires {
+		if _, err := parseVersionRequirement(requirement); err != nil {
+			return fmt.Errorf("migration %s: %w", mig.Id, err)
+		}
+	}
+	return nil
+}
+
+// unmetRequirement returns the first requirement of the dialect the server version does not satisfy
+func (m MigrationService) unmetRequirement(migration Migration, server int) string {
+	for _, requirement := range migration.Metadata.Requires {
+		parsed, err := parseVersionRequirement(requirement)
+		if err != nil || parsed.dialect != m.dialectName() {
+			continue
+		}
+		if !parsed.satisfiedBy(server) {
+			return requirement
+		}
+	}
+	return ""
+}
+
+// serverVersionPattern matches the version at the start of the version text of the server
+var serverVersionPattern = regexp.MustCompile(`^\d+(?:\.\d+){0,2}`)
+
+// serverVersion returns the version of the server in the format of server_version_num
+func (m MigrationService) serverVersion(ctx context.Context) (int, error) {
+	var text string
+	if err := m.conn.QueryRowContext(ctx, m.sqlDialect().VersionQuery()).Scan(&text); err != nil {
+		return 0, fmt.Errorf("failed to query server version: %w", err)
+	}
+	version := serverVersionPattern.FindString(strings.TrimSpace(text))
+	if version == "" {
+		return 0, fmt.Errorf("failed to parse server version %q", text)
+	}
+	return versionNum(version)
+}
+
+// filterVersionRequirements removes the pending migrations whose version requirements are not met if the
+// policy is VersionSkip, otherwise it fails
+func (m MigrationService) filterVersionRequirements(ctx context.Context, pending []Migration) ([]Migration, error) {
+	if !slices.ContainsFunc(pending, func(migration Migration) bool { return len(migration.Metadata.Requires) > 0 }) {
+		return pending, nil
+	}
+	server, err := m.serverVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var unmet []string
+	pending = slices.DeleteFunc(pending, func(migration Migration) bool {
+		requirement := m.unmetRequirement(migration, server)
+		if requirement == "" {
+			return false
+		}
+		if m.versionPolicy == VersionSkip {
+			m.log().Warn("pending migration skipped, server version requirement not met", "id", migration.Id, "requires", requirement, "server", server)
+			return true
+		}
+		unmet = append(unmet, fmt.Sprintf("%s requires %s", migration.Id, requirement))
+		return false
+	})
+	if len(unmet) > 0 {
+		return nil, fmt.Errorf("server version %d does not meet the requirements: %s", server, strings.Join(unmet, ", "))
+	}
+	return pending, nil
+}
@@ -0,0 +1,38 @@
+package migrago
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_versionNum(t *testing.T) {
+	for version, expected := range map[string]int{"15": 150000, "14.5": 140005, "9.6": 90600, "9.6.3": 90603, "8.0.35": 80035} {
+		n, err := versionNum(version)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, n, version)
+	}
+}
+
+func Test_parseVersionRequirement(t *testing.T) {
+	requirement, err := parseVersionRequirement("postgres >= 15")
+	assert.NoError(t, err)
+	assert.Equal(t, versionRequirement{dialect: "postgres", operator: ">=", version: 150000}, requirement)
+	assert.True(t, requirement.satisfiedBy(150004))
+	assert.False(t, requirement.satisfiedBy(140011))
+
+	requirement, err = parseVersionRequirement("postgres<14.5")
+	assert.NoError(t, err)
+	assert.True(t, requirement.satisfiedBy(140004))
+	assert.False(t, requirement.satisfiedBy(140005))
+
+	_, err = parseVersionRequirement("postgres~15")
+	assert.Error(t, err)
+}
+
+func Test_unmetRequirement(t *testing.T) {
+	service := MigrationService{}
+	migration := Migration{Id: "Test", Metadata: Metadata{Requires: []string{"mysql>=8", "postgres>=15"}}}
+	assert.Equal(t, "postgres>=15", service.unmetRequirement(migration, 140000))
+	assert.Equal(t, "", service.unmetRequirement(migration, 160000))
+}
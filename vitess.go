@@ -0,0 +1,178 @@
+package migrago
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// foreignKeyPattern finds foreign keys, which Vitess online DDL does not support
+var foreignKeyPattern = regexp.MustCompile(`(?i)\b(FOREIGN\s+KEY|REFERENCES)\b`)
+
+// VitessOnlineDDL configures the Vitess/PlanetScale mode: DDL statements are submitted as online DDL
+// and the changelog row is only recorded after all DDL workflows of a migration are complete
+type VitessOnlineDDL struct {
+	// Strategy is the ddl_strategy of the session, default is "vitess"
+	Strategy string
+	// PollInterval is the interval the status of the DDL workflows is checked, default is 5 seconds
+	PollInterval time.Duration
+}
+
+// strategy returns the configured strategy or the default
+func (v VitessOnlineDDL) strategy() string {
+	if v.Strategy != "" {
+		return v.Strategy
+	}
+	return "vitess"
+}
+
+// pollInterval returns the configured poll interval or the default
+func (v VitessOnlineDDL) pollInterval() time.Duration {
+	if v.PollInterval > 0 {
+		return v.PollInterval
+	}
+	return 5 * time.Second
+}
+
+// checkNoForeignKeys rejects migrations defining foreign keys, Vitess does not support them
+func (m MigrationService) checkNoForeignKeys(migration Migration) error {
+	return m.scriptStatements(migration, func(statement string) error {
+		if foreignKeyPattern.MatchString(stripComments(statement, true)) {
+			return fmt.Errorf("migration %s defines a foreign key, which is not supported by Vitess", migration.Id)
+		}
+		return nil
+	})
+}
+
+// isDDL checks if a statement changes the schema and has to be submitted as online DDL
+func isDDL(statement string) bool {
+	keyword, _, _ := strings.Cut(stripComments(statement, true), " ")
+	switch strings.ToUpper(keyword) {
+	case "CREATE", "ALTER", "DROP":
+		return true
+	}
+	return false
+}
+
+// executeVitessMigration submits the DDL statements of a migration as online DDL, waits until all workflows are
+// complete and records the migration in the changelog. Online DDL is not transactional, other statements are
+// executed on the same session.
+func (m MigrationService) executeVitessMigration(ctx context.Context, migration Migration) error {
+	start := time.Now()
+	conn, err := m.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`SET @@ddl_strategy = '%s'`, strings.ReplaceAll(m.vitess.strategy(), "'", "''"))); err != nil {
+		return fmt.Errorf("failed to set ddl_strategy: %w", err)
+	}
+
+	var uuids []string
+	submit := m.intercept(func(ctx context.Context, statement Statement) (sql.Result, error) {
+		var uuid string
+		if err := conn.QueryRowContext(ctx, statement.SQL).Scan(&uuid); err != nil {
+			return nil, err
+		}
+		m.log().InfoContext(ctx, "online DDL submitted", "id", migration.Id, "uuid", uuid)
+		uuids = append(uuids, uuid)
+		return nil, nil
+	})
+	err = m.scriptStatements(migration, func(statement string) error {
+		if !isDDL(statement) {
+			if _, err := m.execStatement(ctx, conn, Statement{MigrationId: migration.Id, SQL: statement}); err != nil {
+				return fmt.Errorf("failed to execute migration script: %w", err)
+			}
+			return nil
+		}
+		if _, err := submit(ctx, Statement{MigrationId: migration.Id, SQL: statement}); err != nil {
+			return fmt.Errorf("failed to submit online DDL: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, uuid := range uuids {
+		if err := m.waitForOnlineDDL(ctx, conn, uuid); err != nil {
+			return fmt.Errorf("online DDL of migration %s: %w", migration.Id, err)
+		}
+	}
+
+	// The session is released first, the pool may have a single connection
+	conn.Close()
+
+	migration.Duration = time.Since(start)
+	m.checkSlow(ctx, migration)
+	tx, err := m.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	// Online DDL is not transactional, a violated assertion leaves the migration unrecorded
+	if err := checkAssertions(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := m.insertChangelog(ctx, tx, migration); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// waitForOnlineDDL polls the status of an online DDL workflow until it is complete
+func (m MigrationService) waitForOnlineDDL(ctx context.Context, conn *sql.Conn, uuid string) error {
+	for {
+		status, err := onlineDDLStatus(ctx, conn, uuid)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case "complete":
+			return nil
+		case "failed", "cancelled":
+			return fmt.Errorf("workflow %s is %s", uuid, status)
+		}
+		m.log().InfoContext(ctx, "waiting for online DDL", "uuid", uuid, "status", status)
+		if err := sleep(ctx, m.vitess.pollInterval()); err != nil {
+			return err
+		}
+	}
+}
+
+// onlineDDLStatus returns the migration_status of an online DDL workflow
+func onlineDDLStatus(ctx context.Context, conn *sql.Conn, uuid string) (string, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`SHOW VITESS_MIGRATIONS LIKE '%s'`, strings.ReplaceAll(uuid, "'", "''")))
+	if err != nil {
+		return "", fmt.Errorf("failed to query workflow %s: %w", uuid, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	index := -1
+	for i, column := range columns {
+		if column == "migration_status" {
+			index = i
+		}
+	}
+	if index < 0 {
+		return "", fmt.Errorf("unexpected result of SHOW VITESS_MIGRATIONS")
+	}
+	if !rows.Next() {
+		return "", fmt.Errorf("workflow %s not found", uuid)
+	}
+	values := make([]sql.RawBytes, len(columns))
+	targets := make([]any, len(columns))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return "", err
+	}
+	return string(values[index]), nil
+}
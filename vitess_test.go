@@ -0,0 +1,28 @@
+package migrago
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkNoForeignKeys(t *testing.T) {
+	assert.NoError(t, MigrationService{}.checkNoForeignKeys(Migration{Id: "Test", Script: "CREATE TABLE users (id BIGINT PRIMARY KEY, team_id BIGINT)"}))
+	assert.ErrorContains(t, MigrationService{}.checkNoForeignKeys(Migration{Id: "Test", Script: "ALTER TABLE users ADD CONSTRAINT fk FOREIGN KEY (team_id) REFERENCES teams (id)"}), "not supported by Vitess")
+	assert.ErrorContains(t, MigrationService{}.checkNoForeignKeys(Migration{Id: "Test", Script: "CREATE TABLE users (team_id BIGINT references teams)"}), "not supported by Vitess")
+	// Comments are ignored
+	assert.NoError(t, MigrationService{}.checkNoForeignKeys(Migration{Id: "Test", Script: "-- no foreign key to teams\nCREATE TABLE users (id BIGINT)"}))
+}
+
+func Test_isDDL(t *testing.T) {
+	assert.True(t, isDDL("ALTER TABLE users ADD COLUMN age INT"))
+	assert.True(t, isDDL("-- comment\ncreate table users (id BIGINT)"))
+	assert.False(t, isDDL("INSERT INTO users (id) VALUES (1)"))
+}
+
+func Test_VitessOnlineDDLDefaults(t *testing.T) {
+	assert.Equal(t, "vitess", VitessOnlineDDL{}.strategy())
+	assert.Equal(t, 5*time.Second, VitessOnlineDDL{}.pollInterval())
+	assert.Equal(t, "vitess --postpone-completion", VitessOnlineDDL{Strategy: "vitess --postpone-completion"}.strategy())
+}
@@ -0,0 +1,70 @@
+package migrago
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// MaintenanceWindow decides if heavy migrations may start at a given time,
+// implement it to use e.g. cron expressions
+type MaintenanceWindow interface {
+	Contains(t time.Time) bool
+}
+
+// dailyWindow is a time of day range, optionally limited to some weekdays
+type dailyWindow struct {
+	start, end time.Duration
+	location   *time.Location
+	weekdays   []time.Weekday
+}
+
+// NewDailyWindow creates a MaintenanceWindow from "HH:MM" times in the location, e.g. "03:00" to "05:00".
+// Windows ending before they start span midnight. If weekdays are given, the window only opens on these days.
+func NewDailyWindow(start, end string, location *time.Location, weekdays ...time.Weekday) (MaintenanceWindow, error) {
+	startOffset, err := parseTimeOfDay(start)
+	if err != nil {
+		return nil, err
+	}
+	endOffset, err := parseTimeOfDay(end)
+	if err != nil {
+		return nil, err
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return dailyWindow{start: startOffset, end: endOffset, location: location, weekdays: weekdays}, nil
+}
+
+// parseTimeOfDay parses "HH:MM" into the offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains checks if t is inside the window, the weekday of a window spanning midnight is the day it opened
+func (w dailyWindow) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+	if w.start <= w.end {
+		return offset >= w.start && offset < w.end && w.onDay(day)
+	}
+	if offset >= w.start {
+		return w.onDay(day)
+	}
+	return offset < w.end && w.onDay((day+6)%7)
+}
+
+// onDay checks if the window opens on the weekday
+func (w dailyWindow) onDay(day time.Weekday) bool {
+	return len(w.weekdays) == 0 || slices.Contains(w.weekdays, day)
+}
+
+// outsideWindow checks if a heavy migration has to be deferred to the maintenance window
+func (m MigrationService) outsideWindow(migration Migration) bool {
+	return migration.Metadata.Heavy && m.window != nil && !m.window.Contains(time.Now())
+}
@@ -0,0 +1,43 @@
+package migrago
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_dailyWindow(t *testing.T) {
+	window, err := NewDailyWindow("03:00", "05:00", time.UTC)
+	assert.NoError(t, err)
+	assert.True(t, window.Contains(time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC)))
+	assert.True(t, window.Contains(time.Date(2024, 1, 1, 4, 59, 59, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)))
+	// The location of the checked time does not matter
+	assert.True(t, window.Contains(time.Date(2024, 1, 1, 5, 30, 0, 0, time.FixedZone("CET", 3600))))
+
+	// Windows spanning midnight belong to the day they open, 2024-01-06 is a Saturday
+	window, err = NewDailyWindow("22:00", "02:00", time.UTC, time.Saturday)
+	assert.NoError(t, err)
+	assert.True(t, window.Contains(time.Date(2024, 1, 6, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, window.Contains(time.Date(2024, 1, 7, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2024, 1, 7, 23, 0, 0, 0, time.UTC)))
+	assert.False(t, window.Contains(time.Date(2024, 1, 6, 1, 0, 0, 0, time.UTC)))
+
+	_, err = NewDailyWindow("3am", "05:00", time.UTC)
+	assert.ErrorContains(t, err, "expected HH:MM")
+}
+
+func Test_outsideWindow(t *testing.T) {
+	closed := NewMigrationService("config.json", "scripts", nil, nil, WithMaintenanceWindow(windowFunc(func(time.Time) bool { return false })))
+	assert.True(t, closed.outsideWindow(Migration{Metadata: Metadata{Heavy: true}}))
+	assert.False(t, closed.outsideWindow(Migration{}))
+	assert.False(t, NewMigrationService("config.json", "scripts", nil, nil).outsideWindow(Migration{Metadata: Metadata{Heavy: true}}))
+}
+
+// windowFunc adapts a function to a MaintenanceWindow
+type windowFunc func(time.Time) bool
+
+func (f windowFunc) Contains(t time.Time) bool {
+	return f(t)
+}